@@ -0,0 +1,64 @@
+// Package analyzer detects noteworthy events in decoded flight logs.
+// Analyzers are fed frame by frame, in the order they come out of a
+// FlightLogReader, and report their findings once the log has been read.
+package analyzer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/pkg/errors"
+)
+
+// mainFrameValues returns the values of a main frame that could be decoded
+// and belongs to a valid stream
+func mainFrameValues(frame blackbox.Frame) ([]int64, bool) {
+	mainFrame, ok := frame.(*blackbox.MainFrame)
+	if !ok || frame.Error() != nil || !frame.Validity() {
+		return nil, false
+	}
+	return mainFrame.Values().([]int64), true
+}
+
+// mainFrameTime returns the time of a main frame, given the position of its time field
+func mainFrameTime(values []int64, timeIdx int) time.Duration {
+	return time.Duration(values[timeIdx]) * time.Microsecond
+}
+
+// timeFieldIndex returns the position of the time field of main frames
+func timeFieldIndex(frameDef blackbox.LogDefinition) (int, error) {
+	index, err := frameDef.GetFieldIndex(blackbox.FieldTime)
+	if err != nil {
+		return 0, errors.New("The log doesn't contain the time field")
+	}
+	return index, nil
+}
+
+// arrayFieldIndexes returns the position of every element of an array field
+// like motor[0], motor[1], etc..
+func arrayFieldIndexes(frameDef blackbox.LogDefinition, name string) []int {
+	indexes := []int{}
+	for i := 0; ; i++ {
+		index, err := frameDef.GetFieldIndex(blackbox.FieldName(fmt.Sprintf("%s[%d]", name, i)))
+		if err != nil {
+			return indexes
+		}
+		indexes = append(indexes, index)
+	}
+}
+
+// optionalFieldIndex returns the position of a field or -1 if the log doesn't
+// have it
+func optionalFieldIndex(frameDef blackbox.LogDefinition, name blackbox.FieldName) int {
+	index, err := frameDef.GetFieldIndex(name)
+	if err != nil {
+		return -1
+	}
+	return index
+}
+
+// formatTime prints a time the same way the log statistics do
+func formatTime(d time.Duration) string {
+	return time.Time{}.Add(d).Format("04:05.000")
+}
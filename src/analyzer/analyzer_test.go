@@ -0,0 +1,32 @@
+package analyzer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/blackbox/blackboxtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArrayFieldIndexes(t *testing.T) {
+	frameDef := blackboxtest.LogDefinition("loopIteration", "time", "motor[0]", "motor[1]", "debug[0]", "motor[2]")
+
+	assert.Equal(t, []int{2, 3, 5}, arrayFieldIndexes(frameDef, "motor"))
+	assert.Equal(t, []int{4}, arrayFieldIndexes(frameDef, "debug"))
+	assert.Equal(t, []int{}, arrayFieldIndexes(frameDef, "eRPM"))
+}
+
+func TestMainFrameTime(t *testing.T) {
+	timeIdx, err := timeFieldIndex(blackboxtest.LogDefinition("time", "loopIteration", "motor[0]"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1500*time.Microsecond, mainFrameTime([]int64{1500, 3, 1000}, timeIdx))
+
+	_, err = timeFieldIndex(blackboxtest.LogDefinition("loopIteration", "motor[0]"))
+	assert.EqualError(t, err, "The log doesn't contain the time field")
+}
+
+// testFrame returns a valid main frame. The time is given in milliseconds.
+func testFrame(iteration int64, timeMs int64, values ...int64) blackbox.Frame {
+	return blackbox.MarkValid(blackbox.NewMainFrame(blackbox.LogFrameInter, append([]int64{iteration, timeMs * 1000}, values...), 0, 0, nil))
+}
@@ -0,0 +1,292 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"text/tabwriter"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/pkg/errors"
+)
+
+const (
+	// sagWindow is how far back the voltage is looked at to measure a sag
+	sagWindow = time.Second
+
+	// minRegressionCurrentSpread is the minimum current range (in A) a log has to
+	// cover before its internal resistance can be estimated
+	minRegressionCurrentSpread = 5.0
+
+	// Plausible internal resistance of a single LiPo cell, in ohms. An estimate
+	// outside of this range is more likely due to a badly scaled current sensor.
+	minCellResistance = 0.002
+	maxCellResistance = 0.1
+
+	// maxNegativeCurrent is the lowest current (in A) a correctly calibrated sensor
+	// should report
+	maxNegativeCurrent = -0.5
+)
+
+// BatteryReport summarizes the state of the battery during a flight
+type BatteryReport struct {
+	CellCount    int
+	StartVoltage float64
+	EndVoltage   float64
+	MinVoltage   float64
+
+	// MaxSag is the biggest voltage drop measured within a second, and when it happened
+	MaxSag     float64
+	MaxSagTime time.Duration
+
+	// TimeBelowWarning is how long the average cell voltage stayed below the
+	// warning cell voltage from the headers
+	TimeBelowWarning time.Duration
+
+	// HasCurrentSensor is false when the log doesn't contain any amperage field,
+	// in which case none of the fields below are set
+	HasCurrentSensor      bool
+	ConsumedMilliampHours float64
+	AverageCurrent        float64
+	PeakCurrent           float64
+
+	// InternalResistance is the pack internal resistance in ohms, estimated by
+	// regressing the voltage against the current. It's 0 if the log doesn't
+	// have enough current variation.
+	InternalResistance float64
+
+	// CurrentSensorWarnings lists the reasons to believe the current sensor
+	// scale or offset is miscalibrated
+	CurrentSensorWarnings []string
+}
+
+// CellResistance returns the estimated internal resistance of a single cell in ohms
+func (r BatteryReport) CellResistance() float64 {
+	if r.CellCount == 0 {
+		return 0
+	}
+	return r.InternalResistance / float64(r.CellCount)
+}
+
+func (r BatteryReport) String() string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Cells:\t %dS\n", r.CellCount)
+	_, _ = fmt.Fprintf(w, "Voltage:\t %.2fV start, %.2fV end, %.2fV min\n", r.StartVoltage, r.EndVoltage, r.MinVoltage)
+	_, _ = fmt.Fprintf(w, "Max sag:\t %.2fV at %s\n", r.MaxSag, formatTime(r.MaxSagTime))
+	_, _ = fmt.Fprintf(w, "Below warning:\t %s\n", r.TimeBelowWarning)
+	if r.HasCurrentSensor {
+		_, _ = fmt.Fprintf(w, "Consumed:\t %.0f mAh\n", r.ConsumedMilliampHours)
+		_, _ = fmt.Fprintf(w, "Current:\t %.1fA avg, %.1fA peak\n", r.AverageCurrent, r.PeakCurrent)
+		if r.InternalResistance > 0 {
+			_, _ = fmt.Fprintf(w, "Internal resistance:\t %.1f mOhm (%.1f mOhm per cell)\n", r.InternalResistance*1000, r.CellResistance()*1000)
+		}
+		for _, warning := range r.CurrentSensorWarnings {
+			_, _ = fmt.Fprintf(w, "Warning:\t %s\n", warning)
+		}
+	}
+	_ = w.Flush()
+	return buf.String()
+}
+
+type voltageSample struct {
+	time    time.Duration
+	voltage float64
+}
+
+// BatteryAnalyzer measures the voltage and current drawn from the battery
+type BatteryAnalyzer struct {
+	frameDef    blackbox.LogDefinition
+	timeIdx     int
+	vbatIdx     int
+	amperageIdx int
+	report      BatteryReport
+	frameCount  int
+	lastTime    time.Duration
+	duration    time.Duration
+	recentMax   []voltageSample
+	regression  linearRegression
+	currentSum  float64
+	minCurrent  float64
+}
+
+// NewBatteryAnalyzer returns a new BatteryAnalyzer for logs with the given definition
+func NewBatteryAnalyzer(frameDef blackbox.LogDefinition) (*BatteryAnalyzer, error) {
+	timeIdx, err := timeFieldIndex(frameDef)
+	if err != nil {
+		return nil, err
+	}
+
+	vbatIdx, err := frameDef.GetFieldIndex(blackbox.FieldVbatLatest)
+	if err != nil {
+		return nil, errors.New("The log doesn't contain any battery voltage field")
+	}
+
+	amperageIdx := optionalFieldIndex(frameDef, blackbox.FieldAmperageLatest)
+	return &BatteryAnalyzer{
+		frameDef:    frameDef,
+		timeIdx:     timeIdx,
+		vbatIdx:     vbatIdx,
+		amperageIdx: amperageIdx,
+		report: BatteryReport{
+			CellCount:        estimateCellCount(frameDef),
+			HasCurrentSensor: amperageIdx >= 0,
+			MinVoltage:       math.Inf(1),
+		},
+	}, nil
+}
+
+// estimateCellCount guesses the number of cells of the battery from the
+// voltage measured when the craft was armed, the same way the firmware does
+func estimateCellCount(frameDef blackbox.LogDefinition) int {
+	maxCellVoltage := blackbox.CellVoltageToVolts(frameDef.Sysconfig.Vbatmaxcellvoltage)
+	if maxCellVoltage <= 0 {
+		return 0
+	}
+	return int(frameDef.VbatToVolts(int64(frameDef.Sysconfig.Vbatref))/maxCellVoltage) + 1
+}
+
+// AddFrame analyzes the next frame of the log
+func (a *BatteryAnalyzer) AddFrame(frame blackbox.Frame) {
+	values, ok := mainFrameValues(frame)
+	if !ok {
+		return
+	}
+
+	t := mainFrameTime(values, a.timeIdx)
+	dt := time.Duration(0)
+	if a.frameCount > 0 && t > a.lastTime {
+		dt = t - a.lastTime
+	}
+	a.frameCount++
+	a.lastTime = t
+	a.duration += dt
+
+	voltage := a.frameDef.VbatToVolts(values[a.vbatIdx])
+	a.addVoltage(t, dt, voltage)
+
+	if a.amperageIdx >= 0 {
+		a.addCurrent(t, dt, voltage, a.frameDef.AmperageToAmps(values[a.amperageIdx]))
+	}
+}
+
+func (a *BatteryAnalyzer) addVoltage(t, dt time.Duration, voltage float64) {
+	if a.frameCount == 1 {
+		a.report.StartVoltage = voltage
+	}
+	a.report.EndVoltage = voltage
+	if voltage < a.report.MinVoltage {
+		a.report.MinVoltage = voltage
+	}
+
+	warningCellVoltage := blackbox.CellVoltageToVolts(a.frameDef.Sysconfig.Vbatwarningcellvoltage)
+	if a.report.CellCount > 0 && voltage/float64(a.report.CellCount) < warningCellVoltage {
+		a.report.TimeBelowWarning += dt
+	}
+
+	// Keep a decreasing list of the voltages seen recently, its first element
+	// being the highest voltage of the window
+	for len(a.recentMax) > 0 && t-a.recentMax[0].time > sagWindow {
+		a.recentMax = a.recentMax[1:]
+	}
+	for len(a.recentMax) > 0 && a.recentMax[len(a.recentMax)-1].voltage <= voltage {
+		a.recentMax = a.recentMax[:len(a.recentMax)-1]
+	}
+	a.recentMax = append(a.recentMax, voltageSample{time: t, voltage: voltage})
+
+	if sag := a.recentMax[0].voltage - voltage; sag > a.report.MaxSag {
+		a.report.MaxSag = sag
+		a.report.MaxSagTime = t
+	}
+}
+
+func (a *BatteryAnalyzer) addCurrent(t, dt time.Duration, voltage, current float64) {
+	a.report.ConsumedMilliampHours += current * dt.Hours() * 1000
+	a.currentSum += current * dt.Seconds()
+	if a.frameCount == 1 || current > a.report.PeakCurrent {
+		a.report.PeakCurrent = current
+	}
+	if a.frameCount == 1 || current < a.minCurrent {
+		a.minCurrent = current
+	}
+
+	// The time is part of the regression to compensate for the battery slowly
+	// discharging, which would otherwise be accounted as internal resistance
+	a.regression.add(voltage, current, t.Seconds())
+}
+
+// Report returns the battery report for the frames analyzed so far
+func (a *BatteryAnalyzer) Report() BatteryReport {
+	report := a.report
+	if a.frameCount == 0 {
+		report.MinVoltage = 0
+		return report
+	}
+	if !report.HasCurrentSensor {
+		return report
+	}
+
+	if a.duration > 0 {
+		report.AverageCurrent = a.currentSum / a.duration.Seconds()
+	}
+
+	report.CurrentSensorWarnings = []string{}
+	if a.minCurrent < maxNegativeCurrent {
+		report.CurrentSensorWarnings = append(report.CurrentSensorWarnings, fmt.Sprintf("negative current readings down to %.1fA, the sensor offset looks wrong", a.minCurrent))
+	}
+
+	slope, ok := a.regression.slope()
+	if ok && report.PeakCurrent-a.minCurrent >= minRegressionCurrentSpread {
+		report.InternalResistance = -slope
+
+		cellResistance := report.CellResistance()
+		switch {
+		case report.CellCount == 0:
+		case cellResistance < minCellResistance:
+			report.CurrentSensorWarnings = append(report.CurrentSensorWarnings, fmt.Sprintf("the voltage barely reacts to the current (%.1f mOhm per cell), the current sensor probably over-reads", cellResistance*1000))
+		case cellResistance > maxCellResistance:
+			report.CurrentSensorWarnings = append(report.CurrentSensorWarnings, fmt.Sprintf("the voltage reacts too much to the current (%.1f mOhm per cell), the current sensor probably under-reads", cellResistance*1000))
+		}
+	}
+	return report
+}
+
+// linearRegression fits y = a + b*x + c*z with the least squares method
+type linearRegression struct {
+	n                       float64
+	sx, sz, sy              float64
+	sxx, szz, sxz, sxy, szy float64
+}
+
+func (r *linearRegression) add(y, x, z float64) {
+	r.n++
+	r.sx += x
+	r.sz += z
+	r.sy += y
+	r.sxx += x * x
+	r.szz += z * z
+	r.sxz += x * z
+	r.sxy += x * y
+	r.szy += z * y
+}
+
+// slope returns b, the coefficient of x
+func (r *linearRegression) slope() (float64, bool) {
+	if r.n < 3 {
+		return 0, false
+	}
+
+	// Work on centered values to solve the 2x2 normal equations
+	cxx := r.sxx - r.sx*r.sx/r.n
+	czz := r.szz - r.sz*r.sz/r.n
+	cxz := r.sxz - r.sx*r.sz/r.n
+	cxy := r.sxy - r.sx*r.sy/r.n
+	czy := r.szy - r.sz*r.sy/r.n
+
+	det := cxx*czz - cxz*cxz
+	if math.Abs(det) < 1e-12 {
+		return 0, false
+	}
+	return (cxy*czz - czy*cxz) / det, true
+}
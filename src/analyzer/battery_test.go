@@ -0,0 +1,135 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/blackbox/blackboxtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatteryAnalyzerWithoutVoltage(t *testing.T) {
+	_, err := NewBatteryAnalyzer(blackboxtest.LogDefinition("loopIteration", "time"))
+	assert.EqualError(t, err, "The log doesn't contain any battery voltage field")
+}
+
+func TestBatteryAnalyzerCellCount(t *testing.T) {
+	for cells, volts := range map[int]float64{1: 4.2, 3: 12.6, 4: 16.6, 6: 25.1} {
+		frameDef := testBatteryLogDefinition()
+		frameDef.Sysconfig.Vbatref = uint16(rawVbat(frameDef, volts))
+
+		analyzer, err := NewBatteryAnalyzer(frameDef)
+		assert.NoError(t, err)
+		assert.Equal(t, cells, analyzer.Report().CellCount, "for %.1fV", volts)
+	}
+}
+
+func TestBatteryAnalyzerReport(t *testing.T) {
+	frameDef := testBatteryLogDefinition()
+	analyzer, err := NewBatteryAnalyzer(frameDef)
+	assert.NoError(t, err)
+
+	feedBatteryFrames(analyzer, frameDef, 0.02, 1)
+
+	report := analyzer.Report()
+	assert.Equal(t, 4, report.CellCount)
+	assert.True(t, report.HasCurrentSensor)
+	assert.InDelta(t, 16.3, report.StartVoltage, 0.01)
+	assert.InDelta(t, 13.3, report.EndVoltage, 0.01)
+	assert.InDelta(t, 12.6, report.MinVoltage, 0.01)
+	assert.InDelta(t, 0.85, report.MaxSag, 0.01)
+	assert.InDelta(t, 40, report.PeakCurrent, 1)
+	assert.InDelta(t, 22.5, report.AverageCurrent, 1)
+	assert.InDelta(t, 22.5*20/3.6, report.ConsumedMilliampHours, 5)
+	assert.InDelta(t, 0.02, report.InternalResistance, 0.003)
+	assert.Equal(t, 7*time.Second, report.TimeBelowWarning.Round(time.Second))
+	assert.Empty(t, report.CurrentSensorWarnings)
+}
+
+func TestBatteryAnalyzerOverReadingCurrentSensor(t *testing.T) {
+	frameDef := testBatteryLogDefinition()
+	analyzer, err := NewBatteryAnalyzer(frameDef)
+	assert.NoError(t, err)
+
+	feedBatteryFrames(analyzer, frameDef, 0.02, 10)
+
+	report := analyzer.Report()
+	assert.InDelta(t, 0.002, report.InternalResistance, 0.0005)
+	assert.Len(t, report.CurrentSensorWarnings, 1)
+	assert.Contains(t, report.CurrentSensorWarnings[0], "over-reads")
+}
+
+func TestBatteryAnalyzerNegativeCurrent(t *testing.T) {
+	frameDef := testBatteryLogDefinition()
+	frameDef.Sysconfig.CurrentMeterOffset = 10
+	analyzer, err := NewBatteryAnalyzer(frameDef)
+	assert.NoError(t, err)
+
+	for i := int64(0); i < 100; i++ {
+		analyzer.AddFrame(testFrame(i, i*10, rawVbat(frameDef, 16), 0))
+	}
+
+	report := analyzer.Report()
+	assert.Len(t, report.CurrentSensorWarnings, 1)
+	assert.Contains(t, report.CurrentSensorWarnings[0], "negative current readings down to -10.0A")
+}
+
+func TestBatteryAnalyzerWithoutCurrentSensor(t *testing.T) {
+	frameDef := blackboxtest.LogDefinition("loopIteration", "time", "vbatLatest")
+	frameDef.Sysconfig = testBatteryLogDefinition().Sysconfig
+
+	analyzer, err := NewBatteryAnalyzer(frameDef)
+	assert.NoError(t, err)
+	for i := int64(0); i < 10; i++ {
+		analyzer.AddFrame(testFrame(i, i*10, rawVbat(frameDef, 16)))
+	}
+
+	report := analyzer.Report()
+	assert.False(t, report.HasCurrentSensor)
+	assert.InDelta(t, 16, report.MinVoltage, 0.01)
+	assert.Zero(t, report.ConsumedMilliampHours)
+}
+
+// feedBatteryFrames simulates a 20s flight alternating between 5A and 40A
+// every second, on a battery loosing 0.15V per second
+func feedBatteryFrames(analyzer *BatteryAnalyzer, frameDef blackbox.LogDefinition, resistance float64, currentSensorError float64) {
+	for i := int64(0); i <= 2000; i++ {
+		current := 5.0
+		if (i/100)%2 == 1 {
+			current = 40.0
+		}
+		voltage := 16.4 - resistance*current - 0.0015*float64(i)
+		analyzer.AddFrame(testFrame(i, i*10, rawVbat(frameDef, voltage), rawAmperage(frameDef, current*currentSensorError)))
+	}
+}
+
+func testBatteryLogDefinition() blackbox.LogDefinition {
+	frameDef := blackboxtest.LogDefinition("loopIteration", "time", "vbatLatest", "amperageLatest")
+	frameDef.Sysconfig.Vbatscale = 110
+	frameDef.Sysconfig.Vbatmincellvoltage = 330
+	frameDef.Sysconfig.Vbatwarningcellvoltage = 350
+	frameDef.Sysconfig.Vbatmaxcellvoltage = 430
+	frameDef.Sysconfig.CurrentMeterScale = 10
+	frameDef.Sysconfig.Vbatref = uint16(rawVbat(frameDef, 16.5))
+	return frameDef
+}
+
+func rawVbat(frameDef blackbox.LogDefinition, volts float64) int64 {
+	return closestRawValue(frameDef.VbatToVolts, volts)
+}
+
+func rawAmperage(frameDef blackbox.LogDefinition, amps float64) int64 {
+	return closestRawValue(frameDef.AmperageToAmps, amps)
+}
+
+func closestRawValue(convert func(int64) float64, target float64) int64 {
+	best := int64(0)
+	for raw := int64(0); raw < 4096; raw++ {
+		if math.Abs(convert(raw)-target) < math.Abs(convert(best)-target) {
+			best = raw
+		}
+	}
+	return best
+}
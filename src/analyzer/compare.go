@@ -0,0 +1,240 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/dsp"
+)
+
+const (
+	// significantFieldChange is how much the mean or standard deviation of a field has
+	// to change, relatively, to be reported in the text of a comparison
+	significantFieldChange = 0.1
+)
+
+// Categories of settings
+const (
+	SettingPID    = "pid"
+	SettingFilter = "filter"
+	SettingRate   = "rate"
+	SettingOther  = "other"
+)
+
+// settingCategories recognizes the category of a setting by the name of its header, in
+// this order
+var settingCategories = []struct {
+	category string
+	re       *regexp.Regexp
+}{
+	{SettingPID, regexp.MustCompile(`(?i)(PID$|^d_min|^feedforward|^ff_|^iterm|^anti_gravity|^tpa)`)},
+	{SettingFilter, regexp.MustCompile(`(?i)(lpf|lowpass|notch|filter|^dyn_|smoothing)`)},
+	{SettingRate, regexp.MustCompile(`(?i)(^rates|rc_rates|rc_expo|^rate_|thr_mid|thr_expo)`)},
+}
+
+// SettingChange is a header whose value differs between two logs. The value is empty
+// on the side the header is missing from.
+type SettingChange struct {
+	Category string              `json:"category"`
+	Name     blackbox.HeaderName `json:"name"`
+	Before   string              `json:"before"`
+	After    string              `json:"after"`
+}
+
+// FieldChange compares the statistics of a field present in two logs
+type FieldChange struct {
+	Name   blackbox.FieldName `json:"name"`
+	Before FieldStatistics    `json:"before"`
+	After  FieldStatistics    `json:"after"`
+}
+
+// NoiseChange compares the gyro noise of two logs on one axis
+type NoiseChange struct {
+	Axis   string    `json:"axis"`
+	Before AxisNoise `json:"before"`
+	After  AxisNoise `json:"after"`
+}
+
+// StepResponseChange compares the step response of two logs on one axis
+type StepResponseChange struct {
+	Axis   string          `json:"axis"`
+	Before dsp.StepMetrics `json:"before"`
+	After  dsp.StepMetrics `json:"after"`
+}
+
+// Comparison tells how the settings of a flight changed from another one, and what
+// effect it had
+type Comparison struct {
+	BeforeDuration time.Duration `json:"beforeDuration"`
+	AfterDuration  time.Duration `json:"afterDuration"`
+
+	Settings      []SettingChange      `json:"settings"`
+	Fields        []FieldChange        `json:"fields"`
+	Noise         []NoiseChange        `json:"noise"`
+	StepResponses []StepResponseChange `json:"stepResponses"`
+}
+
+// Compare compares two flights. Fields and axes are only compared when both flights
+// have them.
+func Compare(before, after FlightProfile) Comparison {
+	comparison := Comparison{
+		BeforeDuration: before.Duration,
+		AfterDuration:  after.Duration,
+		Settings:       compareSettings(before.Headers, after.Headers),
+		Fields:         []FieldChange{},
+		Noise:          []NoiseChange{},
+		StepResponses:  []StepResponseChange{},
+	}
+
+	for _, b := range before.Fields {
+		for _, a := range after.Fields {
+			if a.Name == b.Name {
+				comparison.Fields = append(comparison.Fields, FieldChange{Name: b.Name, Before: b, After: a})
+			}
+		}
+	}
+	for _, b := range before.Noise {
+		for _, a := range after.Noise {
+			if a.Axis == b.Axis {
+				comparison.Noise = append(comparison.Noise, NoiseChange{Axis: b.Axis, Before: b, After: a})
+			}
+		}
+	}
+	for _, b := range before.StepResponses {
+		for _, a := range after.StepResponses {
+			if a.Axis == b.Axis {
+				comparison.StepResponses = append(comparison.StepResponses, StepResponseChange{Axis: b.Axis, Before: b.Metrics, After: a.Metrics})
+			}
+		}
+	}
+	return comparison
+}
+
+// compareSettings returns the headers that differ, by category and name. Headers
+// describing the log rather than the craft, like its fields, are ignored.
+func compareSettings(before, after []blackbox.Header) []SettingChange {
+	values := map[blackbox.HeaderName][2]string{}
+	for _, header := range before {
+		v := values[header.Name]
+		v[0] = header.Value
+		values[header.Name] = v
+	}
+	for _, header := range after {
+		v := values[header.Name]
+		v[1] = header.Value
+		values[header.Name] = v
+	}
+
+	changes := []SettingChange{}
+	for name, v := range values {
+		if v[0] == v[1] || strings.HasPrefix(string(name), "Field ") || name == blackbox.HeaderLogStartDatetime {
+			continue
+		}
+		changes = append(changes, SettingChange{Category: settingCategory(name), Name: name, Before: v[0], After: v[1]})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Category != changes[j].Category {
+			return categoryOrder(changes[i].Category) < categoryOrder(changes[j].Category)
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+func settingCategory(name blackbox.HeaderName) string {
+	for _, c := range settingCategories {
+		if c.re.MatchString(string(name)) {
+			return c.category
+		}
+	}
+	return SettingOther
+}
+
+func categoryOrder(category string) int {
+	for i, c := range settingCategories {
+		if c.category == category {
+			return i
+		}
+	}
+	return len(settingCategories)
+}
+
+func (c Comparison) String() string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Duration:\t %s -> %s\n", c.BeforeDuration, c.AfterDuration)
+
+	_, _ = fmt.Fprintf(w, "\nSettings: %d changed\n", len(c.Settings))
+	for _, s := range c.Settings {
+		_, _ = fmt.Fprintf(w, "  %s\t %s\t %s -> %s\n", s.Category, s.Name, orNone(s.Before), orNone(s.After))
+	}
+
+	if len(c.StepResponses) > 0 {
+		_, _ = fmt.Fprintf(w, "\nStep response:\n")
+		for _, s := range c.StepResponses {
+			_, _ = fmt.Fprintf(w, "  %s\t rise %s\t overshoot %s\t settling %s\n", s.Axis,
+				durationChange(s.Before.RiseTime, s.After.RiseTime),
+				valueChange(s.Before.Overshoot*100, s.After.Overshoot*100, "%.1f%%"),
+				durationChange(s.Before.SettlingTime, s.After.SettlingTime))
+		}
+	}
+
+	if len(c.Noise) > 0 {
+		_, _ = fmt.Fprintf(w, "\nGyro noise above %.0fHz:\n", noiseFrequency)
+		for _, n := range c.Noise {
+			_, _ = fmt.Fprintf(w, "  %s\t rms %s\t peak %s\n", n.Axis,
+				valueChange(n.Before.RMS, n.After.RMS, "%.2f"),
+				valueChange(n.Before.PeakFrequency, n.After.PeakFrequency, "%.0fHz"))
+		}
+	}
+
+	fields := []FieldChange{}
+	for _, f := range c.Fields {
+		if significantChange(f.Before.Mean, f.After.Mean) || significantChange(f.Before.StdDev, f.After.StdDev) {
+			fields = append(fields, f)
+		}
+	}
+	_, _ = fmt.Fprintf(w, "\nFields: %d of %d changed by more than %.0f%%\n", len(fields), len(c.Fields), significantFieldChange*100)
+	for _, f := range fields {
+		_, _ = fmt.Fprintf(w, "  %s\t mean %s\t stddev %s\n", f.Name,
+			valueChange(f.Before.Mean, f.After.Mean, "%.1f"),
+			valueChange(f.Before.StdDev, f.After.StdDev, "%.1f"))
+	}
+	_ = w.Flush()
+	return buf.String()
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+// valueChange formats the change of a value, with its relative change when it makes sense
+func valueChange(before, after float64, format string) string {
+	change := fmt.Sprintf(format+" -> "+format, before, after)
+	if before != 0 {
+		change += fmt.Sprintf(" (%+.0f%%)", (after-before)/math.Abs(before)*100)
+	}
+	return change
+}
+
+func durationChange(before, after time.Duration) string {
+	return valueChange(float64(before)/float64(time.Millisecond), float64(after)/float64(time.Millisecond), "%.1fms")
+}
+
+func significantChange(before, after float64) bool {
+	if before == 0 {
+		return after != 0
+	}
+	return math.Abs(after-before)/math.Abs(before) > significantFieldChange
+}
@@ -0,0 +1,99 @@
+package analyzer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/dsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareSettings(t *testing.T) {
+	before := []blackbox.Header{
+		{Name: "Field I name", Value: "loopIteration,time"},
+		{Name: "Log start datetime", Value: "2019-05-01T10:00:00.000+00:00"},
+		{Name: "rollPID", Value: "42,85,35"},
+		{Name: "gyro_lowpass_hz", Value: "200"},
+		{Name: "rc_rates", Value: "100,100,100"},
+		{Name: "dterm_notch_hz", Value: "260"},
+		{Name: "motor_pwm_rate", Value: "480"},
+	}
+	after := []blackbox.Header{
+		{Name: "Field I name", Value: "loopIteration,time,motor[0]"},
+		{Name: "Log start datetime", Value: "2019-05-01T10:30:00.000+00:00"},
+		{Name: "rollPID", Value: "46,85,38"},
+		{Name: "gyro_lowpass_hz", Value: "150"},
+		{Name: "rc_rates", Value: "100,100,100"},
+		{Name: "dyn_notch_range", Value: "1"},
+		{Name: "motor_pwm_rate", Value: "480"},
+	}
+
+	assert.Equal(t, []SettingChange{
+		{Category: SettingPID, Name: "rollPID", Before: "42,85,35", After: "46,85,38"},
+		{Category: SettingFilter, Name: "dterm_notch_hz", Before: "260", After: ""},
+		{Category: SettingFilter, Name: "dyn_notch_range", Before: "", After: "1"},
+		{Category: SettingFilter, Name: "gyro_lowpass_hz", Before: "200", After: "150"},
+	}, compareSettings(before, after))
+}
+
+func TestSettingCategory(t *testing.T) {
+	for name, category := range map[blackbox.HeaderName]string{
+		"yawPID":               SettingPID,
+		"d_min":                SettingPID,
+		"feedforward_weight":   SettingPID,
+		"anti_gravity_gain":    SettingPID,
+		"dterm_lpf1_static_hz": SettingFilter,
+		"gyro_notch_hz":        SettingFilter,
+		"rates":                SettingRate,
+		"rc_expo":              SettingRate,
+		"thr_mid":              SettingRate,
+		"motor_pwm_rate":       SettingOther,
+		"Craft name":           SettingOther,
+	} {
+		assert.Equal(t, category, settingCategory(name), string(name))
+	}
+}
+
+func TestCompare(t *testing.T) {
+	before := FlightProfile{
+		Duration: 30 * time.Second,
+		Headers:  []blackbox.Header{{Name: "gyro_lowpass_hz", Value: "200"}},
+		Fields: []FieldStatistics{
+			{Name: "gyroADC[0]", Mean: 1, StdDev: 100},
+			{Name: "motor[0]", Mean: 500, StdDev: 100},
+		},
+		Noise:         []AxisNoise{{Axis: "roll", RMS: 4, PeakFrequency: 250}},
+		StepResponses: []AxisStepResponse{{Axis: "roll", Metrics: dsp.StepMetrics{Steady: 1, RiseTime: 40 * time.Millisecond, Overshoot: 0.2}}},
+	}
+	after := FlightProfile{
+		Duration: 45 * time.Second,
+		Headers:  []blackbox.Header{{Name: "gyro_lowpass_hz", Value: "150"}},
+		Fields: []FieldStatistics{
+			{Name: "gyroADC[0]", Mean: 1, StdDev: 105},
+			{Name: "motor[0]", Mean: 600, StdDev: 100},
+			{Name: "motor[1]", Mean: 600, StdDev: 100},
+		},
+		Noise: []AxisNoise{
+			{Axis: "roll", RMS: 2, PeakFrequency: 250},
+			{Axis: "pitch", RMS: 2, PeakFrequency: 250},
+		},
+		StepResponses: []AxisStepResponse{{Axis: "roll", Metrics: dsp.StepMetrics{Steady: 1, RiseTime: 50 * time.Millisecond, Overshoot: 0.1}}},
+	}
+
+	comparison := Compare(before, after)
+	assert.Len(t, comparison.Settings, 1)
+	assert.Len(t, comparison.Fields, 2)
+	assert.Len(t, comparison.Noise, 1)
+	assert.Len(t, comparison.StepResponses, 1)
+
+	report := comparison.String()
+	assert.Contains(t, report, "Settings: 1 changed")
+	assert.Contains(t, report, "gyro_lowpass_hz   200 -> 150")
+	assert.Contains(t, report, "rise 40.0ms -> 50.0ms (+25%)")
+	assert.Contains(t, report, "overshoot 20.0% -> 10.0% (-50%)")
+	assert.Contains(t, report, "rms 4.00 -> 2.00 (-50%)")
+	assert.Contains(t, report, "Fields: 1 of 2 changed by more than 10%")
+	assert.Contains(t, report, "motor[0]")
+	assert.NotContains(t, report, "gyroADC[0]")
+}
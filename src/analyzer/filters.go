@@ -0,0 +1,372 @@
+package analyzer
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/dsp"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxFilterDelay is the longest delay looked for between the unfiltered and the
+	// filtered gyro
+	maxFilterDelay = 20 * time.Millisecond
+
+	// defaultMotorPoles is the number of magnetic poles of most motors, used when the
+	// log doesn't tell
+	defaultMotorPoles = 14
+
+	// defaultDynamicNotchMinHz is the lowest frequency dynamic notches are placed at
+	// when the log doesn't tell
+	defaultDynamicNotchMinHz = 100
+
+	// featureDynamicFilter is the bit of the features header enabling the dynamic notch
+	featureDynamicFilter = 1 << 29
+)
+
+// GyroFilterSettingsFromHeaders reads the settings of the gyro filters from the headers
+// of a log written by Betaflight. The values of overrides, indexed by header name,
+// replace the ones of the log so alternative settings can be tried.
+func GyroFilterSettingsFromHeaders(frameDef blackbox.LogDefinition, overrides map[blackbox.HeaderName]string) (dsp.GyroFilterSettings, error) {
+	h := filterHeaders{}
+	for _, header := range frameDef.Headers {
+		h[header.Name] = header.Value
+	}
+	for name, value := range overrides {
+		h[name] = value
+	}
+
+	settings := dsp.GyroFilterSettings{}
+	var err error
+	settings.Lowpass, err = h.lowpass([]blackbox.HeaderName{"gyro_lpf1_type", "gyro_lowpass_type"}, []blackbox.HeaderName{"gyro_lpf1_static_hz", "gyro_lowpass_hz"}, []blackbox.HeaderName{"gyro_lpf1_dyn_hz", "gyro_lowpass_dyn_hz"})
+	if err != nil {
+		return settings, err
+	}
+	settings.Lowpass2, err = h.lowpass([]blackbox.HeaderName{"gyro_lpf2_type", "gyro_lowpass2_type"}, []blackbox.HeaderName{"gyro_lpf2_static_hz", "gyro_lowpass2_hz"}, nil)
+	if err != nil {
+		return settings, err
+	}
+
+	centers, err := h.values("gyro_notch_hz")
+	if err != nil {
+		return settings, err
+	}
+	cutoffs, err := h.values("gyro_notch_cutoff")
+	if err != nil {
+		return settings, err
+	}
+	notches := []*dsp.NotchSettings{&settings.Notch1, &settings.Notch2}
+	for i := 0; i < len(notches) && i < len(centers) && i < len(cutoffs); i++ {
+		*notches[i] = dsp.NotchSettings{Center: centers[i], Cutoff: cutoffs[i]}
+	}
+
+	settings.DynamicNotch, err = h.dynamicNotch()
+	if err != nil {
+		return settings, err
+	}
+
+	rpm := []float64{0, 0, 0}
+	for i, name := range []blackbox.HeaderName{"rpm_filter_harmonics", "rpm_filter_q", "rpm_filter_min_hz"} {
+		if rpm[i], err = h.value(name, 0); err != nil {
+			return settings, err
+		}
+	}
+	settings.RPMFilter = dsp.RPMFilterSettings{Harmonics: int(rpm[0]), Q: rpm[1] / 100, MinHz: rpm[2]}
+	return settings, nil
+}
+
+// filterHeaders are the values of the headers of a log, indexed by name
+type filterHeaders map[blackbox.HeaderName]string
+
+// values returns the numbers of a header, or nothing if the log doesn't have it
+func (h filterHeaders) values(name blackbox.HeaderName) ([]float64, error) {
+	value, ok := h[name]
+	if !ok {
+		return nil, nil
+	}
+	values := []float64{}
+	for _, part := range strings.Split(value, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid value for header '%s'", name)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// value returns the first number of a header, or a default value if the log doesn't
+// have it
+func (h filterHeaders) value(name blackbox.HeaderName, defaultValue float64) (float64, error) {
+	values, err := h.values(name)
+	if err != nil || len(values) == 0 {
+		return defaultValue, err
+	}
+	return values[0], nil
+}
+
+// first returns the name of the first header the log has, as their name changed
+// between versions
+func (h filterHeaders) first(names []blackbox.HeaderName) blackbox.HeaderName {
+	for _, name := range names {
+		if _, ok := h[name]; ok {
+			return name
+		}
+	}
+	return ""
+}
+
+func (h filterHeaders) lowpass(typeNames, cutoffNames, dynamicNames []blackbox.HeaderName) (dsp.LowpassSettings, error) {
+	settings := dsp.LowpassSettings{}
+	lowpassType, err := h.value(h.first(typeNames), 0)
+	if err != nil {
+		return settings, err
+	}
+	settings.Type = dsp.LowpassType(lowpassType)
+	if settings.Cutoff, err = h.value(h.first(cutoffNames), 0); err != nil {
+		return settings, err
+	}
+
+	dynamic, err := h.values(h.first(dynamicNames))
+	if err != nil {
+		return settings, err
+	}
+	if len(dynamic) == 2 && dynamic[0] > 0 {
+		settings.DynamicMin, settings.DynamicMax = dynamic[0], dynamic[1]
+	}
+	return settings, nil
+}
+
+func (h filterHeaders) dynamicNotch() (dsp.DynamicNotchSettings, error) {
+	settings := dsp.DynamicNotchSettings{}
+	if _, ok := h["dyn_notch_q"]; !ok {
+		return settings, nil
+	}
+
+	// Before Betaflight 4.3, the dynamic notch is enabled with a feature
+	if _, ok := h["dyn_notch_count"]; !ok {
+		features, err := h.value("features", featureDynamicFilter)
+		if err != nil {
+			return settings, err
+		}
+		if int64(features)&featureDynamicFilter == 0 {
+			return settings, nil
+		}
+	}
+
+	count, err := h.value("dyn_notch_count", 1)
+	if err != nil {
+		return settings, err
+	}
+	settings.Count = int(count)
+	if settings.WidthPercent, err = h.value("dyn_notch_width_percent", 0); err != nil {
+		return settings, err
+	}
+	q, err := h.value("dyn_notch_q", 0)
+	if err != nil {
+		return settings, err
+	}
+	settings.Q = q / 100
+	if settings.MinHz, err = h.value("dyn_notch_min_hz", defaultDynamicNotchMinHz); err != nil {
+		return settings, err
+	}
+	settings.MaxHz, err = h.value("dyn_notch_max_hz", 0)
+	return settings, err
+}
+
+// AxisFiltering is the effect of filters on the gyro of one axis
+type AxisFiltering struct {
+	Axis string `json:"axis"`
+
+	// Noise is the root mean square of the filtered gyro above 100Hz
+	Noise float64 `json:"noise"`
+
+	// Delay is how late the filtered gyro follows the unfiltered one
+	Delay time.Duration `json:"delay"`
+}
+
+// filterSegment is a part of the log without any gap between main frames
+type filterSegment struct {
+	times    []int64
+	scaled   [][]float64
+	logged   [][]float64
+	throttle []float64
+	erpm     [][]float64
+}
+
+// FilterSimulator replays the gyro filters of Betaflight on the unfiltered gyro logged
+// with the GYRO_SCALED debug mode. Filters run at the rate main frames were logged at,
+// which can be lower than the one of the gyro: frequencies above half of it can't be
+// simulated.
+type FilterSimulator struct {
+	armed         blackbox.FrameFilter
+	timeIdx       int
+	scaledIdx     []int
+	gyroIdx       []int
+	erpmIdx       []int
+	throttleIdx   int
+	motorPoles    float64
+	segments      []*filterSegment
+	lastTime      time.Duration
+	intervalCount map[time.Duration]int
+}
+
+// NewFilterSimulator returns a new FilterSimulator for logs with the given definition
+func NewFilterSimulator(frameDef blackbox.LogDefinition) (*FilterSimulator, error) {
+	timeIdx, err := timeFieldIndex(frameDef)
+	if err != nil {
+		return nil, err
+	}
+	scaledIdx := arrayFieldIndexes(frameDef, "gyroScaled")
+	if len(scaledIdx) == 0 {
+		return nil, errors.New("The log doesn't contain the unfiltered gyro, it has to be recorded with debug_mode = GYRO_SCALED")
+	}
+	gyroIdx := arrayFieldIndexes(frameDef, "gyroADC")
+	if len(gyroIdx) < len(scaledIdx) {
+		return nil, errors.New("The log doesn't contain any gyroADC field")
+	}
+
+	erpmIdx := arrayFieldIndexes(frameDef, "eRPM")
+	if len(erpmIdx) == 0 {
+		erpmIdx = arrayFieldIndexes(frameDef, "dshotErpm")
+	}
+	motorPoles := float64(defaultMotorPoles)
+	if value, err := frameDef.GetHeaderValue("motor_poles"); err == nil {
+		if poles, err := strconv.ParseFloat(value, 64); err == nil && poles > 0 {
+			motorPoles = poles
+		}
+	}
+
+	s := &FilterSimulator{
+		timeIdx:       timeIdx,
+		scaledIdx:     scaledIdx,
+		gyroIdx:       gyroIdx[:len(scaledIdx)],
+		erpmIdx:       erpmIdx,
+		throttleIdx:   optionalFieldIndex(frameDef, blackbox.FieldThrottle),
+		motorPoles:    motorPoles,
+		intervalCount: map[time.Duration]int{},
+	}
+	if armed, err := blackbox.NewArmedFilter(frameDef); err == nil {
+		s.armed = armed
+	}
+	return s, nil
+}
+
+// AddFrame records the next frame of the log
+func (s *FilterSimulator) AddFrame(frame blackbox.Frame) {
+	if s.armed != nil && !s.armed.Keep(frame) {
+		return
+	}
+	values, ok := mainFrameValues(frame)
+	if !ok {
+		return
+	}
+
+	t := mainFrameTime(values, s.timeIdx)
+	segment := s.currentSegment()
+	if segment == nil || t <= s.lastTime || t-s.lastTime > maxSignalGap {
+		segment = &filterSegment{
+			scaled: make([][]float64, len(s.scaledIdx)),
+			logged: make([][]float64, len(s.gyroIdx)),
+			erpm:   make([][]float64, len(s.erpmIdx)),
+		}
+		s.segments = append(s.segments, segment)
+	} else {
+		s.intervalCount[t-s.lastTime]++
+	}
+	s.lastTime = t
+
+	segment.times = append(segment.times, int64(t/time.Microsecond))
+	for axis := range s.scaledIdx {
+		segment.scaled[axis] = append(segment.scaled[axis], float64(values[s.scaledIdx[axis]]))
+		segment.logged[axis] = append(segment.logged[axis], float64(values[s.gyroIdx[axis]]))
+	}
+	throttle := 0.0
+	if s.throttleIdx >= 0 {
+		throttle = float64(values[s.throttleIdx]-1000) / 1000
+	}
+	segment.throttle = append(segment.throttle, throttle)
+	for motor, index := range s.erpmIdx {
+		// eRPM are logged divided by 100
+		hz := float64(values[index]) * 100 / 60 / (s.motorPoles / 2)
+		segment.erpm[motor] = append(segment.erpm[motor], hz)
+	}
+}
+
+func (s *FilterSimulator) currentSegment() *filterSegment {
+	if len(s.segments) == 0 {
+		return nil
+	}
+	return s.segments[len(s.segments)-1]
+}
+
+// Unfiltered returns the noise of the unfiltered gyro
+func (s *FilterSimulator) Unfiltered() ([]AxisFiltering, error) {
+	return s.measure(func(segment *filterSegment, axis int, sampleRate float64) []float64 {
+		return dsp.Uniform(segment.times, segment.scaled[axis], sampleRate)
+	})
+}
+
+// Logged returns the effect of the filters the log was recorded with, measured on the
+// filtered gyro it contains
+func (s *FilterSimulator) Logged() ([]AxisFiltering, error) {
+	return s.measure(func(segment *filterSegment, axis int, sampleRate float64) []float64 {
+		return dsp.Uniform(segment.times, segment.logged[axis], sampleRate)
+	})
+}
+
+// Simulate returns the effect the given filters have on the unfiltered gyro
+func (s *FilterSimulator) Simulate(settings dsp.GyroFilterSettings) ([]AxisFiltering, error) {
+	return s.measure(func(segment *filterSegment, axis int, sampleRate float64) []float64 {
+		gyro := dsp.Uniform(segment.times, segment.scaled[axis], sampleRate)
+		throttle := dsp.Uniform(segment.times, segment.throttle, sampleRate)
+		motors := make([][]float64, len(segment.erpm))
+		for motor := range segment.erpm {
+			motors[motor] = dsp.Uniform(segment.times, segment.erpm[motor], sampleRate)
+		}
+
+		chain := dsp.NewGyroFilterChain(settings, sampleRate)
+		output := make([]float64, len(gyro))
+		frequencies := make([]float64, len(motors))
+		for i := range gyro {
+			for motor := range motors {
+				frequencies[motor] = motors[motor][i]
+			}
+			output[i] = chain.Apply(dsp.GyroSample{Gyro: gyro[i], Throttle: throttle[i], MotorFrequencies: frequencies})
+		}
+		return output
+	})
+}
+
+// measure compares, on every axis, the gyro returned by filter with the unfiltered one
+func (s *FilterSimulator) measure(filter func(segment *filterSegment, axis int, sampleRate float64) []float64) ([]AxisFiltering, error) {
+	sampleRate := medianSampleRate(s.intervalCount)
+	if sampleRate == 0 {
+		return nil, errors.New("Not enough frames to simulate the filters")
+	}
+
+	result := []AxisFiltering{}
+	for axis := 0; axis < len(axisNames) && axis < len(s.scaledIdx); axis++ {
+		unfiltered := []float64{}
+		filtered := []float64{}
+		for _, segment := range s.segments {
+			unfiltered = append(unfiltered, dsp.Uniform(segment.times, segment.scaled[axis], sampleRate)...)
+			filtered = append(filtered, filter(segment, axis, sampleRate)...)
+		}
+
+		spectrum, err := dsp.PowerSpectrum(filtered, sampleRate, dsp.NextPowerOfTwo(int(sampleRate/spectrumResolution)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not measure the noise of the %s axis", axisNames[axis])
+		}
+		result = append(result, AxisFiltering{
+			Axis:  axisNames[axis],
+			Noise: math.Sqrt(spectrum.BandPower(noiseFrequency, sampleRate/2)),
+			Delay: dsp.Delay(unfiltered, filtered, sampleRate, maxFilterDelay),
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,98 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/blackbox/blackboxtest"
+	"github.com/maxlaverse/blackbox-library/src/dsp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGyroFilterSettingsFromHeaders(t *testing.T) {
+	frameDef := blackbox.LogDefinition{Headers: []blackbox.Header{
+		{Name: "gyro_lowpass_type", Value: "0"},
+		{Name: "gyro_lowpass_hz", Value: "0"},
+		{Name: "gyro_lowpass_dyn_hz", Value: "200,500"},
+		{Name: "gyro_lowpass2_type", Value: "0"},
+		{Name: "gyro_lowpass2_hz", Value: "250"},
+		{Name: "gyro_notch_hz", Value: "0,0"},
+		{Name: "gyro_notch_cutoff", Value: "0,0"},
+		{Name: "features", Value: "541130760"},
+		{Name: "dyn_notch_width_percent", Value: "8"},
+		{Name: "dyn_notch_q", Value: "120"},
+		{Name: "dyn_notch_min_hz", Value: "150"},
+		{Name: "rpm_filter_harmonics", Value: "3"},
+		{Name: "rpm_filter_q", Value: "500"},
+		{Name: "rpm_filter_min_hz", Value: "100"},
+	}}
+
+	settings, err := GyroFilterSettingsFromHeaders(frameDef, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, dsp.GyroFilterSettings{
+		Lowpass:      dsp.LowpassSettings{Type: dsp.LowpassPT1, DynamicMin: 200, DynamicMax: 500},
+		Lowpass2:     dsp.LowpassSettings{Type: dsp.LowpassPT1, Cutoff: 250},
+		DynamicNotch: dsp.DynamicNotchSettings{Count: 1, WidthPercent: 8, Q: 1.2, MinHz: 150},
+		RPMFilter:    dsp.RPMFilterSettings{Harmonics: 3, Q: 5, MinHz: 100},
+	}, settings)
+
+	settings, err = GyroFilterSettingsFromHeaders(frameDef, map[blackbox.HeaderName]string{
+		"gyro_lowpass_dyn_hz": "0,0",
+		"gyro_lowpass_hz":     "150",
+		"gyro_notch_hz":       "300,0",
+		"gyro_notch_cutoff":   "200,0",
+		"features":            "0",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, dsp.LowpassSettings{Type: dsp.LowpassPT1, Cutoff: 150}, settings.Lowpass)
+	assert.Equal(t, dsp.NotchSettings{Center: 300, Cutoff: 200}, settings.Notch1)
+	assert.Equal(t, dsp.DynamicNotchSettings{}, settings.DynamicNotch)
+
+	_, err = GyroFilterSettingsFromHeaders(frameDef, map[blackbox.HeaderName]string{"gyro_lowpass2_hz": "high"})
+	assert.EqualError(t, err, "Invalid value for header 'gyro_lowpass2_hz': strconv.ParseFloat: parsing \"high\": invalid syntax")
+}
+
+func TestFilterSimulator(t *testing.T) {
+	simulator, err := NewFilterSimulator(testFilterLogDefinition())
+	assert.NoError(t, err)
+
+	// A 10Hz movement and, on the unfiltered gyro only, a 300Hz vibration
+	for ms := int64(0); ms < 4000; ms++ {
+		movement := 200 * math.Sin(2*math.Pi*10*float64(ms)/1000)
+		noise := 20 * math.Sin(2*math.Pi*300*float64(ms)/1000)
+		logged := int64(math.Round(movement))
+		scaled := int64(math.Round(movement + noise))
+		simulator.AddFrame(testFrame(ms, ms, logged, logged, logged, scaled, scaled, scaled, 1500))
+	}
+
+	unfiltered, err := simulator.Unfiltered()
+	assert.NoError(t, err)
+	assert.Len(t, unfiltered, 3)
+	assert.InDelta(t, 20/math.Sqrt2, unfiltered[0].Noise, 1)
+	assert.Equal(t, 0.0, unfiltered[0].Delay.Seconds())
+
+	logged, err := simulator.Logged()
+	assert.NoError(t, err)
+	assert.InDelta(t, 0, logged[1].Noise, 1)
+
+	simulated, err := simulator.Simulate(dsp.GyroFilterSettings{Lowpass: dsp.LowpassSettings{Type: dsp.LowpassPT1, Cutoff: 50}})
+	assert.NoError(t, err)
+	assert.Equal(t, "yaw", simulated[2].Axis)
+	assert.InDelta(t, 20/math.Sqrt2/6, simulated[2].Noise, 1)
+	assert.InDelta(t, 3.2, simulated[2].Delay.Seconds()*1000, 0.5)
+}
+
+func TestFilterSimulatorWithoutUnfilteredGyro(t *testing.T) {
+	_, err := NewFilterSimulator(testProfileLogDefinition())
+	assert.EqualError(t, err, "The log doesn't contain the unfiltered gyro, it has to be recorded with debug_mode = GYRO_SCALED")
+
+	simulator, err := NewFilterSimulator(testFilterLogDefinition())
+	assert.NoError(t, err)
+	_, err = simulator.Unfiltered()
+	assert.EqualError(t, err, "Not enough frames to simulate the filters")
+}
+
+func testFilterLogDefinition() blackbox.LogDefinition {
+	return blackboxtest.LogDefinition("loopIteration", "time", "gyroADC[0]", "gyroADC[1]", "gyroADC[2]", "gyroScaled[0]", "gyroScaled[1]", "gyroScaled[2]", "rcCommand[3]")
+}
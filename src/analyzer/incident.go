@@ -0,0 +1,318 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+)
+
+// fieldRxSignalReceived is the slow field telling if the radio signal is received
+const fieldRxSignalReceived blackbox.FieldName = "rxSignalReceived"
+
+// betaflightDisarmReasons are the names Betaflight gives to the reasons of a disarm event
+var betaflightDisarmReasons = []string{
+	"arming disabled", "failsafe", "throttle timeout", "sticks", "switch", "crash protection",
+	"runaway takeoff", "GPS rescue", "serial command",
+}
+
+// IncidentEventType is the kind of event leading to an incident
+type IncidentEventType int
+
+// List of the events the IncidentAnalyzer can find
+const (
+	// IncidentFailsafe is a change of the failsafe phase
+	IncidentFailsafe IncidentEventType = iota
+
+	// IncidentRxLoss is the loss of the radio signal
+	IncidentRxLoss
+
+	// IncidentRxRecovery is the radio signal coming back
+	IncidentRxRecovery
+
+	// IncidentAccelerationSpike is a period during which the acceleration goes above a
+	// threshold, typical of an impact
+	IncidentAccelerationSpike
+
+	// IncidentDisarm is the craft being disarmed
+	IncidentDisarm
+
+	// IncidentLogEnd is the end of the log, clean or not
+	IncidentLogEnd
+)
+
+var incidentEventTypeNames = map[IncidentEventType]string{
+	IncidentFailsafe:          "failsafe",
+	IncidentRxLoss:            "RX loss",
+	IncidentRxRecovery:        "RX recovery",
+	IncidentAccelerationSpike: "acceleration spike",
+	IncidentDisarm:            "disarm",
+	IncidentLogEnd:            "log end",
+}
+
+func (t IncidentEventType) String() string {
+	name, ok := incidentEventTypeNames[t]
+	if !ok {
+		return fmt.Sprintf("unknown (%d)", t)
+	}
+	return name
+}
+
+// IncidentEvent is a time-stamped event of the log. Events found outside of main
+// frames are given the time of the last main frame before them, or of the first one
+// if they come before any main frame.
+type IncidentEvent struct {
+	Type IncidentEventType `json:"type"`
+	Time time.Duration     `json:"time"`
+
+	// Description tells what happened, like "failsafe phase IDLE -> RX_LOSS_DETECTED"
+	Description string `json:"description"`
+}
+
+func (e IncidentEvent) String() string {
+	return fmt.Sprintf("%s  %s", formatTime(e.Time), e.Description)
+}
+
+// IncidentAnalyzerOpts holds the thresholds used by an IncidentAnalyzer
+type IncidentAnalyzerOpts struct {
+	// Window is how long before the end of the log the incident is looked at
+	Window time.Duration
+
+	// AccelerationThreshold is the acceleration, in g, above which a spike is reported
+	AccelerationThreshold float64
+}
+
+// DefaultIncidentAnalyzerOpts returns the thresholds that work for most crafts
+func DefaultIncidentAnalyzerOpts() IncidentAnalyzerOpts {
+	return IncidentAnalyzerOpts{
+		Window:                10 * time.Second,
+		AccelerationThreshold: 4,
+	}
+}
+
+// Incident describes the last seconds of a log
+type Incident struct {
+	// Start and End delimit the data worth keeping to understand the incident. Start
+	// is never before the first main frame, so the incident can be shorter than the
+	// window on short logs.
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+
+	// CleanEnd is false if the log stopped without its end marker, usually because the
+	// flight controller lost power
+	CleanEnd bool `json:"cleanEnd"`
+
+	Events []IncidentEvent `json:"events"`
+}
+
+// Narrative tells the sequence of events of the incident
+func (i Incident) Narrative() string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Last %s of the log, from %s to %s:\n", i.End-i.Start, formatTime(i.Start), formatTime(i.End))
+	for _, event := range i.Events {
+		fmt.Fprintf(buf, "  %s (-%.1fs)  %s\n", formatTime(event.Time), (i.End - event.Time).Seconds(), event.Description)
+	}
+
+	sentences := []string{}
+	seen := map[IncidentEventType]bool{}
+	for _, event := range i.Events {
+		if seen[event.Type] {
+			continue
+		}
+		seen[event.Type] = true
+
+		before := (i.End - event.Time).Seconds()
+		switch event.Type {
+		case IncidentRxLoss:
+			sentences = append(sentences, fmt.Sprintf("the RX signal was lost %.1fs before the end", before))
+		case IncidentFailsafe:
+			sentences = append(sentences, fmt.Sprintf("the failsafe triggered %.1fs before the end", before))
+		case IncidentAccelerationSpike:
+			sentences = append(sentences, fmt.Sprintf("an %s was measured %.1fs before the end", event.Description, before))
+		case IncidentDisarm:
+			sentences = append(sentences, fmt.Sprintf("the craft was %s %.1fs before the end", event.Description, before))
+		}
+	}
+	if i.CleanEnd {
+		sentences = append(sentences, "the log was closed cleanly")
+	} else {
+		sentences = append(sentences, "the log stopped abruptly, likely from a power loss")
+	}
+	fmt.Fprintf(buf, "Summary: %s.\n", strings.Join(sentences, ", then "))
+	return buf.String()
+}
+
+// IncidentAnalyzer finds what happened in the last seconds of a log: failsafe phase
+// changes, RX signal losses, acceleration spikes, disarms and how the log ended
+type IncidentAnalyzer struct {
+	opts          IncidentAnalyzerOpts
+	frameDef      blackbox.LogDefinition
+	disarmReasons []string
+	timeIdx       int
+	accIdx        []int
+	failsafeIdx   int
+	rxSignalIdx   int
+	started       bool
+	firstTime     time.Duration
+	lastTime      time.Duration
+	failsafePhase int64
+	rxSignal      int64
+	slowFrameSeen bool
+	spikeIdx      int
+	spikePeak     float64
+	cleanEnd      bool
+	events        []IncidentEvent
+}
+
+// NewIncidentAnalyzer returns a new IncidentAnalyzer for logs with the given definition
+func NewIncidentAnalyzer(frameDef blackbox.LogDefinition, opts IncidentAnalyzerOpts) *IncidentAnalyzer {
+	a := &IncidentAnalyzer{
+		opts:        opts,
+		frameDef:    frameDef,
+		timeIdx:     optionalFieldIndex(frameDef, blackbox.FieldTime),
+		accIdx:      arrayFieldIndexes(frameDef, "accSmooth"),
+		failsafeIdx: slowFieldIndex(frameDef, blackbox.FieldFailsafePhase),
+		rxSignalIdx: slowFieldIndex(frameDef, fieldRxSignalReceived),
+		spikeIdx:    -1,
+	}
+	if frameDef.Firmware.Product == "Betaflight" {
+		a.disarmReasons = betaflightDisarmReasons
+	}
+	return a
+}
+
+// slowFieldIndex returns the position of a slow field or -1 if the log doesn't have it
+func slowFieldIndex(frameDef blackbox.LogDefinition, name blackbox.FieldName) int {
+	for i, field := range frameDef.FieldsS {
+		if field.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddFrame analyzes the next frame of the log
+func (a *IncidentAnalyzer) AddFrame(frame blackbox.Frame) {
+	if frame.Error() != nil {
+		return
+	}
+
+	switch frame := frame.(type) {
+	case *blackbox.SlowFrame:
+		a.addSlowFrame(frame.Values().([]int64))
+
+	case *blackbox.EventFrame:
+		a.addEventFrame(frame)
+
+	default:
+		values, ok := mainFrameValues(frame)
+		if !ok || a.timeIdx < 0 {
+			return
+		}
+		a.lastTime = mainFrameTime(values, a.timeIdx)
+		if !a.started {
+			a.started = true
+			a.firstTime = a.lastTime
+			for i := range a.events {
+				a.events[i].Time = a.firstTime
+			}
+		}
+		a.detectAccelerationSpike(values)
+	}
+}
+
+func (a *IncidentAnalyzer) addSlowFrame(values []int64) {
+	if a.failsafeIdx >= 0 && a.failsafeIdx < len(values) {
+		phase := values[a.failsafeIdx]
+		if a.slowFrameSeen && phase != a.failsafePhase {
+			a.addEvent(IncidentFailsafe, fmt.Sprintf("failsafe phase %s -> %s", blackbox.FailsafePhaseName(a.failsafePhase), blackbox.FailsafePhaseName(phase)))
+		}
+		a.failsafePhase = phase
+	}
+
+	if a.rxSignalIdx >= 0 && a.rxSignalIdx < len(values) {
+		signal := values[a.rxSignalIdx]
+		if signal == 0 && (!a.slowFrameSeen || a.rxSignal != 0) {
+			a.addEvent(IncidentRxLoss, "RX signal lost")
+		} else if signal != 0 && a.slowFrameSeen && a.rxSignal == 0 {
+			a.addEvent(IncidentRxRecovery, "RX signal recovered")
+		}
+		a.rxSignal = signal
+	}
+	a.slowFrameSeen = true
+}
+
+func (a *IncidentAnalyzer) addEventFrame(frame *blackbox.EventFrame) {
+	values := frame.Values().(map[string]interface{})
+	switch frame.EventType() {
+	case blackbox.LogEventDisarm:
+		reason := fmt.Sprintf("reason %v", values["reason"])
+		if code, ok := values["reason"].(uint32); ok && int(code) < len(a.disarmReasons) {
+			reason = a.disarmReasons[code]
+		}
+		a.addEvent(IncidentDisarm, fmt.Sprintf("disarmed (%s)", reason))
+
+	case blackbox.LogEventLogEnd:
+		a.cleanEnd = true
+	}
+}
+
+// detectAccelerationSpike reports the periods during which the acceleration is above
+// the threshold, with their peak
+func (a *IncidentAnalyzer) detectAccelerationSpike(values []int64) {
+	if len(a.accIdx) == 0 {
+		return
+	}
+	total := 0.0
+	for _, index := range a.accIdx {
+		total += math.Pow(float64(values[index]), 2)
+	}
+	acceleration := math.Sqrt(total) / float64(a.frameDef.Sysconfig.Acc1G)
+
+	if acceleration < a.opts.AccelerationThreshold {
+		a.spikeIdx = -1
+		return
+	}
+	if a.spikeIdx < 0 {
+		a.events = append(a.events, IncidentEvent{Type: IncidentAccelerationSpike, Time: a.lastTime})
+		a.spikeIdx = len(a.events) - 1
+		a.spikePeak = 0
+	}
+	if acceleration > a.spikePeak {
+		a.spikePeak = acceleration
+		a.events[a.spikeIdx].Description = fmt.Sprintf("acceleration of %.1fg", acceleration)
+	}
+}
+
+func (a *IncidentAnalyzer) addEvent(eventType IncidentEventType, description string) {
+	a.events = append(a.events, IncidentEvent{Type: eventType, Time: a.lastTime, Description: description})
+}
+
+// Incident returns the events of the last seconds of the log, ending with the log end
+func (a *IncidentAnalyzer) Incident() Incident {
+	incident := Incident{
+		Start:    a.lastTime - a.opts.Window,
+		End:      a.lastTime,
+		CleanEnd: a.cleanEnd,
+		Events:   []IncidentEvent{},
+	}
+	if incident.Start < a.firstTime {
+		incident.Start = a.firstTime
+	}
+
+	for _, event := range a.events {
+		if event.Time >= incident.Start {
+			incident.Events = append(incident.Events, event)
+		}
+	}
+
+	description := "log ends without its end marker"
+	if a.cleanEnd {
+		description = "log ends cleanly"
+	}
+	incident.Events = append(incident.Events, IncidentEvent{Type: IncidentLogEnd, Time: a.lastTime, Description: description})
+	return incident
+}
@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/blackbox/blackboxtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncidentAnalyzer(t *testing.T) {
+	frameDef := blackboxtest.LogDefinition("loopIteration", "time", "accSmooth[0]", "accSmooth[1]", "accSmooth[2]")
+	frameDef.Firmware = blackbox.FirmwareVersion{Product: "Betaflight", Major: 4}
+	frameDef.Sysconfig.Acc1G = 2048
+	for _, name := range []string{"flightModeFlags", "stateFlags", "failsafePhase", "rxSignalReceived"} {
+		frameDef.FieldsS = append(frameDef.FieldsS, blackbox.FieldDefinition{Name: blackbox.FieldName(name)})
+	}
+	analyzer := NewIncidentAnalyzer(frameDef, DefaultIncidentAnalyzerOpts())
+
+	analyzer.AddFrame(blackbox.NewSlowFrame([]int64{1, 0, 0, 1}, 0, 0, nil))
+	for ms := int64(0); ms <= 20000; ms += 10 {
+		acc := int64(2048)
+		if ms >= 18000 && ms < 18050 {
+			acc = 2048 * (ms - 17990) / 2
+		}
+		analyzer.AddFrame(testFrame(ms, ms, 0, 0, acc))
+
+		switch ms {
+		case 1000:
+			// An RX loss long before the end of the log
+			analyzer.AddFrame(blackbox.NewSlowFrame([]int64{1, 0, 0, 0}, 0, 0, nil))
+		case 1100:
+			analyzer.AddFrame(blackbox.NewSlowFrame([]int64{1, 0, 0, 1}, 0, 0, nil))
+		case 15000:
+			analyzer.AddFrame(blackbox.NewSlowFrame([]int64{1, 0, 0, 0}, 0, 0, nil))
+		case 15100:
+			analyzer.AddFrame(blackbox.NewSlowFrame([]int64{1, 0, 1, 0}, 0, 0, nil))
+		case 18500:
+			analyzer.AddFrame(blackbox.NewEventFrame(blackbox.LogEventDisarm, map[string]interface{}{"reason": uint32(1)}, 0, 0, nil))
+		}
+	}
+
+	incident := analyzer.Incident()
+	assert.Equal(t, 10*time.Second, incident.Start)
+	assert.Equal(t, 20*time.Second, incident.End)
+	assert.False(t, incident.CleanEnd)
+	assert.Equal(t, []IncidentEvent{
+		{Type: IncidentRxLoss, Time: 15 * time.Second, Description: "RX signal lost"},
+		{Type: IncidentFailsafe, Time: 15100 * time.Millisecond, Description: "failsafe phase IDLE -> RX_LOSS_DETECTED"},
+		{Type: IncidentAccelerationSpike, Time: 18 * time.Second, Description: "acceleration of 25.0g"},
+		{Type: IncidentDisarm, Time: 18500 * time.Millisecond, Description: "disarmed (failsafe)"},
+		{Type: IncidentLogEnd, Time: 20 * time.Second, Description: "log ends without its end marker"},
+	}, incident.Events)
+
+	assert.Equal(t, `Last 10s of the log, from 00:10.000 to 00:20.000:
+  00:15.000 (-5.0s)  RX signal lost
+  00:15.100 (-4.9s)  failsafe phase IDLE -> RX_LOSS_DETECTED
+  00:18.000 (-2.0s)  acceleration of 25.0g
+  00:18.500 (-1.5s)  disarmed (failsafe)
+  00:20.000 (-0.0s)  log ends without its end marker
+Summary: the RX signal was lost 5.0s before the end, then the failsafe triggered 4.9s before the end, then an acceleration of 25.0g was measured 2.0s before the end, then the craft was disarmed (failsafe) 1.5s before the end, then the log stopped abruptly, likely from a power loss.
+`, incident.Narrative())
+}
+
+func TestIncidentAnalyzerCleanEnd(t *testing.T) {
+	analyzer := NewIncidentAnalyzer(blackboxtest.LogDefinition("loopIteration", "time"), DefaultIncidentAnalyzerOpts())
+	analyzer.AddFrame(testFrame(0, 0))
+	analyzer.AddFrame(testFrame(1, 2000))
+	analyzer.AddFrame(blackbox.NewEventFrame(blackbox.LogEventLogEnd, map[string]interface{}{}, 0, 0, nil))
+
+	incident := analyzer.Incident()
+	assert.Equal(t, time.Duration(0), incident.Start)
+	assert.True(t, incident.CleanEnd)
+	assert.Equal(t, []IncidentEvent{{Type: IncidentLogEnd, Time: 2 * time.Second, Description: "log ends cleanly"}}, incident.Events)
+	assert.Contains(t, incident.Narrative(), "Summary: the log was closed cleanly.")
+}
+
+func TestIncidentAnalyzerShortLog(t *testing.T) {
+	frameDef := blackboxtest.LogDefinition("loopIteration", "time")
+	frameDef.FieldsS = []blackbox.FieldDefinition{{Name: "rxSignalReceived"}}
+	analyzer := NewIncidentAnalyzer(frameDef, DefaultIncidentAnalyzerOpts())
+
+	// The log starts without the RX signal, before any main frame
+	analyzer.AddFrame(blackbox.NewSlowFrame([]int64{0}, 0, 0, nil))
+	for ms := int64(55000); ms <= 55100; ms += 10 {
+		analyzer.AddFrame(testFrame(ms, ms))
+	}
+
+	incident := analyzer.Incident()
+	assert.Equal(t, 55*time.Second, incident.Start)
+	assert.Equal(t, 55100*time.Millisecond, incident.End)
+	assert.Equal(t, IncidentEvent{Type: IncidentRxLoss, Time: 55 * time.Second, Description: "RX signal lost"}, incident.Events[0])
+	assert.Contains(t, incident.Narrative(), "Last 100ms of the log, from 00:55.000 to 00:55.100:\n")
+}
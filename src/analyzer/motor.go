@@ -0,0 +1,356 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/pkg/errors"
+)
+
+// MotorEventType is the kind of problem found on a motor
+type MotorEventType int
+
+// List of the problems the MotorAnalyzer can find
+const (
+	// MotorSaturatedHigh is a period during which a motor sits at its maximum output
+	MotorSaturatedHigh MotorEventType = iota
+
+	// MotorSaturatedLow is a period during which a motor sits at its minimum output
+	MotorSaturatedLow
+
+	// MotorCollapse is a sudden drop of a single motor output the mixer can't explain
+	MotorCollapse
+
+	// MotorRPMDropout is a period during which the eRPM telemetry of a motor collapses
+	// while its command doesn't
+	MotorRPMDropout
+)
+
+var motorEventTypeNames = map[MotorEventType]string{
+	MotorSaturatedHigh: "saturated high",
+	MotorSaturatedLow:  "saturated low",
+	MotorCollapse:      "output collapse",
+	MotorRPMDropout:    "eRPM dropout",
+}
+
+func (t MotorEventType) String() string {
+	name, ok := motorEventTypeNames[t]
+	if !ok {
+		return fmt.Sprintf("unknown (%d)", t)
+	}
+	return name
+}
+
+// MotorEvent is a time-stamped problem found on a motor
+type MotorEvent struct {
+	Type  MotorEventType
+	Motor int
+	Start time.Duration
+	End   time.Duration
+
+	// Value is the most extreme motor output (or eRPM for dropouts) seen during the event
+	Value int64
+}
+
+// Duration returns how long the event lasted
+func (e MotorEvent) Duration() time.Duration {
+	return e.End - e.Start
+}
+
+func (e MotorEvent) String() string {
+	return fmt.Sprintf("%s - %s  motor[%d] %s (%d)", formatTime(e.Start), formatTime(e.End), e.Motor, e.Type, e.Value)
+}
+
+// MotorAnalyzerOpts holds the thresholds used by a MotorAnalyzer
+type MotorAnalyzerOpts struct {
+	// MinSaturationDuration is the shortest saturation period worth reporting
+	MinSaturationDuration time.Duration
+
+	// CollapseWindow is the time frame in which a motor output drop is considered sudden
+	CollapseWindow time.Duration
+
+	// CollapseThreshold is the drop, as a fraction of the motor output range, above which
+	// a single motor output is considered as collapsing
+	CollapseThreshold float64
+
+	// RPMDropoutThreshold is the drop, as a fraction of the recent eRPM, above which a
+	// motor is considered as having lost its RPM
+	RPMDropoutThreshold float64
+
+	// MinRPM is the eRPM value (as logged) under which a motor is considered as not spinning
+	MinRPM int64
+}
+
+// DefaultMotorAnalyzerOpts returns the thresholds that work for most multirotors
+func DefaultMotorAnalyzerOpts() MotorAnalyzerOpts {
+	return MotorAnalyzerOpts{
+		MinSaturationDuration: 5 * time.Millisecond,
+		CollapseWindow:        20 * time.Millisecond,
+		CollapseThreshold:     0.4,
+		RPMDropoutThreshold:   0.5,
+		MinRPM:                10,
+	}
+}
+
+// motorSample is the state of the motors in a main frame
+type motorSample struct {
+	time     time.Duration
+	motors   []int64
+	erpm     []int64
+	throttle int64
+}
+
+// MotorAnalyzer detects saturated motors, ESC desyncs and eRPM dropouts
+type MotorAnalyzer struct {
+	opts        MotorAnalyzerOpts
+	low         int64
+	high        int64
+	timeIdx     int
+	motorIdx    []int
+	erpmIdx     []int
+	throttleIdx int
+	window      []motorSample
+	saturations []map[MotorEventType]*MotorEvent
+	collapses   []*MotorEvent
+	dropouts    []*MotorEvent
+	peakOutputs []int64
+	peakRPMs    []int64
+	events      []MotorEvent
+}
+
+// NewMotorAnalyzer returns a new MotorAnalyzer for logs with the given definition
+func NewMotorAnalyzer(frameDef blackbox.LogDefinition, opts MotorAnalyzerOpts) (*MotorAnalyzer, error) {
+	timeIdx, err := timeFieldIndex(frameDef)
+	if err != nil {
+		return nil, err
+	}
+
+	motorIdx := arrayFieldIndexes(frameDef, "motor")
+	if len(motorIdx) == 0 {
+		return nil, errors.New("The log doesn't contain any motor field")
+	}
+
+	erpmIdx := arrayFieldIndexes(frameDef, "eRPM")
+	if len(erpmIdx) != len(motorIdx) {
+		erpmIdx = nil
+	}
+
+	saturations := make([]map[MotorEventType]*MotorEvent, len(motorIdx))
+	for i := range saturations {
+		saturations[i] = map[MotorEventType]*MotorEvent{}
+	}
+
+	return &MotorAnalyzer{
+		opts:        opts,
+		low:         int64(frameDef.Sysconfig.MotorOutputLow),
+		high:        int64(frameDef.Sysconfig.MotorOutputHigh),
+		timeIdx:     timeIdx,
+		motorIdx:    motorIdx,
+		erpmIdx:     erpmIdx,
+		throttleIdx: optionalFieldIndex(frameDef, blackbox.FieldThrottle),
+		saturations: saturations,
+		collapses:   make([]*MotorEvent, len(motorIdx)),
+		dropouts:    make([]*MotorEvent, len(motorIdx)),
+		peakOutputs: make([]int64, len(motorIdx)),
+		peakRPMs:    make([]int64, len(motorIdx)),
+	}, nil
+}
+
+// AddFrame analyzes the next frame of the log
+func (a *MotorAnalyzer) AddFrame(frame blackbox.Frame) {
+	values, ok := mainFrameValues(frame)
+	if !ok {
+		return
+	}
+
+	sample := motorSample{
+		time:   mainFrameTime(values, a.timeIdx),
+		motors: make([]int64, len(a.motorIdx)),
+	}
+	for i, idx := range a.motorIdx {
+		sample.motors[i] = values[idx]
+	}
+	if a.erpmIdx != nil {
+		sample.erpm = make([]int64, len(a.erpmIdx))
+		for i, idx := range a.erpmIdx {
+			sample.erpm[i] = values[idx]
+		}
+	}
+	if a.throttleIdx >= 0 {
+		sample.throttle = values[a.throttleIdx]
+	}
+
+	// Forget about the samples that are too old to be compared with
+	for len(a.window) > 0 && sample.time-a.window[0].time > a.opts.CollapseWindow {
+		a.window = a.window[1:]
+	}
+
+	for motor := range a.motorIdx {
+		a.detectSaturation(motor, sample)
+		a.detectCollapse(motor, sample)
+		if sample.erpm != nil {
+			a.detectRPMDropout(motor, sample)
+		}
+	}
+
+	a.window = append(a.window, sample)
+}
+
+// Events returns all the events found so far, ordered by start time
+func (a *MotorAnalyzer) Events() []MotorEvent {
+	events := append([]MotorEvent{}, a.events...)
+	for motor := range a.motorIdx {
+		for _, event := range a.saturations[motor] {
+			if event.Duration() >= a.opts.MinSaturationDuration {
+				events = append(events, *event)
+			}
+		}
+		if a.collapses[motor] != nil {
+			events = append(events, *a.collapses[motor])
+		}
+		if a.dropouts[motor] != nil {
+			events = append(events, *a.dropouts[motor])
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Start == events[j].Start {
+			return events[i].Motor < events[j].Motor
+		}
+		return events[i].Start < events[j].Start
+	})
+	return events
+}
+
+// detectSaturation keeps track of the periods during which a motor sits at one
+// end of its output range. Armed motors are clamped to [low, high] by the
+// mixer: a value below the low end means the motors are stopped.
+func (a *MotorAnalyzer) detectSaturation(motor int, sample motorSample) {
+	value := sample.motors[motor]
+	a.updatePeriod(motor, MotorSaturatedHigh, value >= a.high, sample.time, value)
+	a.updatePeriod(motor, MotorSaturatedLow, value == a.low, sample.time, value)
+}
+
+func (a *MotorAnalyzer) updatePeriod(motor int, eventType MotorEventType, active bool, t time.Duration, value int64) {
+	event := a.saturations[motor][eventType]
+	if active {
+		if event == nil {
+			a.saturations[motor][eventType] = &MotorEvent{Type: eventType, Motor: motor, Start: t, End: t, Value: value}
+			return
+		}
+		event.End = t
+		if (eventType == MotorSaturatedHigh && value > event.Value) || (eventType == MotorSaturatedLow && value < event.Value) {
+			event.Value = value
+		}
+		return
+	}
+
+	if event != nil {
+		if event.Duration() >= a.opts.MinSaturationDuration {
+			a.events = append(a.events, *event)
+		}
+		delete(a.saturations[motor], eventType)
+	}
+}
+
+// detectCollapse flags a motor whose output suddenly drops while the other
+// motors and the throttle don't. The mixer adds the throttle to every motor,
+// so a lone motor falling through most of its range usually means the ESC
+// lost sync.
+func (a *MotorAnalyzer) detectCollapse(motor int, sample motorSample) {
+	outputRange := float64(a.high - a.low)
+	if outputRange <= 0 || len(a.motorIdx) < 2 {
+		return
+	}
+
+	// An ongoing collapse lasts until the motor gets back close to the output it had before
+	if event := a.collapses[motor]; event != nil {
+		if float64(a.peakOutputs[motor]-sample.motors[motor])/outputRange >= a.opts.CollapseThreshold/2 {
+			event.End = sample.time
+			if sample.motors[motor] < event.Value {
+				event.Value = sample.motors[motor]
+			}
+			return
+		}
+		a.events = append(a.events, *event)
+		a.collapses[motor] = nil
+	}
+
+	// Motors going under the low end have been stopped, not desynchronized
+	if len(a.window) == 0 || sample.motors[motor] < a.low {
+		return
+	}
+	peak := a.window[0]
+	for _, s := range a.window[1:] {
+		if s.motors[motor] > peak.motors[motor] {
+			peak = s
+		}
+	}
+
+	drop := float64(peak.motors[motor]-sample.motors[motor]) / outputRange
+	othersDrop := (averageOfOthers(peak.motors, motor) - averageOfOthers(sample.motors, motor)) / outputRange
+	throttleDrop := float64(peak.throttle-sample.throttle) / outputRange
+
+	if drop >= a.opts.CollapseThreshold && othersDrop < a.opts.CollapseThreshold/2 && throttleDrop < a.opts.CollapseThreshold/2 {
+		a.collapses[motor] = &MotorEvent{Type: MotorCollapse, Motor: motor, Start: peak.time, End: sample.time, Value: sample.motors[motor]}
+		a.peakOutputs[motor] = peak.motors[motor]
+	}
+}
+
+// detectRPMDropout flags a motor whose eRPM telemetry collapses, or stops,
+// while the motor is still commanded to spin
+func (a *MotorAnalyzer) detectRPMDropout(motor int, sample motorSample) {
+	commanded := sample.motors[motor] > a.low
+	stopped := sample.erpm[motor] < a.opts.MinRPM
+
+	// An ongoing dropout lasts until the eRPM gets back close to what it was before
+	if event := a.dropouts[motor]; event != nil {
+		reference := float64(a.peakRPMs[motor])
+		if commanded && (stopped || float64(sample.erpm[motor]) < reference*(1-a.opts.RPMDropoutThreshold/2)) {
+			event.End = sample.time
+			if sample.erpm[motor] < event.Value {
+				event.Value = sample.erpm[motor]
+			}
+			return
+		}
+		a.events = append(a.events, *event)
+		a.dropouts[motor] = nil
+	}
+
+	if len(a.window) == 0 || !commanded {
+		return
+	}
+	peak := a.window[0]
+	for _, s := range a.window[1:] {
+		if s.erpm[motor] > peak.erpm[motor] {
+			peak = s
+		}
+	}
+	if peak.erpm[motor] < a.opts.MinRPM {
+		return
+	}
+
+	commandDrop := float64(peak.motors[motor]-sample.motors[motor]) / float64(peak.motors[motor])
+	dropping := float64(sample.erpm[motor]) <= float64(peak.erpm[motor])*(1-a.opts.RPMDropoutThreshold) &&
+		commandDrop < a.opts.RPMDropoutThreshold/2
+
+	if dropping || stopped {
+		a.dropouts[motor] = &MotorEvent{Type: MotorRPMDropout, Motor: motor, Start: sample.time, End: sample.time, Value: sample.erpm[motor]}
+		a.peakRPMs[motor] = peak.erpm[motor]
+	}
+}
+
+func averageOfOthers(values []int64, excluded int) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	sum := int64(0)
+	for i, v := range values {
+		if i != excluded {
+			sum += v
+		}
+	}
+	return float64(sum) / float64(len(values)-1)
+}
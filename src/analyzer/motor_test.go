@@ -0,0 +1,96 @@
+package analyzer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/blackbox/blackboxtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMotorAnalyzerWithoutMotors(t *testing.T) {
+	_, err := NewMotorAnalyzer(blackboxtest.LogDefinition("loopIteration", "time"), DefaultMotorAnalyzerOpts())
+	assert.EqualError(t, err, "The log doesn't contain any motor field")
+}
+
+func TestMotorAnalyzerSaturation(t *testing.T) {
+	frameDef := blackboxtest.LogDefinition("loopIteration", "time", "rcCommand[3]", "motor[0]", "motor[1]")
+	analyzer, err := NewMotorAnalyzer(frameDef, DefaultMotorAnalyzerOpts())
+	assert.NoError(t, err)
+
+	motor0 := []int64{1000, 2047, 2047, 2047, 2047, 2047, 2047, 1500, 188, 188, 188, 188, 188, 188, 188, 0}
+	for i, m := range motor0 {
+		analyzer.AddFrame(testFrame(int64(i), int64(i), 1500, m, m))
+	}
+
+	assert.Equal(t, []MotorEvent{
+		{Type: MotorSaturatedHigh, Motor: 0, Start: 1 * time.Millisecond, End: 6 * time.Millisecond, Value: 2047},
+		{Type: MotorSaturatedHigh, Motor: 1, Start: 1 * time.Millisecond, End: 6 * time.Millisecond, Value: 2047},
+		{Type: MotorSaturatedLow, Motor: 0, Start: 8 * time.Millisecond, End: 14 * time.Millisecond, Value: 188},
+		{Type: MotorSaturatedLow, Motor: 1, Start: 8 * time.Millisecond, End: 14 * time.Millisecond, Value: 188},
+	}, analyzer.Events())
+}
+
+func TestMotorAnalyzerIgnoresShortSaturation(t *testing.T) {
+	frameDef := blackboxtest.LogDefinition("loopIteration", "time", "motor[0]")
+	analyzer, err := NewMotorAnalyzer(frameDef, DefaultMotorAnalyzerOpts())
+	assert.NoError(t, err)
+
+	for i, m := range []int64{1000, 2047, 2047, 1000} {
+		analyzer.AddFrame(testFrame(int64(i), int64(i), m))
+	}
+	assert.Empty(t, analyzer.Events())
+}
+
+func TestMotorAnalyzerCollapse(t *testing.T) {
+	frameDef := blackboxtest.LogDefinition("loopIteration", "time", "rcCommand[3]", "motor[0]", "motor[1]", "motor[2]", "motor[3]")
+	analyzer, err := NewMotorAnalyzer(frameDef, DefaultMotorAnalyzerOpts())
+	assert.NoError(t, err)
+
+	motor2 := []int64{1200, 1200, 1210, 900, 400, 300, 350, 1100, 1200, 1200}
+	for i, m := range motor2 {
+		analyzer.AddFrame(testFrame(int64(i), int64(i), 1500, 1200, 1200, m, 1200))
+	}
+
+	assert.Equal(t, []MotorEvent{
+		{Type: MotorCollapse, Motor: 2, Start: 2 * time.Millisecond, End: 6 * time.Millisecond, Value: 300},
+	}, analyzer.Events())
+}
+
+func TestMotorAnalyzerThrottleCutIsNotACollapse(t *testing.T) {
+	frameDef := blackboxtest.LogDefinition("loopIteration", "time", "rcCommand[3]", "motor[0]", "motor[1]")
+	analyzer, err := NewMotorAnalyzer(frameDef, DefaultMotorAnalyzerOpts())
+	assert.NoError(t, err)
+
+	for i, m := range []int64{1200, 1200, 700, 300, 300} {
+		analyzer.AddFrame(testFrame(int64(i), int64(i), 1000+m-200, m, m))
+	}
+	assert.Empty(t, analyzer.Events())
+}
+
+func TestMotorAnalyzerRPMDropout(t *testing.T) {
+	frameDef := blackboxtest.LogDefinition("loopIteration", "time", "motor[0]", "motor[1]", "eRPM[0]", "eRPM[1]")
+	analyzer, err := NewMotorAnalyzer(frameDef, DefaultMotorAnalyzerOpts())
+	assert.NoError(t, err)
+
+	erpm1 := []int64{400, 410, 405, 100, 0, 0, 380, 400}
+	for i, e := range erpm1 {
+		analyzer.AddFrame(testFrame(int64(i), int64(i), 1000, 1000, 400, e))
+	}
+
+	assert.Equal(t, []MotorEvent{
+		{Type: MotorRPMDropout, Motor: 1, Start: 3 * time.Millisecond, End: 5 * time.Millisecond, Value: 0},
+	}, analyzer.Events())
+}
+
+func TestMotorAnalyzerSkipsInvalidFrames(t *testing.T) {
+	frameDef := blackboxtest.LogDefinition("loopIteration", "time", "motor[0]")
+	analyzer, err := NewMotorAnalyzer(frameDef, DefaultMotorAnalyzerOpts())
+	assert.NoError(t, err)
+
+	for i := int64(0); i < 20; i++ {
+		analyzer.AddFrame(blackbox.NewMainFrame(blackbox.LogFrameInter, []int64{i, i * 1000, 2047}, 0, 0, nil))
+	}
+	assert.Empty(t, analyzer.Events())
+}
@@ -0,0 +1,356 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/dsp"
+	"github.com/pkg/errors"
+)
+
+// fieldBaroAlt is the altitude measured by the barometer, in cm
+const fieldBaroAlt blackbox.FieldName = "BaroAlt"
+
+// minOscillationWindowSamples is the fewest main frames a window needs to be analyzed
+const minOscillationWindowSamples = 16
+
+// OscillationEventType is the kind of oscillation found on an axis
+type OscillationEventType int
+
+// List of the oscillations the OscillationAnalyzer can find
+const (
+	// Oscillation is a burst of oscillation no throttle change or descent explains,
+	// often due to high PID gains or mechanical problems
+	Oscillation OscillationEventType = iota
+
+	// Propwash is a burst of oscillation following a throttle chop or happening while
+	// descending, when the propellers go through their own turbulent air
+	Propwash
+)
+
+var oscillationEventTypeNames = map[OscillationEventType]string{
+	Oscillation: "oscillation",
+	Propwash:    "propwash",
+}
+
+func (t OscillationEventType) String() string {
+	name, ok := oscillationEventTypeNames[t]
+	if !ok {
+		return fmt.Sprintf("unknown (%d)", t)
+	}
+	return name
+}
+
+// OscillationEvent is a time-stamped burst of oscillation of a field
+type OscillationEvent struct {
+	Type  OscillationEventType
+	Field blackbox.FieldName
+	Axis  string
+	Start time.Duration
+	End   time.Duration
+
+	// Frequency is the dominant frequency of the oscillation, in Hz
+	Frequency float64
+
+	// Amplitude is the highest root mean square of the field in the oscillation band,
+	// in the unit of the field
+	Amplitude float64
+}
+
+// Duration returns how long the event lasted
+func (e OscillationEvent) Duration() time.Duration {
+	return e.End - e.Start
+}
+
+func (e OscillationEvent) String() string {
+	return fmt.Sprintf("%s - %s  %s %s on %s, %.0fHz (%.1f RMS)", formatTime(e.Start), formatTime(e.End), e.Axis, e.Type, e.Field, e.Frequency, e.Amplitude)
+}
+
+// OscillationAnalyzerOpts holds the thresholds used by an OscillationAnalyzer
+type OscillationAnalyzerOpts struct {
+	// Window is the duration of the sliding windows the oscillation band energy is
+	// measured on. They move by a quarter of their duration.
+	Window time.Duration
+
+	// MinFrequency and MaxFrequency delimit the oscillation band, in Hz
+	MinFrequency float64
+	MaxFrequency float64
+
+	// GyroThreshold is the root mean square of the gyro in the oscillation band above
+	// which an axis is considered as oscillating, in deg/s
+	GyroThreshold float64
+
+	// DTermThreshold is the same threshold for the D-term, as logged
+	DTermThreshold float64
+
+	// ChopWindow is how far back before an oscillation a throttle chop is looked for
+	ChopWindow time.Duration
+
+	// ChopThreshold is the throttle drop, in rcCommand units, considered as a chop
+	ChopThreshold int64
+
+	// DescentRate is the vertical speed, in cm/s, above which the craft is considered
+	// as descending. It's only used if the log has the barometer altitude.
+	DescentRate float64
+}
+
+// DefaultOscillationAnalyzerOpts returns the thresholds that work for most multirotors
+func DefaultOscillationAnalyzerOpts() OscillationAnalyzerOpts {
+	return OscillationAnalyzerOpts{
+		Window:         100 * time.Millisecond,
+		MinFrequency:   20,
+		MaxFrequency:   150,
+		GyroThreshold:  10,
+		DTermThreshold: 20,
+		ChopWindow:     300 * time.Millisecond,
+		ChopThreshold:  200,
+		DescentRate:    200,
+	}
+}
+
+// oscillationSample is the state of the analyzed fields in a main frame
+type oscillationSample struct {
+	time     time.Duration
+	values   []float64
+	throttle int64
+	altitude int64
+}
+
+// OscillationAnalyzer detects bursts of oscillation of the gyro and D-term, and tells
+// propwash apart from other oscillations
+type OscillationAnalyzer struct {
+	opts         OscillationAnalyzerOpts
+	fields       []blackbox.FieldName
+	axes         []string
+	fieldIdx     []int
+	thresholds   []float64
+	timeIdx      int
+	throttleIdx  int
+	altitudeIdx  int
+	history      []oscillationSample
+	lastAnalysis time.Duration
+	current      []*OscillationEvent
+	events       []OscillationEvent
+}
+
+// NewOscillationAnalyzer returns a new OscillationAnalyzer for logs with the given definition
+func NewOscillationAnalyzer(frameDef blackbox.LogDefinition, opts OscillationAnalyzerOpts) (*OscillationAnalyzer, error) {
+	timeIdx, err := timeFieldIndex(frameDef)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &OscillationAnalyzer{
+		opts:        opts,
+		timeIdx:     timeIdx,
+		throttleIdx: optionalFieldIndex(frameDef, blackbox.FieldThrottle),
+		altitudeIdx: optionalFieldIndex(frameDef, fieldBaroAlt),
+	}
+	a.addFields(frameDef, "gyroADC", opts.GyroThreshold)
+	a.addFields(frameDef, "axisD", opts.DTermThreshold)
+	if len(a.fields) == 0 {
+		return nil, errors.New("The log doesn't contain any gyroADC or axisD field")
+	}
+	a.current = make([]*OscillationEvent, len(a.fields))
+	return a, nil
+}
+
+func (a *OscillationAnalyzer) addFields(frameDef blackbox.LogDefinition, name string, threshold float64) {
+	for axis, index := range arrayFieldIndexes(frameDef, name) {
+		if axis >= len(axisNames) {
+			return
+		}
+		a.fields = append(a.fields, blackbox.FieldName(fmt.Sprintf("%s[%d]", name, axis)))
+		a.axes = append(a.axes, axisNames[axis])
+		a.fieldIdx = append(a.fieldIdx, index)
+		a.thresholds = append(a.thresholds, threshold)
+	}
+}
+
+// AddFrame analyzes the next frame of the log
+func (a *OscillationAnalyzer) AddFrame(frame blackbox.Frame) {
+	values, ok := mainFrameValues(frame)
+	if !ok {
+		return
+	}
+
+	sample := oscillationSample{
+		time:   mainFrameTime(values, a.timeIdx),
+		values: make([]float64, len(a.fieldIdx)),
+	}
+	for i, index := range a.fieldIdx {
+		sample.values[i] = float64(values[index])
+	}
+	if a.throttleIdx >= 0 {
+		sample.throttle = values[a.throttleIdx]
+	}
+	if a.altitudeIdx >= 0 {
+		sample.altitude = values[a.altitudeIdx]
+	}
+
+	// Windows don't span over gaps in the log
+	if len(a.history) > 0 {
+		last := a.history[len(a.history)-1].time
+		if sample.time <= last || sample.time-last > maxSignalGap {
+			a.closeEvents()
+			a.history = nil
+		}
+	}
+	if len(a.history) == 0 {
+		a.lastAnalysis = sample.time
+	}
+
+	// Forget about the samples that are too old to be looked at
+	for len(a.history) > 0 && sample.time-a.history[0].time > a.opts.Window+a.opts.ChopWindow {
+		a.history = a.history[1:]
+	}
+	a.history = append(a.history, sample)
+
+	if sample.time-a.lastAnalysis >= a.opts.Window/4 && sample.time-a.history[0].time >= a.opts.Window {
+		a.lastAnalysis = sample.time
+		a.analyzeWindow()
+	}
+}
+
+// analyzeWindow measures the oscillation band energy of every field over the last window
+func (a *OscillationAnalyzer) analyzeWindow() {
+	end := a.history[len(a.history)-1].time
+	first := len(a.history) - 1
+	for first > 0 && end-a.history[first-1].time <= a.opts.Window {
+		first--
+	}
+	window := a.history[first:]
+	if len(window) < minOscillationWindowSamples {
+		return
+	}
+
+	start := window[0].time
+	sampleRate := float64(len(window)-1) / (end - start).Seconds()
+	times := make([]int64, len(window))
+	for i, sample := range window {
+		times[i] = int64(sample.time / time.Microsecond)
+	}
+
+	propwash := a.isThrottleChopped(window) || a.isDescending(window)
+	for field := range a.fields {
+		values := make([]float64, len(window))
+		for i, sample := range window {
+			values[i] = sample.values[field]
+		}
+		signal := detrend(dsp.Uniform(times, values, sampleRate))
+
+		segmentSize := dsp.NextPowerOfTwo(len(signal))
+		if segmentSize > len(signal) {
+			segmentSize /= 2
+		}
+		spectrum, err := dsp.PowerSpectrum(signal[len(signal)-segmentSize:], sampleRate, segmentSize)
+		if err != nil {
+			continue
+		}
+		amplitude := math.Sqrt(spectrum.BandPower(a.opts.MinFrequency, a.opts.MaxFrequency))
+		frequency, _ := spectrum.Peak(a.opts.MinFrequency, a.opts.MaxFrequency)
+
+		event := a.current[field]
+		if amplitude < a.thresholds[field] {
+			if event != nil {
+				a.events = append(a.events, *event)
+				a.current[field] = nil
+			}
+			continue
+		}
+
+		if event == nil {
+			event = &OscillationEvent{Type: Oscillation, Field: a.fields[field], Axis: a.axes[field], Start: start}
+			a.current[field] = event
+		}
+		event.End = end
+		if propwash {
+			event.Type = Propwash
+		}
+		if amplitude > event.Amplitude {
+			event.Amplitude = amplitude
+			event.Frequency = frequency
+		}
+	}
+}
+
+// isThrottleChopped tells if the throttle dropped shortly before or during a window
+func (a *OscillationAnalyzer) isThrottleChopped(window []oscillationSample) bool {
+	if a.throttleIdx < 0 {
+		return false
+	}
+	lowest := window[0].throttle
+	for _, sample := range window {
+		if sample.throttle < lowest {
+			lowest = sample.throttle
+		}
+	}
+	for _, sample := range a.history {
+		if sample.time >= window[0].time-a.opts.ChopWindow && sample.throttle-lowest >= a.opts.ChopThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// isDescending tells if the craft went down during a window
+func (a *OscillationAnalyzer) isDescending(window []oscillationSample) bool {
+	if a.altitudeIdx < 0 {
+		return false
+	}
+	first, last := window[0], window[len(window)-1]
+	rate := float64(first.altitude-last.altitude) / (last.time - first.time).Seconds()
+	return rate >= a.opts.DescentRate
+}
+
+// closeEvents ends the ongoing events
+func (a *OscillationAnalyzer) closeEvents() {
+	for field, event := range a.current {
+		if event != nil {
+			a.events = append(a.events, *event)
+			a.current[field] = nil
+		}
+	}
+}
+
+// Events returns all the events found so far, ordered by start time
+func (a *OscillationAnalyzer) Events() []OscillationEvent {
+	events := append([]OscillationEvent{}, a.events...)
+	for _, event := range a.current {
+		if event != nil {
+			events = append(events, *event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Start < events[j].Start
+	})
+	return events
+}
+
+// detrend removes the straight line best fitting a signal, which would otherwise leak
+// into the low frequencies of its spectrum
+func detrend(signal []float64) []float64 {
+	n := float64(len(signal))
+	if n < 2 {
+		return signal
+	}
+	meanX, meanY := (n-1)/2, 0.0
+	for _, v := range signal {
+		meanY += v / n
+	}
+	covariance, variance := 0.0, 0.0
+	for i, v := range signal {
+		covariance += (float64(i) - meanX) * (v - meanY)
+		variance += (float64(i) - meanX) * (float64(i) - meanX)
+	}
+	slope := covariance / variance
+
+	result := make([]float64, len(signal))
+	for i, v := range signal {
+		result[i] = v - meanY - slope*(float64(i)-meanX)
+	}
+	return result
+}
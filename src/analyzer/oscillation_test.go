@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox/blackboxtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOscillationAnalyzerWithoutGyro(t *testing.T) {
+	_, err := NewOscillationAnalyzer(blackboxtest.LogDefinition("loopIteration", "time"), DefaultOscillationAnalyzerOpts())
+	assert.EqualError(t, err, "The log doesn't contain any gyroADC or axisD field")
+}
+
+func TestOscillationAnalyzer(t *testing.T) {
+	frameDef := blackboxtest.LogDefinition("loopIteration", "time", "rcCommand[3]", "gyroADC[0]", "gyroADC[1]", "gyroADC[2]")
+	analyzer, err := NewOscillationAnalyzer(frameDef, DefaultOscillationAnalyzerOpts())
+	assert.NoError(t, err)
+
+	for ms := int64(0); ms < 4000; ms++ {
+		throttle := int64(1600)
+		if ms >= 2000 {
+			throttle = 1200
+		}
+
+		// Slow stick movements on every axis
+		roll := 100 * math.Sin(2*math.Pi*2*float64(ms)/1000)
+		pitch := 50 * math.Sin(2*math.Pi*1*float64(ms)/1000)
+
+		// A 50Hz oscillation on roll after the throttle chop and a 90Hz one on pitch
+		// while the throttle is steady
+		if ms >= 2100 && ms < 2500 {
+			roll += 40 * math.Sin(2*math.Pi*50*float64(ms)/1000)
+		}
+		if ms >= 3500 && ms < 3800 {
+			pitch += 40 * math.Sin(2*math.Pi*90*float64(ms)/1000)
+		}
+		analyzer.AddFrame(testFrame(ms, ms, throttle, int64(math.Round(roll)), int64(math.Round(pitch)), 0))
+	}
+
+	events := analyzer.Events()
+	assert.Len(t, events, 2)
+
+	assert.Equal(t, Propwash, events[0].Type)
+	assert.Equal(t, "roll", events[0].Axis)
+	assert.Equal(t, "gyroADC[0]", string(events[0].Field))
+	assert.InDelta(t, 2100, events[0].Start.Seconds()*1000, 100)
+	assert.InDelta(t, 2500, events[0].End.Seconds()*1000, 100)
+	assert.InDelta(t, 50, events[0].Frequency, 10)
+	assert.InDelta(t, 40/math.Sqrt2, events[0].Amplitude, 5)
+
+	assert.Equal(t, Oscillation, events[1].Type)
+	assert.Equal(t, "pitch", events[1].Axis)
+	assert.InDelta(t, 90, events[1].Frequency, 10)
+	assert.True(t, events[1].Duration() > 200*time.Millisecond)
+}
+
+func TestOscillationAnalyzerDescent(t *testing.T) {
+	frameDef := blackboxtest.LogDefinition("loopIteration", "time", "BaroAlt", "gyroADC[0]")
+	analyzer, err := NewOscillationAnalyzer(frameDef, DefaultOscillationAnalyzerOpts())
+	assert.NoError(t, err)
+
+	// Falling at 5m/s with a 40Hz oscillation
+	for ms := int64(0); ms < 1000; ms++ {
+		altitude := 5000 - ms/2
+		gyro := 30 * math.Sin(2*math.Pi*40*float64(ms)/1000)
+		analyzer.AddFrame(testFrame(ms, ms, altitude, int64(math.Round(gyro))))
+	}
+
+	events := analyzer.Events()
+	assert.Len(t, events, 1)
+	assert.Equal(t, Propwash, events[0].Type)
+	assert.Equal(t, "00:00.000 - 00:00.975  roll propwash on gyroADC[0], 47Hz (21.0 RMS)", events[0].String())
+}
+
+func TestDetrend(t *testing.T) {
+	assert.Equal(t, []float64{0, 0, 0}, detrend([]float64{1, 3, 5}))
+}
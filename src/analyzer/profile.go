@@ -0,0 +1,259 @@
+package analyzer
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/dsp"
+)
+
+const (
+	// noiseFrequency is the frequency above which gyro movements are considered as noise
+	// rather than flight
+	noiseFrequency = 100.0
+
+	// maxSignalGap is the longest time between two main frames of the same signal
+	// segment. Signals are cut where the log was paused or the craft disarmed.
+	maxSignalGap = 100 * time.Millisecond
+
+	// spectrumResolution is the approximate width of the bins of noise spectra, in Hz
+	spectrumResolution = 4.0
+)
+
+// axisNames are the names of the axes of the gyro and setpoint fields
+var axisNames = []string{"roll", "pitch", "yaw"}
+
+// FieldStatistics summarizes the values of a field
+type FieldStatistics struct {
+	Name   blackbox.FieldName `json:"name"`
+	Mean   float64            `json:"mean"`
+	StdDev float64            `json:"stdDev"`
+	Min    float64            `json:"min"`
+	Max    float64            `json:"max"`
+
+	count int
+	m2    float64
+}
+
+// add updates the statistics with Welford's algorithm
+func (s *FieldStatistics) add(value float64) {
+	if s.count == 0 {
+		s.Min, s.Max = value, value
+	}
+	s.count++
+	delta := value - s.Mean
+	s.Mean += delta / float64(s.count)
+	s.m2 += delta * (value - s.Mean)
+	s.StdDev = math.Sqrt(s.m2 / float64(s.count))
+	s.Min = math.Min(s.Min, value)
+	s.Max = math.Max(s.Max, value)
+}
+
+// AxisNoise measures the noise of the gyro on one axis
+type AxisNoise struct {
+	Axis string `json:"axis"`
+
+	// RMS is the root mean square of the gyro above 100Hz
+	RMS float64 `json:"rms"`
+
+	// PeakFrequency is the frequency above 100Hz with the most noise
+	PeakFrequency float64 `json:"peakFrequency"`
+
+	Spectrum dsp.Spectrum `json:"-"`
+}
+
+// AxisStepResponse is how the gyro follows the setpoint on one axis
+type AxisStepResponse struct {
+	Axis    string          `json:"axis"`
+	Metrics dsp.StepMetrics `json:"metrics"`
+
+	Response dsp.StepResponse `json:"-"`
+}
+
+// FlightProfile summarizes a flight, for it to be compared with another one
+type FlightProfile struct {
+	Headers []blackbox.Header `json:"-"`
+
+	// Duration is how long the craft was flown, or logged if the log doesn't tell
+	Duration   time.Duration `json:"duration"`
+	SampleRate float64       `json:"sampleRate"`
+
+	Fields        []FieldStatistics  `json:"fields"`
+	Noise         []AxisNoise        `json:"noise"`
+	StepResponses []AxisStepResponse `json:"stepResponses"`
+}
+
+// profileSegment is a part of the flight without any gap between main frames
+type profileSegment struct {
+	times    []int64
+	gyro     [][]float64
+	setpoint [][]float64
+}
+
+// FlightProfiler measures what characterizes a flight: the statistics of every field,
+// the noise of the gyro and its response to the setpoint. Only the main frames logged
+// while the craft was armed are used, when the log tells.
+type FlightProfiler struct {
+	frameDef      blackbox.LogDefinition
+	armed         blackbox.FrameFilter
+	fields        []FieldStatistics
+	fieldIdx      []int
+	timeIdx       int
+	gyroIdx       []int
+	setpointIdx   []int
+	segments      []*profileSegment
+	lastTime      time.Duration
+	duration      time.Duration
+	intervalCount map[time.Duration]int
+}
+
+// NewFlightProfiler returns a new FlightProfiler for logs with the given definition
+func NewFlightProfiler(frameDef blackbox.LogDefinition) *FlightProfiler {
+	p := &FlightProfiler{
+		frameDef:      frameDef,
+		timeIdx:       optionalFieldIndex(frameDef, blackbox.FieldTime),
+		gyroIdx:       arrayFieldIndexes(frameDef, "gyroADC"),
+		setpointIdx:   arrayFieldIndexes(frameDef, "setpoint"),
+		intervalCount: map[time.Duration]int{},
+	}
+	if armed, err := blackbox.NewArmedFilter(frameDef); err == nil {
+		p.armed = armed
+	}
+
+	for i, field := range frameDef.FieldsI {
+		if field.Name != blackbox.FieldIteration && field.Name != blackbox.FieldTime {
+			p.fields = append(p.fields, FieldStatistics{Name: field.Name})
+			p.fieldIdx = append(p.fieldIdx, i)
+		}
+	}
+	return p
+}
+
+// AddFrame profiles the next frame of the log
+func (p *FlightProfiler) AddFrame(frame blackbox.Frame) {
+	if p.armed != nil && !p.armed.Keep(frame) {
+		return
+	}
+	values, ok := mainFrameValues(frame)
+	if !ok || p.timeIdx < 0 {
+		return
+	}
+
+	for i, index := range p.fieldIdx {
+		p.fields[i].add(float64(values[index]))
+	}
+
+	t := mainFrameTime(values, p.timeIdx)
+	segment := p.currentSegment()
+	if segment == nil || t <= p.lastTime || t-p.lastTime > maxSignalGap {
+		segment = &profileSegment{
+			gyro:     make([][]float64, len(p.gyroIdx)),
+			setpoint: make([][]float64, len(p.setpointIdx)),
+		}
+		p.segments = append(p.segments, segment)
+	} else {
+		p.duration += t - p.lastTime
+		p.intervalCount[t-p.lastTime]++
+	}
+	p.lastTime = t
+
+	segment.times = append(segment.times, int64(t/time.Microsecond))
+	for axis, index := range p.gyroIdx {
+		segment.gyro[axis] = append(segment.gyro[axis], float64(values[index]))
+	}
+	for axis, index := range p.setpointIdx {
+		segment.setpoint[axis] = append(segment.setpoint[axis], float64(values[index]))
+	}
+}
+
+func (p *FlightProfiler) currentSegment() *profileSegment {
+	if len(p.segments) == 0 {
+		return nil
+	}
+	return p.segments[len(p.segments)-1]
+}
+
+// Profile returns the profile of the flight. Noise and step responses are missing for
+// the axes the log doesn't have enough data about.
+func (p *FlightProfiler) Profile() FlightProfile {
+	profile := FlightProfile{
+		Headers:    p.frameDef.Headers,
+		Duration:   p.duration,
+		SampleRate: p.sampleRate(),
+		Fields:     p.fields,
+	}
+	if profile.SampleRate == 0 {
+		return profile
+	}
+
+	for axis := 0; axis < len(axisNames) && axis < len(p.gyroIdx); axis++ {
+		gyro := p.uniformSignal(func(segment *profileSegment) []float64 { return segment.gyro[axis] }, profile.SampleRate)
+
+		segmentSize := dsp.NextPowerOfTwo(int(profile.SampleRate / spectrumResolution))
+		spectrum, err := dsp.PowerSpectrum(gyro, profile.SampleRate, segmentSize)
+		if err == nil {
+			peakFrequency, _ := spectrum.Peak(noiseFrequency, profile.SampleRate/2)
+			profile.Noise = append(profile.Noise, AxisNoise{
+				Axis:          axisNames[axis],
+				RMS:           math.Sqrt(spectrum.BandPower(noiseFrequency, profile.SampleRate/2)),
+				PeakFrequency: peakFrequency,
+				Spectrum:      spectrum,
+			})
+		}
+
+		if axis >= len(p.setpointIdx) {
+			continue
+		}
+		setpoint := p.uniformSignal(func(segment *profileSegment) []float64 { return segment.setpoint[axis] }, profile.SampleRate)
+		response, err := dsp.EstimateStepResponse(setpoint, gyro, profile.SampleRate, dsp.DefaultStepResponseOpts())
+		if err == nil {
+			profile.StepResponses = append(profile.StepResponses, AxisStepResponse{
+				Axis:     axisNames[axis],
+				Metrics:  response.Metrics(),
+				Response: response,
+			})
+		}
+	}
+	return profile
+}
+
+// sampleRate returns the rate main frames are usually logged at, which is the one
+// signals are resampled to
+func (p *FlightProfiler) sampleRate() float64 {
+	return medianSampleRate(p.intervalCount)
+}
+
+// medianSampleRate returns the rate given by the median interval between main frames,
+// as longer ones are intentionally skipped frames or lost ones
+func medianSampleRate(intervalCount map[time.Duration]int) float64 {
+	intervals := []time.Duration{}
+	total := 0
+	for interval, count := range intervalCount {
+		intervals = append(intervals, interval)
+		total += count
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i] < intervals[j]
+	})
+
+	seen := 0
+	for _, interval := range intervals {
+		seen += intervalCount[interval]
+		if seen*2 >= total {
+			return float64(time.Second) / float64(interval)
+		}
+	}
+	return 0
+}
+
+// uniformSignal returns the values of a field at a uniform rate, with all the segments
+// of the flight put end to end
+func (p *FlightProfiler) uniformSignal(values func(*profileSegment) []float64, sampleRate float64) []float64 {
+	signal := []float64{}
+	for _, segment := range p.segments {
+		signal = append(signal, dsp.Uniform(segment.times, values(segment), sampleRate)...)
+	}
+	return signal
+}
@@ -0,0 +1,88 @@
+package analyzer
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/blackbox/blackboxtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlightProfiler(t *testing.T) {
+	profiler := NewFlightProfiler(testProfileLogDefinition())
+	feedProfileFrames(profiler, 0, 4000, 20, 300)
+
+	profile := profiler.Profile()
+	assert.InDelta(t, 1000, profile.SampleRate, 0.001)
+	assert.Equal(t, 3999*time.Millisecond, profile.Duration)
+
+	assert.Len(t, profile.Fields, 6)
+	assert.Equal(t, "gyroADC[0]", string(profile.Fields[0].Name))
+	assert.Equal(t, "setpoint[0]", string(profile.Fields[3].Name))
+	assert.InDelta(t, profile.Fields[3].Mean, profile.Fields[0].Mean, 5)
+
+	assert.Len(t, profile.Noise, 3)
+	for _, noise := range profile.Noise {
+		assert.InDelta(t, 300, noise.PeakFrequency, spectrumResolution, noise.Axis)
+		assert.InDelta(t, 20/math.Sqrt2, noise.RMS, 1, noise.Axis)
+	}
+
+	assert.Len(t, profile.StepResponses, 3)
+	for _, response := range profile.StepResponses {
+		assert.InDelta(t, 1, response.Metrics.Steady, 0.1, response.Axis)
+		assert.InDelta(t, 44, response.Metrics.RiseTime.Seconds()*1000, 10, response.Axis)
+	}
+}
+
+func TestFieldStatistics(t *testing.T) {
+	statistics := FieldStatistics{}
+	for _, v := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		statistics.add(v)
+	}
+	assert.Equal(t, 5.0, statistics.Mean)
+	assert.Equal(t, 2.0, statistics.StdDev)
+	assert.Equal(t, 2.0, statistics.Min)
+	assert.Equal(t, 9.0, statistics.Max)
+}
+
+func TestFlightProfilerGap(t *testing.T) {
+	profiler := NewFlightProfiler(testProfileLogDefinition())
+	feedProfileFrames(profiler, 0, 2000, 0, 0)
+	feedProfileFrames(profiler, 5000, 2000, 0, 0)
+
+	profile := profiler.Profile()
+	assert.Equal(t, 3998*time.Millisecond, profile.Duration)
+	assert.Len(t, profiler.segments, 2)
+	assert.Len(t, profile.StepResponses, 3)
+}
+
+func TestFlightProfilerWithoutFrames(t *testing.T) {
+	profile := NewFlightProfiler(testProfileLogDefinition()).Profile()
+	assert.Equal(t, 0.0, profile.SampleRate)
+	assert.Empty(t, profile.Noise)
+	assert.Empty(t, profile.StepResponses)
+}
+
+func testProfileLogDefinition() blackbox.LogDefinition {
+	return blackboxtest.LogDefinition("loopIteration", "time", "gyroADC[0]", "gyroADC[1]", "gyroADC[2]", "setpoint[0]", "setpoint[1]", "setpoint[2]")
+}
+
+// feedProfileFrames adds frames at 1kHz where the setpoint jumps to random values about
+// 10 times per second, the gyro follows it with a time constant of 20ms and has a sine
+// noise of a given amplitude and frequency
+func feedProfileFrames(profiler *FlightProfiler, startMs, durationMs int64, noise, noiseFrequency float64) {
+	random := rand.New(rand.NewSource(1))
+	setpoint, gyro := 0.0, 0.0
+	for ms := int64(0); ms < durationMs; ms++ {
+		if random.Float64() < 0.01 {
+			setpoint = math.Round(random.Float64()*600 - 300)
+		}
+		gyro += (setpoint - gyro) * (1 - math.Exp(-1.0/20))
+
+		measured := int64(math.Round(gyro + noise*math.Sin(2*math.Pi*noiseFrequency*float64(ms)/1000)))
+		profiler.AddFrame(testFrame(startMs+ms, startMs+ms, measured, measured, measured, int64(setpoint), int64(setpoint), int64(setpoint)))
+	}
+}
@@ -0,0 +1,25 @@
+// Package blackboxtest provides utilities for testing the packages working on flight logs
+package blackboxtest
+
+import (
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+)
+
+// LogDefinition returns the definition of a log whose main frames have the given fields,
+// with the motor range of a Betaflight craft. Tests needing other settings change its
+// Sysconfig.
+func LogDefinition(fields ...string) blackbox.LogDefinition {
+	frameDef := blackbox.LogDefinition{
+		FieldIRL: map[blackbox.FieldName]int{},
+		Sysconfig: blackbox.SysconfigType{
+			MotorOutputLow:  188,
+			MotorOutputHigh: 2047,
+		},
+	}
+	for i, field := range fields {
+		frameDef.FieldsI = append(frameDef.FieldsI, blackbox.FieldDefinition{Name: blackbox.FieldName(field)})
+		frameDef.FieldsP = append(frameDef.FieldsP, blackbox.FieldDefinition{Name: blackbox.FieldName(field)})
+		frameDef.FieldIRL[blackbox.FieldName(field)] = i
+	}
+	return frameDef
+}
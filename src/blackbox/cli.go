@@ -0,0 +1,194 @@
+package blackbox
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CLISetting is a variable of the command line interface of a flight controller, set
+// from a header of a log
+type CLISetting struct {
+	Name   string
+	Value  string
+	Header HeaderName
+}
+
+func (s CLISetting) String() string {
+	return fmt.Sprintf("set %s = %s", s.Name, s.Value)
+}
+
+// cliVariable tells how a header maps to CLI variables. Headers holding several values,
+// like rollPID, have one variable per value.
+type cliVariable struct {
+	names []string
+
+	// lookup holds the names of the values of a variable set by name rather than by
+	// number, indexed by the number written in the header
+	lookup []string
+}
+
+// Value names of the lookup variables of Betaflight
+var (
+	betaflightLowpassTypes    = []string{"PT1", "BIQUAD", "PT2", "PT3"}
+	betaflightOffOn           = []string{"OFF", "ON"}
+	betaflightItermRelax      = []string{"OFF", "RP", "RPY", "RP_INC", "RPY_INC"}
+	betaflightItermRelaxTypes = []string{"GYRO", "SETPOINT"}
+	betaflightAntiGravityMode = []string{"SMOOTH", "STEP"}
+	betaflightDynNotchRanges  = []string{"HIGH", "MEDIUM", "LOW", "AUTO"}
+	betaflightRcSmoothing     = []string{"INTERPOLATION", "FILTER"}
+)
+
+// betaflightSameNameHeaders are written by Betaflight with the name of their CLI variable
+var betaflightSameNameHeaders = []HeaderName{
+	"thr_mid", "thr_expo", "tpa_rate", "tpa_breakpoint", "deadband", "yaw_deadband",
+	"gyro_sync_denom", "pid_process_denom", "motor_pwm_rate", "dshot_idle_value", "vbat_scale",
+	"gyro_lowpass_hz", "gyro_lowpass2_hz", "yaw_lowpass_hz", "dterm_notch_hz", "dterm_notch_cutoff",
+	"gyro_lpf1_static_hz", "gyro_lpf2_static_hz", "dterm_lpf1_static_hz", "dterm_lpf2_static_hz",
+	"dyn_notch_width_percent", "dyn_notch_q", "dyn_notch_min_hz", "dyn_notch_max_hz", "dyn_notch_count",
+	"rpm_filter_harmonics", "rpm_filter_q", "rpm_filter_min_hz", "rpm_filter_fade_range_hz",
+	"rpm_filter_lpf_hz", "motor_poles", "dyn_idle_min_rpm", "dyn_idle_p_gain", "dyn_idle_i_gain",
+	"dyn_idle_d_gain", "dyn_idle_max_increase", "d_min_gain", "d_min_advance", "iterm_windup",
+	"iterm_relax_cutoff", "iterm_limit", "anti_gravity_gain", "anti_gravity_threshold",
+	"anti_gravity_cutoff_hz", "anti_gravity_p_gain", "abs_control_gain", "feedforward_transition",
+	"feedforward_averaging", "feedforward_smooth_factor", "feedforward_jitter_factor",
+	"feedforward_boost", "feedforward_max_rate_limit", "pidsum_limit", "pidsum_limit_yaw",
+	"acc_limit_yaw", "acc_limit", "throttle_boost", "throttle_boost_cutoff", "thrust_linear",
+	"motor_output_limit", "vbat_sag_compensation", "tpa_mode", "simplified_pids_mode",
+	"simplified_master_multiplier", "simplified_i_gain", "simplified_d_gain", "simplified_pi_gain",
+	"simplified_dmax_gain", "simplified_feedforward_gain", "simplified_pitch_d_gain",
+	"simplified_pitch_pi_gain", "simplified_dterm_filter", "simplified_dterm_filter_multiplier",
+	"simplified_gyro_filter", "simplified_gyro_filter_multiplier", "rc_smoothing_auto_factor",
+	"rc_smoothing_setpoint_cutoff", "rc_smoothing_feedforward_cutoff", "rc_smoothing_throttle_cutoff",
+}
+
+// betaflightVariables maps the headers written by Betaflight to its CLI variables. Some
+// headers were renamed over the versions, both names are listed.
+var betaflightVariables = map[HeaderName]cliVariable{
+	HeaderMinThrottle:     {names: []string{"min_throttle"}},
+	HeaderMaxThrottle:     {names: []string{"max_throttle"}},
+	HeaderVbatcellvoltage: {names: []string{"vbat_min_cell_voltage", "vbat_warning_cell_voltage", "vbat_max_cell_voltage"}},
+	"currentSensor":       {names: []string{"ibata_offset", "ibata_scale"}},
+
+	// PIDs
+	"rollPID":   {names: []string{"p_roll", "i_roll", "d_roll"}},
+	"pitchPID":  {names: []string{"p_pitch", "i_pitch", "d_pitch"}},
+	"yawPID":    {names: []string{"p_yaw", "i_yaw", "d_yaw"}},
+	"levelPID":  {names: []string{"p_level", "i_level", "d_level"}},
+	"d_min":     {names: []string{"d_min_roll", "d_min_pitch", "d_min_yaw"}},
+	"ff_weight": {names: []string{"f_roll", "f_pitch", "f_yaw"}},
+
+	"iterm_relax":        {names: []string{"iterm_relax"}, lookup: betaflightItermRelax},
+	"iterm_relax_type":   {names: []string{"iterm_relax_type"}, lookup: betaflightItermRelaxTypes},
+	"anti_gravity_mode":  {names: []string{"anti_gravity_mode"}, lookup: betaflightAntiGravityMode},
+	"use_integrated_yaw": {names: []string{"use_integrated_yaw"}, lookup: betaflightOffOn},
+
+	// Rates
+	"rc_rates":    {names: []string{"roll_rc_rate", "pitch_rc_rate", "yaw_rc_rate"}},
+	"rc_expo":     {names: []string{"roll_expo", "pitch_expo", "yaw_expo"}},
+	"rates":       {names: []string{"roll_srate", "pitch_srate", "yaw_srate"}},
+	"rate_limits": {names: []string{"roll_rate_limit", "pitch_rate_limit", "yaw_rate_limit"}},
+
+	// Filters, up to Betaflight 4.2
+	"gyro_lowpass_type":   {names: []string{"gyro_lowpass_type"}, lookup: betaflightLowpassTypes},
+	"gyro_lowpass2_type":  {names: []string{"gyro_lowpass2_type"}, lookup: betaflightLowpassTypes},
+	"gyro_lowpass_dyn_hz": {names: []string{"dyn_lpf_gyro_min_hz", "dyn_lpf_gyro_max_hz"}},
+	"dterm_lpf_hz":        {names: []string{"dterm_lowpass_hz"}},
+	"dterm_lpf2_hz":       {names: []string{"dterm_lowpass2_hz"}},
+	"dterm_filter_type":   {names: []string{"dterm_lowpass_type"}, lookup: betaflightLowpassTypes},
+	"dterm_filter2_type":  {names: []string{"dterm_lowpass2_type"}, lookup: betaflightLowpassTypes},
+	"dterm_lpf_dyn_hz":    {names: []string{"dyn_lpf_dterm_min_hz", "dyn_lpf_dterm_max_hz"}},
+	"gyro_notch_hz":       {names: []string{"gyro_notch1_hz", "gyro_notch2_hz"}},
+	"gyro_notch_cutoff":   {names: []string{"gyro_notch1_cutoff", "gyro_notch2_cutoff"}},
+	"dyn_notch_range":     {names: []string{"dyn_notch_range"}, lookup: betaflightDynNotchRanges},
+	"rc_smoothing_type":   {names: []string{"rc_smoothing_type"}, lookup: betaflightRcSmoothing},
+
+	// Filters, from Betaflight 4.3
+	"gyro_lpf1_type":    {names: []string{"gyro_lpf1_type"}, lookup: betaflightLowpassTypes},
+	"gyro_lpf2_type":    {names: []string{"gyro_lpf2_type"}, lookup: betaflightLowpassTypes},
+	"gyro_lpf1_dyn_hz":  {names: []string{"gyro_lpf1_dyn_min_hz", "gyro_lpf1_dyn_max_hz"}},
+	"dterm_lpf1_type":   {names: []string{"dterm_lpf1_type"}, lookup: betaflightLowpassTypes},
+	"dterm_lpf2_type":   {names: []string{"dterm_lpf2_type"}, lookup: betaflightLowpassTypes},
+	"dterm_lpf1_dyn_hz": {names: []string{"dterm_lpf1_dyn_min_hz", "dterm_lpf1_dyn_max_hz"}},
+}
+
+func init() {
+	for _, name := range betaflightSameNameHeaders {
+		betaflightVariables[name] = cliVariable{names: []string{string(name)}}
+	}
+}
+
+// BetaflightSettings returns the Betaflight CLI settings restoring the configuration
+// recorded in the headers of a log, in their order, and the headers that couldn't be
+// converted. Most headers describing the log rather than the configuration, like its
+// fields, aren't part of either.
+func BetaflightSettings(frameDef LogDefinition) ([]CLISetting, []Header, error) {
+	if frameDef.Firmware.Product != "Betaflight" {
+		return nil, nil, errors.Errorf("Only Betaflight settings can be converted, the log was written by %s", frameDef.Firmware)
+	}
+
+	settings := []CLISetting{}
+	unmapped := []Header{}
+	for _, header := range frameDef.Headers {
+		if isLogDescriptionHeader(header.Name) {
+			continue
+		}
+
+		variable, ok := betaflightVariables[header.Name]
+		if !ok {
+			unmapped = append(unmapped, header)
+			continue
+		}
+
+		headerSettings, err := variable.settings(header)
+		if err != nil {
+			unmapped = append(unmapped, header)
+			continue
+		}
+		settings = append(settings, headerSettings...)
+	}
+
+	if frameDef.DebugMode.Name != "" {
+		settings = append(settings, CLISetting{Name: "debug_mode", Value: frameDef.DebugMode.Name, Header: HeaderDebugMode})
+	}
+	return settings, unmapped, nil
+}
+
+// settings returns the settings of the values of a header
+func (v cliVariable) settings(header Header) ([]CLISetting, error) {
+	values := strings.Split(header.Value, ",")
+	if len(values) != len(v.names) {
+		return nil, errors.Errorf("Header '%s' has %d values instead of %d", header.Name, len(values), len(v.names))
+	}
+
+	settings := []CLISetting{}
+	for i, value := range values {
+		value = strings.TrimSpace(value)
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, errors.Errorf("Header '%s' has a value '%s' which is not a number", header.Name, value)
+		}
+
+		if v.lookup != nil {
+			if number < 0 || number >= len(v.lookup) {
+				return nil, errors.Errorf("Header '%s' has an unknown value %d", header.Name, number)
+			}
+			value = v.lookup[number]
+		}
+		settings = append(settings, CLISetting{Name: v.names[i], Value: value, Header: header.Name})
+	}
+	return settings, nil
+}
+
+// isLogDescriptionHeader tells if a header describes the log or the firmware, rather
+// than a setting of the flight controller
+func isLogDescriptionHeader(name HeaderName) bool {
+	switch name {
+	case HeaderProduct, HeaderDataVersion, HeaderFirmwareType, HeaderFirmwareRevision, HeaderFirmwareDate,
+		HeaderLogStartDatetime, HeaderCraftName, HeaderIInterval, HeaderPInterval, HeaderDebugMode:
+		return true
+	}
+	return strings.HasPrefix(string(name), "Field ")
+}
@@ -0,0 +1,62 @@
+package blackbox
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBetaflightSettings(t *testing.T) {
+	frameDef := LogDefinition{
+		Firmware: FirmwareVersion{Product: "Betaflight", Major: 4, Minor: 0},
+		Headers: []Header{
+			{Name: HeaderFirmwareRevision, Value: "Betaflight 4.0.0 (173e958da) MATEKF405"},
+			{Name: HeaderIName, Value: "loopIteration,time"},
+			{Name: "rollPID", Value: "45,80,40"},
+			{Name: "gyro_lowpass_hz", Value: "200"},
+			{Name: "dterm_lpf_hz", Value: "100"},
+			{Name: "dterm_filter_type", Value: "1"},
+			{Name: "iterm_relax", Value: "7"},
+			{Name: "rc_rates", Value: "100,100"},
+			{Name: "gyro_scale", Value: "0x3f800000"},
+		},
+		DebugMode: DebugMode{Name: "GYRO_SCALED"},
+	}
+
+	settings, unmapped, err := BetaflightSettings(frameDef)
+	assert.NoError(t, err)
+	assert.Equal(t, []CLISetting{
+		{Name: "p_roll", Value: "45", Header: "rollPID"},
+		{Name: "i_roll", Value: "80", Header: "rollPID"},
+		{Name: "d_roll", Value: "40", Header: "rollPID"},
+		{Name: "gyro_lowpass_hz", Value: "200", Header: "gyro_lowpass_hz"},
+		{Name: "dterm_lowpass_hz", Value: "100", Header: "dterm_lpf_hz"},
+		{Name: "dterm_lowpass_type", Value: "BIQUAD", Header: "dterm_filter_type"},
+		{Name: "debug_mode", Value: "GYRO_SCALED", Header: HeaderDebugMode},
+	}, settings)
+	assert.Equal(t, []Header{
+		{Name: "iterm_relax", Value: "7"},
+		{Name: "rc_rates", Value: "100,100"},
+		{Name: "gyro_scale", Value: "0x3f800000"},
+	}, unmapped)
+	assert.Equal(t, "set p_roll = 45", settings[0].String())
+}
+
+func TestBetaflightSettingsFromLog(t *testing.T) {
+	headerReader := NewHeaderReader(stream.NewDecoder(bytes.NewReader(readFixture(t))))
+	frameDef, err := headerReader.ProcessHeaders()
+	assert.NoError(t, err)
+
+	settings, _, err := BetaflightSettings(frameDef)
+	assert.NoError(t, err)
+	assert.Contains(t, settings, CLISetting{Name: "min_throttle", Value: "1070", Header: HeaderMinThrottle})
+	assert.Contains(t, settings, CLISetting{Name: "vbat_warning_cell_voltage", Value: "350", Header: HeaderVbatcellvoltage})
+	assert.Contains(t, settings, CLISetting{Name: "ibata_scale", Value: "282", Header: "currentSensor"})
+}
+
+func TestBetaflightSettingsOtherFirmware(t *testing.T) {
+	_, _, err := BetaflightSettings(LogDefinition{Firmware: FirmwareVersion{Product: "INAV", Major: 2, Minor: 6, Patch: 1}})
+	assert.EqualError(t, err, "Only Betaflight settings can be converted, the log was written by INAV 2.6.1")
+}
@@ -0,0 +1,201 @@
+package blackbox
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// DebugSlot describes what one of the debug fields holds
+type DebugSlot struct {
+	// Alias is the name analysis code can use instead of debug[n]
+	Alias FieldName
+
+	// Unit is the unit of the values, or empty if they are raw values
+	Unit string
+}
+
+// DebugMode describes what the debug fields hold, according to the debug_mode header
+type DebugMode struct {
+	// Name is the name the firmware gives to the mode, like GYRO_SCALED, or empty if unknown
+	Name string
+
+	// Slots describes the debug fields, starting with debug[0]. Fields without description are left out.
+	Slots []DebugSlot
+}
+
+// Slot returns the description of a debug field
+func (m DebugMode) Slot(index int) (DebugSlot, bool) {
+	if index < 0 || index >= len(m.Slots) || m.Slots[index].Alias == "" {
+		return DebugSlot{}, false
+	}
+	return m.Slots[index], true
+}
+
+// fieldOf returns the debug field behind an alias
+func (m DebugMode) fieldOf(alias FieldName) (FieldName, bool) {
+	for i, slot := range m.Slots {
+		if slot.Alias != "" && slot.Alias == alias {
+			return debugFieldName(i), true
+		}
+	}
+	return "", false
+}
+
+func debugFieldName(index int) FieldName {
+	return FieldName(fmt.Sprintf("debug[%d]", index))
+}
+
+// debugSlotIndex returns the index of a debug field, or -1 if the field is not one of them
+func debugSlotIndex(fieldName FieldName) int {
+	name := string(fieldName)
+	if len(name) < len("debug[0]") || name[:6] != "debug[" || name[len(name)-1] != ']' {
+		return -1
+	}
+	index, err := strconv.Atoi(name[6 : len(name)-1])
+	if err != nil {
+		return -1
+	}
+	return index
+}
+
+// debugSlots lists, for each debug mode name, what the debug fields hold.
+// See https://github.com/betaflight/blackbox-log-viewer/blob/master/js/flightlog_fielddefs.js
+var debugSlots = map[string][]DebugSlot{
+	"CYCLETIME": {
+		{"cycleTime", "us"},
+		{"cpuLoad", "%"},
+	},
+	"BATTERY": {
+		{"vbatADC", ""},
+		{"vbatFiltered", ""},
+	},
+	"GYRO_FILTERED": gyroDebugSlots("gyroFiltered", "deg/s"),
+	"GYRO_SCALED":   gyroDebugSlots("gyroScaled", "deg/s"),
+	"GYRO_RAW":      gyroDebugSlots("gyroRaw", ""),
+	"ACCELEROMETER": gyroDebugSlots("accRaw", ""),
+	"RC_INTERPOLATION": {
+		{"rcCommandRaw[0]", ""},
+		{"rxRefreshRate", "us"},
+		{"rcInterpolationStep", ""},
+		{"rcSetpoint[0]", "deg/s"},
+	},
+	"ESC_SENSOR_RPM": motorDebugSlots("escRpm", "rpm"),
+	"ESC_SENSOR_TMP": motorDebugSlots("escTemperature", "C"),
+	"FFT_FREQ": {
+		{"dynNotchCenter[0]", "Hz"},
+		{"dynNotchCenter[1]", "Hz"},
+		{"dynNotchCenter[2]", "Hz"},
+		{"gyroPreDynNotch[0]", "deg/s"},
+	},
+	"ITERM_RELAX": {
+		{"setpointHighPass[0]", "deg/s"},
+		{"itermRelaxFactor[0]", "%"},
+		{"itermRelaxError[0]", "deg/s"},
+		{"absoluteControlError[0]", "deg/s"},
+	},
+	"ANTI_GRAVITY": {
+		{"antiGravityThrottleHighPass", ""},
+		{"antiGravityGain", ""},
+	},
+	"DYN_LPF": {
+		{"gyroPreDynLPF[0]", "deg/s"},
+		{"gyroDynLPFCutoff", "Hz"},
+		{"dtermPreDynLPF[0]", ""},
+		{"dtermDynLPFCutoff", "Hz"},
+	},
+	"DSHOT_RPM_TELEMETRY": motorDebugSlots("dshotErpm", "erpm/100"),
+	"RPM_FILTER":          motorDebugSlots("rpmFilterFrequency", "Hz"),
+	"D_MIN": {
+		{"dMinGyroFactor", ""},
+		{"dMinSetpointFactor", ""},
+		{"dMin[0]", ""},
+		{"dMin[1]", ""},
+	},
+	"FF_INTERPOLATED": {
+		{"feedforwardSetpointDelta[0]", ""},
+		{"feedforwardBoost[0]", ""},
+		{"feedforwardBoostClipped[0]", ""},
+		{"feedforwardClip[0]", ""},
+	},
+	"FEEDFORWARD": {
+		{"feedforwardSetpoint[0]", "deg/s"},
+		{"feedforwardDelta[0]", ""},
+		{"feedforwardBoost[0]", ""},
+		{"rcCommandDelta[0]", ""},
+	},
+}
+
+// gyroDebugSlots describes debug fields holding a value for each axis
+func gyroDebugSlots(alias, unit string) []DebugSlot {
+	slots := make([]DebugSlot, 3)
+	for i := range slots {
+		slots[i] = DebugSlot{FieldName(fmt.Sprintf("%s[%d]", alias, i)), unit}
+	}
+	return slots
+}
+
+// motorDebugSlots describes debug fields holding a value for each of the first four motors
+func motorDebugSlots(alias, unit string) []DebugSlot {
+	slots := make([]DebugSlot, 4)
+	for i := range slots {
+		slots[i] = DebugSlot{FieldName(fmt.Sprintf("%s[%d]", alias, i)), unit}
+	}
+	return slots
+}
+
+// debugModeTable lists the names of the debug modes, in the order of the values of the debug_mode header
+type debugModeTable []string
+
+// betaflight3DebugModes are the debug modes of Betaflight 3
+var betaflight3DebugModes = debugModeTable{
+	"NONE", "CYCLETIME", "BATTERY", "GYRO_FILTERED", "ACCELEROMETER", "PIDLOOP", "GYRO_SCALED",
+	"RC_INTERPOLATION", "ANGLERATE", "ESC_SENSOR", "SCHEDULER", "STACK", "ESC_SENSOR_RPM", "ESC_SENSOR_TMP",
+	"ALTITUDE", "FFT", "FFT_TIME", "FFT_FREQ", "RX_FRSKY_SPI", "GYRO_RAW", "DUAL_GYRO", "DUAL_GYRO_RAW",
+	"DUAL_GYRO_COMBINE", "DUAL_GYRO_DIFF", "MAX7456_SIGNAL", "MAX7456_SPICLOCK", "SBUS", "FPORT",
+	"RANGEFINDER", "RANGEFINDER_QUALITY", "LIDAR_TF", "CORE_TEMP", "RUNAWAY_TAKEOFF", "SDIO",
+	"CURRENT_SENSOR", "USB", "SMARTAUDIO", "RTH", "ITERM_RELAX", "ACRO_TRAINER", "RC_SMOOTHING",
+	"RX_SIGNAL_LOSS", "RC_SMOOTHING_RATE", "ANTI_GRAVITY",
+}
+
+// betaflight4DebugModes are the debug modes of Betaflight 4.0 to 4.2. Each version appends its own.
+var betaflight4DebugModes = debugModeTable{
+	"NONE", "CYCLETIME", "BATTERY", "GYRO_FILTERED", "ACCELEROMETER", "PIDLOOP", "GYRO_SCALED",
+	"RC_INTERPOLATION", "ANGLERATE", "ESC_SENSOR", "SCHEDULER", "STACK", "ESC_SENSOR_RPM", "ESC_SENSOR_TMP",
+	"ALTITUDE", "FFT", "FFT_TIME", "FFT_FREQ", "RX_FRSKY_SPI", "RX_SFHSS_SPI", "GYRO_RAW", "DUAL_GYRO",
+	"DUAL_GYRO_RAW", "DUAL_GYRO_COMBINE", "DUAL_GYRO_DIFF", "MAX7456_SIGNAL", "MAX7456_SPICLOCK", "SBUS",
+	"FPORT", "RANGEFINDER", "RANGEFINDER_QUALITY", "LIDAR_TF", "ADC_INTERNAL", "RUNAWAY_TAKEOFF", "SDIO",
+	"CURRENT_SENSOR", "USB", "SMARTAUDIO", "RTH", "ITERM_RELAX", "ACRO_TRAINER", "RC_SMOOTHING",
+	"RX_SIGNAL_LOSS", "RC_SMOOTHING_RATE", "ANTI_GRAVITY", "DYN_LPF", "RX_SPEKTRUM_SPI",
+	"DSHOT_RPM_TELEMETRY", "RPM_FILTER", "D_MIN", "AC_CORRECTION", "AC_ERROR", "DUAL_GYRO_SCALED",
+	"DSHOT_RPM_ERRORS", "CRSF_LINK_STATISTICS_UPLINK", "CRSF_LINK_STATISTICS_PWR",
+	"CRSF_LINK_STATISTICS_DOWN", "BARO", "GPS_RESCUE_THROTTLE_PID", "DYN_IDLE", "FF_LIMIT",
+	"FF_INTERPOLATED", "BLACKBOX_OUTPUT", "GYRO_SAMPLE", "RX_TIMING",
+}
+
+// betaflight43DebugModes are the debug modes of Betaflight 4.3 and later, which renamed the feedforward ones
+var betaflight43DebugModes = append(append(debugModeTable{}, betaflight4DebugModes[:60]...),
+	"FEEDFORWARD_LIMIT", "FEEDFORWARD", "BLACKBOX_OUTPUT", "GYRO_SAMPLE", "RX_TIMING", "D_LPF", "VTX_TRAMP",
+	"GHST", "GHST_MSP", "SCHEDULER_DETERMINISM", "TIMING_ACCURACY", "RX_EXPRESSLRS_SPI",
+	"RX_EXPRESSLRS_PHASELOCK", "RX_STATE_TIME", "GPS_RESCUE_VELOCITY", "GPS_RESCUE_HEADING",
+	"GPS_RESCUE_TRACKING", "ATTITUDE", "VTX_MSP", "GPS_DOP",
+)
+
+// inavDebugModes are the debug modes of INAV
+var inavDebugModes = debugModeTable{
+	"NONE", "GYRO", "AGL", "FLOW_RAW", "FLOW", "SBUS", "FPORT", "ALWAYS", "SAG_COMP_VOLTAGE", "VIBE",
+	"CRUISE", "REM_FLIGHT_TIME", "SMARTAUDIO", "ACC", "ITERM_RELAX", "ERPM", "RPM_FILTER", "RPM_FREQ",
+	"NAV_YAW", "DYNAMIC_FILTER", "DYNAMIC_FILTER_FREQUENCY", "IRLOCK", "CD", "KALMAN_GAIN",
+	"PID_MEASUREMENT", "SPM_CELLS", "SPM_VS600", "SPM_VARIO", "PCF8574", "DYNAMIC_GYRO_LPF", "FW_D",
+	"IMU2", "ALTITUDE", "SMITH_COMPENSATOR", "AUTOTRIM", "AUTOTUNE", "RATE_DYNAMICS",
+}
+
+// decode returns the debug mode for a value of the debug_mode header
+func (t debugModeTable) decode(value int) DebugMode {
+	if value < 0 || value >= len(t) {
+		return DebugMode{}
+	}
+	return DebugMode{
+		Name:  t[value],
+		Slots: debugSlots[t[value]],
+	}
+}
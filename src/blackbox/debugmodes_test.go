@@ -0,0 +1,79 @@
+package blackbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugModeFromHeaders(t *testing.T) {
+	frameDef := readHeaders(t, []string{
+		"H Product:Blackbox flight data recorder by Nicholas Sherlock",
+		"H Data version:2",
+		"H Firmware type:Cleanflight",
+		"H Firmware revision:Betaflight 4.0.0 (173e958da) MATEKF405",
+		"H Field I name:loopIteration,time,debug[0],debug[1],debug[2],debug[3]",
+		"H debug_mode:6",
+	})
+	assert.Equal(t, "GYRO_SCALED", frameDef.DebugMode.Name)
+
+	index, err := frameDef.GetFieldIndex("gyroScaled[1]")
+	assert.NoError(t, err)
+	assert.Equal(t, FieldName("debug[1]"), frameDef.FieldsI[index].Name)
+
+	slot, ok := frameDef.DebugSlot("debug[2]")
+	assert.True(t, ok)
+	assert.Equal(t, DebugSlot{"gyroScaled[2]", "deg/s"}, slot)
+
+	_, ok = frameDef.DebugSlot("debug[3]")
+	assert.False(t, ok)
+	_, ok = frameDef.DebugSlot("time")
+	assert.False(t, ok)
+}
+
+func TestDebugModeByVersion(t *testing.T) {
+	tests := []struct {
+		revision  string
+		debugMode int
+		expected  string
+	}{
+		{"Betaflight 3.5.7 (8f2d21460) OMNIBUSF4SD", 6, "GYRO_SCALED"},
+		{"Betaflight 3.5.7 (8f2d21460) OMNIBUSF4SD", 38, "ITERM_RELAX"},
+		{"Betaflight 4.0.0 (173e958da) MATEKF405", 48, "RPM_FILTER"},
+		{"Betaflight 4.2.9 (173e958da) MATEKF405", 61, "FF_INTERPOLATED"},
+		{"Betaflight 4.3.0 (173e958da) MATEKF405", 61, "FEEDFORWARD"},
+		{"INAV 2.6.1 (4bf4d6d6) MATEKF405SE", 16, "RPM_FILTER"},
+		{"Betaflight 4.0.0 (173e958da) MATEKF405", 500, ""},
+		{"", 6, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.revision, func(t *testing.T) {
+			rules := rulesFor(ParseFirmwareVersion("Cleanflight", test.revision, ""), 2)
+			assert.Equal(t, test.expected, rules.DebugMode(test.debugMode).Name)
+		})
+	}
+}
+
+func TestDebugModeSlots(t *testing.T) {
+	mode := rulesFor(FirmwareVersion{Product: "Betaflight", Major: 4, Minor: 1}, 2).DebugMode(48)
+
+	slot, ok := mode.Slot(3)
+	assert.True(t, ok)
+	assert.Equal(t, DebugSlot{"rpmFilterFrequency[3]", "Hz"}, slot)
+
+	_, ok = mode.Slot(4)
+	assert.False(t, ok)
+
+	field, ok := mode.fieldOf("rpmFilterFrequency[0]")
+	assert.True(t, ok)
+	assert.Equal(t, FieldName("debug[0]"), field)
+}
+
+func TestDebugSlotIndex(t *testing.T) {
+	assert.Equal(t, 0, debugSlotIndex("debug[0]"))
+	assert.Equal(t, 7, debugSlotIndex("debug[7]"))
+	assert.Equal(t, -1, debugSlotIndex("debug"))
+	assert.Equal(t, -1, debugSlotIndex("debug[x]"))
+	assert.Equal(t, -1, debugSlotIndex("motor[0]"))
+}
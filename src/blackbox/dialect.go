@@ -0,0 +1,123 @@
+package blackbox
+
+import (
+	"strings"
+)
+
+// FirmwareDialect is a family of firmwares writing the same flavour of the log format
+type FirmwareDialect int
+
+// List of the supported dialects
+const (
+	// DialectCleanflight is written by Cleanflight, Betaflight and the firmwares based on them
+	DialectCleanflight FirmwareDialect = iota
+
+	// DialectINAV is written by INAV. It adds an encoding and names some fields differently.
+	DialectINAV
+)
+
+// dialectRules lists what a dialect brings on top of the common log format
+type dialectRules struct {
+	name         string
+	encodings    map[int64]bool
+	events       map[LogEventType]bool
+	fieldAliases map[FieldName]FieldName
+}
+
+var commonEncodings = []int64{
+	EncodingSignedVB,
+	EncodingUnsignedVB,
+	EncodingNeg14Bits,
+	EncodingTag8_8SVB,
+	EncodingTag2_3S32,
+	EncodingTag8_4S16,
+	EncodingNull,
+}
+
+var commonEvents = []LogEventType{
+	LogEventSyncBeep,
+	LogEventInflightAdjustment,
+	LogEventLoggingResume,
+	LogEventFlightMode,
+	LogEventLogEnd,
+}
+
+var knownPredictors = map[int64]bool{
+	PredictorZero:              true,
+	PredictorPrevious:          true,
+	PredictorStraightLine:      true,
+	PredicatorAverage2:         true,
+	PredictorMinThrottle:       true,
+	PredictorMotor0:            true,
+	PredictorInc:               true,
+	PredictorHomeCoord:         true,
+	Predictor1500:              true,
+	PredictorVbatRef:           true,
+	PredictorLastMainFrameTime: true,
+	PredictorMinMotor:          true,
+	PredictorHomeCoord1:        true,
+}
+
+var dialects = map[FirmwareDialect]dialectRules{
+	DialectCleanflight: {
+		name:         "Cleanflight",
+		encodings:    newEncodingSet(),
+		events:       newEventSet(LogEventDisarm),
+		fieldAliases: map[FieldName]FieldName{},
+	},
+	DialectINAV: {
+		name:      "INAV",
+		encodings: newEncodingSet(EncodingTag2_3SVariable),
+		events:    newEventSet(),
+		fieldAliases: map[FieldName]FieldName{
+			FieldVbatLatest:     FieldVbat,
+			FieldAmperageLatest: FieldAmperage,
+		},
+	},
+}
+
+// detectDialect guesses the dialect of a log from its 'Firmware type' and 'Firmware revision' headers
+func detectDialect(firmwareType, firmwareRevision string) FirmwareDialect {
+	if strings.HasPrefix(firmwareType, "INAV") || strings.HasPrefix(firmwareRevision, "INAV") {
+		return DialectINAV
+	}
+	return DialectCleanflight
+}
+
+func (d FirmwareDialect) String() string {
+	return dialects[d].name
+}
+
+func (d FirmwareDialect) supportsEncoding(encoding int64) bool {
+	return dialects[d].encodings[encoding]
+}
+
+func (d FirmwareDialect) supportsPredictor(predictor int64) bool {
+	return knownPredictors[predictor]
+}
+
+func (d FirmwareDialect) supportsEvent(eventType LogEventType) bool {
+	return dialects[d].events[eventType]
+}
+
+// fieldAlias returns the name the dialect uses for a field, if it differs
+func (d FirmwareDialect) fieldAlias(fieldName FieldName) (FieldName, bool) {
+	alias, ok := dialects[d].fieldAliases[fieldName]
+	return alias, ok
+}
+
+func newEncodingSet(extra ...int64) map[int64]bool {
+	set := map[int64]bool{}
+	for _, encoding := range append(commonEncodings, extra...) {
+		set[encoding] = true
+	}
+	return set
+}
+
+func newEventSet(extra ...LogEventType) map[LogEventType]bool {
+	set := map[LogEventType]bool{}
+	for _, eventType := range append(commonEvents, extra...) {
+		set[eventType] = true
+	}
+	return set
+}
@@ -0,0 +1,197 @@
+package blackbox
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectDialect(t *testing.T) {
+	tests := []struct {
+		firmwareType     string
+		firmwareRevision string
+		expected         FirmwareDialect
+	}{
+		{"Cleanflight", "Betaflight 4.0.0 (8f2d21460) OMNIBUSF4SD", DialectCleanflight},
+		{"Cleanflight", "INAV 2.6.1 (4bf4d6d6) MATEKF405SE", DialectINAV},
+		{"INAV", "", DialectINAV},
+		{"Baseflight", "", DialectCleanflight},
+		{"", "", DialectCleanflight},
+	}
+
+	for _, test := range tests {
+		t.Run(test.firmwareType+" "+test.firmwareRevision, func(t *testing.T) {
+			assert.Equal(t, test.expected, detectDialect(test.firmwareType, test.firmwareRevision))
+		})
+	}
+}
+
+func TestReadINAVLog(t *testing.T) {
+	frameDef, frames := readINAVFixtureFrames(t)
+	assert.Equal(t, DialectINAV, frameDef.Dialect)
+	assert.Equal(t, "INAV", frameDef.Dialect.String())
+	assert.Len(t, frameDef.FieldsG, 13)
+	assert.Equal(t, int64(PredictorHomeCoord), frameDef.FieldsG[2].Predictor)
+	assert.Equal(t, int64(PredictorHomeCoord1), frameDef.FieldsG[3].Predictor)
+
+	counts := map[LogFrameType]int{}
+	var gpsFrames [][]int64
+	for _, frame := range frames {
+		assert.NoError(t, frame.Error())
+		assert.True(t, frame.Validity())
+		counts[frame.Type()]++
+		if frame.Type() == LogFrameGPS {
+			gpsFrames = append(gpsFrames, frame.Values().([]int64))
+		}
+	}
+	assert.Equal(t, map[LogFrameType]int{
+		LogFrameEvent:   2,
+		LogFrameIntra:   16,
+		LogFrameInter:   240,
+		LogFrameSlow:    1,
+		LogFrameGPSHome: 1,
+		LogFrameGPS:     6,
+	}, counts)
+
+	// The time is predicted from the last main frame and the coordinates from the home position
+	assert.Equal(t, []int64{183250311, 14, 473981910, 85455939, 3000, 2500, 900, 90, 150, 210, 0, 2500, -120}, gpsFrames[0])
+	assert.Equal(t, []int64{183750307, 14, 473981770, 85457580, 3039, 2500, 1043, 90, 150, 210, -618, 2422, -8}, gpsFrames[5])
+
+	// navPos and navVel are written with the Tag2_3SVariable encoding
+	lastFrame := frames[len(frames)-2].Values().([]int64)
+	assert.Equal(t, int64(510), lastFrame[0])
+	assert.Equal(t, []int64{4838, 1261, 3039, -630, 2419, 4}, lastFrame[37:43])
+}
+
+func TestINAVFieldAliasesAndUnits(t *testing.T) {
+	frameDef, _ := readINAVFixtureFrames(t)
+
+	index, err := frameDef.GetFieldIndex(FieldVbatLatest)
+	assert.NoError(t, err)
+	assert.Equal(t, FieldVbat, frameDef.FieldsI[index].Name)
+
+	index, err = frameDef.GetFieldIndex(FieldAmperageLatest)
+	assert.NoError(t, err)
+	assert.Equal(t, FieldAmperage, frameDef.FieldsI[index].Name)
+
+	assert.Equal(t, 16.2, frameDef.VbatToVolts(1620))
+	assert.Equal(t, 12.5, frameDef.AmperageToAmps(1250))
+}
+
+func TestWriteINAVLog(t *testing.T) {
+	content := readINAVFixture(t)
+	headerReader := NewHeaderReader(stream.NewDecoder(bytes.NewReader(content)))
+	frameDef, _ := headerReader.ProcessHeaders()
+	_, frames := readINAVFixtureFrames(t)
+
+	var buf bytes.Buffer
+	logWriter := NewLogWriter(stream.NewEncoder(&buf), frameDef)
+	assert.NoError(t, logWriter.WriteHeaders(headerReader.RawHeaders()))
+	for _, frame := range frames {
+		assert.NoError(t, logWriter.WriteFrame(frame))
+	}
+	assert.Equal(t, content, buf.Bytes())
+}
+
+func TestTrimINAVLog(t *testing.T) {
+	frameDef, frames := readINAVFixtureFrames(t)
+	timeIdx, err := frameDef.GetFieldIndex(FieldTime)
+	assert.NoError(t, err)
+
+	// The range starts 150ms after the first main frame
+	var from time.Duration
+	for _, frame := range frames {
+		if frame.Type() == LogFrameIntra {
+			from = time.Duration(frame.Values().([]int64)[timeIdx])*time.Microsecond + 150*time.Millisecond
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	_, err = Trim(bytes.NewReader(readINAVFixture(t)), &buf, TrimOpts{From: 150 * time.Millisecond})
+	assert.NoError(t, err)
+
+	var expectedGPSFrames []interface{}
+	for _, frame := range frames {
+		if frame.Type() == LogFrameGPS && time.Duration(frame.Values().([]int64)[0])*time.Microsecond >= from {
+			expectedGPSFrames = append(expectedGPSFrames, frame.Values())
+		}
+	}
+
+	// The GPS home frame is written again so that the GPS frames can be decoded
+	var gpsFrames []interface{}
+	dec := stream.NewDecoder(&buf)
+	headerReader := NewHeaderReader(dec)
+	trimmedDef, err := headerReader.ProcessHeaders()
+	assert.NoError(t, err)
+	frameReader := NewFrameReader(dec, trimmedDef, nil)
+	for {
+		frame := frameReader.ReadNextFrame()
+		if frame.Error() == io.EOF {
+			break
+		}
+		assert.NoError(t, frame.Error())
+		if frame.Type() == LogFrameGPS {
+			gpsFrames = append(gpsFrames, frame.Values())
+		}
+	}
+	assert.Len(t, gpsFrames, 4)
+	assert.Equal(t, expectedGPSFrames, gpsFrames)
+}
+
+func TestParseEventFrameDialects(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  FirmwareDialect
+		input    []byte
+		expected eventValues
+		err      string
+	}{
+		{"disarm", DialectCleanflight, []byte{15, 4}, eventValues{"name": "Disarm", "reason": uint32(4)}, ""},
+		{"disarm", DialectINAV, []byte{15, 4}, nil, "Event type is unknown to INAV logs - ignored: 15\n"},
+		{"adjustment", DialectINAV, []byte{13, 3, 10}, eventValues{"name": "Inflight adjustment", "function": byte(3), "value": int32(5)}, ""},
+		{"float adjustment", DialectCleanflight, []byte{13, 131, 0, 0, 192, 63}, eventValues{"name": "Inflight adjustment", "function": byte(3), "value": float32(1.5)}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name+" for "+test.dialect.String(), func(t *testing.T) {
+			_, values, err := parseEventFrame(test.dialect, stream.NewDecoder(bytes.NewReader(test.input)), false)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, values)
+		})
+	}
+}
+
+// readINAVFixture returns the synthetic INAV log, see fixtures/README.md
+func readINAVFixture(t *testing.T) []byte {
+	content, err := ioutil.ReadFile("../../fixtures/inav_synthetic.bfl")
+	assert.NoError(t, err)
+	return content
+}
+
+// readINAVFixtureFrames decodes the INAV fixture and returns its definition and frames
+func readINAVFixtureFrames(t *testing.T) (LogDefinition, []Frame) {
+	dec := stream.NewDecoder(bytes.NewReader(readINAVFixture(t)))
+	headerReader := NewHeaderReader(dec)
+	frameDef, err := headerReader.ProcessHeaders()
+	assert.NoError(t, err)
+
+	frames := []Frame{}
+	frameReader := NewFrameReader(dec, frameDef, nil)
+	for {
+		frame := frameReader.ReadNextFrame()
+		if frame.Error() == io.EOF {
+			return frameDef, frames
+		}
+		frames = append(frames, frame)
+	}
+}
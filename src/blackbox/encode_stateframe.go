@@ -0,0 +1,83 @@
+package blackbox
+
+import (
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+	"github.com/pkg/errors"
+)
+
+// encodeStateFrame is the counterpart of parseStateFrame. It removes the prediction from the values of
+// a data frame and writes what's left with the encoding of each field.
+func encodeStateFrame(frameDef LogDefinition, fields []FieldDefinition, values []int64, history FrameHistory, enc *stream.Encoder) error {
+	if len(values) != len(fields) {
+		return errors.Errorf("Frame has %d values but %d fields are defined", len(values), len(fields))
+	}
+
+	residuals := make([]int64, len(fields))
+	for i, field := range fields {
+		// The incremented fields are guessed by the reader and never written
+		if field.Predictor == PredictorInc {
+			continue
+		}
+
+		prediction, err := ApplyPrediction(frameDef, values, i, int(field.Predictor), 0, history)
+		if err != nil {
+			return err
+		}
+		residuals[i] = values[i] - prediction
+	}
+
+	fieldsToSkip := 0
+	for i, field := range fields {
+		// Skip fields that were written together with a previous one
+		if fieldsToSkip > 0 {
+			fieldsToSkip--
+			continue
+		}
+
+		if field.Predictor == PredictorInc {
+			continue
+		}
+
+		var err error
+		switch field.Encoding {
+		case EncodingSignedVB:
+			err = enc.WriteSignedVB(int32(residuals[i]))
+		case EncodingUnsignedVB:
+			err = enc.WriteUnsignedVB(uint32(residuals[i]))
+		case EncodingNeg14Bits:
+			err = enc.WriteUnsignedVB(uint32(-residuals[i]) & 0x3FFF)
+		case EncodingTag8_8SVB:
+			err = enc.WriteTag8_8SVB(groupResiduals(residuals, i, field.GroupCount), field.GroupCount)
+			fieldsToSkip = field.GroupCount - 1
+		case EncodingTag2_3S32:
+			err = enc.WriteTag2_3S32(groupResiduals(residuals, i, 3))
+			fieldsToSkip = 2
+		case EncodingTag2_3SVariable:
+			err = enc.WriteTag2_3SVariable(groupResiduals(residuals, i, 3))
+			fieldsToSkip = 2
+		case EncodingTag8_4S16:
+			if frameDef.Rules.LegacyTag8_4S16 {
+				err = enc.WriteTag8_4S16V1(groupResiduals(residuals, i, 4))
+			} else {
+				err = enc.WriteTag8_4S16V2(groupResiduals(residuals, i, 4))
+			}
+			fieldsToSkip = 3
+		case EncodingNull:
+			// Nothing is written, the reader considers the value to be zero
+		default:
+			return errors.Errorf("Unsupported encoding '%d' for field '%s'", field.Encoding, field.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupResiduals returns the count values starting at index, padded with zeros
+// if the frame ends before
+func groupResiduals(residuals []int64, index, count int) []int64 {
+	group := make([]int64, count)
+	copy(group, residuals[index:])
+	return group
+}
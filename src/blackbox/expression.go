@@ -0,0 +1,493 @@
+package blackbox
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Expression is an arithmetic expression over the fields of a main frame, like
+// (rcCommand[3]-1000)/10 or mean(motor[*]). It supports numbers, fields, the + - * /
+// operators, parentheses and the functions abs, sqrt, min, max, sum and mean. A field
+// name ending with [*] stands for every field of the group, and can only be the
+// argument of a function.
+//
+// Conditions, like rcCommand[3] > 1500 && !(abs(gyroADC[2]) >= 200), are expressions
+// too: comparisons and the ! && || operators return 1 when true and 0 when false, and
+// any other value than 0 is true.
+type Expression struct {
+	source string
+	root   expressionNode
+}
+
+// valueFunc returns a value computed from the values of a main frame
+type valueFunc func(values []int64) float64
+
+// fieldLookup finds the fields an expression refers to
+type fieldLookup interface {
+	// index returns the position of a field
+	index(name FieldName) (int, error)
+
+	// group returns the positions of the fields of a group, like motor for motor[*]
+	group(name string) ([]int, error)
+
+	// definition returns the definition of the field at a position
+	definition(index int) FieldDefinition
+}
+
+// expressionNode is a node of the syntax tree of an expression. compile returns the
+// functions computing its values, which is more than one for groups of fields.
+type expressionNode interface {
+	compile(fields fieldLookup) ([]valueFunc, error)
+}
+
+// ParseExpression parses an expression
+func ParseExpression(source string) (*Expression, error) {
+	p := expressionParser{source: source}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.position < len(p.tokens) {
+		return nil, p.errorf("unexpected '%s'", p.tokens[p.position].text)
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+// compile returns the function computing the value of the expression for a main frame
+func (e *Expression) compile(fields fieldLookup) (valueFunc, error) {
+	values, err := e.root.compile(fields)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid expression '%s'", e.source)
+	}
+	if len(values) != 1 {
+		return nil, errors.Errorf("Expression '%s' has %d values instead of one", e.source, len(values))
+	}
+	return values[0], nil
+}
+
+// -------------------------------------------------------------------------- //
+
+type numberNode struct {
+	value float64
+}
+
+func (n numberNode) compile(fields fieldLookup) ([]valueFunc, error) {
+	return []valueFunc{func([]int64) float64 {
+		return n.value
+	}}, nil
+}
+
+type fieldNode struct {
+	name FieldName
+}
+
+func (n fieldNode) compile(fields fieldLookup) ([]valueFunc, error) {
+	var indexes []int
+	if group := strings.TrimSuffix(string(n.name), "[*]"); group != string(n.name) {
+		var err error
+		if indexes, err = fields.group(group); err != nil {
+			return nil, err
+		}
+	} else {
+		index, err := fields.index(n.name)
+		if err != nil {
+			return nil, err
+		}
+		indexes = []int{index}
+	}
+
+	values := make([]valueFunc, len(indexes))
+	for i, index := range indexes {
+		index := index
+		field := fields.definition(index)
+		values[i] = func(values []int64) float64 {
+			return field.Value(values[index])
+		}
+	}
+	return values, nil
+}
+
+type unaryNode struct {
+	operator string
+	operand  expressionNode
+}
+
+func (n unaryNode) compile(fields fieldLookup) ([]valueFunc, error) {
+	operand, err := compileScalar(n.operand, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.operator {
+	case "-":
+		return []valueFunc{func(values []int64) float64 { return -operand(values) }}, nil
+	case "!":
+		return []valueFunc{func(values []int64) float64 { return truth(operand(values) == 0) }}, nil
+	default:
+		return nil, errors.Errorf("Unknown operator '%s'", n.operator)
+	}
+}
+
+type operationNode struct {
+	operator    string
+	left, right expressionNode
+}
+
+func (n operationNode) compile(fields fieldLookup) ([]valueFunc, error) {
+	left, err := compileScalar(n.left, fields)
+	if err != nil {
+		return nil, err
+	}
+	right, err := compileScalar(n.right, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var value valueFunc
+	switch n.operator {
+	case "+":
+		value = func(values []int64) float64 { return left(values) + right(values) }
+	case "-":
+		value = func(values []int64) float64 { return left(values) - right(values) }
+	case "*":
+		value = func(values []int64) float64 { return left(values) * right(values) }
+	case "/":
+		value = func(values []int64) float64 { return left(values) / right(values) }
+	case "<":
+		value = func(values []int64) float64 { return truth(left(values) < right(values)) }
+	case "<=":
+		value = func(values []int64) float64 { return truth(left(values) <= right(values)) }
+	case ">":
+		value = func(values []int64) float64 { return truth(left(values) > right(values)) }
+	case ">=":
+		value = func(values []int64) float64 { return truth(left(values) >= right(values)) }
+	case "==":
+		value = func(values []int64) float64 { return truth(left(values) == right(values)) }
+	case "!=":
+		value = func(values []int64) float64 { return truth(left(values) != right(values)) }
+	case "&&":
+		value = func(values []int64) float64 { return truth(left(values) != 0 && right(values) != 0) }
+	case "||":
+		value = func(values []int64) float64 { return truth(left(values) != 0 || right(values) != 0) }
+	default:
+		return nil, errors.Errorf("Unknown operator '%s'", n.operator)
+	}
+	return []valueFunc{value}, nil
+}
+
+// truth returns the value of a condition
+func truth(condition bool) float64 {
+	if condition {
+		return 1
+	}
+	return 0
+}
+
+// expressionFunctions are the functions of expressions, by name. unary tells if the
+// function takes exactly one argument.
+var expressionFunctions = map[string]struct {
+	unary bool
+	apply func(arguments []float64) float64
+}{
+	"abs": {true, func(arguments []float64) float64 {
+		return math.Abs(arguments[0])
+	}},
+	"sqrt": {true, func(arguments []float64) float64 {
+		return math.Sqrt(arguments[0])
+	}},
+	"min": {false, func(arguments []float64) float64 {
+		result := arguments[0]
+		for _, argument := range arguments[1:] {
+			result = math.Min(result, argument)
+		}
+		return result
+	}},
+	"max": {false, func(arguments []float64) float64 {
+		result := arguments[0]
+		for _, argument := range arguments[1:] {
+			result = math.Max(result, argument)
+		}
+		return result
+	}},
+	"sum": {false, sumOf},
+	"mean": {false, func(arguments []float64) float64 {
+		return sumOf(arguments) / float64(len(arguments))
+	}},
+}
+
+func sumOf(arguments []float64) float64 {
+	result := 0.0
+	for _, argument := range arguments {
+		result += argument
+	}
+	return result
+}
+
+type callNode struct {
+	function  string
+	arguments []expressionNode
+}
+
+func (n callNode) compile(fields fieldLookup) ([]valueFunc, error) {
+	function, ok := expressionFunctions[n.function]
+	if !ok {
+		return nil, errors.Errorf("Unknown function '%s'", n.function)
+	}
+
+	arguments := []valueFunc{}
+	for _, argument := range n.arguments {
+		values, err := argument.compile(fields)
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, values...)
+	}
+	if len(arguments) == 0 || (function.unary && len(arguments) != 1) {
+		return nil, errors.Errorf("Function '%s' can't take %d arguments", n.function, len(arguments))
+	}
+
+	return []valueFunc{func(values []int64) float64 {
+		argumentValues := make([]float64, len(arguments))
+		for i, argument := range arguments {
+			argumentValues[i] = argument(values)
+		}
+		return function.apply(argumentValues)
+	}}, nil
+}
+
+// compileScalar compiles a node which must have a single value
+func compileScalar(node expressionNode, fields fieldLookup) (valueFunc, error) {
+	values, err := node.compile(fields)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, errors.New("Groups of fields can only be the arguments of functions")
+	}
+	return values[0], nil
+}
+
+// -------------------------------------------------------------------------- //
+
+type expressionToken struct {
+	text   string
+	number bool
+	symbol bool
+}
+
+// expressionParser is a recursive descent parser with the following grammar:
+//   or         = and { "||" and }
+//   and        = comparison { "&&" comparison }
+//   comparison = sum [ ("<" | "<=" | ">" | ">=" | "==" | "!=") sum ]
+//   sum        = product { ("+" | "-") product }
+//   product    = unary { ("*" | "/") unary }
+//   unary      = ("-" | "!") unary | primary
+//   primary    = number | name "(" or { "," or } ")" | field | "(" or ")"
+type expressionParser struct {
+	source   string
+	tokens   []expressionToken
+	position int
+}
+
+// tokenize splits the source into numbers, names of fields or functions and symbols.
+// Names include brackets, like motor[0] or motor[*].
+func (p *expressionParser) tokenize() error {
+	runes := []rune(p.source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+
+		case unicode.IsDigit(r) || r == '.':
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			p.tokens = append(p.tokens, expressionToken{text: string(runes[start:i]), number: true})
+
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			if i < len(runes) && runes[i] == '[' {
+				for i < len(runes) && runes[i] != ']' {
+					i++
+				}
+				if i == len(runes) {
+					return errors.Errorf("Invalid expression '%s': missing ']' after '%s'", p.source, string(runes[start:i]))
+				}
+				i++
+			}
+			p.tokens = append(p.tokens, expressionToken{text: string(runes[start:i])})
+
+		case i+1 < len(runes) && isExpressionSymbol(string(runes[i:i+2])):
+			i += 2
+			p.tokens = append(p.tokens, expressionToken{text: string(runes[start:i]), symbol: true})
+
+		case isExpressionSymbol(string(r)):
+			i++
+			p.tokens = append(p.tokens, expressionToken{text: string(r), symbol: true})
+
+		default:
+			return errors.Errorf("Invalid expression '%s': unexpected '%c'", p.source, r)
+		}
+	}
+	return nil
+}
+
+// isExpressionSymbol tells if a text is an operator or a punctuation of expressions
+func isExpressionSymbol(text string) bool {
+	switch text {
+	case "+", "-", "*", "/", "(", ")", ",", "<", "<=", ">", ">=", "==", "!=", "!", "&&", "||":
+		return true
+	}
+	return false
+}
+
+func (p *expressionParser) parseOr() (expressionNode, error) {
+	return p.parseOperations(p.parseAnd, "||")
+}
+
+func (p *expressionParser) parseAnd() (expressionNode, error) {
+	return p.parseOperations(p.parseComparison, "&&")
+}
+
+func (p *expressionParser) parseComparison() (expressionNode, error) {
+	node, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, operator := range []string{"<", "<=", ">", ">=", "==", "!="} {
+		if p.next(operator) {
+			p.position++
+			right, err := p.parseSum()
+			return operationNode{operator: operator, left: node, right: right}, err
+		}
+	}
+	return node, nil
+}
+
+func (p *expressionParser) parseSum() (expressionNode, error) {
+	return p.parseOperations(p.parseProduct, "+", "-")
+}
+
+func (p *expressionParser) parseProduct() (expressionNode, error) {
+	return p.parseOperations(p.parseUnary, "*", "/")
+}
+
+// parseOperations parses operands separated by operators of the same precedence, which
+// apply from left to right
+func (p *expressionParser) parseOperations(parseOperand func() (expressionNode, error), operators ...string) (expressionNode, error) {
+	node, err := parseOperand()
+	for err == nil && p.nextOneOf(operators) {
+		operator := p.tokens[p.position].text
+		p.position++
+
+		var right expressionNode
+		right, err = parseOperand()
+		node = operationNode{operator: operator, left: node, right: right}
+	}
+	return node, err
+}
+
+func (p *expressionParser) parseUnary() (expressionNode, error) {
+	if p.next("-") || p.next("!") {
+		operator := p.tokens[p.position].text
+		p.position++
+		operand, err := p.parseUnary()
+		return unaryNode{operator: operator, operand: operand}, err
+	}
+	return p.parsePrimary()
+}
+
+func (p *expressionParser) parsePrimary() (expressionNode, error) {
+	if p.position >= len(p.tokens) {
+		return nil, p.errorf("unexpected end")
+	}
+	token := p.tokens[p.position]
+	p.position++
+
+	switch {
+	case token.number:
+		value, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number '%s'", token.text)
+		}
+		return numberNode{value: value}, nil
+
+	case token.text == "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expect(")")
+
+	case token.symbol:
+		return nil, p.errorf("unexpected '%s'", token.text)
+
+	case p.next("("):
+		p.position++
+		call := callNode{function: token.text}
+		for {
+			argument, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.arguments = append(call.arguments, argument)
+
+			if !p.next(",") {
+				return call, p.expect(")")
+			}
+			p.position++
+		}
+
+	default:
+		return fieldNode{name: FieldName(token.text)}, nil
+	}
+}
+
+// next tells if the next token is the given symbol
+func (p *expressionParser) next(symbol string) bool {
+	return p.position < len(p.tokens) && p.tokens[p.position].symbol && p.tokens[p.position].text == symbol
+}
+
+func (p *expressionParser) nextOneOf(symbols []string) bool {
+	for _, symbol := range symbols {
+		if p.next(symbol) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *expressionParser) expect(symbol string) error {
+	if !p.next(symbol) {
+		if p.position >= len(p.tokens) {
+			return p.errorf("missing '%s'", symbol)
+		}
+		return p.errorf("expected '%s' instead of '%s'", symbol, p.tokens[p.position].text)
+	}
+	p.position++
+	return nil
+}
+
+func (p *expressionParser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("Invalid expression '%s': %s", p.source, fmt.Sprintf(format, args...))
+}
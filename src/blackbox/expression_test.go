@@ -0,0 +1,73 @@
+package blackbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// expressionFields are motor[0..3] followed by rcCommand[3]
+type expressionFields struct{}
+
+func (expressionFields) index(name FieldName) (int, error) {
+	return (&LogDefinition{FieldIRL: map[FieldName]int{"motor[0]": 0, "motor[1]": 1, "motor[2]": 2, "motor[3]": 3, "rcCommand[3]": 4}}).GetFieldIndex(name)
+}
+
+func (expressionFields) group(name string) ([]int, error) {
+	if name != "motor" {
+		return nil, assert.AnError
+	}
+	return []int{0, 1, 2, 3}, nil
+}
+
+func (expressionFields) definition(index int) FieldDefinition {
+	return FieldDefinition{}
+}
+
+func TestExpressionValues(t *testing.T) {
+	values := []int64{1000, 1100, 1200, 1400, 1216}
+
+	for source, expected := range map[string]float64{
+		"(rcCommand[3]-1000)/10":        21.6,
+		"mean(motor[*])":                1175,
+		"max(motor[*]) - min(motor[*])": 400,
+		"sum(motor[0], motor[1], 5)":    2105,
+		"-motor[0] + 2 * 3":             -994,
+		"abs(motor[0] - motor[3]) / 4":  100,
+		"sqrt(16) * -(1 - 2.5)":         6,
+		"1 - 2 - 3":                     -4,
+		"12 / 2 / 3":                    2,
+		"rcCommand[3] > 1200":           1,
+		"motor[0] >= 1000 && motor[1] < 1100 || motor[3] == 1400": 1,
+		"motor[0] != 1000 || !(motor[1] <= 1100)":                 0,
+		"1 + 1 == 2": 1,
+		"!0 + 1":     2,
+	} {
+		expression, err := ParseExpression(source)
+		if !assert.NoError(t, err, source) {
+			continue
+		}
+		value, err := expression.compile(expressionFields{})
+		if assert.NoError(t, err, source) {
+			assert.InDelta(t, expected, value(values), 1e-9, source)
+		}
+	}
+}
+
+func TestExpressionSyntaxErrors(t *testing.T) {
+	for _, source := range []string{"", "1 +", "(1", "1)", "mean(motor[*]", "motor[0", "2 $ 3", "mean()", "1 2", "1 < 2 < 3", "1 = 2", "1 & 2"} {
+		_, err := ParseExpression(source)
+		assert.Error(t, err, source)
+	}
+}
+
+func TestExpressionCompileErrors(t *testing.T) {
+	for _, source := range []string{"gyroADC[0]", "motor[*]", "motor[*] + 1", "abs(motor[*])", "median(motor[0])", "mean(servo[*])"} {
+		expression, err := ParseExpression(source)
+		if !assert.NoError(t, err, source) {
+			continue
+		}
+		_, err = expression.compile(expressionFields{})
+		assert.Error(t, err, source)
+	}
+}
@@ -0,0 +1,167 @@
+package blackbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FrameFilter decides which main frames are kept. Every frame of the log is given to
+// Keep, in order, for filters to follow the state of the craft, but only main frames
+// are filtered: the other ones are always kept.
+type FrameFilter interface {
+	Keep(frame Frame) bool
+}
+
+// FilterChain keeps the main frames all of its filters keep
+type FilterChain []FrameFilter
+
+// Keep tells if every filter keeps a frame. All the filters see every frame, even after
+// one of them dropped it.
+func (c FilterChain) Keep(frame Frame) bool {
+	keep := true
+	for _, filter := range c {
+		if !filter.Keep(frame) {
+			keep = false
+		}
+	}
+	return keep
+}
+
+// FilterFrames returns the frames of a channel that a filter keeps. The returned channel
+// is closed once the input channel is, or the context canceled.
+func FilterFrames(ctx context.Context, frames <-chan Frame, filter FrameFilter) <-chan Frame {
+	return TransformFrames(ctx, frames, FrameFunc(func(frame Frame) []Frame {
+		if !filter.Keep(frame) {
+			return nil
+		}
+		return []Frame{frame}
+	}))
+}
+
+// mainFrameFilter keeps main frames according to their values. Main frames with errors
+// are dropped, as their values can't be trusted.
+type mainFrameFilter func(values []int64) bool
+
+func (f mainFrameFilter) Keep(frame Frame) bool {
+	mainFrame, ok := frame.(*MainFrame)
+	if !ok {
+		return true
+	}
+	return frame.Error() == nil && f(mainFrame.values)
+}
+
+// NewTimeFilter returns a filter keeping the main frames between two times since the
+// first main frame. A zero to doesn't bound the time range.
+func NewTimeFilter(frameDef LogDefinition, from, to time.Duration) (FrameFilter, error) {
+	timeIndex, err := frameDef.GetFieldIndex(FieldTime)
+	if err != nil {
+		return nil, err
+	}
+	if to != 0 && to < from {
+		return nil, errors.Errorf("Time range ends at %s, before it starts at %s", to, from)
+	}
+
+	start := int64(-1)
+	return mainFrameFilter(func(values []int64) bool {
+		if start < 0 {
+			start = values[timeIndex]
+		}
+		elapsed := time.Duration(values[timeIndex]-start) * time.Microsecond
+		return elapsed >= from && (to == 0 || elapsed <= to)
+	}), nil
+}
+
+// NewIterationFilter returns a filter keeping the main frames between two loop
+// iterations, included
+func NewIterationFilter(frameDef LogDefinition, first, last int64) (FrameFilter, error) {
+	iterationIndex, err := frameDef.GetFieldIndex(FieldIteration)
+	if err != nil {
+		return nil, err
+	}
+	if last < first {
+		return nil, errors.Errorf("Iteration range ends at %d, before it starts at %d", last, first)
+	}
+
+	return mainFrameFilter(func(values []int64) bool {
+		return values[iterationIndex] >= first && values[iterationIndex] <= last
+	}), nil
+}
+
+// NewThrottleFilter returns a filter keeping the main frames whose throttle command is
+// between two values, included
+func NewThrottleFilter(frameDef LogDefinition, low, high int64) (FrameFilter, error) {
+	throttleIndex, err := frameDef.GetFieldIndex(FieldThrottle)
+	if err != nil {
+		return nil, err
+	}
+
+	return mainFrameFilter(func(values []int64) bool {
+		return values[throttleIndex] >= low && values[throttleIndex] <= high
+	}), nil
+}
+
+// NewPredicateFilter returns a filter keeping the main frames for which a condition,
+// like rcCommand[3] > 1500 && abs(gyroADC[2]) < 200, is true
+func NewPredicateFilter(frameDef LogDefinition, condition *Expression) (FrameFilter, error) {
+	value, err := condition.compile(&selectionLookup{frameDef: frameDef})
+	if err != nil {
+		return nil, err
+	}
+
+	return mainFrameFilter(func(values []int64) bool {
+		return value(values) != 0
+	}), nil
+}
+
+// flightModeFilter keeps the main frames logged while some flight modes are set,
+// according to the last slow frame
+type flightModeFilter struct {
+	rules      DecodingRules
+	flagsIndex int
+	modes      FlightModes
+	current    FlightModes
+	known      bool
+}
+
+// NewFlightModeFilter returns a filter keeping the main frames logged while all the given
+// flight modes are set, like ANGLE_MODE. Main frames before the first slow frame are
+// dropped, as the modes are not known yet.
+func NewFlightModeFilter(frameDef LogDefinition, modes ...FlightMode) (FrameFilter, error) {
+	flagsIndex := indexOfField(frameDef.FieldsS, FieldFlightModeFlags)
+	if flagsIndex < 0 {
+		return nil, errors.Errorf("Field definition for '%s' not found", FieldFlightModeFlags)
+	}
+
+	f := &flightModeFilter{rules: frameDef.Rules, flagsIndex: flagsIndex}
+	for _, mode := range modes {
+		if !frameDef.Rules.flightModeTable().contains(mode) {
+			return nil, errors.Errorf("Flight mode %s is not logged by this firmware", mode)
+		}
+		f.modes |= 1 << uint(mode)
+	}
+	return f, nil
+}
+
+// NewArmedFilter returns a filter keeping the main frames logged while the craft is armed
+func NewArmedFilter(frameDef LogDefinition) (FrameFilter, error) {
+	return NewFlightModeFilter(frameDef, FlightModeArm)
+}
+
+func (f *flightModeFilter) Keep(frame Frame) bool {
+	switch frame := frame.(type) {
+	case *SlowFrame:
+		if frame.Error() == nil && f.flagsIndex < len(frame.values) {
+			f.current = f.rules.FlightModes(frame.values[f.flagsIndex])
+			f.known = true
+		}
+		return true
+
+	case *MainFrame:
+		return f.known && frame.Error() == nil && f.current&f.modes == f.modes
+
+	default:
+		return true
+	}
+}
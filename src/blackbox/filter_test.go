@@ -0,0 +1,114 @@
+package blackbox
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeFilter(t *testing.T) {
+	// Main frames are at 0, 499, 999, 1503 and 2001us
+	iterations, others := filterFixture(t, func(frameDef LogDefinition) (FrameFilter, error) {
+		return NewTimeFilter(frameDef, 500*time.Microsecond, 1600*time.Microsecond)
+	})
+	assert.Equal(t, []int64{52994, 52995}, iterations)
+	assert.Equal(t, 5, others)
+
+	iterations, _ = filterFixture(t, func(frameDef LogDefinition) (FrameFilter, error) {
+		return NewTimeFilter(frameDef, time.Millisecond, 0)
+	})
+	assert.Equal(t, []int64{52995, 52996}, iterations)
+
+	_, err := NewTimeFilter(selectionFrameDef, time.Second, time.Millisecond)
+	assert.Error(t, err)
+}
+
+func TestIterationFilter(t *testing.T) {
+	iterations, _ := filterFixture(t, func(frameDef LogDefinition) (FrameFilter, error) {
+		return NewIterationFilter(frameDef, 52993, 52994)
+	})
+	assert.Equal(t, []int64{52993, 52994}, iterations)
+}
+
+func TestThrottleFilter(t *testing.T) {
+	iterations, _ := filterFixture(t, func(frameDef LogDefinition) (FrameFilter, error) {
+		return NewThrottleFilter(frameDef, 1000, 1200)
+	})
+	assert.Empty(t, iterations)
+
+	iterations, _ = filterFixture(t, func(frameDef LogDefinition) (FrameFilter, error) {
+		return NewThrottleFilter(frameDef, 1200, 1300)
+	})
+	assert.Len(t, iterations, 5)
+}
+
+func TestPredicateFilter(t *testing.T) {
+	condition, err := ParseExpression("motor[0] > 540 || motor[3] == 661")
+	assert.NoError(t, err)
+
+	iterations, _ := filterFixture(t, func(frameDef LogDefinition) (FrameFilter, error) {
+		return NewPredicateFilter(frameDef, condition)
+	})
+	assert.Equal(t, []int64{52994, 52995, 52996}, iterations)
+
+	condition, err = ParseExpression("servo[0] > 1500")
+	assert.NoError(t, err)
+	_, err = NewPredicateFilter(selectionFrameDef, condition)
+	assert.Error(t, err)
+}
+
+func TestFlightModeFilter(t *testing.T) {
+	// The craft is armed from the first slow frame on, which comes after the I-frame
+	iterations, others := filterFixture(t, NewArmedFilter)
+	assert.Equal(t, []int64{52993, 52994, 52995, 52996}, iterations)
+	assert.Equal(t, 5, others)
+
+	iterations, _ = filterFixture(t, func(frameDef LogDefinition) (FrameFilter, error) {
+		return NewFlightModeFilter(frameDef, FlightModeArm, FlightModeAngle)
+	})
+	assert.Empty(t, iterations)
+
+	_, err := NewFlightModeFilter(selectionFrameDef, FlightModeAngle)
+	assert.Error(t, err)
+}
+
+func TestFilterChain(t *testing.T) {
+	iterations, others := filterFixture(t, func(frameDef LogDefinition) (FrameFilter, error) {
+		armed, err := NewArmedFilter(frameDef)
+		if err != nil {
+			return nil, err
+		}
+		window, err := NewIterationFilter(frameDef, 52992, 52993)
+		if err != nil {
+			return nil, err
+		}
+		return FilterChain{window, armed}, nil
+	})
+	assert.Equal(t, []int64{52993}, iterations)
+	assert.Equal(t, 5, others)
+}
+
+// filterFixture filters the frames of the fixture and returns the iterations of the main
+// frames kept, and the number of other frames
+func filterFixture(t *testing.T, newFilter func(LogDefinition) (FrameFilter, error)) ([]int64, int) {
+	flightLog := NewFlightLogReader(FlightLogReaderOpts{})
+	frameChan, err := flightLog.LoadFile(context.Background(), bytes.NewReader(readFixture(t)))
+	assert.NoError(t, err)
+
+	filter, err := newFilter(flightLog.FrameDef)
+	assert.NoError(t, err)
+
+	iterations := []int64{}
+	others := 0
+	for frame := range FilterFrames(context.Background(), frameChan, filter) {
+		if mainFrame, ok := frame.(*MainFrame); ok {
+			iterations = append(iterations, mainFrame.values[0])
+		} else {
+			others++
+		}
+	}
+	return iterations, others
+}
@@ -0,0 +1,77 @@
+package blackbox
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	// firmwareDateLayout is how the 'Firmware date' header is written, from the __DATE__ and __TIME__ of the build
+	firmwareDateLayout = "Jan _2 2006 15:04:05"
+)
+
+// firmwareRevisionRegExp matches revisions like 'Betaflight 4.0.0 (173e958da) MATEKF405'
+var firmwareRevisionRegExp = regexp.MustCompile(`^(\S+) (\d+)\.(\d+)\.(\d+)(?: \(([0-9a-fA-F]+)\))?(?: (\S+))?`)
+
+// FirmwareVersion describes the firmware that recorded a log
+type FirmwareVersion struct {
+	// Product is the name of the firmware, like Betaflight or INAV
+	Product string
+
+	// Variant is the target the firmware was built for, usually the name of the board
+	Variant string
+
+	Major int
+	Minor int
+	Patch int
+
+	// Commit is the revision the firmware was built from
+	Commit string
+
+	// BuildDate is when the firmware was built, or zero if unknown
+	BuildDate time.Time
+}
+
+// ParseFirmwareVersion returns the version described by the 'Firmware type', 'Firmware revision' and
+// 'Firmware date' headers. Fields that can't be parsed are left empty.
+func ParseFirmwareVersion(firmwareType, firmwareRevision, firmwareDate string) FirmwareVersion {
+	version := FirmwareVersion{Product: firmwareType}
+
+	if match := firmwareRevisionRegExp.FindStringSubmatch(firmwareRevision); match != nil {
+		version.Product = match[1]
+		version.Major, _ = strconv.Atoi(match[2])
+		version.Minor, _ = strconv.Atoi(match[3])
+		version.Patch, _ = strconv.Atoi(match[4])
+		version.Commit = match[5]
+		version.Variant = match[6]
+	} else if firmwareRevision != "" {
+		// Older firmwares only write the commit
+		version.Commit = firmwareRevision
+	}
+
+	if buildDate, err := time.Parse(firmwareDateLayout, firmwareDate); err == nil {
+		version.BuildDate = buildDate
+	}
+	return version
+}
+
+// AtLeast returns true if the version is the given one or a newer one
+func (v FirmwareVersion) AtLeast(major, minor, patch int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	if v.Minor != minor {
+		return v.Minor > minor
+	}
+	return v.Patch >= patch
+}
+
+func (v FirmwareVersion) String() string {
+	version := fmt.Sprintf("%s %d.%d.%d", v.Product, v.Major, v.Minor, v.Patch)
+	if v.Variant != "" {
+		version += " " + v.Variant
+	}
+	return version
+}
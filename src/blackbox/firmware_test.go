@@ -0,0 +1,54 @@
+package blackbox
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFirmwareVersion(t *testing.T) {
+	tests := []struct {
+		firmwareType     string
+		firmwareRevision string
+		firmwareDate     string
+		expected         FirmwareVersion
+	}{
+		{"Cleanflight", "Betaflight 4.0.0 (173e958da) MATEKF405", "Mar 26 2019 19:30:15", FirmwareVersion{
+			Product:   "Betaflight",
+			Variant:   "MATEKF405",
+			Major:     4,
+			Commit:    "173e958da",
+			BuildDate: time.Date(2019, time.March, 26, 19, 30, 15, 0, time.UTC),
+		}},
+		{"Cleanflight", "INAV 2.6.1 (4bf4d6d6) MATEKF405SE", "Feb  8 2021 15:47:21", FirmwareVersion{
+			Product:   "INAV",
+			Variant:   "MATEKF405SE",
+			Major:     2,
+			Minor:     6,
+			Patch:     1,
+			Commit:    "4bf4d6d6",
+			BuildDate: time.Date(2021, time.February, 8, 15, 47, 21, 0, time.UTC),
+		}},
+		{"Cleanflight", "4bf4d6d6", "", FirmwareVersion{Product: "Cleanflight", Commit: "4bf4d6d6"}},
+		{"", "", "yesterday", FirmwareVersion{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.firmwareRevision, func(t *testing.T) {
+			assert.Equal(t, test.expected, ParseFirmwareVersion(test.firmwareType, test.firmwareRevision, test.firmwareDate))
+		})
+	}
+}
+
+func TestFirmwareVersionAtLeast(t *testing.T) {
+	version := FirmwareVersion{Product: "Betaflight", Major: 3, Minor: 1, Patch: 7}
+
+	assert.True(t, version.AtLeast(3, 1, 7))
+	assert.True(t, version.AtLeast(3, 1, 0))
+	assert.True(t, version.AtLeast(2, 9, 9))
+	assert.False(t, version.AtLeast(3, 1, 8))
+	assert.False(t, version.AtLeast(3, 2, 0))
+	assert.False(t, version.AtLeast(4, 0, 0))
+	assert.Equal(t, "Betaflight 3.1.7", version.String())
+}
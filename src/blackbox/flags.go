@@ -0,0 +1,310 @@
+package blackbox
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FlightMode is a mode of the flight controller, whichever bit a firmware logs it with
+type FlightMode int
+
+// List of the flight modes known by the library
+const (
+	FlightModeArm FlightMode = iota
+	FlightModeAngle
+	FlightModeHorizon
+	FlightModeMag
+	FlightModeBaro
+	FlightModeGPSHome
+	FlightModeGPSHold
+	FlightModeHeadfree
+	FlightModeAutotune
+	FlightModePassthru
+	FlightModeSonar
+	FlightModeFailsafe
+	FlightModeGPSRescue
+	FlightModeAntiGravity
+	FlightModeHeadAdjust
+	FlightModeCamStab
+	FlightModeCamTrig
+	FlightModeBeeper
+	FlightModeLEDMax
+	FlightModeLEDLow
+	FlightModeLLights
+	FlightModeCalibration
+	FlightModeGovernor
+	FlightModeOSD
+	FlightModeTelemetry
+	FlightModeGTune
+	FlightModeServo1
+	FlightModeServo2
+	FlightModeServo3
+	FlightModeBlackbox
+	FlightModeAirmode
+	FlightMode3D
+	FlightModeFPVAngleMix
+	FlightModeBlackboxErase
+	FlightModeCamera1
+	FlightModeCamera2
+	FlightModeCamera3
+	FlightModeTurtle
+	FlightModePrearm
+	FlightModeBeepGPSCount
+	FlightModeVTXPitMode
+	FlightModeParalyze
+	FlightModeUser1
+	FlightModeUser2
+	FlightModeUser3
+	FlightModeUser4
+	FlightModePIDAudio
+	FlightModeAcroTrainer
+	FlightModeVTXControlDisable
+	FlightModeLaunchControl
+)
+
+var flightModeNames = []string{
+	FlightModeArm:               "ARM",
+	FlightModeAngle:             "ANGLE_MODE",
+	FlightModeHorizon:           "HORIZON_MODE",
+	FlightModeMag:               "MAG",
+	FlightModeBaro:              "BARO",
+	FlightModeGPSHome:           "GPS_HOME",
+	FlightModeGPSHold:           "GPS_HOLD",
+	FlightModeHeadfree:          "HEADFREE",
+	FlightModeAutotune:          "AUTOTUNE",
+	FlightModePassthru:          "PASSTHRU",
+	FlightModeSonar:             "SONAR",
+	FlightModeFailsafe:          "FAILSAFE",
+	FlightModeGPSRescue:         "GPS_RESCUE",
+	FlightModeAntiGravity:       "ANTI_GRAVITY",
+	FlightModeHeadAdjust:        "HEADADJ",
+	FlightModeCamStab:           "CAMSTAB",
+	FlightModeCamTrig:           "CAMTRIG",
+	FlightModeBeeper:            "BEEPER",
+	FlightModeLEDMax:            "LEDMAX",
+	FlightModeLEDLow:            "LEDLOW",
+	FlightModeLLights:           "LLIGHTS",
+	FlightModeCalibration:       "CALIB",
+	FlightModeGovernor:          "GOVERNOR",
+	FlightModeOSD:               "OSD",
+	FlightModeTelemetry:         "TELEMETRY",
+	FlightModeGTune:             "GTUNE",
+	FlightModeServo1:            "SERVO1",
+	FlightModeServo2:            "SERVO2",
+	FlightModeServo3:            "SERVO3",
+	FlightModeBlackbox:          "BLACKBOX",
+	FlightModeAirmode:           "AIRMODE",
+	FlightMode3D:                "3D",
+	FlightModeFPVAngleMix:       "FPV_ANGLE_MIX",
+	FlightModeBlackboxErase:     "BLACKBOX_ERASE",
+	FlightModeCamera1:           "CAMERA1",
+	FlightModeCamera2:           "CAMERA2",
+	FlightModeCamera3:           "CAMERA3",
+	FlightModeTurtle:            "TURTLE",
+	FlightModePrearm:            "PREARM",
+	FlightModeBeepGPSCount:      "BEEP_GPS_COUNT",
+	FlightModeVTXPitMode:        "VTX_PIT_MODE",
+	FlightModeParalyze:          "PARALYZE",
+	FlightModeUser1:             "USER1",
+	FlightModeUser2:             "USER2",
+	FlightModeUser3:             "USER3",
+	FlightModeUser4:             "USER4",
+	FlightModePIDAudio:          "PID_AUDIO",
+	FlightModeAcroTrainer:       "ACRO_TRAINER",
+	FlightModeVTXControlDisable: "VTX_CONTROL_DISABLE",
+	FlightModeLaunchControl:     "LAUNCH_CONTROL",
+}
+
+// ParseFlightMode returns the flight mode with a name, like ANGLE_MODE
+func ParseFlightMode(name string) (FlightMode, error) {
+	for mode, modeName := range flightModeNames {
+		if strings.EqualFold(modeName, name) {
+			return FlightMode(mode), nil
+		}
+	}
+	return 0, errors.Errorf("Unknown flight mode '%s'", name)
+}
+
+func (m FlightMode) String() string {
+	if m < 0 || int(m) >= len(flightModeNames) {
+		return "UNKNOWN"
+	}
+	return flightModeNames[m]
+}
+
+// FlightModes is a set of flight modes
+type FlightModes uint64
+
+// Has returns true if the mode is part of the set
+func (m FlightModes) Has(mode FlightMode) bool {
+	return m&(1<<uint(mode)) != 0
+}
+
+// List returns the modes of the set, in the order they are declared
+func (m FlightModes) List() []FlightMode {
+	modes := []FlightMode{}
+	for mode := FlightMode(0); int(mode) < len(flightModeNames); mode++ {
+		if m.Has(mode) {
+			modes = append(modes, mode)
+		}
+	}
+	return modes
+}
+
+func (m FlightModes) String() string {
+	names := []string{}
+	for _, mode := range m.List() {
+		names = append(names, mode.String())
+	}
+	if len(names) == 0 {
+		return "0"
+	}
+	return strings.Join(names, "|")
+}
+
+// flightModeTable lists the mode logged with each bit of the flightModeFlags slow field, starting with the lowest one
+type flightModeTable []FlightMode
+
+// cleanflightFlightModes are the flight mode flags of Cleanflight and of Betaflight before 3.2
+var cleanflightFlightModes = flightModeTable{
+	FlightModeAngle,
+	FlightModeHorizon,
+	FlightModeMag,
+	FlightModeBaro,
+	FlightModeGPSHome,
+	FlightModeGPSHold,
+	FlightModeHeadfree,
+	FlightModeAutotune,
+	FlightModePassthru,
+	FlightModeSonar,
+}
+
+// betaflight3FlightModes are the box IDs logged by Betaflight 3.2 to 3.5
+var betaflight3FlightModes = flightModeTable{
+	FlightModeArm,
+	FlightModeAngle,
+	FlightModeHorizon,
+	FlightModeMag,
+	FlightModeBaro,
+	FlightModeGPSHome,
+	FlightModeGPSHold,
+	FlightModeHeadfree,
+	FlightModePassthru,
+	FlightModeSonar,
+	FlightModeFailsafe,
+	FlightModeAntiGravity,
+	FlightModeHeadAdjust,
+	FlightModeCamStab,
+	FlightModeCamTrig,
+	FlightModeBeeper,
+	FlightModeLEDMax,
+	FlightModeLEDLow,
+	FlightModeLLights,
+	FlightModeCalibration,
+	FlightModeGovernor,
+	FlightModeOSD,
+	FlightModeTelemetry,
+	FlightModeGTune,
+	FlightModeServo1,
+	FlightModeServo2,
+	FlightModeServo3,
+	FlightModeBlackbox,
+	FlightModeAirmode,
+	FlightMode3D,
+	FlightModeFPVAngleMix,
+	FlightModeBlackboxErase,
+	FlightModeCamera1,
+	FlightModeCamera2,
+	FlightModeCamera3,
+	FlightModeTurtle,
+	FlightModePrearm,
+	FlightModeBeepGPSCount,
+	FlightModeVTXPitMode,
+}
+
+// betaflight4FlightModes are the box IDs logged by Betaflight 4. Only the first 32 fit in the log.
+var betaflight4FlightModes = flightModeTable{
+	FlightModeArm,
+	FlightModeAngle,
+	FlightModeHorizon,
+	FlightModeMag,
+	FlightModeHeadfree,
+	FlightModePassthru,
+	FlightModeFailsafe,
+	FlightModeGPSRescue,
+	FlightModeAntiGravity,
+	FlightModeHeadAdjust,
+	FlightModeCamStab,
+	FlightModeBeeper,
+	FlightModeLEDLow,
+	FlightModeCalibration,
+	FlightModeOSD,
+	FlightModeTelemetry,
+	FlightModeServo1,
+	FlightModeServo2,
+	FlightModeServo3,
+	FlightModeBlackbox,
+	FlightModeAirmode,
+	FlightMode3D,
+	FlightModeFPVAngleMix,
+	FlightModeBlackboxErase,
+	FlightModeCamera1,
+	FlightModeCamera2,
+	FlightModeCamera3,
+	FlightModeTurtle,
+	FlightModePrearm,
+	FlightModeBeepGPSCount,
+	FlightModeVTXPitMode,
+	FlightModeParalyze,
+	FlightModeUser1,
+	FlightModeUser2,
+	FlightModeUser3,
+	FlightModeUser4,
+	FlightModePIDAudio,
+	FlightModeAcroTrainer,
+	FlightModeVTXControlDisable,
+	FlightModeLaunchControl,
+}
+
+// decode returns the modes whose bit is set in a flightModeFlags value. Unknown bits are ignored.
+func (t flightModeTable) decode(flags int64) FlightModes {
+	modes := FlightModes(0)
+	for bit, mode := range t {
+		if flags&(1<<uint(bit)) != 0 {
+			modes |= 1 << uint(mode)
+		}
+	}
+	return modes
+}
+
+func (t flightModeTable) contains(mode FlightMode) bool {
+	for _, m := range t {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// flightStateTable lists the name of each bit of the stateFlags slow field, starting with the lowest one
+type flightStateTable []string
+
+var cleanflightFlightStates = flightStateTable{
+	"GPS_FIX_HOME",
+	"GPS_FIX",
+	"CALIBRATE_MAG",
+	"SMALL_ANGLE",
+	"FIXED_WING",
+}
+
+// decode returns the names of the bits set in a stateFlags value, lowest bit first
+func (t flightStateTable) decode(flags int64) []string {
+	names := []string{}
+	for bit, name := range t {
+		if flags&(1<<uint(bit)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,59 @@
+package blackbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlightModesByVersion(t *testing.T) {
+	tests := []struct {
+		revision string
+		flags    int64
+		expected string
+	}{
+		{"Betaflight 4.0.0 (173e958da) MATEKF405", 524289, "ARM|BLACKBOX"},
+		{"Betaflight 4.0.0 (173e958da) MATEKF405", 1<<20 | 1<<8 | 1<<7 | 1<<27, "GPS_RESCUE|ANTI_GRAVITY|AIRMODE|TURTLE"},
+		{"Betaflight 3.5.7 (8f2d21460) OMNIBUSF4SD", 1<<0 | 1<<11 | 1<<28, "ARM|ANTI_GRAVITY|AIRMODE"},
+		{"Betaflight 3.1.7 (8f2d21460) SPRACINGF3", 1<<0 | 1<<6, "ANGLE_MODE|HEADFREE"},
+		{"", 1<<9 | 1<<1 | 1<<0 | 1<<30, "ANGLE_MODE|HORIZON_MODE|SONAR"},
+		{"", 0, "0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.revision, func(t *testing.T) {
+			rules := rulesFor(ParseFirmwareVersion("Cleanflight", test.revision, ""), 2)
+			assert.Equal(t, test.expected, rules.FlightModes(test.flags).String())
+		})
+	}
+}
+
+func TestFlightModesHas(t *testing.T) {
+	rules := rulesFor(FirmwareVersion{Product: "Betaflight", Major: 4}, 2)
+	modes := rules.FlightModes(1<<0 | 1<<20)
+
+	assert.True(t, modes.Has(FlightModeArm))
+	assert.True(t, modes.Has(FlightModeAirmode))
+	assert.False(t, modes.Has(FlightModeAngle))
+	assert.Equal(t, []FlightMode{FlightModeArm, FlightModeAirmode}, modes.List())
+}
+
+func TestParseFlightMode(t *testing.T) {
+	mode, err := ParseFlightMode("ANGLE_MODE")
+	assert.NoError(t, err)
+	assert.Equal(t, FlightModeAngle, mode)
+
+	mode, err = ParseFlightMode("airmode")
+	assert.NoError(t, err)
+	assert.Equal(t, FlightModeAirmode, mode)
+
+	_, err = ParseFlightMode("ANGLE")
+	assert.Error(t, err)
+}
+
+func TestFlightStates(t *testing.T) {
+	rules := DecodingRules{}
+
+	assert.Equal(t, []string{"GPS_FIX_HOME", "SMALL_ANGLE", "FIXED_WING"}, rules.FlightStates(25))
+	assert.Equal(t, []string{}, rules.FlightStates(0))
+}
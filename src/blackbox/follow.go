@@ -0,0 +1,49 @@
+package blackbox
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+const (
+	// followPollInterval is how often a followed file is checked for new data
+	followPollInterval = 100 * time.Millisecond
+)
+
+// followReader reads a file while it's being written, like tail -f. At the end of the
+// file, it waits for more data instead of returning io.EOF. It gives up when no data
+// was appended for idleTimeout, or when the context is canceled.
+type followReader struct {
+	ctx         context.Context
+	r           io.Reader
+	idleTimeout time.Duration
+}
+
+func newFollowReader(ctx context.Context, r io.Reader, idleTimeout time.Duration) *followReader {
+	return &followReader{
+		ctx:         ctx,
+		r:           r,
+		idleTimeout: idleTimeout,
+	}
+}
+
+func (f *followReader) Read(p []byte) (int, error) {
+	idleSince := time.Now()
+	for {
+		n, err := f.r.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+
+		if f.idleTimeout > 0 && time.Since(idleSince) >= f.idleTimeout {
+			return 0, io.EOF
+		}
+
+		select {
+		case <-f.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(followPollInterval):
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package blackbox
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFollowGrowingFile(t *testing.T) {
+	normal := readFixture(t)
+	logFile := tempLogFile(t)
+	defer os.Remove(logFile.Name())
+	defer logFile.Close()
+
+	// The firmware writes the log end message without the trailing byte of the fixture,
+	// and a second session could follow
+	go appendSlowly(logFile.Name(), normal[:len(normal)-1], normal)
+
+	flightLog := NewFlightLogReader(FlightLogReaderOpts{Follow: true, FollowIdleTimeout: 5 * time.Second})
+	frameChan, err := flightLog.LoadFile(context.Background(), logFile)
+	assert.NoError(t, err)
+
+	values := [][]int64{}
+	var lastFrame Frame
+	for frame := range frameChan {
+		assert.NoError(t, frame.Error())
+		if frame.Type() == LogFrameIntra || frame.Type() == LogFrameInter {
+			values = append(values, frame.Values().([]int64))
+		}
+		lastFrame = frame
+	}
+
+	assert.Equal(t, byte(LogEventLogEnd), lastFrame.(*EventFrame).EventType())
+	assert.Equal(t, readFixtureMainFrames(t, bytes.NewReader(normal)), values)
+}
+
+func TestFollowIdleTimeout(t *testing.T) {
+	normal := readFixture(t)
+	logFile := tempLogFile(t)
+	defer os.Remove(logFile.Name())
+	defer logFile.Close()
+
+	// The recording stops before the log end
+	_, err := logFile.Write(normal[:len(normal)-100])
+	assert.NoError(t, err)
+	_, err = logFile.Seek(0, 0)
+	assert.NoError(t, err)
+
+	flightLog := NewFlightLogReader(FlightLogReaderOpts{Follow: true, FollowIdleTimeout: 300 * time.Millisecond})
+	frameChan, err := flightLog.LoadFile(context.Background(), logFile)
+	assert.NoError(t, err)
+
+	started := time.Now()
+	frames := 0
+	for range frameChan {
+		frames++
+	}
+	assert.True(t, frames > 0)
+	assert.True(t, time.Since(started) >= 300*time.Millisecond)
+}
+
+func TestFollowCanceled(t *testing.T) {
+	logFile := tempLogFile(t)
+	defer os.Remove(logFile.Name())
+	defer logFile.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	// Nothing is written, not even the headers
+	_, err := NewFlightLogReader(FlightLogReaderOpts{Follow: true}).LoadFile(ctx, logFile)
+	assert.Error(t, err)
+}
+
+func tempLogFile(t *testing.T) *os.File {
+	logFile, err := ioutil.TempFile("", "follow-*.bfl")
+	assert.NoError(t, err)
+	return logFile
+}
+
+// appendSlowly appends data to a file in small chunks, like a logger recording a flight
+func appendSlowly(path string, parts ...[]byte) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	for _, part := range parts {
+		for len(part) > 0 {
+			size := 200
+			if size > len(part) {
+				size = len(part)
+			}
+			if _, err := file.Write(part[:size]); err != nil {
+				return
+			}
+			part = part[size:]
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
@@ -6,27 +6,6 @@ import (
 	"strings"
 )
 
-var flightModeNames = map[int64]string{
-	1:   "ANGLE_MODE",
-	2:   "HORIZON_MODE",
-	4:   "MAG",
-	8:   "BARO",
-	16:  "GPS_HOME",
-	32:  "GPS_HOLD",
-	64:  "HEADFREE",
-	128: "AUTOTUNE",
-	256: "PASSTHRU",
-	512: "SONAR",
-}
-
-var flightStateNames = map[int64]string{
-	1:  "GPS_FIX_HOME",
-	2:  "GPS_FIX",
-	4:  "CALIBRATE_MAG",
-	8:  "SMALL_ANGLE",
-	16: "FIXED_WING",
-}
-
 var failsafePhaseNames = []string{
 	"IDLE",
 	"RX_LOSS_DETECTED",
@@ -34,6 +13,11 @@ var failsafePhaseNames = []string{
 	"LANDED",
 }
 
+// FailsafePhaseName returns the name of a value of the failsafePhase slow field
+func FailsafePhaseName(value int64) string {
+	return decodeEnumToString(failsafePhaseNames, value)
+}
+
 // See https://cleanflight.readthedocs.io/en/stable/development/Blackbox%20Internals/
 
 type Frame interface {
@@ -106,6 +90,13 @@ func (f *baseFrame) setValidity(validity bool) {
 	f.validity = validity
 }
 
+// MarkValid flags a frame as part of a valid stream and returns it.
+// Frames built outside of a FrameReader are otherwise considered as desynchronized.
+func MarkValid(frame Frame) Frame {
+	frame.setValidity(true)
+	return frame
+}
+
 // -------------------------------------------------------------------------- //
 
 // Frame represents a frame
@@ -137,6 +128,7 @@ func (f MainFrame) Values() interface{} {
 type SlowFrame struct {
 	baseFrame
 	values []int64
+	rules  DecodingRules
 }
 
 // NewSlowFrame returns a new frame
@@ -152,6 +144,52 @@ func NewSlowFrame(values []int64, start, end int64, err error) *SlowFrame {
 	}
 }
 
+// GPSFrame represents a frame with the GPS position and speed
+type GPSFrame struct {
+	baseFrame
+	values []int64
+}
+
+// NewGPSFrame returns a new GPS frame
+func NewGPSFrame(values []int64, start, end int64, err error) *GPSFrame {
+	return &GPSFrame{
+		values: values,
+		baseFrame: baseFrame{
+			frameType: LogFrameGPS,
+			start:     start,
+			end:       end,
+			err:       err,
+		},
+	}
+}
+
+func (f GPSFrame) Values() interface{} {
+	return f.values
+}
+
+// GPSHomeFrame represents a frame with the GPS home position, which GPS frames are predicted from
+type GPSHomeFrame struct {
+	baseFrame
+	values []int64
+}
+
+// NewGPSHomeFrame returns a new GPS home frame
+func NewGPSHomeFrame(values []int64, start, end int64, err error) *GPSHomeFrame {
+	return &GPSHomeFrame{
+		values: values,
+		baseFrame: baseFrame{
+			frameType: LogFrameGPSHome,
+			start:     start,
+			end:       end,
+			err:       err,
+		},
+	}
+}
+
+func (f GPSHomeFrame) Values() interface{} {
+	return f.values
+}
+
 // ErrorFrame represents a frame that couldn't be recognized
 type ErrorFrame struct {
 	baseFrame
@@ -177,7 +215,7 @@ func (f SlowFrame) Values() interface{} {
 func (f SlowFrame) StringValues() []string {
 	values := make([]string, len(f.values))
 	for k, v := range f.values {
-		values[k] = slowFrameFlagToString(k, v)
+		values[k] = slowFrameFlagToString(f.rules, k, v)
 	}
 	return values
 }
@@ -186,12 +224,12 @@ func (f SlowFrame) String() string {
 	return fmt.Sprintf("S frame: %s", strings.Join(f.StringValues(), ", "))
 }
 
-func slowFrameFlagToString(fieldIndex int, value int64) string {
+func slowFrameFlagToString(rules DecodingRules, fieldIndex int, value int64) string {
 	switch fieldIndex {
 	case 0:
-		return decodeFlagsToString(flightModeNames, value)
+		return rules.FlightModes(value).String()
 	case 1:
-		return decodeFlagsToString(flightStateNames, value)
+		return decodeFlagsToString(rules.FlightStates(value))
 	case 2:
 		return decodeEnumToString(failsafePhaseNames, value)
 	default:
@@ -199,17 +237,11 @@ func slowFrameFlagToString(fieldIndex int, value int64) string {
 	}
 }
 
-func decodeFlagsToString(flags map[int64]string, flagValue int64) string {
-	flagsAsStrings := []string{}
-	for k, v := range flags {
-		if flagValue&k == k {
-			flagsAsStrings = append(flagsAsStrings, v)
-		}
-	}
-	if len(flagsAsStrings) == 0 {
+func decodeFlagsToString(names []string) string {
+	if len(names) == 0 {
 		return "0"
 	}
-	return strings.Join(flagsAsStrings, "|")
+	return strings.Join(names, "|")
 }
 
 func decodeEnumToString(enum []string, value int64) string {
@@ -0,0 +1,174 @@
+package blackbox
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+	"github.com/pkg/errors"
+)
+
+const (
+	// inspectScanSize is the number of bytes searched at once for an intra frame
+	inspectScanSize = 64 * 1024
+)
+
+// SessionInfo describes a session from its headers and the first and last intra frames
+// found in it, without decoding the frames in between
+type SessionInfo struct {
+	LogSession
+	Definition LogDefinition
+
+	// FramesStart is the offset of the first frame, relative to the start of the session
+	FramesStart int64
+
+	// FirstIntraFrame and LastIntraFrame are nil when no intra frame was found
+	FirstIntraFrame *MainFrame
+	LastIntraFrame  *MainFrame
+}
+
+// Duration returns the time between the first and last intra frames, which is the
+// duration of the session give or take the interval between two intra frames
+func (s SessionInfo) Duration() time.Duration {
+	timeIdx, err := s.Definition.GetFieldIndex(FieldTime)
+	if err != nil || s.FirstIntraFrame == nil || s.LastIntraFrame == nil {
+		return 0
+	}
+	return time.Duration(s.LastIntraFrame.values[timeIdx]-s.FirstIntraFrame.values[timeIdx]) * time.Microsecond
+}
+
+// InspectSession reads the headers of a session of a log file, and looks for its first
+// intra frame from the start and its last from the end
+func InspectSession(r io.ReaderAt, session LogSession) (SessionInfo, error) {
+	section := io.NewSectionReader(r, session.Start, session.Size())
+	dec := stream.NewDecoder(bufio.NewReaderSize(section, defaultBufferSize))
+	headerReader := NewHeaderReader(dec)
+	frameDef, err := headerReader.ProcessHeaders()
+	if errors.Cause(err) == io.EOF {
+		// The session has nothing but headers
+		headerReader.applyFirmwareRules()
+		frameDef = headerReader.def
+	} else if err != nil {
+		return SessionInfo{}, err
+	}
+
+	info := SessionInfo{LogSession: session, Definition: frameDef, FramesStart: dec.Offset()}
+	scanner := newIntraFrameScanner(section, frameDef)
+
+	// Intra frames are looked for in chunks, going forward for the first one and
+	// backward for the last one
+	for offset := info.FramesStart; offset < session.Size() && info.FirstIntraFrame == nil; offset += inspectScanSize {
+		info.FirstIntraFrame, err = scanner.find(offset, offset+inspectScanSize, true)
+		if err != nil {
+			return info, err
+		}
+	}
+	if info.FirstIntraFrame == nil {
+		return info, nil
+	}
+
+	firstOffset := int64(info.FirstIntraFrame.Start())
+	for end := session.Size(); end > firstOffset && info.LastIntraFrame == nil; end -= inspectScanSize {
+		info.LastIntraFrame, err = scanner.find(end-inspectScanSize, end, false)
+		if err != nil {
+			return info, err
+		}
+	}
+	return info, nil
+}
+
+// intraFrameScanner finds intra frames in a session. Any byte equal to 'I' could be the
+// start of one, so it's only considered as such if it decodes to an intra frame that is
+// followed by another frame, and whose iteration and time are consistent with the first
+// intra frame found.
+type intraFrameScanner struct {
+	section  *io.SectionReader
+	frameDef LogDefinition
+	first    *MainFrame
+
+	// iterationIdx and timeIdx are the positions of the loopIteration and time fields,
+	// or -1 if the log doesn't have them, in which case they aren't checked
+	iterationIdx int
+	timeIdx      int
+}
+
+func newIntraFrameScanner(section *io.SectionReader, frameDef LogDefinition) *intraFrameScanner {
+	s := &intraFrameScanner{section: section, frameDef: frameDef, iterationIdx: -1, timeIdx: -1}
+	if index, err := frameDef.GetFieldIndex(FieldIteration); err == nil {
+		s.iterationIdx = index
+	}
+	if index, err := frameDef.GetFieldIndex(FieldTime); err == nil {
+		s.timeIdx = index
+	}
+	return s
+}
+
+// find returns the first or last intra frame starting between two offsets
+func (s *intraFrameScanner) find(from, to int64, forward bool) (*MainFrame, error) {
+	if from < 0 {
+		from = 0
+	}
+	if to > s.section.Size() {
+		to = s.section.Size()
+	}
+
+	buf := make([]byte, to-from)
+	n, err := s.section.ReadAt(buf, from)
+	if err != nil && err != io.EOF {
+		return nil, errors.WithStack(err)
+	}
+	buf = buf[:n]
+
+	for i := range buf {
+		index := i
+		if !forward {
+			index = len(buf) - 1 - i
+		}
+		if buf[index] != LogFrameIntra {
+			continue
+		}
+		if frame := s.readIntraFrame(from + int64(index)); frame != nil {
+			if s.first == nil {
+				s.first = frame
+			}
+			return frame, nil
+		}
+	}
+	return nil, nil
+}
+
+// readIntraFrame returns the intra frame starting at an offset, or nil if there isn't one
+func (s *intraFrameScanner) readIntraFrame(offset int64) *MainFrame {
+	dec := stream.NewDecoder(bufio.NewReader(io.NewSectionReader(s.section, offset, s.section.Size()-offset)))
+	frame := NewFrameReader(dec, s.frameDef, nil).ReadNextFrame()
+	mainFrame, ok := frame.(*MainFrame)
+	if !ok || frame.Type() != LogFrameIntra || frame.Error() != nil || !frame.Validity() || len(mainFrame.values) != len(s.frameDef.FieldsI) {
+		return nil
+	}
+
+	// The frame must be followed by another one, or by the end of the session
+	next, err := dec.NextByte()
+	if err == nil && bytes.IndexByte(LogFrameAllTypes, next) == -1 {
+		return nil
+	}
+
+	// Intra frames are logged every I interval iterations
+	if s.iterationIdx >= 0 {
+		iteration := mainFrame.values[s.iterationIdx]
+		if s.frameDef.Sysconfig.FrameIntervalI > 1 && iteration%int64(s.frameDef.Sysconfig.FrameIntervalI) != 0 {
+			return nil
+		}
+		if s.first != nil && iteration < s.first.values[s.iterationIdx] {
+			return nil
+		}
+	}
+	if s.timeIdx >= 0 && s.first != nil && mainFrame.values[s.timeIdx] < s.first.values[s.timeIdx] {
+		return nil
+	}
+
+	mainFrame.start += offset
+	mainFrame.end += offset
+	return mainFrame
+}
@@ -0,0 +1,72 @@
+package blackbox
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspectSession(t *testing.T) {
+	content := readFixture(t)
+	original := readFixtureMainFrames(t, bytes.NewReader(content))
+
+	// Append an intra frame logged 100ms and one I interval after the first one
+	headerReader := NewHeaderReader(stream.NewDecoder(bytes.NewReader(content)))
+	frameDef, err := headerReader.ProcessHeaders()
+	assert.NoError(t, err)
+
+	last := append([]int64{}, original[0]...)
+	last[0] += 128
+	last[1] += 100000
+
+	var tail bytes.Buffer
+	logWriter := NewLogWriter(stream.NewEncoder(&tail), frameDef)
+	assert.NoError(t, logWriter.WriteIntraFrame(last))
+	assert.NoError(t, logWriter.WriteLogEnd())
+
+	logFile := concatBytes(content[:fixtureFrameP52996Offset], tail.Bytes())
+	session := LogSession{Index: 1, Start: 0, End: int64(len(logFile))}
+	info, err := InspectSession(bytes.NewReader(logFile), session)
+	assert.NoError(t, err)
+
+	assert.Equal(t, session, info.LogSession)
+	assert.Equal(t, "Ergo", info.Definition.CraftName)
+	assert.Equal(t, original[0], info.FirstIntraFrame.Values())
+	assert.Equal(t, last, info.LastIntraFrame.Values())
+	assert.Equal(t, int64(fixtureFrameP52996Offset), int64(info.LastIntraFrame.Start()))
+	assert.Equal(t, 100*time.Millisecond, info.Duration())
+}
+
+func TestInspectSessionWithoutFrames(t *testing.T) {
+	content := readFixture(t)
+	lastHeader := bytes.LastIndex(content, []byte("\nH "))
+	headers := content[:lastHeader+bytes.IndexByte(content[lastHeader+1:], '\n')+2]
+
+	info, err := InspectSession(bytes.NewReader(headers), LogSession{Index: 1, Start: 0, End: int64(len(headers))})
+	assert.NoError(t, err)
+	assert.Equal(t, "Ergo", info.Definition.CraftName)
+	assert.Equal(t, "Betaflight 4.0.0 MATEKF405", info.Definition.Firmware.String())
+	assert.Equal(t, int64(len(headers)), info.FramesStart)
+	assert.Nil(t, info.FirstIntraFrame)
+	assert.Equal(t, time.Duration(0), info.Duration())
+}
+
+func TestSessionInfoDurationFieldOrder(t *testing.T) {
+	frameDef := LogDefinition{
+		FieldsI:  []FieldDefinition{{Name: FieldTime}, {Name: "motor[0]"}, {Name: FieldIteration}},
+		FieldIRL: map[FieldName]int{FieldTime: 0, "motor[0]": 1, FieldIteration: 2},
+	}
+	info := SessionInfo{
+		Definition:      frameDef,
+		FirstIntraFrame: NewMainFrame(LogFrameIntra, []int64{1000000, 1200, 0}, 0, 0, nil),
+		LastIntraFrame:  NewMainFrame(LogFrameIntra, []int64{3500000, 1300, 1024}, 0, 0, nil),
+	}
+	assert.Equal(t, 2500*time.Millisecond, info.Duration())
+
+	// Without a time field, the duration isn't known
+	info.Definition = LogDefinition{FieldsI: frameDef.FieldsI, FieldIRL: map[FieldName]int{"motor[0]": 1}}
+	assert.Equal(t, time.Duration(0), info.Duration())
+}
@@ -1,6 +1,8 @@
 package blackbox
 
 import (
+	"math"
+
 	"github.com/pkg/errors"
 )
 
@@ -8,12 +10,13 @@ type LogFrameType = byte
 
 const (
 	// See https://cleanflight.readthedocs.io/en/stable/development/Blackbox%20Internals/
-	LogFrameEvent  LogFrameType = 69 // E
-	LogFrameIntra               = 73 // I
-	LogFrameInter               = 80 // P
-	LogFrameSlow                = 83 // S
-	LogFrameHeader              = 72 // H
-	LogFrameGPS                 = 71 // G
+	LogFrameEvent   LogFrameType = 69 // E
+	LogFrameIntra                = 73 // I
+	LogFrameInter                = 80 // P
+	LogFrameSlow                 = 83 // S
+	LogFrameHeader               = 72 // H
+	LogFrameGPS                  = 71 // G
+	LogFrameGPSHome              = 72 // H, once the headers have been read
 )
 
 var LogFrameAllTypes = []byte{
@@ -33,6 +36,7 @@ const (
 	LogEventSyncBeep           LogEventType = 0
 	LogEventInflightAdjustment              = 13
 	LogEventLoggingResume                   = 14
+	LogEventDisarm                          = 15
 	LogEventFlightMode                      = 30
 	LogEventLogEnd                          = 255
 )
@@ -49,9 +53,15 @@ type LogDefinition struct {
 	DataVersion      int
 	LogStartDatetime string
 	CraftName        string
+	Dialect          FirmwareDialect
+	Firmware         FirmwareVersion
+	Rules            DecodingRules
+	DebugMode        DebugMode
 	FieldsS          []FieldDefinition
 	FieldsI          []FieldDefinition
 	FieldsP          []FieldDefinition
+	FieldsG          []FieldDefinition
+	FieldsH          []FieldDefinition
 	Headers          []Header
 	Sysconfig        SysconfigType
 	FieldIRL         map[FieldName]int
@@ -64,6 +74,18 @@ type FieldDefinition struct {
 	Predictor  int64
 	Encoding   int64
 	GroupCount int
+
+	// Decimals is the number of decimal digits of fixed-point values: the value of the
+	// field is the integer divided by 10^Decimals. Only derived fields have some.
+	Decimals int
+}
+
+// Value returns the value of the field for the integer found in a frame
+func (f FieldDefinition) Value(value int64) float64 {
+	if f.Decimals == 0 {
+		return float64(value)
+	}
+	return float64(value) / math.Pow10(f.Decimals)
 }
 
 // SysconfigType represents a sysconfig
@@ -77,9 +99,9 @@ type SysconfigType struct {
 	Acc1G                  uint16
 	GyroScale              float64
 	Vbatscale              uint8
-	Vbatmaxcellvoltage     uint8
-	Vbatmincellvoltage     uint8
-	Vbatwarningcellvoltage uint8
+	Vbatmaxcellvoltage     uint16
+	Vbatmincellvoltage     uint16
+	Vbatwarningcellvoltage uint16
 	CurrentMeterOffset     uint16
 	CurrentMeterScale      uint16
 	Vbatref                uint16
@@ -139,8 +161,64 @@ func (f *LogDefinition) GetHeaderValue(headerName HeaderName) (string, error) {
 // GetFieldIndex returns the position of a field
 func (f *LogDefinition) GetFieldIndex(fieldName FieldName) (int, error) {
 	index, ok := f.FieldIRL[fieldName]
+	if !ok {
+		if alias, found := f.Dialect.fieldAlias(fieldName); found {
+			index, ok = f.FieldIRL[alias]
+		}
+	}
+	if !ok {
+		if debugField, found := f.DebugMode.fieldOf(fieldName); found {
+			index, ok = f.FieldIRL[debugField]
+		}
+	}
 	if !ok {
 		return 0, errors.Errorf("Field definition for '%s' not found: %v", fieldName, f.FieldIRL)
 	}
 	return index, nil
 }
+
+// DebugSlot returns what a field holds if it's one of the debug fields and the debug mode is known
+func (f *LogDefinition) DebugSlot(fieldName FieldName) (DebugSlot, bool) {
+	return f.DebugMode.Slot(debugSlotIndex(fieldName))
+}
+
+// fieldsOf returns the definition of the fields of a frame type
+func (f *LogDefinition) fieldsOf(frameType LogFrameType) []FieldDefinition {
+	switch frameType {
+	case LogFrameIntra:
+		return f.FieldsI
+	case LogFrameInter:
+		return f.FieldsP
+	case LogFrameSlow:
+		return f.FieldsS
+	case LogFrameGPS:
+		return f.FieldsG
+	case LogFrameGPSHome:
+		return f.FieldsH
+	}
+	return nil
+}
+
+// indexOfField returns the position of a field, or -1 if it's not defined
+func indexOfField(fields []FieldDefinition, fieldName FieldName) int {
+	for i, field := range fields {
+		if field.Name == fieldName {
+			return i
+		}
+	}
+	return -1
+}
+
+// countSkippedFramesAfter returns how many iterations the firmware intentionally
+// didn't log after the given one, according to the I and P intervals
+func (f *LogDefinition) countSkippedFramesAfter(iteration int64) int64 {
+	count := int64(0)
+	for frameIndex := iteration + 1; !f.frameExpected(int(frameIndex)); frameIndex++ {
+		count++
+	}
+	return count
+}
+
+func (f *LogDefinition) frameExpected(frameIndex int) bool {
+	return (frameIndex%f.Sysconfig.FrameIntervalI+f.Sysconfig.FrameIntervalPNum-1)%f.Sysconfig.FrameIntervalPDenom < f.Sysconfig.FrameIntervalPNum
+}
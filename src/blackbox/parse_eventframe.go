@@ -1,16 +1,37 @@
 package blackbox
 
 import (
+	"encoding/binary"
+	"io"
+	"math"
+
 	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
 	"github.com/pkg/errors"
 )
 
-func parseEventFrame(dec *stream.Decoder) (LogEventType, eventValues, error) {
+const (
+	// inflightAdjustmentFloatFlag is set on the adjustment function when the new value is a float
+	inflightAdjustmentFloatFlag = 128
+
+	// logEndFirmwareLength is the length of the message the firmware writes after the log end event,
+	// 'End of log' and its null terminator
+	logEndFirmwareLength = 11
+
+	// logEndDataLength is the number of bytes read after the log end event of a log file
+	logEndDataLength = 12
+)
+
+// parseEventFrame reads an event. A log end event must be followed by the end of the file, unless
+// the log is read from a stream where the next session may follow right away.
+func parseEventFrame(dialect FirmwareDialect, dec *stream.Decoder, fromStream bool) (LogEventType, eventValues, error) {
 	values := make(eventValues)
 	eventType, err := dec.ReadByte()
 	if err != nil {
 		return 0, nil, err
 	}
+	if !dialect.supportsEvent(eventType) {
+		return 0, nil, errors.Errorf("Event type is unknown to %s logs - ignored: %v\n", dialect, eventType)
+	}
 
 	switch eventType {
 	case LogEventSyncBeep:
@@ -22,7 +43,26 @@ func parseEventFrame(dec *stream.Decoder) (LogEventType, eventValues, error) {
 		values["beepTime"] = beepTime
 
 	case LogEventInflightAdjustment:
-		return 0, nil, errors.New("Not implemented: logEventInflightAdjustment")
+		function, err := dec.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		values["name"] = "Inflight adjustment"
+		values["function"] = function &^ inflightAdjustmentFloatFlag
+
+		if function&inflightAdjustmentFloatFlag != 0 {
+			raw, err := dec.ReadBytes(4)
+			if err != nil {
+				return 0, nil, err
+			}
+			values["value"] = math.Float32frombits(binary.LittleEndian.Uint32(raw))
+		} else {
+			value, err := dec.ReadSignedVB()
+			if err != nil {
+				return 0, nil, err
+			}
+			values["value"] = value
+		}
 
 	case LogEventLoggingResume:
 		val, err := dec.ReadUnsignedVB()
@@ -38,6 +78,14 @@ func parseEventFrame(dec *stream.Decoder) (LogEventType, eventValues, error) {
 		values["name"] = "Logging resume"
 		values["currentTime"] = int64(val)
 
+	case LogEventDisarm:
+		reason, err := dec.ReadUnsignedVB()
+		if err != nil {
+			return 0, nil, err
+		}
+		values["name"] = "Disarm"
+		values["reason"] = reason
+
 	case LogEventFlightMode:
 		flags, err := dec.ReadUnsignedVB()
 		if err != nil {
@@ -52,9 +100,26 @@ func parseEventFrame(dec *stream.Decoder) (LogEventType, eventValues, error) {
 		values["lastFlags"] = lastFlags
 
 	case LogEventLogEnd:
-		val, err := dec.ReadBytes(12)
-		if err != nil {
-			return 0, nil, err
+		if fromStream {
+			val, err := dec.ReadBytes(logEndFirmwareLength)
+			if err != nil {
+				return 0, nil, err
+			}
+			values["name"] = "Log clean end"
+			values["data"] = val
+			break
+		}
+
+		// Files may end before, the missing bytes are left to zero
+		val := make([]byte, logEndDataLength)
+		for i := range val {
+			b, err := dec.ReadByte()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return 0, nil, err
+			}
+			val[i] = b
 		}
 
 		reachedEndOfFile, err := dec.EOF()
@@ -26,10 +26,13 @@ const (
 
 	// EncodingNull is for nothing is written to the file take value to be zero
 	EncodingNull = 9
+
+	// EncodingTag2_3SVariable is for a 2-bit header is written, followed by 3 signed field values packed in 2, 5-5-4, 8-7-7 bits or up to 32 bits each. INAV only.
+	EncodingTag2_3SVariable = 10
 )
 
 // parseStateFrame parse any kind of data frame and applies prediction
-func parseStateFrame(frameDef LogDefinition, fields []FieldDefinition, previousFrame, previousPreviousFrame *MainFrame, dec *stream.Decoder, disablePredicator bool, skippedFrames int64) ([]int64, error) {
+func parseStateFrame(frameDef LogDefinition, fields []FieldDefinition, history FrameHistory, dec *stream.Decoder, disablePredicator bool, skippedFrames int64) ([]int64, error) {
 	frameValues := make([]int64, len(fields))
 	framesToSkip := 0
 	for i, field := range fields {
@@ -47,8 +50,8 @@ func parseStateFrame(frameDef LogDefinition, fields []FieldDefinition, previousF
 		// Simple predicator that increments fields. No need to do more
 		if field.Predictor == PredictorInc {
 			frameValues[i] = skippedFrames + 1
-			if previousFrame != nil {
-				frameValues[i] += previousFrame.values[i]
+			if history.Previous != nil {
+				frameValues[i] += history.Previous.values[i]
 			}
 			continue
 		}
@@ -84,7 +87,7 @@ func parseStateFrame(frameDef LogDefinition, fields []FieldDefinition, previousF
 				return nil, err
 			}
 			for j := 0; j < field.GroupCount; j++ {
-				v, err := ApplyPrediction(frameDef, vals, i+j, int(field.Predictor), int64(vals[j]), previousFrame, previousPreviousFrame)
+				v, err := ApplyPrediction(frameDef, vals, i+j, int(field.Predictor), int64(vals[j]), history)
 				if err != nil {
 					return nil, err
 				}
@@ -98,7 +101,24 @@ func parseStateFrame(frameDef LogDefinition, fields []FieldDefinition, previousF
 				return nil, err
 			}
 			for j := 0; j < 3; j++ {
-				v, err := ApplyPrediction(frameDef, vals, i+j, int(field.Predictor), vals[j], previousFrame, previousPreviousFrame)
+				v, err := ApplyPrediction(frameDef, vals, i+j, int(field.Predictor), vals[j], history)
+				if err != nil {
+					return nil, err
+				}
+				frameValues[i+j] = v
+			}
+			framesToSkip = 2
+			continue
+		case EncodingTag2_3SVariable:
+			if !frameDef.Dialect.supportsEncoding(field.Encoding) {
+				return nil, errors.Errorf("Encoding '%d' of field '%s' is not supported for %s logs", field.Encoding, field.Name, frameDef.Dialect)
+			}
+			vals, err := dec.ReadTag2_3SVariable()
+			if err != nil {
+				return nil, err
+			}
+			for j := 0; j < 3; j++ {
+				v, err := ApplyPrediction(frameDef, vals, i+j, int(field.Predictor), vals[j], history)
 				if err != nil {
 					return nil, err
 				}
@@ -109,7 +129,7 @@ func parseStateFrame(frameDef LogDefinition, fields []FieldDefinition, previousF
 		case EncodingTag8_4S16:
 			var vals []int64
 			var err error
-			if frameDef.DataVersion == 1 {
+			if frameDef.Rules.LegacyTag8_4S16 {
 				vals, err = dec.ReadTag8_4S16V1()
 			} else {
 				vals, err = dec.ReadTag8_4S16V2()
@@ -118,7 +138,7 @@ func parseStateFrame(frameDef LogDefinition, fields []FieldDefinition, previousF
 				return nil, err
 			}
 			for j := 0; j < 4; j++ {
-				v, err := ApplyPrediction(frameDef, vals, i+j, int(field.Predictor), vals[j], previousFrame, previousPreviousFrame)
+				v, err := ApplyPrediction(frameDef, vals, i+j, int(field.Predictor), vals[j], history)
 				if err != nil {
 					return nil, err
 				}
@@ -132,7 +152,7 @@ func parseStateFrame(frameDef LogDefinition, fields []FieldDefinition, previousF
 			return nil, errors.Errorf("Unsupported decoding '%d' for field '%s'", field.Encoding, field.Name)
 		}
 
-		v, err := ApplyPrediction(frameDef, frameValues, i, int(field.Predictor), value, previousFrame, previousPreviousFrame)
+		v, err := ApplyPrediction(frameDef, frameValues, i, int(field.Predictor), value, history)
 		if err != nil {
 			return nil, err
 		}
@@ -30,6 +30,10 @@ const (
 	// PredictorInc assumes that the field will be incremented by 1 unit for every main loop iteration. This is used to predict the `loopIteration` field, which increases by 1 for every loop iteration.
 	PredictorInc = 6
 
+	// PredictorHomeCoord is set to the latitude of the GPS home position, found in the last GPS home frame.
+	// It is used for 'GPS_coord[0]', the prediction for 'GPS_coord[1]' being replaced by PredictorHomeCoord1 when reading the headers.
+	PredictorHomeCoord = 7
+
 	// Predictor1500 is set to a fixed value of 1500.
 	// It is preferred for logging servo values in intraframes, since these  typically lie close to the midpoint of 1500us.
 	Predictor1500 = 8
@@ -38,12 +42,33 @@ const (
 	// It is used when logging intraframe battery voltages in Cleanflight, since these are expected to be broadly similar to the first battery voltage seen during arming.
 	PredictorVbatRef = 9
 
+	// PredictorLastMainFrameTime is set to the time of the last main frame. It is used for the time of GPS frames.
+	PredictorLastMainFrameTime = 10
+
 	// PredictorMinMotor returns the value and the minimum motor low output summed
 	PredictorMinMotor = 11
+
+	// PredictorHomeCoord1 is set to the longitude of the GPS home position. It is never written in logs.
+	PredictorHomeCoord1 = 256
 )
 
+// FrameHistory holds what was decoded before a frame and predictors can refer to
+type FrameHistory struct {
+	// Previous and Previous2 are the last two main frames
+	Previous  *MainFrame
+	Previous2 *MainFrame
+
+	// GPSHome holds the values of the last GPS home frame
+	GPSHome []int64
+
+	// LastMainFrameTime is the time of the last main frame, used by GPS frames
+	LastMainFrameTime int64
+}
+
 // ApplyPrediction a predictor on a field and return the resulting value
-func ApplyPrediction(frameDef LogDefinition, values []int64, fieldIndex int, predictor int, value int64, previous *MainFrame, previous2 *MainFrame) (int64, error) {
+func ApplyPrediction(frameDef LogDefinition, values []int64, fieldIndex int, predictor int, value int64, history FrameHistory) (int64, error) {
+	previous := history.Previous
+	previous2 := history.Previous2
 
 	// First see if we have a prediction that doesn't require a previous frame as reference:
 	switch predictor {
@@ -85,8 +110,26 @@ func ApplyPrediction(frameDef LogDefinition, values []int64, fieldIndex int, pre
 		value = value + (previous.values[fieldIndex]+previous2.values[fieldIndex])/2
 	case PredictorMinMotor:
 		value += int64(frameDef.Sysconfig.MotorOutputLow)
+	case PredictorHomeCoord, PredictorHomeCoord1:
+		homeFieldName := FieldGPSHome0
+		if predictor == PredictorHomeCoord1 {
+			homeFieldName = FieldGPSHome1
+		}
+		homeIndex := indexOfField(frameDef.FieldsH, homeFieldName)
+		if homeIndex == -1 {
+			return value, errors.Errorf("Field definition for '%s' not found", homeFieldName)
+		}
+		if history.GPSHome == nil {
+			return value, errors.New("No GPS home frame to predict from")
+		}
+		value += history.GPSHome[homeIndex]
+	case PredictorLastMainFrameTime:
+		if history.LastMainFrameTime == -1 {
+			return value, errors.New("No main frame to predict from")
+		}
+		value += history.LastMainFrameTime
 	default:
-		return value, errors.Errorf("Unsupported field predictor %d", predictor)
+		return value, errors.Errorf("Unsupported field predictor %d for %s logs", predictor, frameDef.Dialect)
 	}
 
 	return value, nil
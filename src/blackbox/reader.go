@@ -24,6 +24,13 @@ type FlightLogReader struct {
 // FlightLogReaderOpts holds the options to get a new FlightLogReader
 type FlightLogReaderOpts struct {
 	Raw bool
+
+	// Follow keeps reading a file while it's being written, until the log ends
+	Follow bool
+
+	// FollowIdleTimeout stops following a file once no data was appended for this long.
+	// Zero waits forever.
+	FollowIdleTimeout time.Duration
 }
 
 // NewFlightLogReader returns a new FlightLogReader
@@ -36,7 +43,13 @@ func NewFlightLogReader(opts FlightLogReaderOpts) *FlightLogReader {
 // LoadFile reads flight logs from a file.
 // Accepts context and stops processing when the context is canceled.
 // Returns channel with successfully parsed frames.
+// When following, the channel is closed after the log end event, or once no data
+// was appended for the idle timeout.
 func (f *FlightLogReader) LoadFile(ctx context.Context, file io.Reader) (<-chan Frame, error) {
+	if f.opts.Follow {
+		file = newFollowReader(ctx, file, f.opts.FollowIdleTimeout)
+	}
+
 	frameReader, err := f.initFrameReader(file)
 	if err != nil {
 		return nil, err
@@ -44,7 +57,9 @@ func (f *FlightLogReader) LoadFile(ctx context.Context, file io.Reader) (<-chan
 
 	frameChan := make(chan Frame)
 	go func() {
-		f.Stats = readFrameToChannel(ctx, frameReader, frameChan)
+		// Stats are set before the channel is closed, for readers to find them once it is
+		defer close(frameChan)
+		f.Stats = readFrameToChannel(ctx, frameReader, frameChan, f.opts.Follow)
 	}()
 
 	return frameChan, nil
@@ -62,15 +77,16 @@ func (f *FlightLogReader) initFrameReader(file io.Reader) (*FrameReader, error)
 
 	f.FrameDef = frameDefinition
 
+	// The log end event is read as the firmware writes it, without the trailing byte
+	// of the files, which would be waited for forever when following
 	opts := &FrameReaderOptions{
-		Raw: f.opts.Raw,
+		Raw:    f.opts.Raw,
+		Stream: f.opts.Follow,
 	}
 	return NewFrameReader(decoder, frameDefinition, opts), nil
 }
 
-func readFrameToChannel(ctx context.Context, frameReader *FrameReader, frameChan chan Frame) *LogStatistics {
-	defer close(frameChan)
-
+func readFrameToChannel(ctx context.Context, frameReader *FrameReader, frameChan chan Frame, stopAtLogEnd bool) *LogStatistics {
 	stats := NewLogStatistics()
 	stats.HeaderBytes = int(frameReader.dec.Offset())
 
@@ -110,11 +126,19 @@ FrameReadingLoop:
 		}
 
 		frameChan <- frame
+
+		if event, ok := frame.(*EventFrame); stopAtLogEnd && ok && frame.Error() == nil && event.EventType() == LogEventLogEnd {
+			break
+		}
 	}
 	return stats
 }
 
 func updatedFrameStatistics(frame Frame, stats *FrameStatistics) *FrameStatistics {
+	// GPS frames are only counted for logs having some
+	if stats == nil {
+		stats = &FrameStatistics{}
+	}
 	if frame.Error() != nil {
 		stats.CorruptCount++
 	} else {
@@ -28,12 +28,19 @@ const (
 	FieldIteration        FieldName = "loopIteration"
 	FieldTime             FieldName = "time"
 	FieldVbatLatest       FieldName = "vbatLatest"
+	FieldVbat             FieldName = "vbat"
 	FieldAmperageLatest   FieldName = "amperageLatest"
+	FieldAmperage         FieldName = "amperage"
 	FieldEnergyCumulative FieldName = "energyCumulative"
 	FieldFlightModeFlags  FieldName = "flightModeFlags"
 	FieldStateFlags       FieldName = "stateFlags"
 	FieldFailsafePhase    FieldName = "failsafePhase"
 	FieldMotor0           FieldName = "motor[0]"
+	FieldThrottle         FieldName = "rcCommand[3]"
+	FieldGPSCoord0        FieldName = "GPS_coord[0]"
+	FieldGPSCoord1        FieldName = "GPS_coord[1]"
+	FieldGPSHome0         FieldName = "GPS_home[0]"
+	FieldGPSHome1         FieldName = "GPS_home[1]"
 )
 
 // FrameReader reads and decodes data frame
@@ -44,6 +51,7 @@ type FrameReader struct {
 	mainStreamIsValid       bool
 	previousFrame1          *MainFrame
 	previousFrame2          *MainFrame
+	gpsHome                 []int64
 	dec                     *stream.Decoder
 	frameDef                LogDefinition
 	opts                    FrameReaderOptions
@@ -52,6 +60,9 @@ type FrameReader struct {
 // FrameReaderOptions holds the options to create a new FrameReader
 type FrameReaderOptions struct {
 	Raw bool
+
+	// Stream is true when the log is read while being written, and may contain several sessions
+	Stream bool
 }
 
 // NewFrameReader returns a new FrameReader
@@ -85,6 +96,11 @@ func (f *FrameReader) ReadNextFrame() Frame {
 	// Check if the frame could be read and as at least a reasonable size
 	if frame.Error() != nil && frame.Size() > maxFrameLength {
 		frame.setError(frameErrorLengthLimit(frame.Size()))
+	}
+
+	// Whatever follows a frame that couldn't be read can't be predicted from the previous frames
+	if frame.Error() != nil {
+		f.invalidateMainStream()
 		return frame
 	}
 
@@ -98,25 +114,45 @@ func (f *FrameReader) ReadNextFrame() Frame {
 func (f *FrameReader) parseFrame(frameType byte, startOffset int64) Frame {
 	switch frameType {
 	case LogFrameEvent:
-		eventType, values, err := parseEventFrame(f.dec)
+		eventType, values, err := parseEventFrame(f.frameDef.Dialect, f.dec, f.opts.Stream)
 		return NewEventFrame(eventType, values, startOffset, f.dec.Offset(), err)
 
 	case LogFrameSlow:
-		values, err := parseStateFrame(f.frameDef, f.frameDef.FieldsS, nil, nil, f.dec, f.opts.Raw, 0)
-		return NewSlowFrame(values, startOffset, f.dec.Offset(), err)
+		values, err := parseStateFrame(f.frameDef, f.frameDef.FieldsS, FrameHistory{}, f.dec, f.opts.Raw, 0)
+		frame := NewSlowFrame(values, startOffset, f.dec.Offset(), err)
+		frame.rules = f.frameDef.Rules
+		return frame
 
 	case LogFrameIntra:
-		values, err := parseStateFrame(f.frameDef, f.frameDef.FieldsI, f.previousFrame1, f.previousFrame2, f.dec, f.opts.Raw, 0)
+		values, err := parseStateFrame(f.frameDef, f.frameDef.FieldsI, f.mainFrameHistory(), f.dec, f.opts.Raw, 0)
 		return NewMainFrame(frameType, values, startOffset, f.dec.Offset(), err)
 
 	case LogFrameInter:
-		values, err := parseStateFrame(f.frameDef, f.frameDef.FieldsP, f.previousFrame1, f.previousFrame2, f.dec, f.opts.Raw, f.countIntentionallySkippedFrames())
+		values, err := parseStateFrame(f.frameDef, f.frameDef.FieldsP, f.mainFrameHistory(), f.dec, f.opts.Raw, f.countIntentionallySkippedFrames())
 		return NewMainFrame(frameType, values, startOffset, f.dec.Offset(), err)
 
-	default:
-		values, err := f.readBytesToNextFrame()
-		return NewErrorFrame(values, startOffset, f.dec.Offset(), errors.WithStack(frameErrorUnsupportedType(frameType, err)))
+	case LogFrameGPS:
+		// Logs recorded without GPS don't define GPS frames
+		if len(f.frameDef.FieldsG) > 0 {
+			history := FrameHistory{GPSHome: f.gpsHome, LastMainFrameTime: f.lastMainFrameTime}
+			values, err := parseStateFrame(f.frameDef, f.frameDef.FieldsG, history, f.dec, f.opts.Raw, 0)
+			return NewGPSFrame(values, startOffset, f.dec.Offset(), err)
+		}
+
+	case LogFrameGPSHome:
+		if len(f.frameDef.FieldsH) > 0 {
+			values, err := parseStateFrame(f.frameDef, f.frameDef.FieldsH, FrameHistory{}, f.dec, f.opts.Raw, 0)
+			return NewGPSHomeFrame(values, startOffset, f.dec.Offset(), err)
+		}
 	}
+
+	values, err := f.readBytesToNextFrame()
+	return NewErrorFrame(values, startOffset, f.dec.Offset(), errors.WithStack(frameErrorUnsupportedType(frameType, err)))
+}
+
+// mainFrameHistory returns the previous main frames, to predict the next one from
+func (f *FrameReader) mainFrameHistory() FrameHistory {
+	return FrameHistory{Previous: f.previousFrame1, Previous2: f.previousFrame2}
 }
 
 // validateFrame checks if a frame is valid and update the reader's internal state
@@ -129,16 +165,18 @@ func (f *FrameReader) validateFrame(frame Frame) bool {
 		}
 		return true
 
-	case LogFrameSlow:
+	case LogFrameSlow, LogFrameGPS:
+		return true
+
+	case LogFrameGPSHome:
+		f.gpsHome = frame.Values().([]int64)
 		return true
 
 	case LogFrameIntra:
 		f.flightLogApplyMainFrameTimeRollover(frame.(*MainFrame))
 
 		if !f.opts.Raw && f.lastMainFrameIteration != -1 && !f.validateMainFrameValues(frame.(*MainFrame)) {
-			f.mainStreamIsValid = false
-			f.previousFrame1 = nil
-			f.previousFrame2 = nil
+			f.invalidateMainStream()
 		} else {
 			f.mainStreamIsValid = true
 		}
@@ -158,9 +196,7 @@ func (f *FrameReader) validateFrame(frame Frame) bool {
 
 		// Only attempt to validate the frame values if we have something to check it against
 		if !f.opts.Raw && f.mainStreamIsValid && !f.validateMainFrameValues(frame.(*MainFrame)) {
-			f.mainStreamIsValid = false
-			f.previousFrame1 = nil
-			f.previousFrame2 = nil
+			f.invalidateMainStream()
 		}
 
 		if f.mainStreamIsValid {
@@ -178,6 +214,13 @@ func (f *FrameReader) validateFrame(frame Frame) bool {
 	return false
 }
 
+// invalidateMainStream drops the history until the next intra frame
+func (f *FrameReader) invalidateMainStream() {
+	f.mainStreamIsValid = false
+	f.previousFrame1 = nil
+	f.previousFrame2 = nil
+}
+
 // readBytesToNextFrame reads bytes until the begining of a frame is found or the end of the file is reached
 func (f *FrameReader) readBytesToNextFrame() ([]byte, error) {
 	values := []byte{}
@@ -199,17 +242,7 @@ func (f *FrameReader) countIntentionallySkippedFrames() int64 {
 	if f.lastMainFrameIteration == -1 {
 		return 0
 	}
-
-	count := 0
-	for frameIndex := f.lastMainFrameIteration + 1; !f.frameExpected(int(frameIndex)); frameIndex++ {
-		count++
-	}
-
-	return int64(count)
-}
-
-func (f *FrameReader) frameExpected(frameIndex int) bool {
-	return (frameIndex%f.frameDef.Sysconfig.FrameIntervalI+f.frameDef.Sysconfig.FrameIntervalPNum-1)%f.frameDef.Sysconfig.FrameIntervalPDenom < f.frameDef.Sysconfig.FrameIntervalPNum
+	return f.frameDef.countSkippedFramesAfter(f.lastMainFrameIteration)
 }
 
 func (f *FrameReader) flightLogApplyMainFrameTimeRollover(frame *MainFrame) {
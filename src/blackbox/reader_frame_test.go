@@ -176,6 +176,25 @@ func TestReadBrokenFrame(t *testing.T) {
 	assert.IsType(t, &ErrorFrame{}, frame)
 }
 
+func TestReadTruncatedFrame(t *testing.T) {
+	r := bytes.NewReader(buildStream(encodedFrameI, encodedFramesP[0][:10]))
+	dec := stream.NewDecoder(r)
+
+	frameDef := dummyFrameDefinition()
+
+	frameReader := NewFrameReader(dec, frameDef, nil)
+
+	frame := frameReader.ReadNextFrame()
+	assert.NoError(t, frame.Error())
+
+	frame = frameReader.ReadNextFrame()
+	assert.Equal(t, io.EOF, frame.Error())
+	assert.Equal(t, 51, frame.Start())
+	assert.Equal(t, 10, frame.Size())
+	assert.False(t, frame.Validity())
+	assert.False(t, frameReader.mainStreamIsValid)
+}
+
 func valid(frame Frame) Frame {
 	frame.setValidity(true)
 	return frame
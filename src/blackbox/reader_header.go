@@ -1,6 +1,7 @@
 package blackbox
 
 import (
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
@@ -14,42 +15,65 @@ type HeaderName string
 
 // List of all the headers used by the library
 const (
-	HeaderProduct         HeaderName = "Product"
-	HeaderDataVersion     HeaderName = "Data version"
-	HeaderIName           HeaderName = "Field I name"
-	HeaderISigned         HeaderName = "Field I signed"
-	HeaderIPredictor      HeaderName = "Field I predictor"
-	HeaderIEncoding       HeaderName = "Field I encoding"
-	HeaderPPredictor      HeaderName = "Field P predictor"
-	HeaderPEncoding       HeaderName = "Field P encoding"
-	HeaderSName           HeaderName = "Field S name"
-	HeaderSSigned         HeaderName = "Field S signed"
-	HeaderSPredictor      HeaderName = "Field S predictor"
-	HeaderSEncoding       HeaderName = "Field S encoding"
-	HeaderVbatref         HeaderName = "vbatref"
-	HeaderVbatcellvoltage HeaderName = "vbatcellvoltage"
-	HeaderCurrentMeter    HeaderName = "currentMeter"
-	HeaderMotorOutput     HeaderName = "motorOutput"
-	HeaderFirmwareType    HeaderName = "Firmware type"
-	HeaderIInterval       HeaderName = "I interval"
-	HeaderPInterval       HeaderName = "P interval"
+	HeaderProduct          HeaderName = "Product"
+	HeaderDataVersion      HeaderName = "Data version"
+	HeaderIName            HeaderName = "Field I name"
+	HeaderISigned          HeaderName = "Field I signed"
+	HeaderIPredictor       HeaderName = "Field I predictor"
+	HeaderIEncoding        HeaderName = "Field I encoding"
+	HeaderPPredictor       HeaderName = "Field P predictor"
+	HeaderPEncoding        HeaderName = "Field P encoding"
+	HeaderSName            HeaderName = "Field S name"
+	HeaderSSigned          HeaderName = "Field S signed"
+	HeaderSPredictor       HeaderName = "Field S predictor"
+	HeaderSEncoding        HeaderName = "Field S encoding"
+	HeaderGName            HeaderName = "Field G name"
+	HeaderGSigned          HeaderName = "Field G signed"
+	HeaderGPredictor       HeaderName = "Field G predictor"
+	HeaderGEncoding        HeaderName = "Field G encoding"
+	HeaderHName            HeaderName = "Field H name"
+	HeaderHSigned          HeaderName = "Field H signed"
+	HeaderHPredictor       HeaderName = "Field H predictor"
+	HeaderHEncoding        HeaderName = "Field H encoding"
+	HeaderVbatref          HeaderName = "vbatref"
+	HeaderVbatcellvoltage  HeaderName = "vbatcellvoltage"
+	HeaderCurrentMeter     HeaderName = "currentMeter"
+	HeaderMotorOutput      HeaderName = "motorOutput"
+	HeaderFirmwareType     HeaderName = "Firmware type"
+	HeaderFirmwareRevision HeaderName = "Firmware revision"
+	HeaderFirmwareDate     HeaderName = "Firmware date"
+	HeaderLogStartDatetime HeaderName = "Log start datetime"
+	HeaderCraftName        HeaderName = "Craft name"
+	HeaderMinThrottle      HeaderName = "minthrottle"
+	HeaderMaxThrottle      HeaderName = "maxthrottle"
+	HeaderAcc1G            HeaderName = "acc_1G"
+	HeaderGyroScale        HeaderName = "gyro_scale"
+	HeaderDebugMode        HeaderName = "debug_mode"
+	HeaderIInterval        HeaderName = "I interval"
+	HeaderPInterval        HeaderName = "P interval"
 
 	headerRegExp = `H ([^:]+):(.*)`
+
+	// fieldHeaderRegExp matches the headers defining the fields of a frame type
+	fieldHeaderRegExp = `^Field ([IPSGH]) (name|signed|predictor|encoding)$`
 )
 
 // HeaderReader reads the headers of a log file
 type HeaderReader struct {
-	def LogDefinition
-	enc *stream.Decoder
-	re  *regexp.Regexp
+	def     LogDefinition
+	enc     *stream.Decoder
+	re      *regexp.Regexp
+	fieldRe *regexp.Regexp
+	raw     []byte
 }
 
 // NewHeaderReader returns a new HeaderReader
 func NewHeaderReader(enc *stream.Decoder) HeaderReader {
 	return HeaderReader{
-		enc: enc,
-		def: defaultLogDefinition(),
-		re:  regexp.MustCompile(headerRegExp),
+		enc:     enc,
+		def:     defaultLogDefinition(),
+		re:      regexp.MustCompile(headerRegExp),
+		fieldRe: regexp.MustCompile(fieldHeaderRegExp),
 	}
 }
 
@@ -61,29 +85,54 @@ func (h *HeaderReader) ProcessHeaders() (LogDefinition, error) {
 			return h.def, err
 		}
 		if string(command) != "H" {
+			h.applyFirmwareRules()
 			return h.def, nil
 		}
 
-		var byteBuffer []string
-		for {
-			iteration, err := h.enc.ReadByte()
-			if err != nil {
-				return h.def, errors.WithStack(err)
-			}
-			if string(iteration) == "\n" {
-				err := h.parseHeader(strings.Join(byteBuffer, ""))
-				if err != nil {
-					return h.def, err
-				}
-				break
-			}
-			byteBuffer = append(byteBuffer, string(iteration))
+		if err := h.readHeaderLine(nil); err != nil {
+			return h.def, err
+		}
+	}
+}
+
+// readHeaderLine reads the rest of a header line whose beginning was already read, and parses it
+func (h *HeaderReader) readHeaderLine(start []byte) error {
+	line := append([]byte{}, start...)
+	for {
+		iteration, err := h.enc.ReadByte()
+		if err != nil {
+			return errors.WithStack(err)
 		}
+		if iteration == '\n' {
+			break
+		}
+		line = append(line, iteration)
 	}
+
+	h.raw = append(h.raw, line...)
+	h.raw = append(h.raw, '\n')
+	return h.parseHeader(string(line))
+}
+
+// RawHeaders returns the header lines read so far, as they appear in the log
+func (h *HeaderReader) RawHeaders() []byte {
+	return h.raw
 }
 
 func (h *HeaderReader) parseHeader(out string) error {
 	match := h.re.FindStringSubmatch(out)
+	if match == nil {
+		return errors.Errorf("Could not parse header line '%s'", out)
+	}
+
+	if fieldMatch := h.fieldRe.FindStringSubmatch(match[1]); fieldMatch != nil {
+		err := h.parseFieldHeader(fieldMatch[1][0], fieldMatch[2], match[1], match[2])
+		if err != nil {
+			return err
+		}
+		h.updateFieldIndexes()
+		return nil
+	}
 
 	switch HeaderName(match[1]) {
 	case HeaderProduct:
@@ -91,6 +140,28 @@ func (h *HeaderReader) parseHeader(out string) error {
 
 	case HeaderFirmwareType:
 		h.def.Sysconfig.FirmwareType = match[2]
+		revision, _ := h.def.GetHeaderValue(HeaderFirmwareRevision)
+		h.def.Dialect = detectDialect(match[2], revision)
+
+	case HeaderFirmwareRevision:
+		header := Header{
+			Name:  HeaderFirmwareRevision,
+			Value: match[2],
+		}
+		h.def.Headers = append(h.def.Headers, header)
+		h.def.Dialect = detectDialect(h.def.Sysconfig.FirmwareType, match[2])
+
+	case HeaderLogStartDatetime, HeaderCraftName:
+		header := Header{
+			Name:  HeaderName(match[1]),
+			Value: match[2],
+		}
+		h.def.Headers = append(h.def.Headers, header)
+		if header.Name == HeaderCraftName {
+			h.def.CraftName = match[2]
+		} else {
+			h.def.LogStartDatetime = match[2]
+		}
 
 	case HeaderDataVersion:
 		b, err := strconv.ParseInt(match[2], 10, 32)
@@ -99,105 +170,50 @@ func (h *HeaderReader) parseHeader(out string) error {
 		}
 		h.def.DataVersion = int(b)
 
-	case HeaderIName:
-		fieldsRaw := strings.Split(match[2], ",")
-		for _, fr := range fieldsRaw {
-			d := FieldDefinition{
-				Name: FieldName(fr),
-			}
-			h.def.FieldsI = append(h.def.FieldsI, d)
-			h.def.FieldsP = append(h.def.FieldsP, d)
-		}
-
-	case HeaderISigned:
-		fieldsRaw := strings.Split(match[2], ",")
-		for i, fr := range fieldsRaw {
-			b, err := strconv.ParseBool(fr)
-			if err != nil {
-				return errors.Errorf("Could not parse fieldISigned '%s' to bool", match[2])
-			}
-			h.def.FieldsI[i].Signed = b
-		}
-
-	case HeaderIPredictor:
-		fieldsRaw := strings.Split(match[2], ",")
-		for i, fr := range fieldsRaw {
-			n, err := strconv.ParseInt(fr, 10, 8)
-			if err != nil {
-				return errors.Errorf("Could not parse fieldIPredictor '%s' to int", match[2])
-			}
-			h.def.FieldsI[i].Predictor = n
+	case HeaderMinThrottle:
+		header := Header{
+			Name:  HeaderMinThrottle,
+			Value: match[2],
 		}
+		h.def.Headers = append(h.def.Headers, header)
 
-	case HeaderIEncoding:
-		fieldsRaw := strings.Split(match[2], ",")
-		for i, fr := range fieldsRaw {
-			n, err := strconv.ParseInt(fr, 10, 8)
-			if err != nil {
-				return errors.Errorf("Could not parse fieldIEncoding '%s' to int", match[2])
-			}
-			h.def.FieldsI[i].Encoding = n
-
+		val, err := strconv.ParseInt(match[2], 10, 32)
+		if err != nil {
+			return errors.Errorf("Could not parse '%s' value '%s' to int", match[1], match[2])
 		}
+		h.def.Sysconfig.MinThrottle = int(val)
 
-	case HeaderPPredictor:
-		fieldsRaw := strings.Split(match[2], ",")
-		for i, fr := range fieldsRaw {
-			n, err := strconv.ParseInt(fr, 10, 8)
-			if err != nil {
-				return errors.Errorf("Could not parse fieldPPredictor '%s' to int", match[2])
-			}
-			h.def.FieldsP[i].Predictor = n
+	case HeaderMaxThrottle:
+		header := Header{
+			Name:  HeaderMaxThrottle,
+			Value: match[2],
 		}
+		h.def.Headers = append(h.def.Headers, header)
 
-	case HeaderPEncoding:
-		fieldsRaw := strings.Split(match[2], ",")
-		for i, fr := range fieldsRaw {
-			n, err := strconv.ParseInt(fr, 10, 8)
-			if err != nil {
-				return errors.Errorf("Could not parse fieldPEncoding '%s' to int", match[2])
-			}
-			h.def.FieldsP[i].Encoding = n
+		val, err := strconv.ParseInt(match[2], 10, 32)
+		if err != nil {
+			return errors.Errorf("Could not parse '%s' value '%s' to int", match[1], match[2])
 		}
+		h.def.Sysconfig.MaxThrottle = int(val)
 
-	case HeaderSName:
-		fieldsRaw := strings.Split(match[2], ",")
-		for _, fr := range fieldsRaw {
-			d := FieldDefinition{
-				Name: FieldName(fr),
-			}
-			h.def.FieldsS = append(h.def.FieldsS, d)
-		}
+	case HeaderAcc1G:
+		h.def.Headers = append(h.def.Headers, Header{Name: HeaderAcc1G, Value: match[2]})
 
-	case HeaderSSigned:
-		fieldsRaw := strings.Split(match[2], ",")
-		for i, fr := range fieldsRaw {
-			b, err := strconv.ParseBool(fr)
-			if err != nil {
-				return errors.Errorf("Could not parse fieldSSigned '%s' to bool", match[2])
-			}
-			h.def.FieldsS[i].Signed = b
+		val, err := strconv.ParseUint(match[2], 10, 16)
+		if err != nil {
+			return errors.Errorf("Could not parse '%s' value '%s' to int", match[1], match[2])
 		}
+		h.def.Sysconfig.Acc1G = uint16(val)
 
-	case HeaderSPredictor:
-		fieldsRaw := strings.Split(match[2], ",")
-		for i, fr := range fieldsRaw {
-			n, err := strconv.ParseInt(fr, 10, 8)
-			if err != nil {
-				return errors.Errorf("Could not parse fieldSPredictor '%s' to int", match[2])
-			}
-			h.def.FieldsS[i].Predictor = n
-		}
+	case HeaderGyroScale:
+		h.def.Headers = append(h.def.Headers, Header{Name: HeaderGyroScale, Value: match[2]})
 
-	case HeaderSEncoding:
-		fieldsRaw := strings.Split(match[2], ",")
-		for i, fr := range fieldsRaw {
-			n, err := strconv.ParseInt(fr, 10, 8)
-			if err != nil {
-				return errors.Errorf("Could not parse fieldSEncoding '%s' to int", match[2])
-			}
-			h.def.FieldsS[i].Encoding = n
+		// The scale is written as the bits of a float
+		val, err := strconv.ParseUint(strings.TrimPrefix(match[2], "0x"), 16, 32)
+		if err != nil {
+			return errors.Errorf("Could not parse '%s' value '%s' to float", match[1], match[2])
 		}
+		h.def.Sysconfig.GyroScale = float64(math.Float32frombits(uint32(val)))
 
 	case HeaderVbatref:
 		val, err := strconv.ParseInt(match[2], 10, 32)
@@ -218,19 +234,19 @@ func (h *HeaderReader) parseHeader(out string) error {
 		if err != nil {
 			return errors.Errorf("Could not parse first part of fieldVbatcellvoltage '%s' to int", vals[0])
 		}
-		h.def.Sysconfig.Vbatmincellvoltage = uint8(val)
+		h.def.Sysconfig.Vbatmincellvoltage = uint16(val)
 
 		val, err = strconv.ParseInt(vals[1], 10, 32)
 		if err != nil {
 			return errors.Errorf("Could not parse second part of fieldVbatcellvoltage '%s' to int", vals[1])
 		}
-		h.def.Sysconfig.Vbatwarningcellvoltage = uint8(val)
+		h.def.Sysconfig.Vbatwarningcellvoltage = uint16(val)
 
 		val, err = strconv.ParseInt(vals[2], 10, 32)
 		if err != nil {
 			return errors.Errorf("Could not parse third part of fieldVbatcellvoltage '%s' to int", vals[2])
 		}
-		h.def.Sysconfig.Vbatmaxcellvoltage = uint8(val)
+		h.def.Sysconfig.Vbatmaxcellvoltage = uint16(val)
 
 	case HeaderCurrentMeter:
 		header := Header{
@@ -306,42 +322,124 @@ func (h *HeaderReader) parseHeader(out string) error {
 		h.def.Headers = append(h.def.Headers, header)
 	}
 
-	for i, field := range h.def.FieldsI {
-		if field.Encoding == EncodingTag8_8SVB {
-			groupCount := 0
-			for j := i + 1; j < i+8 && j < len(h.def.FieldsI); j++ {
-				groupCount = j - i
-				if h.def.FieldsI[j].Encoding != EncodingTag8_8SVB {
-					break
-				}
+	return nil
+}
+
+// applyFirmwareRules derives the firmware version and the decoding rules once all the headers are read
+func (h *HeaderReader) applyFirmwareRules() {
+	revision, _ := h.def.GetHeaderValue(HeaderFirmwareRevision)
+	date, _ := h.def.GetHeaderValue(HeaderFirmwareDate)
+	h.def.Firmware = ParseFirmwareVersion(h.def.Sysconfig.FirmwareType, revision, date)
+	h.def.Rules = rulesFor(h.def.Firmware, h.def.DataVersion)
+	if debugMode, err := h.def.GetHeaderValue(HeaderDebugMode); err == nil {
+		if value, err := strconv.Atoi(debugMode); err == nil {
+			h.def.DebugMode = h.def.Rules.DebugMode(value)
+		}
+	}
+
+	// Older firmwares clamp the motors to the throttle range instead
+	_, err := h.def.GetHeaderValue(HeaderMotorOutput)
+	if err != nil || h.def.Rules.MotorRangeFromThrottle {
+		h.def.Sysconfig.MotorOutputLow = h.def.Sysconfig.MinThrottle
+		h.def.Sysconfig.MotorOutputHigh = h.def.Sysconfig.MaxThrottle
+	}
+}
+
+// parseFieldHeader parses one of the headers defining the name, sign, predictor or encoding
+// of the fields of a frame type
+func (h *HeaderReader) parseFieldHeader(frameType LogFrameType, property, headerName, value string) error {
+	fieldsRaw := strings.Split(value, ",")
+	if property == "name" {
+		for _, fr := range fieldsRaw {
+			d := FieldDefinition{
+				Name: FieldName(fr),
+			}
+			switch frameType {
+			case LogFrameIntra:
+				// Inter frames have the same fields as intra frames
+				h.def.FieldsI = append(h.def.FieldsI, d)
+				h.def.FieldsP = append(h.def.FieldsP, d)
+			case LogFrameSlow:
+				h.def.FieldsS = append(h.def.FieldsS, d)
+			case LogFrameGPS:
+				h.def.FieldsG = append(h.def.FieldsG, d)
+			case LogFrameGPSHome:
+				h.def.FieldsH = append(h.def.FieldsH, d)
 			}
-			for j := i; j < i+8 && j < len(h.def.FieldsI); j++ {
-				h.def.FieldsI[j].GroupCount = groupCount
+		}
+		return nil
+	}
+
+	fields := h.def.fieldsOf(frameType)
+	if err := checkFieldCount(headerName, len(fieldsRaw), len(fields)); err != nil {
+		return err
+	}
+	for i, fr := range fieldsRaw {
+		if property == "signed" {
+			b, err := strconv.ParseBool(fr)
+			if err != nil {
+				return errors.Errorf("Could not parse '%s' value '%s' to bool", headerName, value)
 			}
+			fields[i].Signed = b
+			continue
+		}
 
+		n, err := strconv.ParseInt(fr, 10, 8)
+		if err != nil {
+			return errors.Errorf("Could not parse '%s' value '%s' to int", headerName, value)
+		}
+		if property == "predictor" {
+			fields[i].Predictor = n
+		} else {
+			fields[i].Encoding = n
 		}
 	}
 
-	for i, field := range h.def.FieldsP {
+	// The longitude of GPS frames is predicted from the second coordinate of the home position
+	if frameType == LogFrameGPS && property == "predictor" {
+		for i := range fields {
+			if fields[i].Name == FieldGPSCoord1 && fields[i].Predictor == PredictorHomeCoord {
+				fields[i].Predictor = PredictorHomeCoord1
+			}
+		}
+	}
+	return nil
+}
+
+// updateFieldIndexes refreshes what's derived from the field definitions
+func (h *HeaderReader) updateFieldIndexes() {
+	for _, fields := range [][]FieldDefinition{h.def.FieldsI, h.def.FieldsP, h.def.FieldsS, h.def.FieldsG, h.def.FieldsH} {
+		updateGroupCounts(fields)
+	}
+
+	h.def.FieldIRL = map[FieldName]int{}
+	for i, field := range h.def.FieldsI {
+		h.def.FieldIRL[field.Name] = i
+	}
+}
+
+// updateGroupCounts sets how many adjacent fields are written together with the Tag8_8SVB encoding
+func updateGroupCounts(fields []FieldDefinition) {
+	for i, field := range fields {
 		if field.Encoding == EncodingTag8_8SVB {
 			groupCount := 0
-			for j := i + 1; j < i+8 && j < len(h.def.FieldsP); j++ {
+			for j := i + 1; j < i+8 && j < len(fields); j++ {
 				groupCount = j - i
-				if h.def.FieldsP[j].Encoding != EncodingTag8_8SVB {
+				if fields[j].Encoding != EncodingTag8_8SVB {
 					break
 				}
 			}
-			for j := i; j < i+8 && j < len(h.def.FieldsP); j++ {
-				h.def.FieldsP[j].GroupCount = groupCount
+			for j := i; j < i+8 && j < len(fields); j++ {
+				fields[j].GroupCount = groupCount
 			}
-
 		}
 	}
+}
 
-	h.def.FieldIRL = map[FieldName]int{}
-	for i, field := range h.def.FieldsI {
-		h.def.FieldIRL[field.Name] = i
+// checkFieldCount verifies a header doesn't describe more fields than were named
+func checkFieldCount(headerName string, count, expected int) error {
+	if count > expected {
+		return errors.Errorf("Header '%s' has %d values but only %d fields are defined", headerName, count, expected)
 	}
-
 	return nil
 }
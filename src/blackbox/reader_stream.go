@@ -0,0 +1,104 @@
+package blackbox
+
+import (
+	"io"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+	"github.com/pkg/errors"
+)
+
+// StreamReader reads a log while the flight controller writes it, like the blackbox data
+// sent over a serial port to an OpenLager or a companion computer. Reading can start in
+// the middle of a session: everything before the headers of the next one is skipped.
+// A new session is looked for after each log end.
+type StreamReader struct {
+	// FrameDef is the definition of the current session
+	FrameDef LogDefinition
+
+	// Sessions is the number of sessions started so far
+	Sessions int
+
+	dec         *stream.Decoder
+	frameReader *FrameReader
+	opts        FlightLogReaderOpts
+}
+
+// NewStreamReader returns a new StreamReader
+func NewStreamReader(r io.Reader, opts FlightLogReaderOpts) *StreamReader {
+	return &StreamReader{
+		dec:  stream.NewDecoder(r),
+		opts: opts,
+	}
+}
+
+// Next returns the next frame as soon as it's been received, waiting for the headers of
+// a session first if needed. Returns io.EOF once the stream is closed. After an error
+// in the headers, the next call looks for the following session.
+func (s *StreamReader) Next() (Frame, error) {
+	if s.frameReader == nil {
+		if err := s.startSession(); err != nil {
+			return nil, err
+		}
+	}
+
+	frame := s.frameReader.ReadNextFrame()
+	if frame.Error() == io.EOF {
+		return nil, io.EOF
+	}
+
+	if event, ok := frame.(*EventFrame); ok && frame.Error() == nil && event.EventType() == LogEventLogEnd {
+		s.frameReader = nil
+	}
+	return frame, nil
+}
+
+// Offset returns the number of bytes read from the stream so far
+func (s *StreamReader) Offset() int64 {
+	return s.dec.Offset()
+}
+
+// startSession skips the data until the headers of the next session, and reads them
+func (s *StreamReader) startSession() error {
+	if err := s.skipToSession(); err != nil {
+		return err
+	}
+
+	headerReader := NewHeaderReader(s.dec)
+	err := headerReader.readHeaderLine(logSessionMarker)
+	if err == nil {
+		s.FrameDef, err = headerReader.ProcessHeaders()
+	}
+	if errors.Cause(err) == io.EOF {
+		return io.EOF
+	} else if err != nil {
+		return errors.Wrap(err, "could not read the headers of the session")
+	}
+
+	s.Sessions++
+	s.frameReader = NewFrameReader(s.dec, s.FrameDef, &FrameReaderOptions{
+		Raw:    s.opts.Raw,
+		Stream: true,
+	})
+	return nil
+}
+
+// skipToSession reads the stream until the beginning of a session has been read
+func (s *StreamReader) skipToSession() error {
+	matched := 0
+	for matched < len(logSessionMarker) {
+		b, err := s.dec.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		// The marker starts with the only 'H' it contains
+		if b == logSessionMarker[matched] {
+			matched++
+		} else if b == logSessionMarker[0] {
+			matched = 1
+		} else {
+			matched = 0
+		}
+	}
+	return nil
+}
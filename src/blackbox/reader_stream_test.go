@@ -0,0 +1,104 @@
+package blackbox
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// wireChunkSize is how many bytes are written at once to the pipe, like a serial port would deliver them
+const wireChunkSize = 7
+
+func TestStreamReaderSessions(t *testing.T) {
+	normal := readFixture(t)
+	inav := readINAVFixture(t)
+
+	// Reading starts in the middle of a session which isn't decoded
+	r, w := io.Pipe()
+	go writeToWire(w, normal[len(normal)-200:], normal, inav)
+
+	streamReader := NewStreamReader(r, FlightLogReaderOpts{})
+	mainFrames := [][][]int64{}
+	var lastSession int
+	for {
+		frame, err := streamReader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		assert.NoError(t, frame.Error())
+		assert.True(t, frame.Validity())
+
+		if streamReader.Sessions != lastSession {
+			lastSession = streamReader.Sessions
+			mainFrames = append(mainFrames, [][]int64{})
+		}
+		if frame.Type() == LogFrameIntra || frame.Type() == LogFrameInter {
+			mainFrames[lastSession-1] = append(mainFrames[lastSession-1], frame.Values().([]int64))
+		}
+	}
+
+	assert.Equal(t, 2, streamReader.Sessions)
+	assert.Equal(t, DialectINAV, streamReader.FrameDef.Dialect)
+	assert.Equal(t, readFixtureMainFrames(t, bytes.NewReader(normal)), mainFrames[0])
+	assert.Equal(t, readFixtureMainFrames(t, bytes.NewReader(inav)), mainFrames[1])
+}
+
+func TestStreamReaderEmitsFramesWhileReceiving(t *testing.T) {
+	normal := readFixture(t)
+	release := make(chan bool)
+
+	// The log end event is only sent once all the other frames have been read
+	logEnd := len(normal) - 2 - 12
+	r, w := io.Pipe()
+	go func() {
+		_, _ = w.Write(normal[:logEnd])
+		<-release
+		writeToWire(w, normal[logEnd:])
+	}()
+
+	streamReader := NewStreamReader(r, FlightLogReaderOpts{})
+	for i := 0; i < 9; i++ {
+		frame, err := streamReader.Next()
+		assert.NoError(t, err)
+		assert.NoError(t, frame.Error())
+	}
+	assert.Equal(t, 1, streamReader.Sessions)
+	assert.Equal(t, int64(logEnd), streamReader.Offset())
+	close(release)
+
+	frame, err := streamReader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(LogEventLogEnd), frame.(*EventFrame).EventType())
+
+	_, err = streamReader.Next()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, int64(len(normal)), streamReader.Offset())
+}
+
+func TestStreamReaderWithoutSession(t *testing.T) {
+	r, w := io.Pipe()
+	go writeToWire(w, []byte("H Product:Something else\nH Data version:2\n"))
+
+	_, err := NewStreamReader(r, FlightLogReaderOpts{}).Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+// writeToWire writes data to a pipe in small chunks, and closes it
+func writeToWire(w *io.PipeWriter, parts ...[]byte) {
+	for _, part := range parts {
+		for len(part) > 0 {
+			size := wireChunkSize
+			if size > len(part) {
+				size = len(part)
+			}
+			if _, err := w.Write(part[:size]); err != nil {
+				return
+			}
+			part = part[size:]
+		}
+	}
+	w.Close()
+}
@@ -0,0 +1,44 @@
+package blackbox
+
+import (
+	"io"
+)
+
+// RemovedRange is a region of a log left out by Repair, with offsets relative to the
+// start of the session
+type RemovedRange struct {
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+	Reason string `json:"reason"`
+}
+
+// RepairReport describes what Repair kept and removed from a log
+type RepairReport struct {
+	MainFrames int            `json:"mainFrames"`
+	Bytes      int64          `json:"bytes"`
+	Removed    []RemovedRange `json:"removed"`
+}
+
+// Repair reads a single session log and writes a new log without the corrupted frames, nor the
+// main frames that can't be predicted because of them. Every run of frames following a removed
+// region starts with a LoggingResume event and a re-encoded intra frame.
+func Repair(r io.Reader, w io.Writer) (RepairReport, error) {
+	report := RepairReport{Removed: []RemovedRange{}}
+
+	onDrop := func(frame Frame, reason string) {
+		start := int64(frame.Start())
+		end := start + int64(frame.Size())
+
+		// Merge the consecutive frames removed for the same reason
+		if last := len(report.Removed) - 1; last >= 0 && report.Removed[last].End == start && report.Removed[last].Reason == reason {
+			report.Removed[last].End = end
+			return
+		}
+		report.Removed = append(report.Removed, RemovedRange{Start: start, End: end, Reason: reason})
+	}
+
+	result, err := rewriteLog(r, w, TrimOpts{}, onDrop)
+	report.MainFrames = result.MainFrames
+	report.Bytes = result.Bytes
+	return report, err
+}
@@ -0,0 +1,125 @@
+package blackbox
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// Offsets of the main frames of the fixture
+const (
+	fixtureFrameP52994Offset = 1673
+	fixtureFrameP52995Offset = 1701
+	fixtureFrameP52996Offset = 1729
+)
+
+func TestRepairCleanLog(t *testing.T) {
+	var buf bytes.Buffer
+	report, err := Repair(openFixture(t), &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, report.MainFrames)
+	assert.Empty(t, report.Removed)
+}
+
+func TestRepairCorruptedLog(t *testing.T) {
+	content := readFixture(t)
+	corrupted := concatBytes(content[:fixtureFrameP52994Offset], []byte{1, 2}, content[fixtureFrameP52994Offset:])
+
+	var buf bytes.Buffer
+	report, err := Repair(bytes.NewReader(corrupted), &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.MainFrames)
+	assert.Equal(t, []RemovedRange{
+		RemovedRange{Start: 1673, End: 1675, Reason: "corrupted frame: Frame type '\x01' (b'1') is not supported"},
+		RemovedRange{Start: 1675, End: 1761, Reason: "main frame following a corrupted region, waiting for the next intra frame"},
+	}, report.Removed)
+
+	frames := readFixtureMainFrames(t, &buf)
+	assert.Len(t, frames, 2)
+}
+
+func TestRepairLogWithoutFrames(t *testing.T) {
+	content := readFixture(t)
+
+	// The headers followed by garbage
+	var buf bytes.Buffer
+	_, err := Repair(bytes.NewReader(concatBytes(content[:1564], []byte{1, 2})), &buf)
+	assert.IsType(t, &NoValidFrameError{}, err)
+	assert.EqualError(t, err, "No valid frame found")
+	assert.Empty(t, buf.Bytes())
+}
+
+func TestRepairWriteError(t *testing.T) {
+	_, err := Repair(openFixture(t), failingWriter{})
+	assert.EqualError(t, err, "disk full")
+	_, ok := errors.Cause(err).(*NoValidFrameError)
+	assert.False(t, ok)
+}
+
+// failingWriter is a writer always failing, like on a full disk
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestRepairResumesOnIntraFrame(t *testing.T) {
+	content := readFixture(t)
+	original := readFixtureMainFrames(t, bytes.NewReader(content))
+
+	// Replace the end of the log with an intra frame followed by an inter frame
+	headerReader := NewHeaderReader(stream.NewDecoder(bytes.NewReader(content)))
+	frameDef, err := headerReader.ProcessHeaders()
+	assert.NoError(t, err)
+
+	var tail bytes.Buffer
+	logWriter := NewLogWriter(stream.NewEncoder(&tail), frameDef)
+	assert.NoError(t, logWriter.WriteIntraFrame(original[3]))
+	assert.NoError(t, logWriter.WriteMainFrame(original[4]))
+	assert.NoError(t, logWriter.WriteLogEnd())
+
+	corrupted := concatBytes(content[:fixtureFrameP52994Offset], []byte{1, 2}, content[fixtureFrameP52994Offset:fixtureFrameP52995Offset], tail.Bytes())
+
+	var buf bytes.Buffer
+	report, err := Repair(bytes.NewReader(corrupted), &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.MainFrames)
+	assert.Equal(t, []RemovedRange{
+		RemovedRange{Start: 1673, End: 1675, Reason: "corrupted frame: Frame type '\x01' (b'1') is not supported"},
+		RemovedRange{Start: 1675, End: 1703, Reason: "main frame following a corrupted region, waiting for the next intra frame"},
+	}, report.Removed)
+
+	assert.Equal(t, [][]int64{original[0], original[1], original[3], original[4]}, readFixtureMainFrames(t, &buf))
+}
+
+func TestRepairTruncatedLog(t *testing.T) {
+	content := readFixture(t)
+
+	var buf bytes.Buffer
+	report, err := Repair(bytes.NewReader(content[:len(content)-20]), &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.MainFrames)
+	assert.Equal(t, []RemovedRange{
+		RemovedRange{Start: fixtureFrameP52996Offset, End: 1753, Reason: "truncated frame at the end of the log"},
+	}, report.Removed)
+
+	assert.Len(t, readFixtureMainFrames(t, &buf), 4)
+}
+
+func readFixture(t *testing.T) []byte {
+	content, err := ioutil.ReadFile("../../fixtures/normal.bfl")
+	assert.NoError(t, err)
+	return content
+}
+
+func concatBytes(parts ...[]byte) []byte {
+	result := []byte{}
+	for _, part := range parts {
+		result = append(result, part...)
+	}
+	return result
+}
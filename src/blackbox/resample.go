@@ -0,0 +1,336 @@
+package blackbox
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ResampleMethod is a way of bringing main frames to a uniform rate
+type ResampleMethod int
+
+// List of the resampling methods
+const (
+	// ResampleLinear interpolates the values of the main frames at regular times
+	ResampleLinear ResampleMethod = iota
+
+	// ResampleLTTB keeps one main frame per period, the one that best preserves the shape
+	// of the curves (Largest-Triangle-Three-Buckets). Peaks survive the decimation but
+	// frames are not exactly evenly spaced.
+	ResampleLTTB
+)
+
+const (
+	// maxInterpolationGap is the longest time between two main frames over which values
+	// are interpolated. Longer gaps, like a paused log, restart the timeline.
+	maxInterpolationGap = 100 * time.Millisecond
+)
+
+// ResampleOpts holds the options of a Resampler
+type ResampleOpts struct {
+	// Rate is the number of main frames per second to output
+	Rate float64
+
+	Method ResampleMethod
+}
+
+// Resampler converts the irregular timeline of main frames into a uniform one. Main
+// frames are irregular because the firmware skips some P-frames on purpose, and because
+// of frames lost to corruption. Other frames are passed through in their original order.
+type Resampler interface {
+	// AddFrame feeds a frame and returns the frames ready to be output
+	AddFrame(frame Frame) []Frame
+
+	// Flush returns the frames still held back, once every frame has been fed
+	Flush() []Frame
+}
+
+// NewResampler returns a new Resampler for a log
+func NewResampler(frameDef LogDefinition, opts ResampleOpts) (Resampler, error) {
+	if opts.Rate <= 0 || math.IsInf(opts.Rate, 0) || math.IsNaN(opts.Rate) {
+		return nil, errors.Errorf("Invalid resampling rate %v", opts.Rate)
+	}
+
+	timeIndex, err := frameDef.GetFieldIndex(FieldTime)
+	if err != nil {
+		return nil, err
+	}
+	period := int64(math.Round(1e6 / opts.Rate))
+	if period < 1 {
+		return nil, errors.Errorf("Resampling rate %v is too high", opts.Rate)
+	}
+
+	switch opts.Method {
+	case ResampleLinear:
+		return &linearResampler{timeIndex: timeIndex, period: period}, nil
+	case ResampleLTTB:
+		return &lttbResampler{timeIndex: timeIndex, period: period}, nil
+	default:
+		return nil, errors.Errorf("Unknown resampling method %d", opts.Method)
+	}
+}
+
+// Resample returns the frames of a channel with their main frames resampled. The
+// returned channel is closed once the input channel is, or the context canceled.
+func Resample(ctx context.Context, frames <-chan Frame, frameDef LogDefinition, opts ResampleOpts) (<-chan Frame, error) {
+	resampler, err := NewResampler(frameDef, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return TransformFrames(ctx, frames, resampler), nil
+}
+
+// resampledMainFrame returns the values of a main frame to resample, or false if the
+// frame is passed through as is
+func resampledMainFrame(frame Frame) (*MainFrame, bool) {
+	mainFrame, ok := frame.(*MainFrame)
+	if !ok || frame.Error() != nil {
+		return nil, false
+	}
+	return mainFrame, true
+}
+
+// -------------------------------------------------------------------------- //
+
+// linearResampler interpolates values at times spaced by period, starting with the
+// first main frame
+type linearResampler struct {
+	timeIndex int
+	period    int64
+
+	previous *MainFrame
+	next     int64
+	pending  []Frame
+}
+
+func (r *linearResampler) AddFrame(frame Frame) []Frame {
+	mainFrame, ok := resampledMainFrame(frame)
+	if !ok {
+		r.pending = append(r.pending, frame)
+		return nil
+	}
+	if !frame.Validity() {
+		return nil
+	}
+
+	values := mainFrame.values
+	current := values[r.timeIndex]
+
+	output := []Frame{}
+	if r.previous == nil || current-r.previous.values[r.timeIndex] > int64(maxInterpolationGap/time.Microsecond) || current < r.previous.values[r.timeIndex] {
+		// The timeline (re)starts with this frame
+		r.next = current
+	} else {
+		previousTime := r.previous.values[r.timeIndex]
+		for ; r.next < current; r.next += r.period {
+			output = append(output, r.interpolate(r.previous, mainFrame, float64(r.next-previousTime)/float64(current-previousTime)))
+		}
+	}
+
+	// Frames received since the previous main frame apply from this one on
+	output = append(output, r.pending...)
+	r.pending = nil
+	r.previous = mainFrame
+
+	if r.next == current {
+		output = append(output, r.interpolate(mainFrame, mainFrame, 0))
+		r.next += r.period
+	}
+	return output
+}
+
+func (r *linearResampler) Flush() []Frame {
+	output := r.pending
+	r.pending = nil
+	return output
+}
+
+// interpolate returns a frame with values between the ones of two frames, at a ratio
+// of the way from the first to the second
+func (r *linearResampler) interpolate(from, to *MainFrame, ratio float64) Frame {
+	values := make([]int64, len(from.values))
+	for i := range values {
+		values[i] = from.values[i] + int64(math.Round(float64(to.values[i]-from.values[i])*ratio))
+	}
+	values[r.timeIndex] = r.next
+
+	frameType := from.Type()
+	if ratio != 0 {
+		frameType = LogFrameInter
+	}
+	return MarkValid(NewMainFrame(frameType, values, from.start, from.end, nil))
+}
+
+// -------------------------------------------------------------------------- //
+
+// lttbBucket holds the main frames of one period, and the frames received with them
+type lttbBucket struct {
+	index  int64
+	frames []*MainFrame
+	others []Frame
+}
+
+// lttbResampler selects a main frame per bucket of one period. The frame selected in a
+// bucket is the one forming the largest triangle with the frame selected in the previous
+// bucket and the average of the next bucket, which needs the next bucket to be complete.
+type lttbResampler struct {
+	timeIndex int
+	period    int64
+
+	firstTime int64
+	lastTime  int64
+	low       []int64
+	high      []int64
+	selected  *MainFrame
+	current   *lttbBucket
+	next      *lttbBucket
+	pending   []Frame
+}
+
+func (r *lttbResampler) AddFrame(frame Frame) []Frame {
+	mainFrame, ok := resampledMainFrame(frame)
+	if !ok {
+		r.pending = append(r.pending, frame)
+		return nil
+	}
+	if !frame.Validity() {
+		return nil
+	}
+
+	values := mainFrame.values
+	current := values[r.timeIndex]
+
+	if r.selected != nil && (current-r.lastTime > int64(maxInterpolationGap/time.Microsecond) || current < r.lastTime) {
+		// The timeline restarts with this frame
+		output := r.Flush()
+		r.pending = nil
+		r.selected = nil
+		return append(output, r.AddFrame(frame)...)
+	}
+	r.lastTime = current
+	r.updateRange(values)
+
+	// The first frame is always kept
+	if r.selected == nil {
+		r.firstTime = current
+		r.selected = mainFrame
+		output := append(r.pending, mainFrame)
+		r.pending = nil
+		return output
+	}
+
+	output := []Frame{}
+	index := (current - r.firstTime) / r.period
+	if r.next != nil && index != r.next.index {
+		output = r.selectFromCurrent()
+	}
+
+	if r.next == nil || index != r.next.index {
+		r.next = &lttbBucket{index: index}
+	}
+	r.next.others = append(r.next.others, r.pending...)
+	r.pending = nil
+	r.next.frames = append(r.next.frames, mainFrame)
+	return output
+}
+
+func (r *lttbResampler) Flush() []Frame {
+	output := r.selectFromCurrent()
+
+	// The last frame is always kept
+	if r.current != nil {
+		output = append(output, r.current.others...)
+		output = append(output, r.current.frames[len(r.current.frames)-1])
+		r.current = nil
+	}
+	output = append(output, r.pending...)
+	r.pending = nil
+	return output
+}
+
+// selectFromCurrent selects the frame of the current bucket, now that the next one is
+// complete, and makes the next bucket the current one
+func (r *lttbResampler) selectFromCurrent() []Frame {
+	if r.next == nil {
+		return nil
+	}
+
+	output := []Frame{}
+	if r.current != nil {
+		average := averageValues(r.next.frames)
+
+		best := r.current.frames[0]
+		bestArea := -1.0
+		for _, candidate := range r.current.frames {
+			area := r.triangleArea(r.selected.values, candidate.values, average)
+			if area > bestArea {
+				best = candidate
+				bestArea = area
+			}
+		}
+
+		output = append(output, r.current.others...)
+		output = append(output, best)
+		r.selected = best
+	}
+
+	r.current = r.next
+	r.next = nil
+	return output
+}
+
+// triangleArea sums the areas of the triangles formed by three points for every field,
+// each normalized by the range of the field so that all of them weigh the same
+func (r *lttbResampler) triangleArea(a, b, c []int64) float64 {
+	ta := float64(a[r.timeIndex])
+	tb := float64(b[r.timeIndex])
+	tc := float64(c[r.timeIndex])
+
+	total := 0.0
+	for i := range a {
+		spread := float64(r.high[i] - r.low[i])
+		if i == r.timeIndex || spread == 0 {
+			continue
+		}
+
+		va, vb, vc := float64(a[i]), float64(b[i]), float64(c[i])
+		total += math.Abs((ta-tc)*(vb-va)-(ta-tb)*(vc-va)) / spread
+	}
+	return total
+}
+
+// updateRange extends the range of the fields seen so far
+func (r *lttbResampler) updateRange(values []int64) {
+	if r.low == nil {
+		r.low = append([]int64{}, values...)
+		r.high = append([]int64{}, values...)
+		return
+	}
+
+	for i, v := range values {
+		if v < r.low[i] {
+			r.low[i] = v
+		}
+		if v > r.high[i] {
+			r.high[i] = v
+		}
+	}
+}
+
+func averageValues(frames []*MainFrame) []int64 {
+	sums := make([]float64, len(frames[0].values))
+	for _, frame := range frames {
+		for i, v := range frame.values {
+			sums[i] += float64(v)
+		}
+	}
+
+	average := make([]int64, len(sums))
+	for i, sum := range sums {
+		average[i] = int64(math.Round(sum / float64(len(frames))))
+	}
+	return average
+}
@@ -0,0 +1,161 @@
+package blackbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// resampleFrameDef has an iteration, the time and a value
+var resampleFrameDef = LogDefinition{
+	FieldIRL: map[FieldName]int{FieldIteration: 0, FieldTime: 1, "motor[0]": 2},
+}
+
+func TestResampleLinear(t *testing.T) {
+	resampler, err := NewResampler(resampleFrameDef, ResampleOpts{Rate: 1000})
+	assert.NoError(t, err)
+
+	// The iteration 3 was intentionally not logged
+	frames := resampleAll(resampler,
+		resampleMainFrame(LogFrameIntra, 0, 10000, 100),
+		resampleMainFrame(LogFrameInter, 1, 10500, 200),
+		NewSlowFrame([]int64{1, 0, 0, 0, 0}, 0, 0, nil),
+		resampleMainFrame(LogFrameInter, 2, 11000, 300),
+		resampleMainFrame(LogFrameInter, 4, 12000, 100),
+		resampleMainFrame(LogFrameInter, 5, 12600, 400),
+		NewEventFrame(LogEventLogEnd, nil, 0, 0, nil),
+	)
+
+	assert.Equal(t, []interface{}{
+		[]int64{0, 10000, 100},
+		byte(LogFrameSlow),
+		[]int64{2, 11000, 300},
+		[]int64{4, 12000, 100},
+		byte(LogFrameEvent),
+	}, frameValues(frames))
+	assert.Equal(t, byte(LogFrameIntra), frames[0].Type())
+
+	resampler, err = NewResampler(resampleFrameDef, ResampleOpts{Rate: 4000})
+	assert.NoError(t, err)
+	frames = resampleAll(resampler,
+		resampleMainFrame(LogFrameIntra, 0, 10000, 100),
+		resampleMainFrame(LogFrameInter, 2, 11000, 300),
+	)
+	assert.Equal(t, []interface{}{
+		[]int64{0, 10000, 100},
+		[]int64{1, 10250, 150},
+		[]int64{1, 10500, 200},
+		[]int64{2, 10750, 250},
+		[]int64{2, 11000, 300},
+	}, frameValues(frames))
+	assert.Equal(t, byte(LogFrameInter), frames[1].Type())
+}
+
+func TestResampleLinearGap(t *testing.T) {
+	resampler, err := NewResampler(resampleFrameDef, ResampleOpts{Rate: 1000})
+	assert.NoError(t, err)
+
+	// The log was paused for a second, nothing is interpolated in between
+	frames := resampleAll(resampler,
+		resampleMainFrame(LogFrameIntra, 0, 10000, 100),
+		resampleMainFrame(LogFrameInter, 1, 11000, 200),
+		NewEventFrame(LogEventLoggingResume, nil, 0, 0, nil),
+		resampleMainFrame(LogFrameIntra, 2000, 1011500, 300),
+		resampleMainFrame(LogFrameInter, 2002, 1012500, 500),
+	)
+
+	assert.Equal(t, []interface{}{
+		[]int64{0, 10000, 100},
+		[]int64{1, 11000, 200},
+		byte(LogFrameEvent),
+		[]int64{2000, 1011500, 300},
+		[]int64{2002, 1012500, 500},
+	}, frameValues(frames))
+}
+
+func TestResampleLTTB(t *testing.T) {
+	resampler, err := NewResampler(resampleFrameDef, ResampleOpts{Rate: 1000, Method: ResampleLTTB})
+	assert.NoError(t, err)
+
+	// The peaks of every millisecond are kept
+	frames := resampleAll(resampler,
+		resampleMainFrame(LogFrameIntra, 0, 10000, 100),
+		resampleMainFrame(LogFrameInter, 1, 10250, 110),
+		resampleMainFrame(LogFrameInter, 2, 10500, 900),
+		resampleMainFrame(LogFrameInter, 3, 10750, 120),
+		resampleMainFrame(LogFrameInter, 4, 11000, 100),
+		NewSlowFrame([]int64{1, 0, 0, 0, 0}, 0, 0, nil),
+		resampleMainFrame(LogFrameInter, 5, 11250, -500),
+		resampleMainFrame(LogFrameInter, 6, 11500, 90),
+		resampleMainFrame(LogFrameInter, 7, 11750, 100),
+		resampleMainFrame(LogFrameInter, 8, 12000, 100),
+		resampleMainFrame(LogFrameInter, 9, 12250, 110),
+		NewEventFrame(LogEventLogEnd, nil, 0, 0, nil),
+	)
+
+	assert.Equal(t, []interface{}{
+		[]int64{0, 10000, 100},
+		[]int64{2, 10500, 900},
+		byte(LogFrameSlow),
+		[]int64{5, 11250, -500},
+		[]int64{9, 12250, 110},
+		byte(LogFrameEvent),
+	}, frameValues(frames))
+}
+
+func TestResampleChannel(t *testing.T) {
+	frameChan := make(chan Frame)
+	go func() {
+		for i := int64(0); i < 100; i++ {
+			frameChan <- resampleMainFrame(LogFrameInter, i, 10000+i*500, i)
+		}
+		close(frameChan)
+	}()
+
+	resampledChan, err := Resample(context.Background(), frameChan, resampleFrameDef, ResampleOpts{Rate: 500})
+	assert.NoError(t, err)
+
+	count := 0
+	for frame := range resampledChan {
+		assert.Equal(t, 10000+int64(count)*2000, frame.Values().([]int64)[1])
+		count++
+	}
+	assert.Equal(t, 25, count)
+}
+
+func TestResampleInvalidOptions(t *testing.T) {
+	_, err := NewResampler(resampleFrameDef, ResampleOpts{Rate: 0})
+	assert.Error(t, err)
+
+	_, err = NewResampler(resampleFrameDef, ResampleOpts{Rate: 100, Method: 5})
+	assert.Error(t, err)
+
+	_, err = NewResampler(LogDefinition{}, ResampleOpts{Rate: 100})
+	assert.Error(t, err)
+}
+
+func resampleMainFrame(frameType LogFrameType, iteration, time, value int64) Frame {
+	return MarkValid(NewMainFrame(frameType, []int64{iteration, time, value}, 0, 0, nil))
+}
+
+func resampleAll(resampler Resampler, frames ...Frame) []Frame {
+	output := []Frame{}
+	for _, frame := range frames {
+		output = append(output, resampler.AddFrame(frame)...)
+	}
+	return append(output, resampler.Flush()...)
+}
+
+// frameValues returns the values of main frames, and the type of the other ones
+func frameValues(frames []Frame) []interface{} {
+	values := []interface{}{}
+	for _, frame := range frames {
+		if mainFrame, ok := frame.(*MainFrame); ok {
+			values = append(values, mainFrame.Values())
+		} else {
+			values = append(values, frame.Type())
+		}
+	}
+	return values
+}
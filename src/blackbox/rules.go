@@ -0,0 +1,133 @@
+package blackbox
+
+// VbatUnit is the unit a firmware logs the battery voltage in
+type VbatUnit int
+
+// List of the units of the battery voltage
+const (
+	// VbatADC is the raw reading of the ADC, scaled with the vbat_scale setting
+	VbatADC VbatUnit = iota
+
+	// VbatDecivolts is 0.1V
+	VbatDecivolts
+
+	// VbatCentivolts is 0.01V
+	VbatCentivolts
+)
+
+// AmperageUnit is the unit a firmware logs the current in
+type AmperageUnit int
+
+// List of the units of the current
+const (
+	// AmperageADC is the raw reading of the ADC, scaled with the currentMeter offset and scale
+	AmperageADC AmperageUnit = iota
+
+	// AmperageCentiamps is 0.01A
+	AmperageCentiamps
+)
+
+// DecodingRules tells how to interpret a log according to the firmware that recorded it.
+// The zero value describes the logs of the original Cleanflight.
+type DecodingRules struct {
+	// LegacyTag8_4S16 is true when the Tag8_4S16 fields are written with the first version
+	// of the encoding, which has a different layout for the 4 bit values
+	LegacyTag8_4S16 bool
+
+	// MotorRangeFromThrottle is true when the motor output range is given by the minthrottle
+	// and maxthrottle headers rather than by the motorOutput one
+	MotorRangeFromThrottle bool
+
+	Vbat     VbatUnit
+	Amperage AmperageUnit
+
+	flightModes  flightModeTable
+	flightStates flightStateTable
+	debugModes   debugModeTable
+}
+
+// versionRule changes the rules for the logs of a product, starting with a version
+type versionRule struct {
+	product             string
+	major, minor, patch int
+	apply               func(rules *DecodingRules)
+}
+
+// versionRules lists the changes of the log semantics, oldest first for each product.
+// See https://github.com/betaflight/blackbox-log-viewer/blob/master/js/flightlog_parser.js
+var versionRules = []versionRule{
+	{"Cleanflight", 0, 0, 0, func(r *DecodingRules) { r.MotorRangeFromThrottle = true }},
+	{"Cleanflight", 2, 0, 0, func(r *DecodingRules) {
+		r.MotorRangeFromThrottle = false
+		r.Vbat = VbatDecivolts
+		r.Amperage = AmperageCentiamps
+	}},
+	{"Betaflight", 0, 0, 0, func(r *DecodingRules) { r.MotorRangeFromThrottle = true }},
+	{"Betaflight", 3, 0, 0, func(r *DecodingRules) {
+		r.MotorRangeFromThrottle = false
+		r.debugModes = betaflight3DebugModes
+	}},
+	{"Betaflight", 3, 1, 0, func(r *DecodingRules) { r.Vbat = VbatDecivolts }},
+	{"Betaflight", 3, 1, 7, func(r *DecodingRules) { r.Amperage = AmperageCentiamps }},
+	{"Betaflight", 3, 2, 0, func(r *DecodingRules) { r.flightModes = betaflight3FlightModes }},
+	{"Betaflight", 4, 0, 0, func(r *DecodingRules) {
+		r.Vbat = VbatCentivolts
+		r.flightModes = betaflight4FlightModes
+		r.debugModes = betaflight4DebugModes
+	}},
+	{"Betaflight", 4, 3, 0, func(r *DecodingRules) { r.debugModes = betaflight43DebugModes }},
+	{"INAV", 0, 0, 0, func(r *DecodingRules) {
+		r.Vbat = VbatCentivolts
+		r.Amperage = AmperageCentiamps
+		r.debugModes = inavDebugModes
+	}},
+}
+
+// rulesFor returns the rules to decode the logs of a firmware version
+func rulesFor(version FirmwareVersion, dataVersion int) DecodingRules {
+	rules := DecodingRules{
+		LegacyTag8_4S16: dataVersion == 1,
+		flightModes:     cleanflightFlightModes,
+		flightStates:    cleanflightFlightStates,
+	}
+	for _, rule := range versionRules {
+		if rule.product == version.Product && version.AtLeast(rule.major, rule.minor, rule.patch) {
+			rule.apply(&rules)
+		}
+	}
+	return rules
+}
+
+// FlightModes returns the modes set in a value of the flightModeFlags slow field
+func (r DecodingRules) FlightModes(flightModeFlags int64) FlightModes {
+	return r.flightModeTable().decode(flightModeFlags)
+}
+
+// FlightStates returns the names of the states set in a value of the stateFlags slow field
+func (r DecodingRules) FlightStates(stateFlags int64) []string {
+	if r.flightStates == nil {
+		return cleanflightFlightStates.decode(stateFlags)
+	}
+	return r.flightStates.decode(stateFlags)
+}
+
+// Armed tells if the craft is armed according to the flightModeFlags slow field.
+// The second value is false if the firmware doesn't log it.
+func (r DecodingRules) Armed(flightModeFlags int64) (bool, bool) {
+	if !r.flightModeTable().contains(FlightModeArm) {
+		return false, false
+	}
+	return r.FlightModes(flightModeFlags).Has(FlightModeArm), true
+}
+
+// DebugMode returns what the debug fields hold for a value of the debug_mode header
+func (r DecodingRules) DebugMode(debugMode int) DebugMode {
+	return r.debugModes.decode(debugMode)
+}
+
+func (r DecodingRules) flightModeTable() flightModeTable {
+	if r.flightModes == nil {
+		return cleanflightFlightModes
+	}
+	return r.flightModes
+}
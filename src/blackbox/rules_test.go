@@ -0,0 +1,125 @@
+package blackbox
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRulesFor(t *testing.T) {
+	tests := []struct {
+		revision        string
+		dataVersion     int
+		legacyTag8_4S16 bool
+		throttleRange   bool
+		vbat            VbatUnit
+		amperage        AmperageUnit
+		armed           bool
+	}{
+		{"Cleanflight 1.14.2 (8f2d21460) NAZE", 1, true, true, VbatADC, AmperageADC, false},
+		{"Cleanflight 2.1.0 (8f2d21460) SPRACINGF3", 2, false, false, VbatDecivolts, AmperageCentiamps, false},
+		{"Betaflight 2.9.1 (8f2d21460) NAZE", 2, false, true, VbatADC, AmperageADC, false},
+		{"Betaflight 3.1.6 (8f2d21460) SPRACINGF3", 2, false, false, VbatDecivolts, AmperageADC, false},
+		{"Betaflight 3.1.7 (8f2d21460) SPRACINGF3", 2, false, false, VbatDecivolts, AmperageCentiamps, false},
+		{"Betaflight 3.5.7 (8f2d21460) OMNIBUSF4SD", 2, false, false, VbatDecivolts, AmperageCentiamps, true},
+		{"Betaflight 4.0.0 (173e958da) MATEKF405", 2, false, false, VbatCentivolts, AmperageCentiamps, true},
+		{"INAV 2.6.1 (4bf4d6d6) MATEKF405SE", 2, false, false, VbatCentivolts, AmperageCentiamps, false},
+		{"", 2, false, true, VbatADC, AmperageADC, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.revision, func(t *testing.T) {
+			rules := rulesFor(ParseFirmwareVersion("Cleanflight", test.revision, ""), test.dataVersion)
+			assert.Equal(t, test.legacyTag8_4S16, rules.LegacyTag8_4S16)
+			assert.Equal(t, test.throttleRange, rules.MotorRangeFromThrottle)
+			assert.Equal(t, test.vbat, rules.Vbat)
+			assert.Equal(t, test.amperage, rules.Amperage)
+			_, known := rules.Armed(0)
+			assert.Equal(t, test.armed, known)
+		})
+	}
+}
+
+func TestRulesArmed(t *testing.T) {
+	rules := rulesFor(FirmwareVersion{Product: "Betaflight", Major: 4}, 2)
+	armed, known := rules.Armed(524289)
+	assert.True(t, armed)
+	assert.True(t, known)
+
+	armed, known = rules.Armed(524288)
+	assert.False(t, armed)
+	assert.True(t, known)
+
+	_, known = DecodingRules{}.Armed(524289)
+	assert.False(t, known)
+}
+
+func TestHeadersFirmwareRules(t *testing.T) {
+	frameDef := readHeaders(t, []string{
+		"H Product:Blackbox flight data recorder by Nicholas Sherlock",
+		"H Data version:2",
+		"H Firmware type:Cleanflight",
+		"H Firmware revision:Betaflight 4.0.0 (173e958da) MATEKF405",
+		"H Firmware date:Mar 26 2019 19:30:15",
+		"H minthrottle:1070",
+		"H maxthrottle:2000",
+		"H motorOutput:188,2047",
+		"H acc_1G:2048",
+		"H gyro_scale:0x3f800000",
+	})
+	assert.Equal(t, "Betaflight 4.0.0 MATEKF405", frameDef.Firmware.String())
+	assert.Equal(t, VbatCentivolts, frameDef.Rules.Vbat)
+	assert.Equal(t, 1070, frameDef.Sysconfig.MinThrottle)
+	assert.Equal(t, 188, frameDef.Sysconfig.MotorOutputLow)
+	assert.Equal(t, 2047, frameDef.Sysconfig.MotorOutputHigh)
+	assert.Equal(t, uint16(2048), frameDef.Sysconfig.Acc1G)
+	assert.Equal(t, 1.0, frameDef.Sysconfig.GyroScale)
+	assert.Equal(t, 16.07, frameDef.VbatToVolts(1607))
+	assert.Equal(t, 7.55, frameDef.AmperageToAmps(755))
+}
+
+func TestHeadersMotorRangeFromThrottle(t *testing.T) {
+	frameDef := readHeaders(t, []string{
+		"H Product:Blackbox flight data recorder by Nicholas Sherlock",
+		"H Data version:1",
+		"H Firmware type:Cleanflight",
+		"H Firmware revision:Betaflight 2.9.1 (8f2d21460) NAZE",
+		"H minthrottle:1070",
+		"H maxthrottle:2000",
+		"H motorOutput:188,2047",
+	})
+	assert.True(t, frameDef.Rules.LegacyTag8_4S16)
+	assert.Equal(t, 1070, frameDef.Sysconfig.MotorOutputLow)
+	assert.Equal(t, 2000, frameDef.Sysconfig.MotorOutputHigh)
+}
+
+func TestLegacyTag8_4S16(t *testing.T) {
+	frameDef := LogDefinition{Rules: DecodingRules{LegacyTag8_4S16: true}}
+	fields := []FieldDefinition{
+		{Name: "a", Signed: true, Encoding: EncodingTag8_4S16},
+		{Name: "b", Signed: true, Encoding: EncodingTag8_4S16},
+		{Name: "c", Signed: true, Encoding: EncodingTag8_4S16},
+		{Name: "d", Signed: true, Encoding: EncodingTag8_4S16},
+	}
+
+	encoded := []byte{229, 227, 100, 212, 254}
+	values, err := parseStateFrame(frameDef, fields, FrameHistory{}, stream.NewDecoder(bytes.NewReader(encoded)), true, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3, -2, 100, -300}, values)
+
+	var buf bytes.Buffer
+	err = encodeStateFrame(frameDef, fields, values, FrameHistory{}, stream.NewEncoder(&buf))
+	assert.NoError(t, err)
+	assert.Equal(t, encoded, buf.Bytes())
+}
+
+func readHeaders(t *testing.T, headers []string) LogDefinition {
+	content := strings.Join(headers, "\n") + "\nI"
+	headerReader := NewHeaderReader(stream.NewDecoder(strings.NewReader(content)))
+	frameDef, err := headerReader.ProcessHeaders()
+	assert.NoError(t, err)
+	return frameDef
+}
@@ -0,0 +1,232 @@
+package blackbox
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DerivedFieldDecimals is the number of decimal digits derived fields keep. Their values are
+// stored as fixed-point integers, see FieldDefinition.Decimals.
+const DerivedFieldDecimals = 6
+
+// DerivedField is a field computed from the other fields of every main frame
+type DerivedField struct {
+	Name       FieldName
+	Expression *Expression
+}
+
+// ParseDerivedField parses the definition of a derived field, like
+// motorAvg = mean(motor[*])
+func ParseDerivedField(definition string) (DerivedField, error) {
+	parts := strings.SplitN(definition, "=", 2)
+	if len(parts) != 2 {
+		return DerivedField{}, errors.Errorf("Invalid derived field '%s', expected <name> = <expression>", definition)
+	}
+
+	name := strings.TrimSpace(parts[0])
+	if !derivedFieldNameRegexp.MatchString(name) {
+		return DerivedField{}, errors.Errorf("Invalid name for derived field '%s'", definition)
+	}
+	expression, err := ParseExpression(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return DerivedField{}, err
+	}
+	return DerivedField{Name: FieldName(name), Expression: expression}, nil
+}
+
+var derivedFieldNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\[[0-9]+\])?$`)
+
+// SelectionOpts holds the options of a FieldSelector
+type SelectionOpts struct {
+	// Fields are the fields to keep, in this order. A name ending with [*], like motor[*],
+	// stands for every field of the group. Every field is kept if it's empty.
+	Fields []string
+
+	// Derived are added after the selected fields. They can refer to any field of the
+	// log and to the derived fields before them.
+	Derived []DerivedField
+}
+
+// FieldSelector keeps some of the fields of main frames and computes derived ones. It
+// changes the definition of the log accordingly, for exporters to be given the one of
+// Definition.
+type FieldSelector struct {
+	definition  LogDefinition
+	fieldsCount int
+	indexes     []int
+	derived     []valueFunc
+}
+
+// NewFieldSelector returns a new FieldSelector for a log
+func NewFieldSelector(frameDef LogDefinition, opts SelectionOpts) (*FieldSelector, error) {
+	lookup := &selectionLookup{frameDef: frameDef}
+	s := &FieldSelector{fieldsCount: len(frameDef.FieldsI)}
+
+	if len(opts.Fields) == 0 {
+		for i := range frameDef.FieldsI {
+			s.indexes = append(s.indexes, i)
+		}
+	}
+	for _, name := range opts.Fields {
+		var indexes []int
+		if group := strings.TrimSuffix(name, "[*]"); group != name {
+			var err error
+			if indexes, err = lookup.group(group); err != nil {
+				return nil, err
+			}
+		} else {
+			index, err := lookup.index(FieldName(name))
+			if err != nil {
+				return nil, err
+			}
+			indexes = []int{index}
+		}
+		s.indexes = append(s.indexes, indexes...)
+	}
+
+	fieldsI := []FieldDefinition{}
+	fieldsP := []FieldDefinition{}
+	for _, index := range s.indexes {
+		fieldsI = append(fieldsI, frameDef.FieldsI[index])
+		if index < len(frameDef.FieldsP) {
+			fieldsP = append(fieldsP, frameDef.FieldsP[index])
+		} else {
+			fieldsP = append(fieldsP, frameDef.FieldsI[index])
+		}
+	}
+
+	for _, derived := range opts.Derived {
+		if _, err := lookup.index(derived.Name); err == nil {
+			return nil, errors.Errorf("Derived field '%s' is already a field", derived.Name)
+		}
+
+		value, err := derived.Expression.compile(lookup)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not compute derived field '%s'", derived.Name)
+		}
+		s.derived = append(s.derived, value)
+		lookup.derived = append(lookup.derived, derived.Name)
+
+		field := derivedFieldDefinition(derived.Name)
+		fieldsI = append(fieldsI, field)
+		fieldsP = append(fieldsP, field)
+	}
+
+	s.definition = frameDef
+	s.definition.FieldsI = fieldsI
+	s.definition.FieldsP = fieldsP
+	s.definition.FieldIRL = map[FieldName]int{}
+	for i, field := range fieldsI {
+		s.definition.FieldIRL[field.Name] = i
+	}
+	return s, nil
+}
+
+// Definition returns the definition of the log with the selected and derived fields
+func (s *FieldSelector) Definition() LogDefinition {
+	return s.definition
+}
+
+// Frame returns a main frame with the selected and derived fields. Other frames, and
+// main frames whose values couldn't all be decoded, are returned as they are.
+func (s *FieldSelector) Frame(frame Frame) Frame {
+	mainFrame, ok := frame.(*MainFrame)
+	if !ok || len(mainFrame.values) != s.fieldsCount {
+		return frame
+	}
+
+	// Derived fields are computed with the ones before them
+	all := append(make([]int64, 0, s.fieldsCount+len(s.derived)), mainFrame.values...)
+	for _, derived := range s.derived {
+		all = append(all, derivedValue(derived(all)))
+	}
+
+	values := make([]int64, 0, len(s.indexes)+len(s.derived))
+	for _, index := range s.indexes {
+		values = append(values, all[index])
+	}
+	values = append(values, all[s.fieldsCount:]...)
+
+	selected := NewMainFrame(frame.Type(), values, int64(frame.Start()), int64(frame.Start()+frame.Size()), frame.Error())
+	selected.setValidity(frame.Validity())
+	return selected
+}
+
+// derivedFieldDefinition returns the definition of a derived field
+func derivedFieldDefinition(name FieldName) FieldDefinition {
+	return FieldDefinition{Name: name, Signed: true, Decimals: DerivedFieldDecimals}
+}
+
+// derivedValue returns the fixed-point integer of the value of a derived field. Values which
+// can't be represented, like after a division by zero, are 0.
+func derivedValue(value float64) int64 {
+	value *= math.Pow10(DerivedFieldDecimals)
+	if math.IsNaN(value) || math.IsInf(value, 0) || math.Abs(value) > math.MaxInt64 {
+		return 0
+	}
+	return int64(math.Round(value))
+}
+
+// SelectFields returns the frames of a channel with the fields of their main frames
+// selected. The returned channel is closed once the input channel is, or the context
+// canceled.
+func SelectFields(ctx context.Context, frames <-chan Frame, selector *FieldSelector) <-chan Frame {
+	return TransformFrames(ctx, frames, FrameFunc(func(frame Frame) []Frame {
+		return []Frame{selector.Frame(frame)}
+	}))
+}
+
+// selectionLookup finds fields among the ones of a log, followed by derived ones
+type selectionLookup struct {
+	frameDef LogDefinition
+	derived  []FieldName
+}
+
+func (l *selectionLookup) index(name FieldName) (int, error) {
+	for i, derived := range l.derived {
+		if derived == name {
+			return len(l.frameDef.FieldsI) + i, nil
+		}
+	}
+	return l.frameDef.GetFieldIndex(name)
+}
+
+func (l *selectionLookup) definition(index int) FieldDefinition {
+	if index >= len(l.frameDef.FieldsI) {
+		return derivedFieldDefinition(l.derived[index-len(l.frameDef.FieldsI)])
+	}
+	return l.frameDef.FieldsI[index]
+}
+
+func (l *selectionLookup) group(name string) ([]int, error) {
+	indexes := []int{}
+	for i, field := range l.frameDef.FieldsI {
+		if isFieldOfGroup(field.Name, name) {
+			indexes = append(indexes, i)
+		}
+	}
+	for i, derived := range l.derived {
+		if isFieldOfGroup(derived, name) {
+			indexes = append(indexes, len(l.frameDef.FieldsI)+i)
+		}
+	}
+
+	if len(indexes) == 0 {
+		return nil, errors.Errorf("No field matches '%s[*]'", name)
+	}
+	return indexes, nil
+}
+
+// isFieldOfGroup tells if a field is one of a group, like motor[2] for motor
+func isFieldOfGroup(field FieldName, group string) bool {
+	if !strings.HasPrefix(string(field), group+"[") || !strings.HasSuffix(string(field), "]") {
+		return false
+	}
+	_, err := strconv.Atoi(string(field)[len(group)+1 : len(field)-1])
+	return err == nil
+}
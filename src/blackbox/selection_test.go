@@ -0,0 +1,109 @@
+package blackbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// selectionFrameDef has the time, four motors and the throttle
+var selectionFrameDef = LogDefinition{
+	FieldsI: []FieldDefinition{{Name: FieldTime}, {Name: "motor[0]"}, {Name: "motor[1]"}, {Name: "motor[2]"}, {Name: "motor[3]"}, {Name: "rcCommand[3]"}},
+	FieldIRL: map[FieldName]int{
+		FieldTime: 0, "motor[0]": 1, "motor[1]": 2, "motor[2]": 3, "motor[3]": 4, "rcCommand[3]": 5,
+	},
+}
+
+func TestFieldSelector(t *testing.T) {
+	selector, err := NewFieldSelector(selectionFrameDef, SelectionOpts{
+		Fields: []string{"motor[*]", "time"},
+		Derived: []DerivedField{
+			mustParseDerivedField(t, "motorAvg = mean(motor[*])"),
+			mustParseDerivedField(t, "thrustPct = (rcCommand[3]-1000)/10"),
+			mustParseDerivedField(t, "spread = motorAvg - motor[0]"),
+		},
+	})
+	assert.NoError(t, err)
+
+	definition := selector.Definition()
+	names := []FieldName{}
+	for _, field := range definition.FieldsI {
+		names = append(names, field.Name)
+	}
+	assert.Equal(t, []FieldName{"motor[0]", "motor[1]", "motor[2]", "motor[3]", FieldTime, "motorAvg", "thrustPct", "spread"}, names)
+	assert.Len(t, definition.FieldsP, len(names))
+
+	index, err := definition.GetFieldIndex(FieldTime)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, index)
+	_, err = definition.GetFieldIndex("rcCommand[3]")
+	assert.Error(t, err)
+
+	frame := selector.Frame(MarkValid(NewMainFrame(LogFrameInter, []int64{5000, 1000, 1101, 1200, 1400, 1216}, 10, 40, nil)))
+	assert.Equal(t, []int64{1000, 1101, 1200, 1400, 5000, 1175250000, 21600000, 175250000}, frame.Values())
+	assert.Equal(t, 0, definition.FieldsI[4].Decimals)
+	assert.Equal(t, DerivedFieldDecimals, definition.FieldsI[5].Decimals)
+	assert.Equal(t, 1175.25, definition.FieldsI[5].Value(frame.Values().([]int64)[5]))
+	assert.Equal(t, byte(LogFrameInter), frame.Type())
+	assert.True(t, frame.Validity())
+	assert.Equal(t, 10, frame.Start())
+	assert.Equal(t, 30, frame.Size())
+
+	// Other frames go through
+	slowFrame := NewSlowFrame([]int64{1, 0, 0, 0, 0}, 0, 0, nil)
+	assert.Equal(t, slowFrame, selector.Frame(slowFrame))
+}
+
+func TestFieldSelectorKeepsAllFields(t *testing.T) {
+	selector, err := NewFieldSelector(selectionFrameDef, SelectionOpts{
+		Derived: []DerivedField{mustParseDerivedField(t, "ratio = motor[0] / (rcCommand[3] - 1216)")},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, selector.Definition().FieldsI, 7)
+
+	// Values of a division by zero are 0
+	frame := selector.Frame(NewMainFrame(LogFrameIntra, []int64{5000, 1000, 1100, 1200, 1400, 1216}, 0, 0, nil))
+	assert.Equal(t, []int64{5000, 1000, 1100, 1200, 1400, 1216, 0}, frame.Values())
+}
+
+func TestFieldSelectorErrors(t *testing.T) {
+	_, err := NewFieldSelector(selectionFrameDef, SelectionOpts{Fields: []string{"gyroADC[*]"}})
+	assert.Error(t, err)
+
+	_, err = NewFieldSelector(selectionFrameDef, SelectionOpts{Fields: []string{"vbatLatest"}})
+	assert.Error(t, err)
+
+	_, err = NewFieldSelector(selectionFrameDef, SelectionOpts{Derived: []DerivedField{mustParseDerivedField(t, "time = 1")}})
+	assert.Error(t, err)
+
+	_, err = NewFieldSelector(selectionFrameDef, SelectionOpts{Derived: []DerivedField{mustParseDerivedField(t, "x = motorAvg")}})
+	assert.Error(t, err)
+
+	for _, definition := range []string{"motorAvg", "= 1", "2x = 1", "x = (1"} {
+		_, err := ParseDerivedField(definition)
+		assert.Error(t, err, definition)
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	selector, err := NewFieldSelector(selectionFrameDef, SelectionOpts{Fields: []string{"rcCommand[3]"}})
+	assert.NoError(t, err)
+
+	frames := make(chan Frame, 2)
+	frames <- NewMainFrame(LogFrameIntra, []int64{5000, 1000, 1100, 1200, 1400, 1216}, 0, 0, nil)
+	frames <- NewEventFrame(LogEventLogEnd, nil, 0, 0, nil)
+	close(frames)
+
+	selected := []Frame{}
+	for frame := range SelectFields(context.Background(), frames, selector) {
+		selected = append(selected, frame)
+	}
+	assert.Equal(t, []interface{}{[]int64{1216}, byte(LogFrameEvent)}, frameValues(selected))
+}
+
+func mustParseDerivedField(t *testing.T, definition string) DerivedField {
+	derived, err := ParseDerivedField(definition)
+	assert.NoError(t, err)
+	return derived
+}
@@ -0,0 +1,71 @@
+package blackbox
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// sessionScanBufferSize is the number of bytes read at once while looking for sessions
+	sessionScanBufferSize = 64 * 1024
+)
+
+// logSessionMarker is how every session of a log file starts
+var logSessionMarker = []byte("H " + string(HeaderProduct) + ":Blackbox flight data recorder")
+
+// LogSession is the location of a session within a log file. The flight controller
+// starts a new session, with its own headers, every time logging is started.
+type LogSession struct {
+	Index int
+	Start int64
+	End   int64
+}
+
+// Size returns the size in bytes of the session
+func (s LogSession) Size() int64 {
+	return s.End - s.Start
+}
+
+// FindSessions returns the sessions of a log file of the given size
+func FindSessions(r io.ReaderAt, size int64) ([]LogSession, error) {
+	sessions := []LogSession{}
+	buf := make([]byte, sessionScanBufferSize)
+
+	// Consecutive reads overlap so that a marker can't be missed across two of them
+	step := int64(sessionScanBufferSize - len(logSessionMarker) + 1)
+	for offset := int64(0); offset < size; offset += step {
+		n, err := r.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return nil, errors.WithStack(err)
+		}
+
+		for searchFrom := 0; ; {
+			index := bytes.Index(buf[searchFrom:n], logSessionMarker)
+			if index == -1 {
+				break
+			}
+			start := offset + int64(searchFrom+index)
+			sessions = append(sessions, LogSession{Index: len(sessions) + 1, Start: start})
+			searchFrom += index + 1
+		}
+
+		if int64(n) < int64(len(buf)) {
+			break
+		}
+	}
+
+	if len(sessions) == 0 {
+		return nil, errors.New("No log session found")
+	}
+
+	for i := range sessions {
+		if i+1 < len(sessions) {
+			sessions[i].End = sessions[i+1].Start
+		} else {
+			sessions[i].End = size
+		}
+	}
+	return sessions, nil
+}
@@ -0,0 +1,41 @@
+package blackbox
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindSessions(t *testing.T) {
+	content, err := ioutil.ReadFile("../../fixtures/normal.bfl")
+	assert.NoError(t, err)
+
+	logFile := append(append([]byte{}, content...), content...)
+	sessions, err := FindSessions(bytes.NewReader(logFile), int64(len(logFile)))
+	assert.NoError(t, err)
+
+	size := int64(len(content))
+	assert.Equal(t, []LogSession{
+		LogSession{Index: 1, Start: 0, End: size},
+		LogSession{Index: 2, Start: size, End: 2 * size},
+	}, sessions)
+	assert.Equal(t, size, sessions[1].Size())
+}
+
+func TestFindSessionsAcrossBuffers(t *testing.T) {
+	logFile := make([]byte, sessionScanBufferSize+100)
+	start := sessionScanBufferSize - 10
+	copy(logFile[start:], logSessionMarker)
+
+	sessions, err := FindSessions(bytes.NewReader(logFile), int64(len(logFile)))
+	assert.NoError(t, err)
+	assert.Equal(t, []LogSession{LogSession{Index: 1, Start: int64(start), End: int64(len(logFile))}}, sessions)
+}
+
+func TestFindSessionsWithoutSession(t *testing.T) {
+	logFile := []byte("not a log")
+	_, err := FindSessions(bytes.NewReader(logFile), int64(len(logFile)))
+	assert.EqualError(t, err, "No log session found")
+}
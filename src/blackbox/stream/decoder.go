@@ -54,16 +54,17 @@ func (d *Decoder) ReadInt() (int64, error) {
 	return int64(bytes[0]), nil
 }
 
-// ReadBytes reads multiple bytes
+// ReadBytes reads multiple bytes, waiting for all of them to be available.
+// Returns io.EOF if the stream ends before.
 func (d *Decoder) ReadBytes(number int) ([]byte, error) {
 	bytes := make([]byte, number)
-	n, err := d.reader.Read(bytes)
-	if err == io.EOF {
-		return nil, err
+	n, err := io.ReadFull(d.reader, bytes)
+	d.offset += int64(n)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, io.EOF
 	} else if err != nil {
 		return nil, ReadError{err}
 	}
-	d.offset += int64(n)
 	return bytes, nil
 }
 
@@ -201,70 +202,164 @@ func (d *Decoder) ReadTag2_3S32() ([]int64, error) {
 		values[2] = SignExtend6Bit(uint8(leadByte & 0x3F))
 	case 3:
 		// Fields are 8, 16 or 24 bits, read selector to figure out which field is which size
-		for i := 0; i < 3; i++ {
-			switch leadByte & 0x03 {
-			case 0: // 8-bit
-				byte1, err := d.ReadInt()
-				if err != nil {
-					return values, err
-				}
-				values[i] = byte1
-			case 1: // 16-bit
-				byte1, err := d.ReadInt()
-				if err != nil {
-					return values, err
-				}
-				byte2, err := d.ReadInt()
-				if err != nil {
-					return values, err
-				}
+		err = d.readTag2_3S32Fields(leadByte, values)
+	}
+	return values, err
+}
 
-				values[i] = byte1 | byte2<<8
-			case 2: // 24-bit
-				byte1, err := d.ReadInt()
-				if err != nil {
-					return values, err
-				}
-				byte2, err := d.ReadInt()
-				if err != nil {
-					return values, err
-				}
-				byte3, err := d.ReadInt()
-				if err != nil {
-					return values, err
-				}
+// readTag2_3S32Fields reads three fields of 8, 16, 24 or 32 bits, their respective
+// sizes being given by the 6 lower bits of leadByte
+func (d *Decoder) readTag2_3S32Fields(leadByte byte, values []int64) error {
+	for i := 0; i < 3; i++ {
+		switch leadByte & 0x03 {
+		case 0: // 8-bit
+			byte1, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+			values[i] = int64(int8(byte1))
+		case 1: // 16-bit
+			byte1, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+			byte2, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
 
-				values[i] = SignExtend24Bit(uint32(byte1 | (byte2 << 8) | (byte3 << 16)))
-			case 3: // 32-bit
-				byte1, err := d.ReadInt()
-				if err != nil {
-					return values, err
-				}
-				byte2, err := d.ReadInt()
-				if err != nil {
-					return values, err
-				}
-				byte3, err := d.ReadInt()
-				if err != nil {
-					return values, err
-				}
-				byte4, err := d.ReadInt()
-				if err != nil {
-					return values, err
-				}
-				// Sign-extend
-				values[i] = (byte1 | (byte2 << 8) | (byte3 << 16) | (byte4 << 24))
+			values[i] = int64(int16(byte1 | byte2<<8))
+		case 2: // 24-bit
+			byte1, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+			byte2, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+			byte3, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+
+			values[i] = SignExtend24Bit(uint32(byte1 | (byte2 << 8) | (byte3 << 16)))
+		case 3: // 32-bit
+			byte1, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+			byte2, err := d.ReadInt()
+			if err != nil {
+				return err
 			}
-			leadByte >>= 2
+			byte3, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+			byte4, err := d.ReadInt()
+			if err != nil {
+				return err
+			}
+			// Sign-extend
+			values[i] = int64(int32(byte1 | (byte2 << 8) | (byte3 << 16) | (byte4 << 24)))
 		}
+		leadByte >>= 2
 	}
-	return values, nil
+	return nil
+}
+
+// ReadTag2_3SVariable returns three signed values packed in 1 to 13 bytes. It is only used by INAV.
+func (d *Decoder) ReadTag2_3SVariable() ([]int64, error) {
+	values := make([]int64, 3)
+	leadByte, err := d.ReadByte()
+	if err != nil {
+		return values, err
+	}
+
+	switch leadByte >> 6 {
+	case 0:
+		// 2-bit fields
+		values[0] = SignExtend2Bit(uint8((leadByte >> 4) & 0x03))
+		values[1] = SignExtend2Bit(uint8((leadByte >> 2) & 0x03))
+		values[2] = SignExtend2Bit(uint8(leadByte & 0x03))
+	case 1:
+		// 5-5-4 bit fields
+		values[0] = SignExtend5Bit(uint8((leadByte & 0x3E) >> 1))
+
+		byte1, err := d.ReadByte()
+		if err != nil {
+			return values, err
+		}
+		values[1] = SignExtend5Bit(uint8((leadByte&0x01)<<4 | (byte1&0xF0)>>4))
+		values[2] = SignExtend4Bit(uint8(byte1 & 0x0F))
+	case 2:
+		// 8-7-7 bit fields
+		byte1, err := d.ReadByte()
+		if err != nil {
+			return values, err
+		}
+		values[0] = SignExtend8Bit(uint8((leadByte&0x3F)<<2 | (byte1&0xC0)>>6))
+
+		byte2, err := d.ReadByte()
+		if err != nil {
+			return values, err
+		}
+		values[1] = SignExtend7Bit(uint8((byte1&0x3F)<<1 | (byte2&0x80)>>7))
+		values[2] = SignExtend7Bit(uint8(byte2 & 0x7F))
+	case 3:
+		// Fields are 8, 16, 24 or 32 bits, read selector to figure out which field is which size
+		err = d.readTag2_3S32Fields(leadByte, values)
+	}
+	return values, err
 }
 
-//ReadTag8_4S16V1 returns
+// ReadTag8_4S16V1 returns 4 values written with the first version of the encoding, used by logs with
+// data version 1. 4-bit fields come in pairs sharing the same byte, 16-bit fields are little-endian.
 func (d *Decoder) ReadTag8_4S16V1() ([]int64, error) {
-	// TODO: Implement
-	panic("Not implemented")
+	values := make([]int64, 8)
+	selector, err := d.ReadByte()
+	if err != nil {
+		return values, err
+	}
+
+	for i := 0; i < 4; i++ {
+		switch selector & 0x03 {
+		case fieldZero:
+			values[i] = 0
+		case field4Bit: // Two 4-bit fields
+			combined, err := d.ReadByte()
+			if err != nil {
+				return values, err
+			}
+			values[i] = SignExtend4Bit(uint8(combined & 0x0F))
+
+			// The next field is part of the same byte
+			i++
+			selector >>= 2
+			if i < 4 {
+				values[i] = SignExtend4Bit(uint8(combined >> 4))
+			}
+		case field8Bit: // 8-bit field
+			val, err := d.ReadByte()
+			if err != nil {
+				return values, err
+			}
+			values[i] = int64(int8(val))
+		case field16Bit: // 16-bit field
+			char1, err := d.ReadByte()
+			if err != nil {
+				return values, err
+			}
+			char2, err := d.ReadByte()
+			if err != nil {
+				return values, err
+			}
+			values[i] = int64(int16(uint16(char1) | uint16(char2)<<8))
+		}
+		selector >>= 2
+	}
+	return values, nil
 }
 
 // ReadTag8_4S16V2 returns
@@ -308,7 +403,7 @@ func (d *Decoder) ReadTag8_4S16V2() ([]int64, error) {
 					return values, err
 				}
 
-				values[i] = int64(val)
+				values[i] = int64(int8(val))
 			} else {
 				char1 = buffer << 4
 				val, err := d.ReadByte()
@@ -318,7 +413,7 @@ func (d *Decoder) ReadTag8_4S16V2() ([]int64, error) {
 				buffer = uint8(val)
 
 				char1 |= buffer >> 4
-				values[i] = int64(char1)
+				values[i] = int64(int8(char1))
 			}
 
 		case field16Bit: // 16-bit field
@@ -335,7 +430,7 @@ func (d *Decoder) ReadTag8_4S16V2() ([]int64, error) {
 				char2 = uint8(val)
 
 				//Sign extend...
-				values[i] = int64(uint16(((char1 << 8) | char2)))
+				values[i] = int64(int16(uint16(char1)<<8 | uint16(char2)))
 			} else {
 				/*
 				 * We're in the low 4 bits of the current buffer, then one byte, then the high 4 bits of the next
@@ -352,7 +447,7 @@ func (d *Decoder) ReadTag8_4S16V2() ([]int64, error) {
 				}
 				char2 = uint8(val)
 
-				values[i] = int64(uint16(((buffer << 12) | (char1 << 4) | (char2 >> 4))))
+				values[i] = int64(int16(uint16(buffer)<<12 | uint16(char1)<<4 | uint16(char2)>>4))
 
 				buffer = char2
 			}
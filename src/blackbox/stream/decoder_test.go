@@ -3,7 +3,9 @@ package stream
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -71,6 +73,20 @@ func TestEOFReached(t *testing.T) {
 	assert.True(t, eof)
 }
 
+func TestReadBytesFromPartialReads(t *testing.T) {
+	r := iotest.OneByteReader(bytes.NewReader([]byte{1, 2, 3, 4, 5}))
+	decoder := NewDecoder(r)
+
+	val, err := decoder.ReadBytes(4)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 2, 3, 4}, val)
+	assert.Equal(t, int64(4), decoder.Offset())
+
+	_, err = decoder.ReadBytes(4)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, int64(5), decoder.Offset())
+}
+
 func TestReadUnsignedVB(t *testing.T) {
 	inputArray := [][]byte{
 		[]byte{55, 99},
@@ -203,8 +219,8 @@ func TestReadTag2_3S32(t *testing.T) {
 
 		[]int64{15, 24, 77, 0, 0, 0, 0, 0},
 
-		// 16843009 (00000001 0000000 10000000 100000001), 19777 (01001101 01000001), 155
-		[]int64{16843009, 19777, 155, 0, 0, 0, 0, 0},
+		// 16843009 (00000001 0000000 10000000 100000001), 19777 (01001101 01000001), -101 (10011011)
+		[]int64{16843009, 19777, -101, 0, 0, 0, 0, 0},
 	}
 
 	for testIndex, input := range inputArray {
@@ -219,6 +235,74 @@ func TestReadTag2_3S32(t *testing.T) {
 	}
 }
 
+func TestReadTag2_3SVariable(t *testing.T) {
+	inputArray := [][]byte{
+		// 2 bits fields - lead byte 30 (00 01 11 10)
+		[]byte{30},
+
+		// 5-5-4 bits fields - lead byte 118 (01 11011 0), 205 (1100 1101)
+		[]byte{118, 205},
+
+		// 8-7-7 bits fields - lead byte 153 (10 011001), 44 (00 101100), 63 (0 0111111)
+		[]byte{153, 44, 63},
+
+		// variable bits fields - lead byte 199 (11 00 01 11), 65 (01000001), 77 (01001101), 155
+		[]byte{199, 1, 1, 1, 1, 65, 77, 155},
+	}
+	outputArray := [][]int64{
+		// 1 (01), -1 (11), -2 (10)
+		[]int64{1, -1, -2},
+
+		// -5 (11011), 12 (01100), -3 (1101)
+		[]int64{-5, 12, -3},
+
+		// 100 (01100100), -40 (1011000), 63 (0111111)
+		[]int64{100, -40, 63},
+
+		[]int64{16843009, 19777, -101},
+	}
+
+	for testIndex, input := range inputArray {
+		t.Run(fmt.Sprintf("for %v", input), func(t *testing.T) {
+			r := bytes.NewReader(input)
+			decoder := NewDecoder(r)
+
+			val, err := decoder.ReadTag2_3SVariable()
+			assert.Nil(t, err)
+			assert.Equal(t, outputArray[testIndex], val)
+		})
+	}
+}
+
+func TestReadTag8_4S16V1(t *testing.T) {
+	inputArray := [][]byte{
+		// selector 229 (11 10 01 01), 227 (1110 0011), 100, 212 (11010100) 254 (11111110)
+		[]byte{229, 227, 100, 212, 254},
+
+		// selector 14 (00 00 11 10), 5, 200 0
+		[]byte{14, 5, 200, 0},
+
+		// selector 128 (10 00 00 00), 249 (11111001)
+		[]byte{128, 249},
+	}
+	outputArray := [][]int64{
+		[]int64{3, -2, 100, -300, 0, 0, 0, 0},
+		[]int64{5, 200, 0, 0, 0, 0, 0, 0},
+		[]int64{0, 0, 0, -7, 0, 0, 0, 0},
+	}
+
+	for testIndex, input := range inputArray {
+		t.Run(fmt.Sprintf("for %v", input), func(t *testing.T) {
+			r := bytes.NewReader(input)
+			decoder := NewDecoder(r)
+
+			val, err := decoder.ReadTag8_4S16V1()
+			assert.Nil(t, err)
+			assert.Equal(t, outputArray[testIndex], val)
+		})
+	}
+}
+
 func TestReadTag8_4S16V2(t *testing.T) {
 	inputArray := [][]byte{
 		[]byte{156, 15, 57, 112, 99},
@@ -227,10 +311,12 @@ func TestReadTag8_4S16V2(t *testing.T) {
 		[]byte{189, 254, 13, 99, 1, 2, 3, 4, 99, 15},
 	}
 	outputArray := [][]int64{
-		[]int64{0, 57, 7, 6, 0, 0, 0, 0},
-		[]int64{57, 99, 2, 0, 0, 0, 0, 0},
-		[]int64{1, 99, 2, 0, 0, 0, 0, 0},
-		[]int64{-1, 214, 16, 32, 0, 0, 0, 0},
+		// 3897 (00001111 00111001)
+		[]int64{0, 3897, 7, 6, 0, 0, 0, 0},
+		[]int64{3897, 28771, 258, 0, 0, 0, 0, 0},
+		[]int64{21505, 14435, 258, 0, 0, 0, 0, 0},
+		// -7978 (1110 00001101 0110), 12304 (0011 00000001 0000)
+		[]int64{-1, -7978, 12304, 32, 0, 0, 0, 0},
 	}
 
 	for testIndex, input := range inputArray {
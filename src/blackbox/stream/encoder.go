@@ -0,0 +1,297 @@
+package stream
+
+import (
+	"io"
+)
+
+// Encoder can write various form of encoded bytes. It's the counterpart of
+// the Decoder.
+type Encoder struct {
+	writer io.Writer
+	offset int64
+}
+
+// NewEncoder returns a new instance of an Encoder
+func NewEncoder(writer io.Writer) *Encoder {
+	return &Encoder{
+		writer: writer,
+		offset: 0,
+	}
+}
+
+// Offset returns the number of bytes written so far
+func (e *Encoder) Offset() int64 {
+	return e.offset
+}
+
+// WriteByte writes one byte
+func (e *Encoder) WriteByte(value byte) error {
+	return e.WriteBytes([]byte{value})
+}
+
+// WriteBytes writes multiple bytes
+func (e *Encoder) WriteBytes(values []byte) error {
+	n, err := e.writer.Write(values)
+	e.offset += int64(n)
+	if err != nil {
+		return WriteError{err}
+	}
+	return nil
+}
+
+// WriteUnsignedVB writes the lower 7 bits of the value in a byte, and sets the
+// high bit of that byte to one if more bytes are required to store the rest of
+// the value.
+func (e *Encoder) WriteUnsignedVB(value uint32) error {
+	for value > 127 {
+		err := e.WriteByte(uint8(value | 0x80))
+		if err != nil {
+			return err
+		}
+		value >>= 7
+	}
+	return e.WriteByte(uint8(value))
+}
+
+// WriteSignedVB folds negative values into positive ones before writing them
+// as unsigned variable bytes.
+func (e *Encoder) WriteSignedVB(value int32) error {
+	return e.WriteUnsignedVB(zigzagEncode(value))
+}
+
+// WriteTag8_8SVB writes an 8-bit header with a bit set for each non-zero
+// value, followed by the non-zero values as signed variable bytes. A single
+// value is written without header.
+func (e *Encoder) WriteTag8_8SVB(values []int64, valueCount int) error {
+	if valueCount == 1 {
+		return e.WriteSignedVB(int32(values[0]))
+	}
+
+	header := uint8(0)
+	for i := valueCount - 1; i >= 0; i-- {
+		header <<= 1
+		if values[i] != 0 {
+			header |= 0x01
+		}
+	}
+
+	err := e.WriteByte(header)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < valueCount; i++ {
+		if values[i] != 0 {
+			err = e.WriteSignedVB(int32(values[i]))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteTag2_3S32 writes 3 values packed in 2, 4 or 6 bits each when they are
+// small enough, or with a selector giving the size of each of them otherwise.
+func (e *Encoder) WriteTag2_3S32(values []int64) error {
+	const (
+		bits2  = 0
+		bits4  = 1
+		bits6  = 2
+		bits32 = 3
+	)
+
+	// Find out how many bits the largest value requires
+	selector := bits2
+	for i := 0; i < 3; i++ {
+		if values[i] >= 32 || values[i] < -32 {
+			selector = bits32
+			break
+		}
+		if values[i] >= 8 || values[i] < -8 {
+			if selector < bits6 {
+				selector = bits6
+			}
+		} else if values[i] >= 2 || values[i] < -2 {
+			if selector < bits4 {
+				selector = bits4
+			}
+		}
+	}
+
+	switch selector {
+	case bits2:
+		return e.WriteByte(uint8(selector<<6) | uint8(values[0]&0x03)<<4 | uint8(values[1]&0x03)<<2 | uint8(values[2]&0x03))
+	case bits4:
+		return e.WriteBytes([]byte{
+			uint8(selector<<6) | uint8(values[0]&0x0F),
+			uint8(values[1]&0x0F)<<4 | uint8(values[2]&0x0F),
+		})
+	case bits6:
+		return e.WriteBytes([]byte{
+			uint8(selector<<6) | uint8(values[0]&0x3F),
+			uint8(values[1] & 0x3F),
+			uint8(values[2] & 0x3F),
+		})
+	}
+
+	return e.writeTag2_3S32Fields(selector, values)
+}
+
+// writeTag2_3S32Fields writes a lead byte made of selector and the size of each of the
+// three values, followed by the values in as many bytes as they need
+func (e *Encoder) writeTag2_3S32Fields(selector int, values []int64) error {
+	// Every value gets its own size, the first field being in the low bits
+	sizes := uint8(0)
+	for i := 2; i >= 0; i-- {
+		sizes <<= 2
+		switch {
+		case values[i] < 128 && values[i] >= -128:
+			sizes |= 0
+		case values[i] < 32768 && values[i] >= -32768:
+			sizes |= 1
+		case values[i] < 8388608 && values[i] >= -8388608:
+			sizes |= 2
+		default:
+			sizes |= 3
+		}
+	}
+
+	buffer := []byte{uint8(selector<<6) | sizes}
+	for i := 0; i < 3; i++ {
+		byteCount := int(sizes&0x03) + 1
+		for b := 0; b < byteCount; b++ {
+			buffer = append(buffer, uint8(values[i]>>(8*uint(b))))
+		}
+		sizes >>= 2
+	}
+	return e.WriteBytes(buffer)
+}
+
+// WriteTag2_3SVariable writes three signed values the way INAV does, using fields of
+// 2, 5-5-4 or 8-7-7 bits when the values are small enough
+func (e *Encoder) WriteTag2_3SVariable(values []int64) error {
+	fits := func(value int64, bits uint) bool {
+		return value >= -(1<<(bits-1)) && value < 1<<(bits-1)
+	}
+
+	switch {
+	case fits(values[0], 2) && fits(values[1], 2) && fits(values[2], 2):
+		return e.WriteByte(uint8(values[0]&0x03)<<4 | uint8(values[1]&0x03)<<2 | uint8(values[2]&0x03))
+	case fits(values[0], 5) && fits(values[1], 5) && fits(values[2], 4):
+		return e.WriteBytes([]byte{
+			1<<6 | uint8(values[0]&0x1F)<<1 | uint8(values[1]&0x1F)>>4,
+			uint8(values[1]&0x0F)<<4 | uint8(values[2]&0x0F),
+		})
+	case fits(values[0], 8) && fits(values[1], 7) && fits(values[2], 7):
+		return e.WriteBytes([]byte{
+			2<<6 | uint8(values[0]&0xFF)>>2,
+			uint8(values[0]&0x03)<<6 | uint8(values[1]&0x7F)>>1,
+			uint8(values[1]&0x01)<<7 | uint8(values[2]&0x7F),
+		})
+	}
+	return e.writeTag2_3S32Fields(3, values)
+}
+
+// WriteTag8_4S16V2 writes an 8-bit selector giving the size of each of the 4
+// values, followed by the values packed as nibbles.
+func (e *Encoder) WriteTag8_4S16V2(values []int64) error {
+	selector := uint8(0)
+	for i := 3; i >= 0; i-- {
+		selector <<= 2
+		selector |= tag8_4S16FieldSize(values[i])
+	}
+
+	buffer := []byte{selector}
+	nibble := uint8(0)
+	nibbleIndex := 0
+	for i := 0; i < 4; i++ {
+		value := uint16(values[i])
+		switch selector & 0x03 {
+		case field4Bit:
+			if nibbleIndex == 0 {
+				nibble = uint8(value << 4)
+				nibbleIndex = 1
+			} else {
+				buffer = append(buffer, nibble|uint8(value&0x0F))
+				nibbleIndex = 0
+			}
+		case field8Bit:
+			if nibbleIndex == 0 {
+				buffer = append(buffer, uint8(value))
+			} else {
+				buffer = append(buffer, nibble|uint8((value>>4)&0x0F))
+				nibble = uint8(value << 4)
+			}
+		case field16Bit:
+			if nibbleIndex == 0 {
+				buffer = append(buffer, uint8(value>>8), uint8(value))
+			} else {
+				buffer = append(buffer, nibble|uint8((value>>12)&0x0F), uint8(value>>4))
+				nibble = uint8(value << 4)
+			}
+		}
+		selector >>= 2
+	}
+
+	// Flush the nibble left over
+	if nibbleIndex == 1 {
+		buffer = append(buffer, nibble)
+	}
+	return e.WriteBytes(buffer)
+}
+
+// WriteTag8_4S16V1 writes 4 values with the first version of the encoding, used by logs with data
+// version 1. A 4-bit field shares its byte with the next field, which has to fit in 4 bits as well.
+func (e *Encoder) WriteTag8_4S16V1(values []int64) error {
+	sizes := make([]uint8, 4)
+	for i := 0; i < 4; i++ {
+		sizes[i] = tag8_4S16FieldSize(values[i])
+		if sizes[i] != field4Bit {
+			continue
+		}
+
+		// Values that can't be paired with the next one get a full byte
+		if i == 3 || tag8_4S16FieldSize(values[i+1]) > field4Bit {
+			sizes[i] = field8Bit
+			continue
+		}
+		i++
+		sizes[i] = field4Bit
+	}
+
+	selector := uint8(0)
+	for i := 3; i >= 0; i-- {
+		selector = selector<<2 | sizes[i]
+	}
+
+	buffer := []byte{selector}
+	for i := 0; i < 4; i++ {
+		switch sizes[i] {
+		case field4Bit:
+			buffer = append(buffer, uint8(values[i]&0x0F)|uint8(values[i+1]&0x0F)<<4)
+			i++
+		case field8Bit:
+			buffer = append(buffer, uint8(values[i]))
+		case field16Bit:
+			buffer = append(buffer, uint8(values[i]), uint8(values[i]>>8))
+		}
+	}
+	return e.WriteBytes(buffer)
+}
+
+func tag8_4S16FieldSize(value int64) uint8 {
+	switch {
+	case value == 0:
+		return fieldZero
+	case value < 8 && value >= -8:
+		return field4Bit
+	case value < 128 && value >= -128:
+		return field8Bit
+	default:
+		return field16Bit
+	}
+}
+
+func zigzagEncode(value int32) uint32 {
+	return uint32((value << 1) ^ (value >> 31))
+}
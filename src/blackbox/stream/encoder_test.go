@@ -0,0 +1,230 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteUnsignedVB(t *testing.T) {
+	inputArray := []uint32{0x37, 0x633, 0x37f3d}
+	outputArray := [][]byte{
+		[]byte{55},
+		[]byte{179, 12},
+		[]byte{189, 254, 13},
+	}
+
+	for testIndex, input := range inputArray {
+		t.Run(fmt.Sprintf("for %v", input), func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := NewEncoder(&buf)
+
+			err := encoder.WriteUnsignedVB(input)
+			assert.Nil(t, err)
+			assert.Equal(t, outputArray[testIndex], buf.Bytes())
+			assert.Equal(t, int64(len(outputArray[testIndex])), encoder.Offset())
+		})
+	}
+}
+
+func TestWriteSignedVB(t *testing.T) {
+	inputArray := []int32{-28, -794, -114591}
+	outputArray := [][]byte{
+		[]byte{55},
+		[]byte{179, 12},
+		[]byte{189, 254, 13},
+	}
+
+	for testIndex, input := range inputArray {
+		t.Run(fmt.Sprintf("for %v", input), func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := NewEncoder(&buf)
+
+			err := encoder.WriteSignedVB(input)
+			assert.Nil(t, err)
+			assert.Equal(t, outputArray[testIndex], buf.Bytes())
+		})
+	}
+}
+
+func TestWriteTag8_8SVB(t *testing.T) {
+	inputArray := [][]int64{
+		[]int64{-8, -29, 0, 0, 0, 0, 0, 0},
+		[]int64{6, 6346, 0, 0, 0, 0, 0, 0},
+		[]int64{0, 0, 0, 0, 0, 0, 0, 0},
+	}
+	outputArray := [][]byte{
+		[]byte{3, 15, 57},
+		[]byte{3, 12, 148, 99},
+		[]byte{0},
+	}
+
+	for testIndex, input := range inputArray {
+		t.Run(fmt.Sprintf("for %v", input), func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := NewEncoder(&buf)
+
+			err := encoder.WriteTag8_8SVB(input, 3)
+			assert.Nil(t, err)
+			assert.Equal(t, outputArray[testIndex], buf.Bytes())
+		})
+	}
+}
+
+func TestWriteTag2_3S32(t *testing.T) {
+	inputArray := [][]int64{
+		[]int64{1, -1, -2},
+		[]int64{28, 15, -7},
+		[]int64{-1, 5, 4},
+		[]int64{15, 24, 77},
+		[]int64{16843009, 19777, -101},
+	}
+	outputArray := [][]byte{
+		[]byte{30},
+		[]byte{156, 15, 57},
+		[]byte{79, 84},
+		[]byte{192, 15, 24, 77},
+		[]byte{199, 1, 1, 1, 1, 65, 77, 155},
+	}
+
+	for testIndex, input := range inputArray {
+		t.Run(fmt.Sprintf("for %v", input), func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := NewEncoder(&buf)
+
+			err := encoder.WriteTag2_3S32(input)
+			assert.Nil(t, err)
+			assert.Equal(t, outputArray[testIndex], buf.Bytes())
+		})
+	}
+}
+
+func TestWriteTag2_3SVariable(t *testing.T) {
+	inputArray := [][]int64{
+		[]int64{1, -1, -2},
+		[]int64{-5, 12, -3},
+		[]int64{100, -40, 63},
+		[]int64{16843009, 19777, -101},
+	}
+	outputArray := [][]byte{
+		[]byte{30},
+		[]byte{118, 205},
+		[]byte{153, 44, 63},
+		[]byte{199, 1, 1, 1, 1, 65, 77, 155},
+	}
+
+	for testIndex, input := range inputArray {
+		t.Run(fmt.Sprintf("for %v", input), func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := NewEncoder(&buf)
+
+			err := encoder.WriteTag2_3SVariable(input)
+			assert.Nil(t, err)
+			assert.Equal(t, outputArray[testIndex], buf.Bytes())
+		})
+	}
+}
+
+func TestWriteTag8_4S16V1(t *testing.T) {
+	inputArray := [][]int64{
+		[]int64{3, -2, 100, -300},
+		[]int64{5, 200, 0, 0},
+		[]int64{0, 0, 0, -7},
+	}
+	outputArray := [][]byte{
+		[]byte{229, 227, 100, 212, 254},
+		[]byte{14, 5, 200, 0},
+		[]byte{128, 249},
+	}
+
+	for testIndex, input := range inputArray {
+		t.Run(fmt.Sprintf("for %v", input), func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := NewEncoder(&buf)
+
+			err := encoder.WriteTag8_4S16V1(input)
+			assert.Nil(t, err)
+			assert.Equal(t, outputArray[testIndex], buf.Bytes())
+		})
+	}
+}
+
+func TestWriteTag8_4S16V2(t *testing.T) {
+	inputArray := [][]int64{
+		[]int64{0, 3897, 7, 6},
+		[]int64{3897, 28771, 258, 0},
+		[]int64{-1, -7978, 12304, 32},
+		[]int64{1, -3, 0, 5},
+	}
+	outputArray := [][]byte{
+		[]byte{92, 15, 57, 118},
+		[]byte{63, 15, 57, 112, 99, 1, 2},
+		[]byte{189, 254, 13, 99, 1, 2, 0},
+		[]byte{69, 29, 80},
+	}
+
+	for testIndex, input := range inputArray {
+		t.Run(fmt.Sprintf("for %v", input), func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := NewEncoder(&buf)
+
+			err := encoder.WriteTag8_4S16V2(input)
+			assert.Nil(t, err)
+			assert.Equal(t, outputArray[testIndex], buf.Bytes())
+		})
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	values := [][]int64{
+		[]int64{0, 0, 0, 0},
+		[]int64{1, -2, 7, -8},
+		[]int64{127, -128, 100, -3},
+		[]int64{32767, -32768, 1000, -1000},
+		[]int64{-1, 2000000, -9000000, 2147483647},
+	}
+
+	for _, input := range values {
+		t.Run(fmt.Sprintf("for %v", input), func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder := NewEncoder(&buf)
+
+			assert.Nil(t, encoder.WriteTag2_3S32(input[1:]))
+			assert.Nil(t, encoder.WriteTag2_3SVariable(input[:3]))
+			assert.Nil(t, encoder.WriteTag8_8SVB(input, 4))
+			if input[3] >= -32768 && input[3] < 32768 && input[1] >= -32768 && input[1] < 32768 {
+				assert.Nil(t, encoder.WriteTag8_4S16V2(input))
+				assert.Nil(t, encoder.WriteTag8_4S16V1(input))
+			}
+
+			decoder := NewDecoder(&buf)
+			val, err := decoder.ReadTag2_3S32()
+			assert.Nil(t, err)
+			assert.Equal(t, input[1:], val[:3])
+
+			val, err = decoder.ReadTag2_3SVariable()
+			assert.Nil(t, err)
+			assert.Equal(t, input[:3], val)
+
+			val, err = decoder.ReadTag8_8SVB(4)
+			assert.Nil(t, err)
+			assert.Equal(t, input, val[:4])
+
+			if input[3] >= -32768 && input[3] < 32768 && input[1] >= -32768 && input[1] < 32768 {
+				val, err = decoder.ReadTag8_4S16V2()
+				assert.Nil(t, err)
+				assert.Equal(t, input, val[:4])
+
+				val, err = decoder.ReadTag8_4S16V1()
+				assert.Nil(t, err)
+				assert.Equal(t, input, val[:4])
+			}
+
+			eof, err := decoder.EOF()
+			assert.Nil(t, err)
+			assert.True(t, eof)
+		})
+	}
+}
@@ -7,3 +7,11 @@ type ReadError struct {
 func (e ReadError) Error() string {
 	return e.reason.Error()
 }
+
+type WriteError struct {
+	reason error
+}
+
+func (e WriteError) Error() string {
+	return e.reason.Error()
+}
@@ -16,6 +16,14 @@ func SignExtend4Bit(nibble uint8) int64 {
 	return int64(nibble)
 }
 
+// SignExtend5Bit signs
+func SignExtend5Bit(val uint8) int64 {
+	if val&0x10 == 0x10 {
+		return int64(int8(val | 0xE0))
+	}
+	return int64(val)
+}
+
 // SignExtend6Bit signs
 func SignExtend6Bit(nibble uint8) int64 {
 	if nibble&0x20 == 0x20 {
@@ -24,6 +32,19 @@ func SignExtend6Bit(nibble uint8) int64 {
 	return int64(nibble)
 }
 
+// SignExtend7Bit signs
+func SignExtend7Bit(val uint8) int64 {
+	if val&0x40 == 0x40 {
+		return int64(int8(val | 0x80))
+	}
+	return int64(val)
+}
+
+// SignExtend8Bit signs
+func SignExtend8Bit(val uint8) int64 {
+	return int64(int8(val))
+}
+
 // SignExtend14Bit signs
 func SignExtend14Bit(val uint16) int64 {
 	if val&0x2000 == 0x2000 {
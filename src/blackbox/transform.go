@@ -0,0 +1,56 @@
+package blackbox
+
+import (
+	"context"
+)
+
+// FrameTransformer turns the frames of a log into other ones, like a Resampler
+type FrameTransformer interface {
+	// AddFrame feeds a frame and returns the frames ready to be output
+	AddFrame(frame Frame) []Frame
+
+	// Flush returns the frames still held back, once every frame has been fed
+	Flush() []Frame
+}
+
+// FrameFunc is a FrameTransformer outputting the frames a function returns for every
+// frame, without holding any back
+type FrameFunc func(frame Frame) []Frame
+
+// AddFrame returns the frames the function returns for a frame
+func (f FrameFunc) AddFrame(frame Frame) []Frame {
+	return f(frame)
+}
+
+// Flush returns nothing, as no frame is held back
+func (f FrameFunc) Flush() []Frame {
+	return nil
+}
+
+// TransformFrames returns the frames of a channel, transformed. The returned channel is
+// closed once the input channel is and the transformer flushed, or the context canceled.
+func TransformFrames(ctx context.Context, frames <-chan Frame, transformer FrameTransformer) <-chan Frame {
+	transformedChan := make(chan Frame)
+	go func() {
+		defer close(transformedChan)
+
+		send := func(frames []Frame) bool {
+			for _, frame := range frames {
+				select {
+				case transformedChan <- frame:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			return true
+		}
+
+		for frame := range frames {
+			if !send(transformer.AddFrame(frame)) {
+				return
+			}
+		}
+		send(transformer.Flush())
+	}()
+	return transformedChan
+}
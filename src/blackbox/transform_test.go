@@ -0,0 +1,69 @@
+package blackbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pairTransformer outputs the frames two by two, and the last one on flush
+type pairTransformer struct {
+	held Frame
+}
+
+func (p *pairTransformer) AddFrame(frame Frame) []Frame {
+	if p.held == nil {
+		p.held = frame
+		return nil
+	}
+	pair := []Frame{p.held, frame}
+	p.held = nil
+	return pair
+}
+
+func (p *pairTransformer) Flush() []Frame {
+	if p.held == nil {
+		return nil
+	}
+	return []Frame{p.held}
+}
+
+func sendFrames(frames ...Frame) <-chan Frame {
+	frameChan := make(chan Frame, len(frames))
+	for _, frame := range frames {
+		frameChan <- frame
+	}
+	close(frameChan)
+	return frameChan
+}
+
+func TestTransformFrames(t *testing.T) {
+	frames := []Frame{
+		NewMainFrame(LogFrameIntra, []int64{0}, 0, 0, nil),
+		NewMainFrame(LogFrameInter, []int64{1}, 0, 0, nil),
+		NewMainFrame(LogFrameInter, []int64{2}, 0, 0, nil),
+	}
+
+	transformed := []Frame{}
+	for frame := range TransformFrames(context.Background(), sendFrames(frames...), &pairTransformer{}) {
+		transformed = append(transformed, frame)
+	}
+	assert.Equal(t, frames, transformed)
+}
+
+func TestTransformFramesCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	frames := []Frame{}
+	for i := int64(0); i < 4; i++ {
+		frames = append(frames, NewMainFrame(LogFrameInter, []int64{i}, 0, 0, nil))
+	}
+
+	transformedChan := TransformFrames(ctx, sendFrames(frames...), &pairTransformer{})
+	<-transformedChan
+	cancel()
+
+	// The channel is closed without the remaining frames having to be read
+	for range transformedChan {
+	}
+}
@@ -0,0 +1,244 @@
+package blackbox
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+	"github.com/pkg/errors"
+)
+
+// TrimOpts holds the options to trim a log
+type TrimOpts struct {
+	// From and To delimit the frames to keep, as offsets from the first main frame
+	// of the log. A zero To means until the end of the log.
+	From time.Duration
+	To   time.Duration
+}
+
+// TrimResult describes the log written by Trim. Start and End are the offsets of its first
+// and last main frames from the first main frame of the original log.
+type TrimResult struct {
+	Start      time.Duration
+	End        time.Duration
+	MainFrames int
+	Bytes      int64
+}
+
+// NoValidFrameError is returned when a log has no valid main frame to write. Nothing is
+// written then.
+type NoValidFrameError struct {
+	From time.Duration
+	To   time.Duration
+}
+
+func (e *NoValidFrameError) Error() string {
+	if e.From == 0 && e.To == 0 {
+		return "No valid frame found"
+	}
+	return fmt.Sprintf("No valid frame found between %s and %s", e.From, e.To)
+}
+
+// Trim reads a single session log and writes the main frames between opts.From and opts.To as a
+// new log with the same headers. The new log starts with an intra frame, and every
+// run of frames following a corrupted region starts with a LoggingResume event and
+// an intra frame, so that the output can be decoded from scratch.
+func Trim(r io.Reader, w io.Writer, opts TrimOpts) (TrimResult, error) {
+	if opts.To != 0 && opts.To < opts.From {
+		return TrimResult{}, errors.Errorf("Time range ends at %s, before it starts at %s", opts.To, opts.From)
+	}
+	return rewriteLog(r, w, opts, nil)
+}
+
+// ParseFlightTime parses a time in a flight, like the ones the viewers display: mm:ss or
+// hh:mm:ss, with optional fractions of seconds like 01:05.250. Go durations like 1m5s are
+// accepted as well.
+func ParseFlightTime(value string) (time.Duration, error) {
+	invalid := errors.Errorf("Invalid time '%s', expected mm:ss[.fff], hh:mm:ss or a duration like 1m5s", value)
+	if !strings.Contains(value, ":") {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return 0, invalid
+		}
+		return d, nil
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, invalid
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || seconds < 0 || seconds >= 60 {
+		return 0, invalid
+	}
+	minutes, err := strconv.ParseUint(parts[len(parts)-2], 10, 32)
+	if err != nil || (len(parts) == 3 && minutes >= 60) {
+		return 0, invalid
+	}
+	d := time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second))
+	if len(parts) == 3 {
+		hours, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return 0, invalid
+		}
+		d += time.Duration(hours) * time.Hour
+	}
+	return d, nil
+}
+
+// dropHandler is called with the frames a log rewrite leaves out, and why
+type dropHandler func(frame Frame, reason string)
+
+// rewriteLog re-encodes the valid frames of a single session log, starting every run of them
+// with an intra frame
+func rewriteLog(r io.Reader, w io.Writer, opts TrimOpts, onDrop dropHandler) (TrimResult, error) {
+	result := TrimResult{}
+	if onDrop == nil {
+		onDrop = func(Frame, string) {}
+	}
+
+	dec := stream.NewDecoder(bufio.NewReaderSize(r, defaultBufferSize))
+	headerReader := NewHeaderReader(dec)
+	frameDef, err := headerReader.ProcessHeaders()
+	if err != nil {
+		return result, err
+	}
+	timeIdx, err := frameDef.GetFieldIndex(FieldTime)
+	if err != nil {
+		return result, err
+	}
+	frameReader := NewFrameReader(dec, frameDef, nil)
+
+	bufferedWriter := bufio.NewWriter(w)
+	enc := stream.NewEncoder(bufferedWriter)
+	logWriter := NewLogWriter(enc, frameDef)
+
+	var lastSlowFrame, lastGPSHomeFrame []int64
+	logStart := int64(-1)
+	started := false
+	resync := false
+
+ReadingLoop:
+	for {
+		frame := frameReader.ReadNextFrame()
+		if frame.Error() == io.EOF {
+			if frame.Size() > 0 {
+				onDrop(frame, "truncated frame at the end of the log")
+			}
+			break
+		}
+
+		// Drop anything that can't be trusted until the next valid main frame
+		if frame.Error() != nil {
+			onDrop(frame, fmt.Sprintf("corrupted frame: %v", frame.Error()))
+			resync = true
+			continue
+		}
+		if !frame.Validity() {
+			if resync {
+				onDrop(frame, "main frame following a corrupted region, waiting for the next intra frame")
+			} else {
+				onDrop(frame, "main frame with an unexpected iteration or time")
+			}
+			resync = true
+			continue
+		}
+
+		switch frame.Type() {
+		case LogFrameEvent:
+			eventType := frame.(*EventFrame).EventType()
+			if eventType == LogEventLoggingResume || eventType == LogEventLogEnd || !started {
+				continue
+			}
+			err = logWriter.WriteFrame(frame)
+
+		case LogFrameSlow:
+			lastSlowFrame = frame.Values().([]int64)
+			if !started {
+				continue
+			}
+			err = logWriter.WriteFrame(frame)
+
+		case LogFrameGPSHome:
+			lastGPSHomeFrame = frame.Values().([]int64)
+			if !started {
+				continue
+			}
+			err = logWriter.WriteFrame(frame)
+
+		case LogFrameGPS:
+			if !started {
+				continue
+			}
+			err = logWriter.WriteFrame(frame)
+
+		case LogFrameIntra, LogFrameInter:
+			values := frame.Values().([]int64)
+			if logStart < 0 {
+				logStart = values[timeIdx]
+			}
+			offset := time.Duration(values[timeIdx]-logStart) * time.Microsecond
+			if offset < opts.From {
+				continue
+			}
+			if opts.To > 0 && offset > opts.To {
+				break ReadingLoop
+			}
+
+			if !started {
+				err = logWriter.WriteHeaders(headerReader.RawHeaders())
+				if err == nil {
+					err = resumeWith(logWriter, values, lastSlowFrame, false)
+				}
+				// GPS frames to come are predicted from the home position
+				if err == nil && lastGPSHomeFrame != nil {
+					err = logWriter.WriteGPSHomeFrame(lastGPSHomeFrame)
+				}
+				result.Start = offset
+				started = true
+			} else if resync {
+				err = resumeWith(logWriter, values, nil, true)
+			} else {
+				err = logWriter.WriteFrame(frame)
+			}
+			resync = false
+			result.End = offset
+			result.MainFrames++
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if !started {
+		return result, &NoValidFrameError{From: opts.From, To: opts.To}
+	}
+
+	err = logWriter.WriteLogEnd()
+	if err != nil {
+		return result, err
+	}
+	result.Bytes = logWriter.Offset()
+	return result, errors.WithStack(bufferedWriter.Flush())
+}
+
+// resumeWith starts a new run of frames with an intra frame, optionally announced by a
+// LoggingResume event, followed by the last known slow frame
+func resumeWith(logWriter *LogWriter, values []int64, lastSlowFrame []int64, announce bool) error {
+	if announce && logWriter.iterationIdx >= 0 && logWriter.timeIdx >= 0 {
+		err := logWriter.WriteLoggingResume(values[logWriter.iterationIdx], values[logWriter.timeIdx])
+		if err != nil {
+			return err
+		}
+	}
+
+	err := logWriter.WriteIntraFrame(values)
+	if err != nil || lastSlowFrame == nil {
+		return err
+	}
+	return logWriter.WriteSlowFrame(lastSlowFrame)
+}
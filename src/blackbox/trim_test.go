@@ -0,0 +1,94 @@
+package blackbox
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrimWholeLog(t *testing.T) {
+	original := readFixtureMainFrames(t, openFixture(t))
+
+	var buf bytes.Buffer
+	result, err := Trim(openFixture(t), &buf, TrimOpts{})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), result.Start)
+	assert.Equal(t, 2001*time.Microsecond, result.End)
+	assert.Equal(t, 5, result.MainFrames)
+	assert.Equal(t, int64(buf.Len()), result.Bytes)
+
+	assert.Equal(t, original, readFixtureMainFrames(t, &buf))
+}
+
+func TestTrimExcerpt(t *testing.T) {
+	var buf bytes.Buffer
+	// The main frames are at 0, 499, 999, 1503 and 2001us from the first one
+	result, err := Trim(openFixture(t), &buf, TrimOpts{From: 900 * time.Microsecond, To: 1600 * time.Microsecond})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.MainFrames)
+	assert.Equal(t, 999*time.Microsecond, result.Start)
+	assert.Equal(t, 1503*time.Microsecond, result.End)
+
+	frames := readFixtureMainFrames(t, &buf)
+	assert.Len(t, frames, 2)
+	assert.Equal(t, int64(52994), frames[0][0])
+	assert.Equal(t, int64(52995), frames[1][0])
+}
+
+func TestTrimOutOfRange(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := Trim(openFixture(t), &buf, TrimOpts{From: time.Hour})
+	assert.EqualError(t, err, "No valid frame found between 1h0m0s and 0s")
+
+	_, err = Trim(openFixture(t), &buf, TrimOpts{From: time.Second, To: time.Millisecond})
+	assert.EqualError(t, err, "Time range ends at 1ms, before it starts at 1s")
+}
+
+func openFixture(t *testing.T) io.Reader {
+	return bytes.NewReader(readFixture(t))
+}
+
+// readFixtureMainFrames decodes a log, checks it's clean and returns the values of its main frames
+func readFixtureMainFrames(t *testing.T, r io.Reader) [][]int64 {
+	flightLog := NewFlightLogReader(FlightLogReaderOpts{})
+	frameChan, err := flightLog.LoadFile(context.Background(), r)
+	assert.NoError(t, err)
+
+	values := [][]int64{}
+	var lastFrame Frame
+	for frame := range frameChan {
+		assert.NoError(t, frame.Error())
+		assert.True(t, frame.Validity())
+		if frame.Type() == LogFrameIntra || frame.Type() == LogFrameInter {
+			values = append(values, frame.Values().([]int64))
+		}
+		lastFrame = frame
+	}
+	assert.Equal(t, byte(LogEventLogEnd), lastFrame.(*EventFrame).EventType())
+	return values
+}
+
+func TestParseFlightTime(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"01:05":     65 * time.Second,
+		"1:05":      65 * time.Second,
+		"01:05.250": 65*time.Second + 250*time.Millisecond,
+		"75:00":     75 * time.Minute,
+		"1:02:03":   time.Hour + 2*time.Minute + 3*time.Second,
+		"1m30s":     90 * time.Second,
+		"0":         0,
+	} {
+		d, err := ParseFlightTime(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, d, value)
+	}
+
+	for _, value := range []string{"", "abc", "01:", ":05", "01:60", "1:60:00", "1:2:3:4", "-1:05", "01:-5", "-5s", "65"} {
+		_, err := ParseFlightTime(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,39 @@
+package blackbox
+
+const (
+	// adcVref is the voltage reference of the flight controller's ADC, in 0.1V
+	adcVref = 33
+
+	// adcMax is the maximum value of the 12 bit ADC
+	adcMax = 4095
+)
+
+// VbatToVolts converts a raw battery voltage reading into volts
+func (f *LogDefinition) VbatToVolts(value int64) float64 {
+	switch f.Rules.Vbat {
+	case VbatDecivolts:
+		return float64(value) / 10
+	case VbatCentivolts:
+		return float64(value) / 100
+	}
+
+	// ADC is 12 bit (i.e. max 0xFFF), voltage reference is 3.3V, vbatscale is premultiplied by 100
+	return float64(value*adcVref*int64(f.Sysconfig.Vbatscale)) / adcMax / 100.0
+}
+
+// AmperageToAmps converts a raw current sensor reading into amperes
+func (f *LogDefinition) AmperageToAmps(value int64) float64 {
+	if f.Rules.Amperage == AmperageCentiamps {
+		return float64(value) / 100
+	}
+	return float64(value*adcVref*100/adcMax-int64(f.Sysconfig.CurrentMeterOffset)) * 10 / float64(f.Sysconfig.CurrentMeterScale)
+}
+
+// CellVoltageToVolts converts one of the vbatcellvoltage header values into
+// volts. Older firmwares write them in 0.1V, newer ones in 0.01V.
+func CellVoltageToVolts(value uint16) float64 {
+	if value < 100 {
+		return float64(value) / 10
+	}
+	return float64(value) / 100
+}
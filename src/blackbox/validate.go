@@ -0,0 +1,250 @@
+package blackbox
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+)
+
+// ValidationSeverity tells how bad a validation issue is
+type ValidationSeverity string
+
+// List of the validation severities
+const (
+	// SeverityError is for issues making the log, or part of it, unreadable
+	SeverityError ValidationSeverity = "error"
+
+	// SeverityWarning is for issues hinting at a problem with the recorder
+	SeverityWarning ValidationSeverity = "warning"
+
+	// SeverityInfo is for noteworthy but normal things
+	SeverityInfo ValidationSeverity = "info"
+)
+
+// ValidationCode identifies a kind of validation issue
+type ValidationCode string
+
+// List of the issues Validate can find
+const (
+	ValidationInvalidHeader      ValidationCode = "invalid-header"
+	ValidationFieldCountMismatch ValidationCode = "field-count-mismatch"
+	ValidationUnknownEncoding    ValidationCode = "unknown-encoding"
+	ValidationUnknownPredictor   ValidationCode = "unknown-predictor"
+	ValidationMissingField       ValidationCode = "missing-field"
+	ValidationCorruptedFrame     ValidationCode = "corrupted-frame"
+	ValidationDesyncFrames       ValidationCode = "desynchronized-frames"
+	ValidationIterationGap       ValidationCode = "iteration-gap"
+	ValidationTimeRollover       ValidationCode = "time-rollover"
+	ValidationMissingLogEnd      ValidationCode = "missing-log-end"
+)
+
+const (
+	// maxIssuesPerCode is the number of issues of a given kind after which they are only counted
+	maxIssuesPerCode = 100
+)
+
+// ValidationIssue is a problem found in a log. Offset is the position in bytes it was found at.
+type ValidationIssue struct {
+	Severity ValidationSeverity `json:"severity"`
+	Code     ValidationCode     `json:"code"`
+	Message  string             `json:"message"`
+	Offset   int64              `json:"offset"`
+}
+
+func (i ValidationIssue) String() string {
+	return fmt.Sprintf("%-8s %-22s %10d  %s", i.Severity, i.Code, i.Offset, i.Message)
+}
+
+// ValidationReport lists the issues found in a log
+type ValidationReport struct {
+	Issues        []ValidationIssue `json:"issues"`
+	TotalFrames   int               `json:"totalFrames"`
+	CorruptFrames int               `json:"corruptFrames"`
+	DesyncFrames  int               `json:"desyncFrames"`
+	IterationGaps int               `json:"iterationGaps"`
+	TimeRollovers int               `json:"timeRollovers"`
+	HasLogEnd     bool              `json:"hasLogEnd"`
+	issuesPerCode map[ValidationCode]int
+}
+
+// Count returns the number of issues of a given severity
+func (r ValidationReport) Count(severity ValidationSeverity) int {
+	count := 0
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// Valid returns false if errors were found, or warnings in strict mode
+func (r ValidationReport) Valid(strict bool) bool {
+	if strict && r.Count(SeverityWarning) > 0 {
+		return false
+	}
+	return r.Count(SeverityError) == 0
+}
+
+func (r *ValidationReport) add(severity ValidationSeverity, code ValidationCode, offset int64, format string, args ...interface{}) {
+	if r.issuesPerCode == nil {
+		r.issuesPerCode = map[ValidationCode]int{}
+	}
+	r.issuesPerCode[code]++
+	if r.issuesPerCode[code] > maxIssuesPerCode {
+		return
+	}
+	r.Issues = append(r.Issues, ValidationIssue{Severity: severity, Code: code, Offset: offset, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate decodes a single session log and reports the inconsistencies found in its headers and frames
+func Validate(r io.Reader) ValidationReport {
+	report := ValidationReport{Issues: []ValidationIssue{}}
+
+	dec := stream.NewDecoder(bufio.NewReaderSize(r, defaultBufferSize))
+	headerReader := NewHeaderReader(dec)
+	frameDef, err := headerReader.ProcessHeaders()
+	validateFieldCounts(&report, headerReader.RawHeaders(), err == nil)
+	if err != nil {
+		report.add(SeverityError, ValidationInvalidHeader, dec.Offset(), "%v", err)
+		return report
+	}
+	validateFieldDefinitions(&report, frameDef)
+
+	frameReader := NewFrameReader(dec, frameDef, nil)
+	iterationIdx := indexOfField(frameDef.FieldsI, FieldIteration)
+	lastIteration := int64(-1)
+	for {
+		rolloverAccumulator := frameReader.timeRolloverAccumulator
+		frame := frameReader.ReadNextFrame()
+		if frame.Error() == io.EOF && frame.Size() == 0 {
+			break
+		}
+		report.TotalFrames++
+
+		if frame.Error() == io.EOF {
+			report.CorruptFrames++
+			report.add(SeverityError, ValidationCorruptedFrame, int64(frame.Start()), "the last frame is truncated")
+			break
+		}
+		if frame.Error() != nil {
+			report.CorruptFrames++
+			report.add(SeverityError, ValidationCorruptedFrame, int64(frame.Start()), "%v", frame.Error())
+			continue
+		}
+
+		if frameReader.timeRolloverAccumulator != rolloverAccumulator {
+			report.TimeRollovers++
+			report.add(SeverityInfo, ValidationTimeRollover, int64(frame.Start()), "the 32-bit time rolled over")
+		}
+
+		switch frame.Type() {
+		case LogFrameEvent:
+			switch frame.(*EventFrame).EventType() {
+			case LogEventLoggingResume:
+				lastIteration = frame.Values().(map[string]interface{})["iteration"].(int64)
+			case LogEventLogEnd:
+				report.HasLogEnd = true
+			}
+
+		case LogFrameIntra, LogFrameInter:
+			if !frame.Validity() {
+				report.DesyncFrames++
+			}
+			if iterationIdx < 0 {
+				break
+			}
+			iteration := frame.Values().([]int64)[iterationIdx]
+
+			// Inter frames are only checked when valid, as their iteration is otherwise meaningless
+			if frame.Type() == LogFrameIntra && lastIteration != -1 && (iteration < lastIteration || iteration-lastIteration >= maximumIterationJumpBetweenFrames) {
+				report.IterationGaps++
+				report.add(SeverityWarning, ValidationIterationGap, int64(frame.Start()), "the iteration jumps from %d to %d", lastIteration, iteration)
+			}
+			if frame.Type() == LogFrameIntra || frame.Validity() {
+				lastIteration = iteration
+			}
+		}
+	}
+
+	if report.DesyncFrames > 0 {
+		report.add(SeverityWarning, ValidationDesyncFrames, dec.Offset(), "%d main frames couldn't be decoded because of a corruption or gap before them", report.DesyncFrames)
+	}
+	if !report.HasLogEnd {
+		report.add(SeverityWarning, ValidationMissingLogEnd, dec.Offset(), "the log doesn't end with a LogEnd event, the recording was probably interrupted")
+	}
+	return report
+}
+
+// validateFieldCounts verifies every field definition header describes as many fields as were named.
+// Missing headers are only reported once all the headers have been read.
+func validateFieldCounts(report *ValidationReport, rawHeaders []byte, complete bool) {
+	re := regexp.MustCompile(headerRegExp)
+	counts := map[HeaderName]int{}
+	for _, line := range bytes.Split(rawHeaders, []byte("\n")) {
+		match := re.FindStringSubmatch(string(line))
+		if match != nil && strings.HasPrefix(match[1], "Field ") {
+			counts[HeaderName(match[1])] = len(strings.Split(match[2], ","))
+		}
+	}
+
+	checks := []struct {
+		reference HeaderName
+		headers   []HeaderName
+	}{
+		{HeaderIName, []HeaderName{HeaderISigned, HeaderIPredictor, HeaderIEncoding, HeaderPPredictor, HeaderPEncoding}},
+		{HeaderSName, []HeaderName{HeaderSSigned, HeaderSPredictor, HeaderSEncoding}},
+		{HeaderGName, []HeaderName{HeaderGPredictor, HeaderGEncoding}},
+		{HeaderHName, []HeaderName{HeaderHPredictor, HeaderHEncoding}},
+	}
+	for _, check := range checks {
+		expected, ok := counts[check.reference]
+		if !ok {
+			continue
+		}
+		for _, header := range check.headers {
+			count, ok := counts[header]
+			if !ok {
+				if complete {
+					report.add(SeverityError, ValidationFieldCountMismatch, 0, "'%s' is missing", header)
+				}
+			} else if count != expected {
+				report.add(SeverityError, ValidationFieldCountMismatch, 0, "'%s' has %d values but '%s' has %d", header, count, check.reference, expected)
+			}
+		}
+	}
+}
+
+// validateFieldDefinitions verifies the reader knows how to decode every field
+func validateFieldDefinitions(report *ValidationReport, frameDef LogDefinition) {
+	definitions := []struct {
+		frameType LogFrameType
+		fields    []FieldDefinition
+	}{
+		{LogFrameIntra, frameDef.FieldsI},
+		{LogFrameInter, frameDef.FieldsP},
+		{LogFrameSlow, frameDef.FieldsS},
+		{LogFrameGPS, frameDef.FieldsG},
+		{LogFrameGPSHome, frameDef.FieldsH},
+	}
+	for _, name := range []FieldName{FieldIteration, FieldTime} {
+		if indexOfField(frameDef.FieldsI, name) < 0 {
+			report.add(SeverityError, ValidationMissingField, 0, "main frames don't have the field '%s' frames are ordered by", name)
+		}
+	}
+	for _, definition := range definitions {
+		for _, field := range definition.fields {
+			if !frameDef.Dialect.supportsEncoding(field.Encoding) {
+				report.add(SeverityError, ValidationUnknownEncoding, 0, "field '%s' of %s frames has the encoding %d unknown to %s logs", field.Name, string(definition.frameType), field.Encoding, frameDef.Dialect)
+			}
+			if !frameDef.Dialect.supportsPredictor(field.Predictor) {
+				report.add(SeverityError, ValidationUnknownPredictor, 0, "field '%s' of %s frames has the predictor %d unknown to %s logs", field.Name, string(definition.frameType), field.Predictor, frameDef.Dialect)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package blackbox
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCleanLog(t *testing.T) {
+	report := Validate(openFixture(t))
+	assert.Empty(t, report.Issues)
+	assert.Equal(t, 10, report.TotalFrames)
+	assert.True(t, report.HasLogEnd)
+	assert.True(t, report.Valid(true))
+}
+
+func TestValidateMissingLogEnd(t *testing.T) {
+	content := readFixture(t)
+	report := Validate(bytes.NewReader(content[:len(content)-14]))
+	assert.Equal(t, []ValidationIssue{
+		ValidationIssue{Severity: SeverityWarning, Code: ValidationMissingLogEnd, Offset: 1759, Message: "the log doesn't end with a LogEnd event, the recording was probably interrupted"},
+	}, report.Issues)
+	assert.True(t, report.Valid(false))
+	assert.False(t, report.Valid(true))
+}
+
+func TestValidateTruncatedLog(t *testing.T) {
+	content := readFixture(t)
+	report := Validate(bytes.NewReader(content[:len(content)-20]))
+	assert.Equal(t, 1, report.CorruptFrames)
+	assert.Equal(t, ValidationCorruptedFrame, report.Issues[0].Code)
+	assert.Equal(t, int64(fixtureFrameP52996Offset), report.Issues[0].Offset)
+	assert.Equal(t, "the last frame is truncated", report.Issues[0].Message)
+	assert.False(t, report.Valid(false))
+}
+
+func TestValidateFieldCountMismatch(t *testing.T) {
+	content := bytes.Replace(readFixture(t), []byte("H Field P encoding:9,0,0,"), []byte("H Field P encoding:9,0,"), 1)
+	report := Validate(bytes.NewReader(content))
+	assert.Equal(t, ValidationIssue{Severity: SeverityError, Code: ValidationFieldCountMismatch, Message: "'Field P encoding' has 37 values but 'Field I name' has 38"}, report.Issues[0])
+	assert.False(t, report.Valid(false))
+}
+
+func TestValidateTooManyFieldValues(t *testing.T) {
+	content := bytes.Replace(readFixture(t), []byte("H Field S signed:0,0,0,0,0"), []byte("H Field S signed:0,0,0,0,0,0"), 1)
+	report := Validate(bytes.NewReader(content))
+	assert.Equal(t, []ValidationIssue{
+		ValidationIssue{Severity: SeverityError, Code: ValidationFieldCountMismatch, Message: "'Field S signed' has 6 values but 'Field S name' has 5"},
+		ValidationIssue{Severity: SeverityError, Code: ValidationInvalidHeader, Offset: 1086, Message: "Header 'Field S signed' has 6 values but only 5 fields are defined"},
+	}, report.Issues)
+}
+
+func TestValidateUnknownEncodingAndPredictor(t *testing.T) {
+	content := bytes.Replace(readFixture(t), []byte("H Field S encoding:1,1,7,7,7"), []byte("H Field S encoding:1,1,7,7,2"), 1)
+	content = bytes.Replace(content, []byte("H Field S predictor:0,0,0,0,0"), []byte("H Field S predictor:0,0,0,0,12"), 1)
+	report := Validate(bytes.NewReader(content))
+	assert.Equal(t, []ValidationIssue{
+		ValidationIssue{Severity: SeverityError, Code: ValidationUnknownEncoding, Message: "field 'rxFlightChannelsValid' of S frames has the encoding 2 unknown to Cleanflight logs"},
+		ValidationIssue{Severity: SeverityError, Code: ValidationUnknownPredictor, Message: "field 'rxFlightChannelsValid' of S frames has the predictor 12 unknown to Cleanflight logs"},
+	}, report.Issues[:2])
+}
+
+func TestValidateMissingIteration(t *testing.T) {
+	content := bytes.Replace(readFixture(t), []byte("H Field I name:loopIteration,"), []byte("H Field I name:index,"), 1)
+	report := Validate(bytes.NewReader(content))
+	assert.Equal(t, ValidationIssue{Severity: SeverityError, Code: ValidationMissingField, Message: "main frames don't have the field 'loopIteration' frames are ordered by"}, report.Issues[0])
+	assert.False(t, report.Valid(false))
+}
+
+func TestValidateIterationGap(t *testing.T) {
+	content := readFixture(t)
+	original := readFixtureMainFrames(t, bytes.NewReader(content))
+
+	headerReader := NewHeaderReader(stream.NewDecoder(bytes.NewReader(content)))
+	frameDef, err := headerReader.ProcessHeaders()
+	assert.NoError(t, err)
+
+	jumped := append([]int64{}, original[3]...)
+	jumped[0] += maximumIterationJumpBetweenFrames
+
+	var tail bytes.Buffer
+	logWriter := NewLogWriter(stream.NewEncoder(&tail), frameDef)
+	assert.NoError(t, logWriter.WriteIntraFrame(jumped))
+	assert.NoError(t, logWriter.WriteLogEnd())
+
+	report := Validate(bytes.NewReader(concatBytes(content[:fixtureFrameP52995Offset], tail.Bytes())))
+	assert.Equal(t, []ValidationIssue{
+		ValidationIssue{Severity: SeverityWarning, Code: ValidationIterationGap, Offset: fixtureFrameP52995Offset, Message: "the iteration jumps from 52994 to 57995"},
+		ValidationIssue{Severity: SeverityWarning, Code: ValidationDesyncFrames, Offset: 1768, Message: "1 main frames couldn't be decoded because of a corruption or gap before them"},
+	}, report.Issues)
+	assert.Equal(t, 1, report.IterationGaps)
+	assert.Equal(t, 1, report.DesyncFrames)
+}
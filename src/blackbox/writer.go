@@ -0,0 +1,244 @@
+package blackbox
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+	"github.com/pkg/errors"
+)
+
+// logEndMessage is the payload of the event closing a log
+var logEndMessage = []byte("End of log\x00\n")
+
+// LogWriter writes flight logs in the binary format of the firmware.
+// Main frames are written as inter frames whenever the reader will be able to
+// predict them from the previous frames, and as intra frames otherwise.
+type LogWriter struct {
+	enc            *stream.Encoder
+	frameDef       LogDefinition
+	previousFrame1 *MainFrame
+	previousFrame2 *MainFrame
+
+	// iterationIdx and timeIdx are the positions of the loopIteration and time fields
+	// of main frames, or -1 if the log doesn't have them
+	iterationIdx int
+	timeIdx      int
+
+	// gpsHome and lastMainFrameTime are what GPS frames are predicted from
+	gpsHome           []int64
+	lastMainFrameTime int64
+}
+
+// NewLogWriter returns a new LogWriter for logs with the given definition
+func NewLogWriter(enc *stream.Encoder, frameDef LogDefinition) *LogWriter {
+	return &LogWriter{
+		enc:               enc,
+		frameDef:          frameDef,
+		iterationIdx:      indexOfField(frameDef.FieldsI, FieldIteration),
+		timeIdx:           indexOfField(frameDef.FieldsI, FieldTime),
+		lastMainFrameTime: -1,
+	}
+}
+
+// WriteHeaders writes the header lines of the log, as returned by HeaderReader.RawHeaders()
+func (w *LogWriter) WriteHeaders(headers []byte) error {
+	return w.enc.WriteBytes(headers)
+}
+
+// WriteFrame writes a frame previously read by a FrameReader
+func (w *LogWriter) WriteFrame(frame Frame) error {
+	switch frame.Type() {
+	case LogFrameEvent:
+		return w.WriteEvent(frame.(*EventFrame).EventType(), frame.Values().(map[string]interface{}))
+	case LogFrameSlow:
+		return w.WriteSlowFrame(frame.Values().([]int64))
+	case LogFrameIntra:
+		return w.WriteIntraFrame(frame.Values().([]int64))
+	case LogFrameInter:
+		return w.WriteMainFrame(frame.Values().([]int64))
+	case LogFrameGPS:
+		return w.WriteGPSFrame(frame.Values().([]int64))
+	case LogFrameGPSHome:
+		return w.WriteGPSHomeFrame(frame.Values().([]int64))
+	default:
+		return errors.Errorf("Frame type '%s' can't be written", string(frame.Type()))
+	}
+}
+
+// WriteMainFrame writes the values of a main frame as an inter frame if possible,
+// or as an intra frame otherwise
+func (w *LogWriter) WriteMainFrame(values []int64) error {
+	if !w.canPredict(values) {
+		return w.WriteIntraFrame(values)
+	}
+
+	err := w.enc.WriteByte(LogFrameInter)
+	if err != nil {
+		return err
+	}
+	err = encodeStateFrame(w.frameDef, w.frameDef.FieldsP, values, w.mainFrameHistory(), w.enc)
+	if err != nil {
+		return err
+	}
+
+	frame := NewMainFrame(LogFrameInter, values, 0, 0, nil)
+	w.previousFrame2 = w.previousFrame1
+	w.previousFrame1 = frame
+	w.setLastMainFrameTime(values)
+	return nil
+}
+
+// WriteIntraFrame writes the values of a main frame as an intra frame
+func (w *LogWriter) WriteIntraFrame(values []int64) error {
+	err := w.enc.WriteByte(LogFrameIntra)
+	if err != nil {
+		return err
+	}
+	err = encodeStateFrame(w.frameDef, w.frameDef.FieldsI, values, w.mainFrameHistory(), w.enc)
+	if err != nil {
+		return err
+	}
+
+	frame := NewMainFrame(LogFrameIntra, values, 0, 0, nil)
+	w.previousFrame2 = frame
+	w.previousFrame1 = frame
+	w.setLastMainFrameTime(values)
+	return nil
+}
+
+// setLastMainFrameTime remembers the time of the last main frame, which the time of GPS
+// frames is predicted from
+func (w *LogWriter) setLastMainFrameTime(values []int64) {
+	if w.timeIdx >= 0 && w.timeIdx < len(values) {
+		w.lastMainFrameTime = values[w.timeIdx]
+	}
+}
+
+// WriteSlowFrame writes the values of a slow frame
+func (w *LogWriter) WriteSlowFrame(values []int64) error {
+	err := w.enc.WriteByte(LogFrameSlow)
+	if err != nil {
+		return err
+	}
+	return encodeStateFrame(w.frameDef, w.frameDef.FieldsS, values, FrameHistory{}, w.enc)
+}
+
+// WriteGPSFrame writes the values of a GPS frame. It is predicted from the last GPS home
+// frame and main frame written.
+func (w *LogWriter) WriteGPSFrame(values []int64) error {
+	err := w.enc.WriteByte(LogFrameGPS)
+	if err != nil {
+		return err
+	}
+	history := FrameHistory{GPSHome: w.gpsHome, LastMainFrameTime: w.lastMainFrameTime}
+	return encodeStateFrame(w.frameDef, w.frameDef.FieldsG, values, history, w.enc)
+}
+
+// WriteGPSHomeFrame writes the values of a GPS home frame
+func (w *LogWriter) WriteGPSHomeFrame(values []int64) error {
+	err := w.enc.WriteByte(LogFrameGPSHome)
+	if err != nil {
+		return err
+	}
+	err = encodeStateFrame(w.frameDef, w.frameDef.FieldsH, values, FrameHistory{}, w.enc)
+	if err != nil {
+		return err
+	}
+	w.gpsHome = values
+	return nil
+}
+
+// WriteLoggingResume writes an event telling the reader the log continues from the given
+// iteration and time. The next main frame is written as an intra frame.
+func (w *LogWriter) WriteLoggingResume(iteration, currentTime int64) error {
+	return w.WriteEvent(LogEventLoggingResume, eventValues{
+		"iteration":   iteration,
+		"currentTime": currentTime,
+	})
+}
+
+// WriteLogEnd writes the event closing a log. Nothing should be written afterwards.
+func (w *LogWriter) WriteLogEnd() error {
+	return w.WriteEvent(LogEventLogEnd, nil)
+}
+
+// WriteEvent writes an event frame with the values as returned by the FrameReader
+func (w *LogWriter) WriteEvent(eventType LogEventType, values map[string]interface{}) error {
+	err := w.enc.WriteBytes([]byte{LogFrameEvent, eventType})
+	if err != nil {
+		return err
+	}
+
+	switch eventType {
+	case LogEventSyncBeep:
+		return w.enc.WriteUnsignedVB(values["beepTime"].(uint32))
+
+	case LogEventLoggingResume:
+		err = w.enc.WriteUnsignedVB(uint32(values["iteration"].(int64)))
+		if err != nil {
+			return err
+		}
+
+		// Whatever comes next can't be predicted from what was written before
+		w.previousFrame1 = nil
+		w.previousFrame2 = nil
+		w.lastMainFrameTime = values["currentTime"].(int64)
+		return w.enc.WriteUnsignedVB(uint32(values["currentTime"].(int64)))
+
+	case LogEventInflightAdjustment:
+		function := values["function"].(byte)
+		if value, ok := values["value"].(float32); ok {
+			err = w.enc.WriteByte(function | inflightAdjustmentFloatFlag)
+			if err != nil {
+				return err
+			}
+			raw := make([]byte, 4)
+			binary.LittleEndian.PutUint32(raw, math.Float32bits(value))
+			return w.enc.WriteBytes(raw)
+		}
+		err = w.enc.WriteByte(function)
+		if err != nil {
+			return err
+		}
+		return w.enc.WriteSignedVB(values["value"].(int32))
+
+	case LogEventDisarm:
+		return w.enc.WriteUnsignedVB(values["reason"].(uint32))
+
+	case LogEventFlightMode:
+		err = w.enc.WriteUnsignedVB(values["flags"].(uint32))
+		if err != nil {
+			return err
+		}
+		return w.enc.WriteUnsignedVB(values["lastFlags"].(uint32))
+
+	case LogEventLogEnd:
+		return w.enc.WriteBytes(logEndMessage)
+
+	default:
+		return errors.Errorf("Event type %d can't be written", eventType)
+	}
+}
+
+// Offset returns the number of bytes written so far
+func (w *LogWriter) Offset() int64 {
+	return w.enc.Offset()
+}
+
+// mainFrameHistory returns the previous main frames, to predict the next one from
+func (w *LogWriter) mainFrameHistory() FrameHistory {
+	return FrameHistory{Previous: w.previousFrame1, Previous2: w.previousFrame2}
+}
+
+// canPredict returns true if the reader will be able to rebuild the values of an inter frame:
+// there must be a previous frame to predict from, and the iteration of the frame must be the
+// one expected after it
+func (w *LogWriter) canPredict(values []int64) bool {
+	if w.previousFrame1 == nil || w.iterationIdx < 0 || w.iterationIdx >= len(values) {
+		return false
+	}
+
+	previousIteration := w.previousFrame1.values[w.iterationIdx]
+	return values[w.iterationIdx] == previousIteration+w.frameDef.countSkippedFramesAfter(previousIteration)+1
+}
@@ -0,0 +1,107 @@
+package blackbox
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteStream(t *testing.T) {
+	var buf bytes.Buffer
+	logWriter := NewLogWriter(stream.NewEncoder(&buf), dummyFrameDefinition())
+
+	err := logWriter.WriteMainFrame(decodedPredictedFrameI)
+	assert.NoError(t, err)
+	assert.Equal(t, encodedFrameI, buf.Bytes())
+
+	for idx, values := range decodedPredictedFramesP {
+		t.Run(fmt.Sprintf("for frame P%v", idx+1), func(t *testing.T) {
+			buf.Reset()
+			err := logWriter.WriteMainFrame(values)
+			assert.NoError(t, err)
+			assert.Equal(t, encodedFramesP[idx], buf.Bytes())
+		})
+	}
+}
+
+func TestWriteFrameAfterSkippedIterations(t *testing.T) {
+	var buf bytes.Buffer
+	logWriter := NewLogWriter(stream.NewEncoder(&buf), dummyFrameDefinition())
+
+	assert.NoError(t, logWriter.WriteMainFrame(decodedPredictedFrameI))
+
+	// The reader couldn't guess the iteration of this frame, it has to be an intra frame
+	buf.Reset()
+	assert.NoError(t, logWriter.WriteMainFrame(decodedPredictedFramesP[1]))
+	assert.Equal(t, byte(LogFrameIntra), buf.Bytes()[0])
+}
+
+func TestWriteFrameWithoutIteration(t *testing.T) {
+	frameDef := dummyFrameDefinition()
+	frameDef.FieldsI = append([]FieldDefinition{}, frameDef.FieldsI...)
+	frameDef.FieldsI[0].Name = "index"
+
+	var buf bytes.Buffer
+	logWriter := NewLogWriter(stream.NewEncoder(&buf), frameDef)
+	assert.NoError(t, logWriter.WriteMainFrame(decodedPredictedFrameI))
+
+	// Without the iteration, the writer can't tell if the reader will predict the frame
+	buf.Reset()
+	assert.NoError(t, logWriter.WriteMainFrame(decodedPredictedFramesP[0]))
+	assert.Equal(t, byte(LogFrameIntra), buf.Bytes()[0])
+}
+
+func TestWriteEvents(t *testing.T) {
+	for idx, encodedFrame := range encodedFramesE[:2] {
+		t.Run(fmt.Sprintf("for event %v", idx), func(t *testing.T) {
+			frameReader := NewFrameReader(stream.NewDecoder(bytes.NewReader(encodedFrame)), dummyFrameDefinition(), nil)
+			frame := frameReader.ReadNextFrame()
+			assert.NoError(t, frame.Error())
+
+			var buf bytes.Buffer
+			logWriter := NewLogWriter(stream.NewEncoder(&buf), dummyFrameDefinition())
+			assert.NoError(t, logWriter.WriteFrame(frame))
+
+			frameReader = NewFrameReader(stream.NewDecoder(&buf), dummyFrameDefinition(), nil)
+			rewrittenFrame := frameReader.ReadNextFrame()
+			assert.NoError(t, rewrittenFrame.Error())
+			assert.Equal(t, frame.Values(), rewrittenFrame.Values())
+		})
+	}
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	logWriter := NewLogWriter(stream.NewEncoder(&buf), dummyFrameDefinition())
+
+	assert.NoError(t, logWriter.WriteMainFrame(decodedPredictedFrameI))
+	for _, values := range decodedPredictedFramesP {
+		assert.NoError(t, logWriter.WriteMainFrame(values))
+	}
+	assert.NoError(t, logWriter.WriteLoggingResume(decodedPredictedFrameI[0], decodedPredictedFrameI[1]))
+	assert.NoError(t, logWriter.WriteMainFrame(decodedPredictedFrameI))
+	assert.NoError(t, logWriter.WriteLogEnd())
+
+	frameReader := NewFrameReader(stream.NewDecoder(&buf), dummyFrameDefinition(), nil)
+	expectedValues := append([][]int64{decodedPredictedFrameI}, decodedPredictedFramesP...)
+	for _, values := range expectedValues {
+		frame := frameReader.ReadNextFrame()
+		assert.NoError(t, frame.Error())
+		assert.True(t, frame.Validity())
+		assert.Equal(t, values, frame.Values())
+	}
+
+	frame := frameReader.ReadNextFrame()
+	assert.Equal(t, byte(LogEventLoggingResume), frame.(*EventFrame).EventType())
+
+	frame = frameReader.ReadNextFrame()
+	assert.Equal(t, byte(LogFrameIntra), frame.Type())
+	assert.True(t, frame.Validity())
+
+	frame = frameReader.ReadNextFrame()
+	assert.NoError(t, frame.Error())
+	assert.Equal(t, byte(LogEventLogEnd), frame.(*EventFrame).EventType())
+}
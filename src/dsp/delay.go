@@ -0,0 +1,43 @@
+package dsp
+
+import (
+	"time"
+)
+
+// Delay estimates how late a signal follows another one sampled at the same rate, as the
+// lag between 0 and maxDelay maximizing their cross-correlation. It's refined between
+// samples, and 0 if the signals are too short.
+func Delay(input, output []float64, sampleRate float64, maxDelay time.Duration) time.Duration {
+	maxLag := int(maxDelay.Seconds() * sampleRate)
+	length := len(input)
+	if len(output) < length {
+		length = len(output)
+	}
+	if length <= maxLag+2 {
+		return 0
+	}
+
+	inputMean := meanOf(input[:length])
+	outputMean := meanOf(output[:length])
+	correlations := make([]float64, maxLag+1)
+	for lag := range correlations {
+		total := 0.0
+		for i := 0; i+lag < length; i++ {
+			total += (input[i] - inputMean) * (output[i+lag] - outputMean)
+		}
+		correlations[lag] = total / float64(length-lag)
+	}
+
+	best := 0
+	for lag, correlation := range correlations {
+		if correlation > correlations[best] {
+			best = lag
+		}
+	}
+
+	lag := float64(best)
+	if best > 0 && best < maxLag {
+		lag += interpolatePeak(correlations[best-1], correlations[best], correlations[best+1])
+	}
+	return time.Duration(lag / sampleRate * float64(time.Second))
+}
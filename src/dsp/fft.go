@@ -0,0 +1,87 @@
+// Package dsp processes the signals of flight logs, like gyro traces, once they are
+// sampled at a uniform rate.
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// FFT returns the discrete Fourier transform of x. Its length must be a power of two.
+func FFT(x []complex128) []complex128 {
+	return fft(x, false)
+}
+
+// InverseFFT returns the inverse discrete Fourier transform of x. Its length must be a
+// power of two.
+func InverseFFT(x []complex128) []complex128 {
+	result := fft(x, true)
+	for i := range result {
+		result[i] /= complex(float64(len(result)), 0)
+	}
+	return result
+}
+
+// fft is an iterative radix-2 Cooley-Tukey transform
+func fft(x []complex128, inverse bool) []complex128 {
+	n := len(x)
+	if n&(n-1) != 0 {
+		panic("dsp: FFT length is not a power of two")
+	}
+
+	result := make([]complex128, n)
+	copy(result, x)
+
+	// Bit reversal permutation
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			result[i], result[j] = result[j], result[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Rect(1, sign*2*math.Pi/float64(size))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even := result[start+k]
+				odd := result[start+k+size/2] * w
+				result[start+k] = even + odd
+				result[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+	return result
+}
+
+// NextPowerOfTwo returns the smallest power of two greater or equal to n
+func NextPowerOfTwo(n int) int {
+	power := 1
+	for power < n {
+		power <<= 1
+	}
+	return power
+}
+
+// HannWindow returns the coefficients of a Hann window of a given length
+func HannWindow(length int) []float64 {
+	window := make([]float64, length)
+	if length == 1 {
+		window[0] = 1
+		return window
+	}
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(length-1))
+	}
+	return window
+}
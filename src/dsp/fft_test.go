@@ -0,0 +1,52 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFFT(t *testing.T) {
+	x := []complex128{1, 2, 0, -1, 3, 0.5, -2, 4}
+	result := FFT(x)
+
+	// Naive discrete Fourier transform
+	for k := range x {
+		expected := complex(0, 0)
+		for n := range x {
+			expected += x[n] * cmplx.Rect(1, -2*math.Pi*float64(k*n)/float64(len(x)))
+		}
+		assert.InDelta(t, real(expected), real(result[k]), 1e-9)
+		assert.InDelta(t, imag(expected), imag(result[k]), 1e-9)
+	}
+
+	inverse := InverseFFT(result)
+	for i := range x {
+		assert.InDelta(t, real(x[i]), real(inverse[i]), 1e-9)
+		assert.InDelta(t, 0, imag(inverse[i]), 1e-9)
+	}
+}
+
+func TestFFTInvalidLength(t *testing.T) {
+	assert.Panics(t, func() {
+		FFT(make([]complex128, 6))
+	})
+}
+
+func TestNextPowerOfTwo(t *testing.T) {
+	assert.Equal(t, 1, NextPowerOfTwo(0))
+	assert.Equal(t, 1, NextPowerOfTwo(1))
+	assert.Equal(t, 1024, NextPowerOfTwo(1000))
+	assert.Equal(t, 1024, NextPowerOfTwo(1024))
+}
+
+func TestUniform(t *testing.T) {
+	// The frame at 3000us is missing
+	values := Uniform([]int64{1000, 2000, 4000, 5000}, []float64{10, 20, 40, 30}, 1000)
+	assert.Equal(t, []float64{10, 20, 30, 40, 30}, values)
+
+	assert.Equal(t, []float64{10, 15, 20}, Uniform([]int64{0, 1000}, []float64{10, 20}, 2000))
+	assert.Nil(t, Uniform(nil, nil, 1000))
+}
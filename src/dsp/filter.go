@@ -0,0 +1,120 @@
+package dsp
+
+import (
+	"math"
+)
+
+// Filter processes a signal one sample at a time, like the firmware of a flight
+// controller does
+type Filter interface {
+	Apply(input float64) float64
+}
+
+// PTNFilter is a chain of identical first order lowpass filters, as Betaflight calls
+// PT1, PT2 and PT3 filters
+type PTNFilter struct {
+	k     float64
+	state []float64
+}
+
+// NewPTNFilter returns a lowpass filter of a given order. Like in Betaflight, the cutoff
+// of each stage is raised for the whole filter to attenuate by 3dB at the cutoff.
+func NewPTNFilter(order int, cutoff, sampleRate float64) *PTNFilter {
+	f := &PTNFilter{state: make([]float64, order)}
+	f.SetCutoff(cutoff, sampleRate)
+	return f
+}
+
+// SetCutoff changes the cutoff of the filter, keeping its state
+func (f *PTNFilter) SetCutoff(cutoff, sampleRate float64) {
+	order := float64(len(f.state))
+	correction := 1 / math.Sqrt(math.Pow(2, 1/order)-1)
+	rc := 1 / (2 * math.Pi * correction * cutoff)
+	dt := 1 / sampleRate
+	f.k = dt / (rc + dt)
+}
+
+// Apply filters the next sample
+func (f *PTNFilter) Apply(input float64) float64 {
+	for i := range f.state {
+		f.state[i] += f.k * (input - f.state[i])
+		input = f.state[i]
+	}
+	return input
+}
+
+// BiquadFilter is a second order filter, used by Betaflight as lowpass and notch
+type BiquadFilter struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+// NewBiquadLowpassFilter returns a Butterworth lowpass filter
+func NewBiquadLowpassFilter(cutoff, sampleRate float64) *BiquadFilter {
+	f := &BiquadFilter{}
+	f.SetCutoff(cutoff, sampleRate)
+	return f
+}
+
+// SetCutoff changes the cutoff of a lowpass filter, keeping its state
+func (f *BiquadFilter) SetCutoff(cutoff, sampleRate float64) {
+	omega := 2 * math.Pi * cutoff / sampleRate
+	alpha := math.Sin(omega) / (2 / math.Sqrt2)
+	cos := math.Cos(omega)
+	f.setCoefficients((1-cos)/2, 1-cos, (1-cos)/2, 1+alpha, -2*cos, 1-alpha)
+}
+
+// NewBiquadNotchFilter returns a notch filter centered on a frequency, with a quality
+// factor given by NotchQ
+func NewBiquadNotchFilter(center, q, sampleRate float64) *BiquadFilter {
+	f := &BiquadFilter{}
+	f.SetNotch(center, q, sampleRate)
+	return f
+}
+
+// SetNotch moves the notch of a filter, keeping its state like dynamic notches do
+func (f *BiquadFilter) SetNotch(center, q, sampleRate float64) {
+	omega := 2 * math.Pi * center / sampleRate
+	alpha := math.Sin(omega) / (2 * q)
+	cos := math.Cos(omega)
+	f.setCoefficients(1, -2*cos, 1, 1+alpha, -2*cos, 1-alpha)
+}
+
+func (f *BiquadFilter) setCoefficients(b0, b1, b2, a0, a1, a2 float64) {
+	f.b0, f.b1, f.b2 = b0/a0, b1/a0, b2/a0
+	f.a1, f.a2 = a1/a0, a2/a0
+}
+
+// Apply filters the next sample
+func (f *BiquadFilter) Apply(input float64) float64 {
+	output := f.b0*input + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, input
+	f.y2, f.y1 = f.y1, output
+	return output
+}
+
+// NotchQ returns the quality factor of a notch filter attenuating by 3dB between its
+// cutoff and its center
+func NotchQ(center, cutoff float64) float64 {
+	return center * cutoff / (center*center - cutoff*cutoff)
+}
+
+// FilterChain applies filters one after the other
+type FilterChain []Filter
+
+// Apply filters the next sample
+func (c FilterChain) Apply(input float64) float64 {
+	for _, filter := range c {
+		input = filter.Apply(input)
+	}
+	return input
+}
+
+// ApplyFilter returns a signal filtered from its start
+func ApplyFilter(filter Filter, samples []float64) []float64 {
+	output := make([]float64, len(samples))
+	for i, v := range samples {
+		output[i] = filter.Apply(v)
+	}
+	return output
+}
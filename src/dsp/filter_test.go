@@ -0,0 +1,131 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPTNFilterCutoff(t *testing.T) {
+	for order := 1; order <= 3; order++ {
+		assert.InDelta(t, 1, gain(NewPTNFilter(order, 100, 8000), 2, 8000), 0.01, "PT%d", order)
+		assert.InDelta(t, 1/math.Sqrt2, gain(NewPTNFilter(order, 100, 8000), 100, 8000), 0.04, "PT%d", order)
+	}
+	assert.True(t, gain(NewPTNFilter(3, 100, 8000), 1000, 8000) < gain(NewPTNFilter(1, 100, 8000), 1000, 8000))
+}
+
+func TestBiquadLowpassFilter(t *testing.T) {
+	assert.InDelta(t, 1, gain(NewBiquadLowpassFilter(100, 8000), 2, 8000), 0.01)
+	assert.InDelta(t, 1/math.Sqrt2, gain(NewBiquadLowpassFilter(100, 8000), 100, 8000), 0.01)
+	assert.InDelta(t, 0.01, gain(NewBiquadLowpassFilter(100, 8000), 1000, 8000), 0.005)
+}
+
+func TestBiquadNotchFilter(t *testing.T) {
+	q := NotchQ(260, 160)
+	assert.InDelta(t, 0.99, q, 0.001)
+
+	assert.InDelta(t, 0, gain(NewBiquadNotchFilter(260, q, 8000), 260, 8000), 0.01)
+	assert.InDelta(t, 1/math.Sqrt2, gain(NewBiquadNotchFilter(260, q, 8000), 160, 8000), 0.02)
+	assert.InDelta(t, 1, gain(NewBiquadNotchFilter(260, q, 8000), 10, 8000), 0.01)
+}
+
+func TestDynamicLowpassCutoff(t *testing.T) {
+	settings := LowpassSettings{DynamicMin: 200, DynamicMax: 500}
+	assert.Equal(t, 200.0, dynamicLowpassCutoff(settings, 0))
+	assert.Equal(t, 200.0, dynamicLowpassCutoff(settings, 0.2))
+	assert.InDelta(t, 500, dynamicLowpassCutoff(settings, 1), 0.001)
+}
+
+func TestGyroFilterChainDynamicNotch(t *testing.T) {
+	settings := GyroFilterSettings{DynamicNotch: DynamicNotchSettings{Count: 1, Q: 3, MinHz: 150, MaxHz: 600}}
+	chain := NewGyroFilterChain(settings, 4000)
+
+	// A 30Hz movement with a 320Hz vibration
+	output := make([]float64, 8000)
+	for i := range output {
+		t := float64(i) / 4000
+		output[i] = chain.Apply(GyroSample{Gyro: 100*math.Sin(2*math.Pi*30*t) + 20*math.Sin(2*math.Pi*320*t)})
+	}
+
+	spectrum, err := PowerSpectrum(output[4000:], 4000, 512)
+	assert.NoError(t, err)
+	assert.True(t, math.Sqrt(spectrum.BandPower(300, 340)) < 2)
+	assert.InDelta(t, 100/math.Sqrt2, math.Sqrt(spectrum.BandPower(20, 40)), 5)
+}
+
+func TestGyroFilterChainRPMFilter(t *testing.T) {
+	settings := GyroFilterSettings{RPMFilter: RPMFilterSettings{Harmonics: 3, Q: 5, MinHz: 100}}
+	chain := NewGyroFilterChain(settings, 4000)
+
+	// The third harmonic of a motor spinning at 150Hz
+	output := make([]float64, 8000)
+	for i := range output {
+		t := float64(i) / 4000
+		output[i] = chain.Apply(GyroSample{Gyro: 20 * math.Sin(2*math.Pi*450*t), MotorFrequencies: []float64{150, 200}})
+	}
+	assert.True(t, rms(output[4000:]) < 0.5)
+}
+
+func TestGyroFilterChainOrder(t *testing.T) {
+	settings := GyroFilterSettings{
+		Lowpass: LowpassSettings{DynamicMin: 100, DynamicMax: 300},
+		Notch1:  NotchSettings{Center: 200, Cutoff: 150},
+	}
+	chain := NewGyroFilterChain(settings, 4000)
+
+	// The lowpass follows the throttle, so it only gives the same result before the notch
+	lowpass := newLowpassFilter(settings.Lowpass, 4000)
+	notch := NewBiquadNotchFilter(200, NotchQ(200, 150), 4000)
+	notchFirst := NewBiquadNotchFilter(200, NotchQ(200, 150), 4000)
+	lowpassLast := newLowpassFilter(settings.Lowpass, 4000)
+
+	var expected, actual, reversed []float64
+	for i := 0; i < 400; i++ {
+		sample := GyroSample{Gyro: 100 * math.Sin(2*math.Pi*float64(i)/40), Throttle: float64(i%100) / 100}
+		actual = append(actual, chain.Apply(sample))
+
+		cutoff := dynamicLowpassCutoff(settings.Lowpass, sample.Throttle)
+		lowpass.SetCutoff(cutoff, 4000)
+		expected = append(expected, notch.Apply(lowpass.Apply(sample.Gyro)))
+		lowpassLast.SetCutoff(cutoff, 4000)
+		reversed = append(reversed, lowpassLast.Apply(notchFirst.Apply(sample.Gyro)))
+	}
+	assert.Equal(t, expected, actual)
+	assert.NotEqual(t, reversed, actual)
+}
+
+func TestGyroFilterChainWithoutFilters(t *testing.T) {
+	chain := NewGyroFilterChain(GyroFilterSettings{}, 4000)
+	assert.Equal(t, 12.5, chain.Apply(GyroSample{Gyro: 12.5}))
+}
+
+func TestDelay(t *testing.T) {
+	input := make([]float64, 4000)
+	for i := range input {
+		input[i] = math.Sin(2*math.Pi*7*float64(i)/4000) + math.Sin(2*math.Pi*31*float64(i)/4000)
+	}
+	output := ApplyFilter(NewPTNFilter(1, 50, 4000), input)
+
+	// A PT1 filter delays low frequencies by about 1/(2*pi*cutoff)
+	assert.InDelta(t, 3.2, Delay(input, output, 4000, 20*time.Millisecond).Seconds()*1000, 0.5)
+	assert.Equal(t, time.Duration(0), Delay(input[:10], output[:10], 4000, 20*time.Millisecond))
+}
+
+// gain returns the amplitude of the output of a filter for a sine of amplitude 1
+func gain(filter Filter, frequency, sampleRate float64) float64 {
+	output := make([]float64, int(sampleRate))
+	for i := range output {
+		output[i] = filter.Apply(math.Sin(2 * math.Pi * frequency * float64(i) / sampleRate))
+	}
+	return rms(output[len(output)/2:]) * math.Sqrt2
+}
+
+func rms(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v * v
+	}
+	return math.Sqrt(total / float64(len(values)))
+}
@@ -0,0 +1,312 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"sort"
+)
+
+const (
+	// dynamicNotchResolution is the approximate width of the bins of the spectrum the
+	// dynamic notches look for peaks in, in Hz
+	dynamicNotchResolution = 10.0
+
+	// maxNotchFrequency is the highest frequency a notch or a cutoff can be placed at,
+	// relatively to the sample rate. Above, it would be too close to the Nyquist frequency.
+	maxNotchFrequency = 0.48
+)
+
+// LowpassType is the type of a Betaflight lowpass filter, numbered like in its headers
+type LowpassType int
+
+// List of the lowpass filter types
+const (
+	LowpassPT1 LowpassType = iota
+	LowpassBiquad
+	LowpassPT2
+	LowpassPT3
+)
+
+// LowpassSettings configure a lowpass filter. It's disabled if its cutoff is 0.
+type LowpassSettings struct {
+	Type   LowpassType
+	Cutoff float64
+
+	// DynamicMin and DynamicMax, when set, make the cutoff follow the throttle between them
+	DynamicMin float64
+	DynamicMax float64
+}
+
+// NotchSettings configure a static notch filter. It's disabled if its center is 0.
+type NotchSettings struct {
+	Center float64
+	Cutoff float64
+}
+
+// DynamicNotchSettings configure notches following the peaks of the spectrum of the
+// gyro. They are disabled if Count is 0.
+type DynamicNotchSettings struct {
+	// Count is the number of peaks followed
+	Count int
+
+	// WidthPercent, when set, places two notches on each peak, at this percentage below
+	// and above it, like Betaflight 4.0 and 4.1 do
+	WidthPercent float64
+
+	Q     float64
+	MinHz float64
+	MaxHz float64
+}
+
+// RPMFilterSettings configure notches on the rotation frequency of each motor and its
+// harmonics. They are disabled if Harmonics is 0.
+type RPMFilterSettings struct {
+	Harmonics int
+	Q         float64
+	MinHz     float64
+}
+
+// GyroFilterSettings configure the filters Betaflight applies to the gyro of each axis
+type GyroFilterSettings struct {
+	Lowpass      LowpassSettings
+	Lowpass2     LowpassSettings
+	Notch1       NotchSettings
+	Notch2       NotchSettings
+	DynamicNotch DynamicNotchSettings
+	RPMFilter    RPMFilterSettings
+}
+
+// GyroSample is a sample of the gyro of an axis, with what the filters adapt to
+type GyroSample struct {
+	Gyro float64
+
+	// Throttle is between 0 and 1
+	Throttle float64
+
+	// MotorFrequencies are the rotation frequencies of the motors, in Hz
+	MotorFrequencies []float64
+}
+
+// GyroFilterChain filters the gyro of an axis in the order of Betaflight: RPM notches,
+// lowpass filters 1 and 2, static notches 1 and 2 and finally dynamic notches. Every filter
+// runs at the sample rate of the chain, which should be the one of the gyro for the result
+// to be accurate.
+type GyroFilterChain struct {
+	sampleRate   float64
+	settings     GyroFilterSettings
+	rpmNotches   []*BiquadFilter
+	static       FilterChain
+	lowpass      lowpassFilter
+	lowpass2     lowpassFilter
+	dynamicNotch *dynamicNotch
+}
+
+// NewGyroFilterChain returns a new GyroFilterChain
+func NewGyroFilterChain(settings GyroFilterSettings, sampleRate float64) *GyroFilterChain {
+	c := &GyroFilterChain{sampleRate: sampleRate, settings: settings}
+
+	for _, notch := range []NotchSettings{settings.Notch1, settings.Notch2} {
+		if notch.Center > 0 && notch.Cutoff > 0 && notch.Cutoff < notch.Center && notch.Center < maxNotchFrequency*sampleRate {
+			c.static = append(c.static, NewBiquadNotchFilter(notch.Center, NotchQ(notch.Center, notch.Cutoff), sampleRate))
+		}
+	}
+	c.lowpass = newLowpassFilter(settings.Lowpass, sampleRate)
+	c.lowpass2 = newLowpassFilter(settings.Lowpass2, sampleRate)
+	if settings.DynamicNotch.Count > 0 && settings.DynamicNotch.Q > 0 {
+		c.dynamicNotch = newDynamicNotch(settings.DynamicNotch, sampleRate)
+	}
+	return c
+}
+
+// Apply filters the next sample
+func (c *GyroFilterChain) Apply(sample GyroSample) float64 {
+	value := c.applyRPMNotches(sample.Gyro, sample.MotorFrequencies)
+
+	if c.lowpass != nil {
+		if c.settings.Lowpass.DynamicMin > 0 {
+			cutoff := dynamicLowpassCutoff(c.settings.Lowpass, sample.Throttle)
+			c.lowpass.SetCutoff(math.Min(cutoff, maxNotchFrequency*c.sampleRate), c.sampleRate)
+		}
+		value = c.lowpass.Apply(value)
+	}
+	if c.lowpass2 != nil {
+		value = c.lowpass2.Apply(value)
+	}
+
+	value = c.static.Apply(value)
+
+	if c.dynamicNotch != nil {
+		value = c.dynamicNotch.Apply(value)
+	}
+	return value
+}
+
+func (c *GyroFilterChain) applyRPMNotches(value float64, motorFrequencies []float64) float64 {
+	rpm := c.settings.RPMFilter
+	if rpm.Harmonics == 0 || rpm.Q <= 0 {
+		return value
+	}
+	for len(c.rpmNotches) < len(motorFrequencies)*rpm.Harmonics {
+		c.rpmNotches = append(c.rpmNotches, &BiquadFilter{})
+	}
+
+	for motor, frequency := range motorFrequencies {
+		for harmonic := 1; harmonic <= rpm.Harmonics; harmonic++ {
+			center := frequency * float64(harmonic)
+			if center < rpm.MinHz || center > maxNotchFrequency*c.sampleRate {
+				continue
+			}
+			notch := c.rpmNotches[motor*rpm.Harmonics+harmonic-1]
+			notch.SetNotch(center, rpm.Q, c.sampleRate)
+			value = notch.Apply(value)
+		}
+	}
+	return value
+}
+
+// lowpassFilter is a lowpass filter whose cutoff can change
+type lowpassFilter interface {
+	Filter
+	SetCutoff(cutoff, sampleRate float64)
+}
+
+func newLowpassFilter(settings LowpassSettings, sampleRate float64) lowpassFilter {
+	cutoff := settings.Cutoff
+	if settings.DynamicMin > 0 {
+		cutoff = settings.DynamicMin
+	}
+	if cutoff <= 0 {
+		return nil
+	}
+	cutoff = math.Min(cutoff, maxNotchFrequency*sampleRate)
+
+	switch settings.Type {
+	case LowpassBiquad:
+		return NewBiquadLowpassFilter(cutoff, sampleRate)
+	case LowpassPT2:
+		return NewPTNFilter(2, cutoff, sampleRate)
+	case LowpassPT3:
+		return NewPTNFilter(3, cutoff, sampleRate)
+	default:
+		return NewPTNFilter(1, cutoff, sampleRate)
+	}
+}
+
+// dynamicLowpassCutoff returns the cutoff of a dynamic lowpass filter, which rises with
+// the throttle on a curve like in Betaflight
+func dynamicLowpassCutoff(settings LowpassSettings, throttle float64) float64 {
+	throttle = math.Max(0, math.Min(1, throttle))
+	curve := throttle * (1 - throttle*throttle/3) * 1.5
+	return math.Max(curve*settings.DynamicMax, settings.DynamicMin)
+}
+
+// dynamicNotch places notches on the highest peaks of the spectrum of the last samples
+// it filtered, updated several times per window of samples
+type dynamicNotch struct {
+	settings   DynamicNotchSettings
+	sampleRate float64
+	window     []float64
+	samples    []float64
+	position   int
+	filled     bool
+	sinceLast  int
+	notches    []*BiquadFilter
+}
+
+func newDynamicNotch(settings DynamicNotchSettings, sampleRate float64) *dynamicNotch {
+	if settings.MaxHz <= 0 || settings.MaxHz > maxNotchFrequency*sampleRate {
+		settings.MaxHz = maxNotchFrequency * sampleRate
+	}
+
+	size := NextPowerOfTwo(int(sampleRate / dynamicNotchResolution))
+	if size < 64 {
+		size = 64
+	}
+	return &dynamicNotch{
+		settings:   settings,
+		sampleRate: sampleRate,
+		window:     HannWindow(size),
+		samples:    make([]float64, size),
+	}
+}
+
+func (n *dynamicNotch) Apply(input float64) float64 {
+	n.samples[n.position] = input
+	n.position = (n.position + 1) % len(n.samples)
+	n.filled = n.filled || n.position == 0
+	n.sinceLast++
+	if n.filled && n.sinceLast >= len(n.samples)/4 {
+		n.sinceLast = 0
+		n.update()
+	}
+
+	for _, notch := range n.notches {
+		input = notch.Apply(input)
+	}
+	return input
+}
+
+// update moves the notches to the peaks of the spectrum of the last samples. Notches
+// stay where they are if not enough peaks are found.
+func (n *dynamicNotch) update() {
+	size := len(n.samples)
+	ordered := append(append([]float64{}, n.samples[n.position:]...), n.samples[:n.position]...)
+	mean := meanOf(ordered)
+	x := make([]complex128, size)
+	for i, v := range ordered {
+		x[i] = complex((v-mean)*n.window[i], 0)
+	}
+	spectrum := FFT(x)
+	power := make([]float64, size/2)
+	for i := range power {
+		power[i] = math.Pow(cmplx.Abs(spectrum[i]), 2)
+	}
+
+	resolution := n.sampleRate / float64(size)
+	type peak struct{ frequency, power float64 }
+	peaks := []peak{}
+	for i := 1; i < len(power)-1; i++ {
+		if power[i] <= power[i-1] || power[i] < power[i+1] {
+			continue
+		}
+		frequency := (float64(i) + interpolatePeak(power[i-1], power[i], power[i+1])) * resolution
+		if frequency >= n.settings.MinHz && frequency <= n.settings.MaxHz {
+			peaks = append(peaks, peak{frequency, power[i]})
+		}
+	}
+	if len(peaks) < n.settings.Count {
+		return
+	}
+	sort.Slice(peaks, func(i, j int) bool {
+		return peaks[i].power > peaks[j].power
+	})
+	peaks = peaks[:n.settings.Count]
+	sort.Slice(peaks, func(i, j int) bool {
+		return peaks[i].frequency < peaks[j].frequency
+	})
+
+	centers := []float64{}
+	for _, p := range peaks {
+		if n.settings.WidthPercent > 0 {
+			centers = append(centers, p.frequency*(1-n.settings.WidthPercent/100), p.frequency*(1+n.settings.WidthPercent/100))
+		} else {
+			centers = append(centers, p.frequency)
+		}
+	}
+	for i, center := range centers {
+		if i == len(n.notches) {
+			n.notches = append(n.notches, &BiquadFilter{})
+		}
+		n.notches[i].SetNotch(center, n.settings.Q, n.sampleRate)
+	}
+}
+
+// interpolatePeak returns the offset, in bins, of the top of the parabola going through
+// a peak and its neighbours
+func interpolatePeak(left, center, right float64) float64 {
+	denominator := left - 2*center + right
+	if denominator == 0 {
+		return 0
+	}
+	return 0.5 * (left - right) / denominator
+}
@@ -0,0 +1,104 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+
+	"github.com/pkg/errors"
+)
+
+// Spectrum is the power spectral density of a signal, in squared units of the signal
+// per Hz
+type Spectrum struct {
+	// Frequencies are the center of each bin, in Hz, from 0 to the Nyquist frequency
+	Frequencies []float64
+
+	Power []float64
+}
+
+// PowerSpectrum returns the spectrum of a signal sampled at sampleRate, with Welch's
+// method: the spectra of overlapping segments of segmentSize samples are averaged, which
+// reduces the variance of the estimate. segmentSize must be a power of two.
+func PowerSpectrum(samples []float64, sampleRate float64, segmentSize int) (Spectrum, error) {
+	if segmentSize < 2 || segmentSize&(segmentSize-1) != 0 {
+		return Spectrum{}, errors.Errorf("Segment size %d is not a power of two", segmentSize)
+	}
+	if len(samples) < segmentSize {
+		return Spectrum{}, errors.Errorf("Signal has %d samples, less than a segment of %d", len(samples), segmentSize)
+	}
+
+	window := HannWindow(segmentSize)
+	windowPower := 0.0
+	for _, w := range window {
+		windowPower += w * w
+	}
+
+	power := make([]float64, segmentSize/2+1)
+	segments := 0
+	for start := 0; start+segmentSize <= len(samples); start += segmentSize / 2 {
+		segment := samples[start : start+segmentSize]
+		mean := meanOf(segment)
+
+		x := make([]complex128, segmentSize)
+		for i, v := range segment {
+			x[i] = complex((v-mean)*window[i], 0)
+		}
+		for i, v := range FFT(x)[:len(power)] {
+			power[i] += math.Pow(cmplx.Abs(v), 2)
+		}
+		segments++
+	}
+
+	spectrum := Spectrum{
+		Frequencies: make([]float64, len(power)),
+		Power:       power,
+	}
+	for i := range power {
+		spectrum.Frequencies[i] = float64(i) * sampleRate / float64(segmentSize)
+
+		// One-sided density: every bin but DC and Nyquist holds the negative frequencies too
+		power[i] /= float64(segments) * sampleRate * windowPower
+		if i != 0 && i != len(power)-1 {
+			power[i] *= 2
+		}
+	}
+	return spectrum, nil
+}
+
+// BandPower returns the power of the signal between two frequencies, in squared units
+func (s Spectrum) BandPower(low, high float64) float64 {
+	if len(s.Frequencies) < 2 {
+		return 0
+	}
+	resolution := s.Frequencies[1] - s.Frequencies[0]
+
+	total := 0.0
+	for i, frequency := range s.Frequencies {
+		if frequency >= low && frequency <= high {
+			total += s.Power[i] * resolution
+		}
+	}
+	return total
+}
+
+// Peak returns the frequency with the most power between two frequencies, and its power
+func (s Spectrum) Peak(low, high float64) (float64, float64) {
+	peakFrequency, peakPower := 0.0, 0.0
+	for i, frequency := range s.Frequencies {
+		if frequency >= low && frequency <= high && s.Power[i] > peakPower {
+			peakFrequency, peakPower = frequency, s.Power[i]
+		}
+	}
+	return peakFrequency, peakPower
+}
+
+func meanOf(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total / float64(len(values))
+}
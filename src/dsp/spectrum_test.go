@@ -0,0 +1,37 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPowerSpectrum(t *testing.T) {
+	// A 200Hz sine of amplitude 10 plus an offset, sampled at 2kHz
+	samples := make([]float64, 8192)
+	for i := range samples {
+		samples[i] = 5 + 10*math.Sin(2*math.Pi*200*float64(i)/2000)
+	}
+
+	spectrum, err := PowerSpectrum(samples, 2000, 512)
+	assert.NoError(t, err)
+	assert.Len(t, spectrum.Frequencies, 257)
+	assert.Equal(t, 1000.0, spectrum.Frequencies[256])
+
+	frequency, _ := spectrum.Peak(0, 1000)
+	assert.InDelta(t, 200, frequency, 4)
+
+	// The power of a sine is half its squared amplitude, and the offset is removed
+	assert.InDelta(t, 50, spectrum.BandPower(0, 1000), 1)
+	assert.InDelta(t, 50, spectrum.BandPower(150, 250), 1)
+	assert.InDelta(t, 0, spectrum.BandPower(300, 1000), 0.1)
+}
+
+func TestPowerSpectrumErrors(t *testing.T) {
+	_, err := PowerSpectrum(make([]float64, 1000), 2000, 500)
+	assert.Error(t, err)
+
+	_, err = PowerSpectrum(make([]float64, 100), 2000, 512)
+	assert.Error(t, err)
+}
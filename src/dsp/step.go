@@ -0,0 +1,182 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// stepRegularization is added to the power of the input, relative to its average,
+	// to keep frequencies the input barely excites from amplifying noise
+	stepRegularization = 0.01
+
+	// settlingBand is how close to its steady value a response has to stay to be settled
+	settlingBand = 0.05
+)
+
+// StepResponseOpts holds the options of EstimateStepResponse
+type StepResponseOpts struct {
+	// WindowDuration is the length of the parts of the signals the response is estimated over
+	WindowDuration time.Duration
+
+	// ResponseDuration is the length of the estimated response
+	ResponseDuration time.Duration
+
+	// MinInput is the peak input a window must reach to be used. Windows without any
+	// significant input tell nothing about the response, like when a stick isn't moved.
+	MinInput float64
+}
+
+// DefaultStepResponseOpts returns options suited to the gyro response to the setpoint,
+// in deg/s
+func DefaultStepResponseOpts() StepResponseOpts {
+	return StepResponseOpts{
+		WindowDuration:   time.Second,
+		ResponseDuration: 500 * time.Millisecond,
+		MinInput:         20,
+	}
+}
+
+// StepResponse is the response of a system to a unit step of its input
+type StepResponse struct {
+	SampleRate float64
+
+	// Values of the response, from the step on
+	Values []float64
+
+	// Windows is the number of windows the response was estimated over
+	Windows int
+}
+
+// StepMetrics describe the shape of a step response
+type StepMetrics struct {
+	// Steady is the value the response settles at, 1 for an output tracking the input
+	Steady float64 `json:"steady"`
+
+	// RiseTime is how long the response takes to go from 10% to 90% of its steady value
+	RiseTime time.Duration `json:"riseTime"`
+
+	// PeakTime is when the response reaches its maximum
+	PeakTime time.Duration `json:"peakTime"`
+
+	// Overshoot is how much the response exceeds its steady value, as a fraction of it
+	Overshoot float64 `json:"overshoot"`
+
+	// SettlingTime is when the response stops leaving the band of 5% around its steady value
+	SettlingTime time.Duration `json:"settlingTime"`
+}
+
+// EstimateStepResponse estimates the step response of a system from its input and output,
+// sampled at the same rate. The input and output are cut in half-overlapping windows, and
+// the transfer function is the average cross spectrum of the windows divided by the
+// average power of their input. The step response is the integral of its inverse.
+func EstimateStepResponse(input, output []float64, sampleRate float64, opts StepResponseOpts) (StepResponse, error) {
+	if len(input) != len(output) {
+		return StepResponse{}, errors.Errorf("Input has %d samples but output has %d", len(input), len(output))
+	}
+
+	windowSize := NextPowerOfTwo(int(opts.WindowDuration.Seconds() * sampleRate))
+	responseSize := int(opts.ResponseDuration.Seconds() * sampleRate)
+	if responseSize < 2 || responseSize > windowSize {
+		return StepResponse{}, errors.Errorf("Response of %s doesn't fit in windows of %s at %.0fHz", opts.ResponseDuration, opts.WindowDuration, sampleRate)
+	}
+
+	window := HannWindow(windowSize)
+	crossSpectrum := make([]complex128, windowSize)
+	inputPower := make([]float64, windowSize)
+	windows := 0
+	for start := 0; start+windowSize <= len(input); start += windowSize / 2 {
+		if peakOf(input[start:start+windowSize]) < opts.MinInput {
+			continue
+		}
+
+		x := make([]complex128, windowSize)
+		y := make([]complex128, windowSize)
+		for i := range x {
+			x[i] = complex(input[start+i]*window[i], 0)
+			y[i] = complex(output[start+i]*window[i], 0)
+		}
+
+		xSpectrum := FFT(x)
+		ySpectrum := FFT(y)
+		for k := range xSpectrum {
+			crossSpectrum[k] += ySpectrum[k] * cmplx.Conj(xSpectrum[k])
+			inputPower[k] += math.Pow(cmplx.Abs(xSpectrum[k]), 2)
+		}
+		windows++
+	}
+	if windows == 0 {
+		return StepResponse{}, errors.Errorf("No window of %s has an input of at least %v", opts.WindowDuration, opts.MinInput)
+	}
+
+	regularization := stepRegularization * meanOf(inputPower)
+	transfer := make([]complex128, windowSize)
+	for k := range transfer {
+		transfer[k] = crossSpectrum[k] / complex(inputPower[k]+regularization, 0)
+	}
+
+	impulse := InverseFFT(transfer)
+	response := StepResponse{SampleRate: sampleRate, Values: make([]float64, responseSize), Windows: windows}
+	total := 0.0
+	for i := range response.Values {
+		total += real(impulse[i])
+		response.Values[i] = total
+	}
+	return response, nil
+}
+
+// Metrics returns the metrics of a response. They are all zero if the response doesn't
+// settle at a positive value.
+func (r StepResponse) Metrics() StepMetrics {
+	if len(r.Values) < 2 {
+		return StepMetrics{}
+	}
+
+	// The response is considered steady over its second half
+	metrics := StepMetrics{Steady: meanOf(r.Values[len(r.Values)/2:])}
+	if metrics.Steady <= 0 {
+		return StepMetrics{}
+	}
+
+	rise10, rise90 := -1, -1
+	peak := 0
+	settled := 0
+	for i, v := range r.Values {
+		if rise10 < 0 && v >= 0.1*metrics.Steady {
+			rise10 = i
+		}
+		if rise90 < 0 && v >= 0.9*metrics.Steady {
+			rise90 = i
+		}
+		if v > r.Values[peak] {
+			peak = i
+		}
+		if math.Abs(v-metrics.Steady) > settlingBand*metrics.Steady {
+			settled = i + 1
+		}
+	}
+
+	if rise10 >= 0 && rise90 >= 0 {
+		metrics.RiseTime = r.duration(rise90 - rise10)
+	}
+	metrics.PeakTime = r.duration(peak)
+	metrics.Overshoot = math.Max(0, r.Values[peak]/metrics.Steady-1)
+	metrics.SettlingTime = r.duration(settled)
+	return metrics
+}
+
+// duration returns the duration of a number of samples
+func (r StepResponse) duration(samples int) time.Duration {
+	return time.Duration(float64(samples) / r.SampleRate * float64(time.Second))
+}
+
+func peakOf(values []float64) float64 {
+	peak := 0.0
+	for _, v := range values {
+		peak = math.Max(peak, math.Abs(v))
+	}
+	return peak
+}
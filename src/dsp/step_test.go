@@ -0,0 +1,85 @@
+package dsp
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepResponseFirstOrder(t *testing.T) {
+	// A first order system with a time constant of 20ms rises from 10% to 90% in 44ms
+	input := stickInput(2000, 20*time.Second)
+	output := simulate(input, 2000, func(x, y, v, dt float64) (float64, float64) {
+		return y + (x-y)*dt/0.02, 0
+	})
+
+	response, err := EstimateStepResponse(input, output, 2000, DefaultStepResponseOpts())
+	assert.NoError(t, err)
+	assert.Len(t, response.Values, 1000)
+	assert.True(t, response.Windows > 30)
+
+	metrics := response.Metrics()
+	assert.InDelta(t, 1, metrics.Steady, 0.05)
+	assert.InDelta(t, 44, float64(metrics.RiseTime/time.Millisecond), 6)
+	assert.InDelta(t, 0, metrics.Overshoot, 0.05)
+}
+
+func TestStepResponseOvershoot(t *testing.T) {
+	// A second order system with a damping ratio of 0.4 overshoots by 25%
+	const frequency = 2 * math.Pi * 15
+	const damping = 0.4
+	input := stickInput(2000, 20*time.Second)
+	output := simulate(input, 2000, func(x, y, v, dt float64) (float64, float64) {
+		acceleration := frequency*frequency*(x-y) - 2*damping*frequency*v
+		v += acceleration * dt
+		return y + v*dt, v
+	})
+
+	response, err := EstimateStepResponse(input, output, 2000, DefaultStepResponseOpts())
+	assert.NoError(t, err)
+
+	metrics := response.Metrics()
+	assert.InDelta(t, 1, metrics.Steady, 0.05)
+	assert.InDelta(t, 0.25, metrics.Overshoot, 0.05)
+	assert.InDelta(t, 37, float64(metrics.PeakTime/time.Millisecond), 6)
+	assert.True(t, metrics.SettlingTime > metrics.PeakTime)
+}
+
+func TestStepResponseWithoutInput(t *testing.T) {
+	input := make([]float64, 10000)
+	_, err := EstimateStepResponse(input, input, 2000, DefaultStepResponseOpts())
+	assert.Error(t, err)
+
+	_, err = EstimateStepResponse(input, input[1:], 2000, DefaultStepResponseOpts())
+	assert.Error(t, err)
+}
+
+// stickInput returns stick movements, as a setpoint which holds random rates for random
+// durations
+func stickInput(sampleRate float64, duration time.Duration) []float64 {
+	random := rand.New(rand.NewSource(1))
+	input := make([]float64, int(duration.Seconds()*sampleRate))
+	value := 0.0
+	for i := range input {
+		if random.Float64() < 10/sampleRate {
+			value = random.Float64()*600 - 300
+		}
+		input[i] = value
+	}
+	return input
+}
+
+// simulate returns the output of a system given its input. step returns the next
+// output and speed of the system.
+func simulate(input []float64, sampleRate float64, step func(x, y, v, dt float64) (float64, float64)) []float64 {
+	output := make([]float64, len(input))
+	y, v := 0.0, 0.0
+	for i, x := range input {
+		output[i] = y
+		y, v = step(x, y, v, 1/sampleRate)
+	}
+	return output
+}
@@ -0,0 +1,29 @@
+package dsp
+
+// Uniform returns the values of a signal sampled at increasing times, in microseconds,
+// linearly interpolated at a uniform rate from the first time on
+func Uniform(times []int64, values []float64, sampleRate float64) []float64 {
+	if len(times) == 0 || len(times) != len(values) || sampleRate <= 0 {
+		return nil
+	}
+
+	period := 1e6 / sampleRate
+	duration := float64(times[len(times)-1] - times[0])
+	result := make([]float64, 0, int(duration/period)+1)
+
+	j := 0
+	for i := 0; float64(i)*period <= duration; i++ {
+		t := float64(times[0]) + float64(i)*period
+		for j+1 < len(times)-1 && float64(times[j+1]) <= t {
+			j++
+		}
+
+		if j+1 >= len(times) || times[j+1] == times[j] {
+			result = append(result, values[j])
+			continue
+		}
+		ratio := (t - float64(times[j])) / float64(times[j+1]-times[j])
+		result = append(result, values[j]+(values[j+1]-values[j])*ratio)
+	}
+	return result
+}
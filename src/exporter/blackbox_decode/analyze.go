@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/maxlaverse/blackbox-library/src/analyzer"
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/spf13/cobra"
+)
+
+func newAnalyzeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "analyze <input log>",
+		Short: "Report motor, battery and oscillation problems found in a log",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("You need to provide the path to the log")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return analyze(args[0])
+		},
+	}
+}
+
+func analyze(sourceFilepath string) error {
+	var motorAnalyzer *analyzer.MotorAnalyzer
+	var batteryAnalyzer *analyzer.BatteryAnalyzer
+	var oscillationAnalyzer *analyzer.OscillationAnalyzer
+	var motorErr, batteryErr, oscillationErr error
+
+	init := func(frameDef blackbox.LogDefinition) error {
+		motorAnalyzer, motorErr = analyzer.NewMotorAnalyzer(frameDef, analyzer.DefaultMotorAnalyzerOpts())
+		batteryAnalyzer, batteryErr = analyzer.NewBatteryAnalyzer(frameDef)
+		oscillationAnalyzer, oscillationErr = analyzer.NewOscillationAnalyzer(frameDef, analyzer.DefaultOscillationAnalyzerOpts())
+		return nil
+	}
+
+	handler := func(frame blackbox.Frame) error {
+		if motorErr == nil {
+			motorAnalyzer.AddFrame(frame)
+		}
+		if batteryErr == nil {
+			batteryAnalyzer.AddFrame(frame)
+		}
+		if oscillationErr == nil {
+			oscillationAnalyzer.AddFrame(frame)
+		}
+		return nil
+	}
+
+	err := forEachFrame(sourceFilepath, blackbox.FlightLogReaderOpts{}, init, handler)
+	if err != nil {
+		return err
+	}
+
+	if motorErr != nil {
+		fmt.Printf("Motors: %v\n", motorErr)
+	} else {
+		printMotorEvents(motorAnalyzer.Events())
+	}
+
+	fmt.Println()
+	if batteryErr != nil {
+		fmt.Printf("Battery: %v\n", batteryErr)
+	} else {
+		fmt.Printf("Battery:\n%s", batteryAnalyzer.Report())
+	}
+
+	fmt.Println()
+	if oscillationErr != nil {
+		fmt.Printf("Oscillations: %v\n", oscillationErr)
+	} else {
+		printOscillationEvents(oscillationAnalyzer.Events())
+	}
+	return nil
+}
+
+func printMotorEvents(events []analyzer.MotorEvent) {
+	fmt.Printf("Motors: %d events\n", len(events))
+	for _, event := range events {
+		fmt.Printf("  %s\n", event)
+	}
+}
+
+func printOscillationEvents(events []analyzer.OscillationEvent) {
+	fmt.Printf("Oscillations: %d events\n", len(events))
+	for _, event := range events {
+		fmt.Printf("  %s\n", event)
+	}
+}
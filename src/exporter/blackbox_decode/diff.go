@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/maxlaverse/blackbox-library/src/analyzer"
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/spf13/cobra"
+)
+
+type diffOptions struct {
+	json bool
+}
+
+func newDiffCommand() *cobra.Command {
+	var opts diffOptions
+
+	cmd := &cobra.Command{
+		Use:   "diff [options] <before log> <after log>",
+		Short: "Compare the settings of two logs and their effect on noise and step response",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return fmt.Errorf("You need to provide the paths to the two logs")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return diff(args[0], args[1], opts)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.json, "json", "", false, "Print the report as JSON")
+	return cmd
+}
+
+func diff(beforeFilepath, afterFilepath string, opts diffOptions) error {
+	before, err := profileFlight(beforeFilepath)
+	if err != nil {
+		return err
+	}
+	after, err := profileFlight(afterFilepath)
+	if err != nil {
+		return err
+	}
+
+	comparison := analyzer.Compare(before, after)
+	if opts.json {
+		content, err := json.MarshalIndent(comparison, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(content))
+		return nil
+	}
+
+	fmt.Print(comparison)
+	return nil
+}
+
+func profileFlight(sourceFilepath string) (analyzer.FlightProfile, error) {
+	var profiler *analyzer.FlightProfiler
+
+	init := func(frameDef blackbox.LogDefinition) error {
+		profiler = analyzer.NewFlightProfiler(frameDef)
+		return nil
+	}
+
+	handler := func(frame blackbox.Frame) error {
+		profiler.AddFrame(frame)
+		return nil
+	}
+
+	err := forEachFrame(sourceFilepath, blackbox.FlightLogReaderOpts{}, init, handler)
+	if err != nil {
+		return analyzer.FlightProfile{}, err
+	}
+	return profiler.Profile(), nil
+}
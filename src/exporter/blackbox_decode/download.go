@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/msp"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// eraseTimeout is how long an erase of the onboard flash can take
+	eraseTimeout = 5 * time.Minute
+)
+
+type downloadOptions struct {
+	baud         int
+	chunkSize    uint16
+	erase        bool
+	huffmanTable string
+	mspVersion   int
+}
+
+func newDownloadCommand() *cobra.Command {
+	var opts downloadOptions
+
+	cmd := &cobra.Command{
+		Use:   "download [options] <serial port> <output log>",
+		Short: "Download the logs from the onboard flash of a flight controller",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 2 {
+				return fmt.Errorf("You need to provide the serial port of the flight controller and the path of the log to write")
+			}
+			if opts.mspVersion != 1 && opts.mspVersion != 2 {
+				return fmt.Errorf("MSP version must be 1 or 2")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return download(args[0], args[1], opts)
+		},
+	}
+
+	cmd.Flags().IntVarP(&opts.baud, "baud", "", 115200, "Baud rate of the serial port, ignored by USB ports")
+	cmd.Flags().Uint16VarP(&opts.chunkSize, "chunk-size", "", msp.DefaultChunkSize, "Number of bytes requested at once")
+	cmd.Flags().BoolVarP(&opts.erase, "erase", "", false, "Erase the onboard flash once the logs are downloaded")
+	cmd.Flags().StringVarP(&opts.huffmanTable, "huffman-table", "", "", "Compress the reads with the Huffman table found in this firmware source file (huffman_table.c)")
+	cmd.Flags().IntVarP(&opts.mspVersion, "msp-version", "", 1, "Version of the MultiWii Serial Protocol to use (1 or 2)")
+	return cmd
+}
+
+func download(port, targetFilepath string, opts downloadOptions) error {
+	var huffmanTable *msp.HuffmanTable
+	if opts.huffmanTable != "" {
+		table, err := readHuffmanTable(opts.huffmanTable)
+		if err != nil {
+			return err
+		}
+		huffmanTable = table
+	}
+
+	serialPort, err := openSerialPort(port, opts.baud)
+	if err != nil {
+		return err
+	}
+	defer serialPort.Close()
+
+	client := msp.NewClient(serialPort, msp.Version(opts.mspVersion))
+	client.Huffman = huffmanTable
+	summary, err := client.ReadDataflashSummary()
+	if err != nil {
+		return err
+	}
+	if !summary.Supported {
+		return fmt.Errorf("The flight controller has no onboard flash")
+	}
+	fmt.Printf("Onboard flash: %d of %d bytes used\n", summary.UsedSize, summary.TotalSize)
+
+	targetFile, err := os.Create(targetFilepath)
+	if err != nil {
+		return err
+	}
+	defer targetFile.Close()
+
+	started := time.Now()
+	n, err := client.DownloadDataflash(targetFile, opts.chunkSize, func(read, total uint32) {
+		fmt.Printf("\rDownloaded %d of %d bytes (%d%%)", read, total, uint64(read)*100/uint64(total))
+	})
+	fmt.Println()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s (%d bytes) in %s\n", targetFilepath, n, time.Since(started).Round(time.Second/10))
+
+	if !opts.erase {
+		return nil
+	}
+
+	// The downloaded logs have to be safe on disk before erasing them
+	if err := targetFile.Sync(); err != nil {
+		return err
+	}
+	fmt.Println("Erasing the onboard flash")
+	if err := client.EraseDataflash(); err != nil {
+		return err
+	}
+	return client.WaitDataflashReady(time.Second, eraseTimeout)
+}
+
+// readHuffmanTable reads the Huffman table of the firmware from its sources
+func readHuffmanTable(filepath string) (*msp.HuffmanTable, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	table, err := msp.ParseHuffmanTable(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read the Huffman table in '%s'", filepath)
+	}
+	return table, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/maxlaverse/blackbox-library/src/analyzer"
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/spf13/cobra"
+)
+
+type filtersOptions struct {
+	set       []string
+	overrides map[blackbox.HeaderName]string
+	json      bool
+}
+
+// filtersReport is the effect of the logged and alternative filters on each axis
+type filtersReport struct {
+	Unfiltered  []analyzer.AxisFiltering `json:"unfiltered"`
+	Logged      []analyzer.AxisFiltering `json:"logged"`
+	Simulated   []analyzer.AxisFiltering `json:"simulated"`
+	Alternative []analyzer.AxisFiltering `json:"alternative,omitempty"`
+}
+
+func newFiltersCommand() *cobra.Command {
+	opts := filtersOptions{overrides: map[blackbox.HeaderName]string{}}
+
+	cmd := &cobra.Command{
+		Use:   "filters [options] <input log>",
+		Short: "Replay the gyro filters on the unfiltered gyro of a GYRO_SCALED log and report noise and delay",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("You need to provide the path to the log")
+			}
+			for _, definition := range opts.set {
+				parts := strings.SplitN(definition, "=", 2)
+				if len(parts) != 2 {
+					return fmt.Errorf("Invalid setting '%s', expected 'header=value'", definition)
+				}
+				opts.overrides[blackbox.HeaderName(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return filters(args[0], opts)
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&opts.set, "set", "", nil, "Try an alternative value for a filter header, like 'gyro_lowpass2_hz=300' (can be repeated)")
+	cmd.Flags().BoolVarP(&opts.json, "json", "", false, "Print the report as JSON")
+	return cmd
+}
+
+func filters(sourceFilepath string, opts filtersOptions) error {
+	var frameDef blackbox.LogDefinition
+	var simulator *analyzer.FilterSimulator
+
+	init := func(def blackbox.LogDefinition) error {
+		var err error
+		frameDef = def
+		simulator, err = analyzer.NewFilterSimulator(def)
+		return err
+	}
+
+	handler := func(frame blackbox.Frame) error {
+		simulator.AddFrame(frame)
+		return nil
+	}
+
+	err := forEachFrame(sourceFilepath, blackbox.FlightLogReaderOpts{}, init, handler)
+	if err != nil {
+		return err
+	}
+
+	report := filtersReport{}
+	if report.Unfiltered, err = simulator.Unfiltered(); err != nil {
+		return err
+	}
+	if report.Logged, err = simulator.Logged(); err != nil {
+		return err
+	}
+	settings, err := analyzer.GyroFilterSettingsFromHeaders(frameDef, nil)
+	if err != nil {
+		return err
+	}
+	if report.Simulated, err = simulator.Simulate(settings); err != nil {
+		return err
+	}
+	if len(opts.overrides) > 0 {
+		alternative, err := analyzer.GyroFilterSettingsFromHeaders(frameDef, opts.overrides)
+		if err != nil {
+			return err
+		}
+		if report.Alternative, err = simulator.Simulate(alternative); err != nil {
+			return err
+		}
+	}
+
+	if opts.json {
+		content, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(content))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Filters\tAxis\tNoise (deg/s RMS > 100Hz)\tDelay\n")
+	printFiltering(w, "unfiltered", report.Unfiltered)
+	printFiltering(w, "logged", report.Logged)
+	printFiltering(w, "simulated", report.Simulated)
+	printFiltering(w, "alternative", report.Alternative)
+	return w.Flush()
+}
+
+func printFiltering(w *tabwriter.Writer, name string, axes []analyzer.AxisFiltering) {
+	for _, axis := range axes {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\n", name, axis.Axis, axis.Noise, axis.Delay)
+	}
+}
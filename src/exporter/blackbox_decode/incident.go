@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/analyzer"
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/spf13/cobra"
+)
+
+type incidentOptions struct {
+	session int
+	window  time.Duration
+	output  string
+}
+
+func newIncidentCommand() *cobra.Command {
+	var opts incidentOptions
+
+	cmd := &cobra.Command{
+		Use:   "incident [options] <input log>",
+		Short: "Extract the last seconds of a log with the failsafe, RX loss, impact and disarm events leading to its end",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("You need to provide the path to the log")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return incident(args[0], opts)
+		},
+	}
+
+	cmd.Flags().IntVarP(&opts.session, "session", "", 1, "Session of the log to look at")
+	cmd.Flags().DurationVarP(&opts.window, "window", "", analyzer.DefaultIncidentAnalyzerOpts().Window, "How long before the end of the log to look at")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "", "Directory to write the incident bundle to (defaults to <input>.<session>.incident)")
+	return cmd
+}
+
+func incident(sourceFilepath string, opts incidentOptions) error {
+	logFile, err := os.Open(sourceFilepath)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	session, err := openSession(logFile, opts.session)
+	if err != nil {
+		return err
+	}
+
+	analyzerOpts := analyzer.DefaultIncidentAnalyzerOpts()
+	analyzerOpts.Window = opts.window
+	var incidentAnalyzer *analyzer.IncidentAnalyzer
+
+	init := func(frameDef blackbox.LogDefinition) error {
+		incidentAnalyzer = analyzer.NewIncidentAnalyzer(frameDef, analyzerOpts)
+		return nil
+	}
+
+	handler := func(frame blackbox.Frame) error {
+		incidentAnalyzer.AddFrame(frame)
+		return nil
+	}
+
+	err = forEachFrameOf(session, blackbox.FlightLogReaderOpts{}, init, handler)
+	if err != nil {
+		return err
+	}
+	result := incidentAnalyzer.Incident()
+
+	targetDirpath := opts.output
+	if targetDirpath == "" {
+		targetDirpath = sessionFilepath(sourceFilepath, opts.session, "incident")
+	}
+	err = os.MkdirAll(targetDirpath, 0755)
+	if err != nil {
+		return err
+	}
+
+	// The session is read again from its start to write the data of the incident
+	session, err = openSession(logFile, opts.session)
+	if err != nil {
+		return err
+	}
+	targetFile, err := os.Create(path.Join(targetDirpath, "log.bfl"))
+	if err != nil {
+		return err
+	}
+	defer targetFile.Close()
+	_, err = blackbox.Trim(session, targetFile, blackbox.TrimOpts{From: result.Start, To: result.End})
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path.Join(targetDirpath, "events.json"), content, 0644)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path.Join(targetDirpath, "narrative.txt"), []byte(result.Narrative()), 0644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(result.Narrative())
+	fmt.Printf("\nWrote the incident bundle to %s\n", targetDirpath)
+	return nil
+}
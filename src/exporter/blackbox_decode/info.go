@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/spf13/cobra"
+)
+
+type infoOptions struct {
+	json bool
+}
+
+// sessionInfo is what is printed about a session, with offsets relative to the start of
+// the file
+type sessionInfo struct {
+	Index            int                    `json:"session"`
+	Start            int64                  `json:"start"`
+	End              int64                  `json:"end"`
+	Product          string                 `json:"product"`
+	Firmware         string                 `json:"firmware"`
+	CraftName        string                 `json:"craftName"`
+	LogStartDatetime string                 `json:"logStartDatetime"`
+	Fields           []string               `json:"fields"`
+	Sysconfig        blackbox.SysconfigType `json:"sysconfig"`
+	FirstIteration   int64                  `json:"firstIteration"`
+	LastIteration    int64                  `json:"lastIteration"`
+	Duration         time.Duration          `json:"duration"`
+}
+
+func newInfoCommand() *cobra.Command {
+	var opts infoOptions
+
+	cmd := &cobra.Command{
+		Use:   "info [options] <input log>",
+		Short: "Describe the sessions of a log from their headers, without decoding them",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("You need to provide the path to the log")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return info(args[0], opts)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.json, "json", "", false, "Print the sessions as JSON")
+	return cmd
+}
+
+func info(sourceFilepath string, opts infoOptions) error {
+	logFile, err := os.Open(sourceFilepath)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	sessions, err := findSessions(logFile)
+	if err != nil {
+		return err
+	}
+
+	infos := []sessionInfo{}
+	for _, session := range sessions {
+		inspected, err := blackbox.InspectSession(logFile, session)
+		if err != nil {
+			return fmt.Errorf("Could not inspect log %d: %v", session.Index, err)
+		}
+		infos = append(infos, newSessionInfo(inspected))
+	}
+
+	if opts.json {
+		content, err := json.MarshalIndent(infos, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(content))
+		return nil
+	}
+
+	for i, info := range infos {
+		if i > 0 {
+			fmt.Println()
+		}
+		printSessionInfo(info, len(infos))
+	}
+	return nil
+}
+
+func newSessionInfo(inspected blackbox.SessionInfo) sessionInfo {
+	frameDef := inspected.Definition
+	info := sessionInfo{
+		Index:            inspected.Index,
+		Start:            inspected.Start,
+		End:              inspected.End,
+		Product:          frameDef.Product,
+		Firmware:         frameDef.Firmware.String(),
+		CraftName:        frameDef.CraftName,
+		LogStartDatetime: frameDef.LogStartDatetime,
+		Fields:           []string{},
+		Sysconfig:        frameDef.Sysconfig,
+		Duration:         inspected.Duration(),
+	}
+	for _, field := range frameDef.FieldsI {
+		info.Fields = append(info.Fields, string(field.Name))
+	}
+	if inspected.FirstIntraFrame != nil {
+		info.FirstIteration = inspected.FirstIntraFrame.Values().([]int64)[0]
+		info.LastIteration = inspected.LastIntraFrame.Values().([]int64)[0]
+	}
+	return info
+}
+
+func printSessionInfo(info sessionInfo, count int) {
+	config := info.Sysconfig
+
+	fmt.Printf("Log %d of %d: bytes %d to %d\n", info.Index, count, info.Start, info.End)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "  Product:\t %s\n", info.Product)
+	_, _ = fmt.Fprintf(w, "  Firmware:\t %s\n", info.Firmware)
+	_, _ = fmt.Fprintf(w, "  Craft name:\t %s\n", info.CraftName)
+	_, _ = fmt.Fprintf(w, "  Started:\t %s\n", info.LogStartDatetime)
+	_, _ = fmt.Fprintf(w, "  Duration:\t ~%s (iterations %d to %d)\n", info.Duration, info.FirstIteration, info.LastIteration)
+	_, _ = fmt.Fprintf(w, "  Frame intervals:\t I every %d, P %d/%d\n", config.FrameIntervalI, config.FrameIntervalPNum, config.FrameIntervalPDenom)
+	_, _ = fmt.Fprintf(w, "  Throttle:\t %d to %d\n", config.MinThrottle, config.MaxThrottle)
+	_, _ = fmt.Fprintf(w, "  Motor output:\t %d to %d\n", config.MotorOutputLow, config.MotorOutputHigh)
+	_, _ = fmt.Fprintf(w, "  Cell voltage:\t %d min, %d warning, %d max (x0.01V)\n", config.Vbatmincellvoltage, config.Vbatwarningcellvoltage, config.Vbatmaxcellvoltage)
+	_, _ = fmt.Fprintf(w, "  Current meter:\t offset %d, scale %d\n", config.CurrentMeterOffset, config.CurrentMeterScale)
+	_, _ = fmt.Fprintf(w, "  Fields:\t %d, %s\n", len(info.Fields), strings.Join(info.Fields, ","))
+	_ = w.Flush()
+}
@@ -5,28 +5,61 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/maxlaverse/blackbox-library/src/blackbox"
 	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
 	"github.com/maxlaverse/blackbox-library/src/exporter/exporter"
+	"github.com/maxlaverse/blackbox-library/src/imu"
 	"github.com/spf13/cobra"
 )
 
+const (
+	// followFlushInterval is how often the output is flushed when following a log
+	followFlushInterval = time.Second
+)
+
 type cmdOptions struct {
-	raw     bool
-	debug   bool
-	verbose int
+	raw         bool
+	debug       bool
+	verbose     int
+	format      string
+	follow      bool
+	idleTimeout time.Duration
+	rate        float64
+	resample    string
+	fields      string
+	derive      []string
+	derived     []blackbox.DerivedField
+	from        time.Duration
+	to          time.Duration
+	where       string
+	condition   *blackbox.Expression
+	attitude    string
+}
+
+// resampleMethods are the resampling methods, by name
+var resampleMethods = map[string]blackbox.ResampleMethod{
+	"linear": blackbox.ResampleLinear,
+	"lttb":   blackbox.ResampleLTTB,
+}
+
+// attitudeFilters are the filters estimating the attitude, by name
+var attitudeFilters = map[string]func() imu.AttitudeFilter{
+	"mahony":   func() imu.AttitudeFilter { return imu.NewMahonyFilter() },
+	"madgwick": func() imu.AttitudeFilter { return imu.NewMadgwickFilter() },
 }
 
 func main() {
 	var opts cmdOptions
 
 	cmd := &cobra.Command{
-		Use: "blackbox_decode [options] <input logs>",
+		Use:  "blackbox_decode [options] <input logs>",
+		Args: cobra.ArbitraryArgs,
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 			flag.Set("logtostderr", "true")
 			flag.Set("v", strconv.Itoa(opts.verbose))
@@ -36,6 +69,31 @@ func main() {
 			if len(args) == 0 {
 				return fmt.Errorf("You need to provide the path to the logs")
 			}
+			for _, name := range strings.Split(opts.format, ",") {
+				if _, err := exporter.Lookup(name); err != nil {
+					return err
+				}
+			}
+			if _, ok := resampleMethods[opts.resample]; !ok {
+				return fmt.Errorf("Unknown resampling method '%s', expected linear or lttb", opts.resample)
+			}
+			for _, definition := range opts.derive {
+				derived, err := blackbox.ParseDerivedField(definition)
+				if err != nil {
+					return err
+				}
+				opts.derived = append(opts.derived, derived)
+			}
+			if _, ok := attitudeFilters[opts.attitude]; opts.attitude != "" && !ok {
+				return fmt.Errorf("Unknown attitude filter '%s', expected mahony or madgwick", opts.attitude)
+			}
+			if opts.where != "" {
+				condition, err := blackbox.ParseExpression(opts.where)
+				if err != nil {
+					return err
+				}
+				opts.condition = condition
+			}
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -46,6 +104,30 @@ func main() {
 	cmd.Flags().IntVarP(&opts.verbose, "verbose", "v", 0, "Be verbose on log output")
 	cmd.Flags().BoolVarP(&opts.raw, "raw", "", false, "Don't apply predictions to fields (show raw field deltas)")
 	cmd.Flags().BoolVarP(&opts.debug, "debug", "", false, "Show extra debugging information")
+	cmd.Flags().StringVarP(&opts.format, "format", "", "csv", fmt.Sprintf("Formats of the output, separated by commas (%s)", strings.Join(exporter.Formats(), ", ")))
+	cmd.Flags().BoolVarP(&opts.follow, "follow", "f", false, "Keep decoding the log while it's being written, until it ends")
+	cmd.Flags().Float64VarP(&opts.rate, "rate", "", 0, "Resample the main frames to this many per second (0 keeps them all)")
+	cmd.Flags().StringVarP(&opts.resample, "resample", "", "linear", "Resampling method: linear interpolation or lttb decimation keeping peaks")
+	cmd.Flags().StringVarP(&opts.fields, "fields", "", "", "Fields to export, separated by commas, like gyroADC[*],motor[*] (all by default)")
+	cmd.Flags().StringArrayVarP(&opts.derive, "derive", "", nil, "Add a field computed for every main frame, like 'motorAvg = mean(motor[*])' (can be repeated)")
+	cmd.Flags().VarP((*flightTimeValue)(&opts.from), "from", "", "Export the main frames from this time after the first one, as mm:ss[.fff], hh:mm:ss or a duration")
+	cmd.Flags().VarP((*flightTimeValue)(&opts.to), "to", "", "Export the main frames until this time after the first one (0 until the end)")
+	cmd.Flags().StringVarP(&opts.where, "where", "", "", "Export the main frames matching a condition, like 'rcCommand[3] > 1500 && abs(gyroADC[2]) < 200'")
+	cmd.Flags().StringVarP(&opts.attitude, "attitude", "", "", "Add the roll, pitch and yaw estimated from the gyro and accelerometer as imuAttitude[*] fields, with the mahony or madgwick filter")
+	cmd.Flags().DurationVarP(&opts.idleTimeout, "idle-timeout", "", 0, "Stop following the log once nothing was written for this long (0 waits forever)")
+
+	cmd.AddCommand(newAnalyzeCommand())
+	cmd.AddCommand(newDiffCommand())
+	cmd.AddCommand(newFiltersCommand())
+	cmd.AddCommand(newIncidentCommand())
+	cmd.AddCommand(newInfoCommand())
+	cmd.AddCommand(newSettingsCommand())
+	cmd.AddCommand(newTrimCommand())
+	cmd.AddCommand(newSplitCommand())
+	cmd.AddCommand(newRepairCommand())
+	cmd.AddCommand(newValidateCommand())
+	cmd.AddCommand(newDownloadCommand())
+	cmd.AddCommand(newServeCommand())
 
 	if err := cmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Unexpected resut: %v\n", err)
@@ -54,64 +136,200 @@ func main() {
 }
 
 func export(sourceFilepath string, opts cmdOptions) error {
-	filename := path.Base(sourceFilepath)
-	dirpath := path.Dir(sourceFilepath)
-	parts := strings.Split(filename, ".")
-	csvFilepath := path.Join(dirpath, fmt.Sprintf("%s01.csv", strings.TrimSuffix(filename, parts[len(parts)-1])))
-
 	logFile, err := os.Open(sourceFilepath)
 	if err != nil {
 		return err
 	}
 	defer logFile.Close()
 
-	// prepare reader and target file
-	readerOpts := blackbox.FlightLogReaderOpts{Raw: opts.raw}
+	// prepare reader and target files
+	readerOpts := blackbox.FlightLogReaderOpts{
+		Raw:               opts.raw,
+		Follow:            opts.follow,
+		FollowIdleTimeout: opts.idleTimeout,
+	}
 	flightLog := blackbox.NewFlightLogReader(readerOpts)
 	defer func() {
 		fmt.Println(flightLog.Stats)
 	}()
 
-	csvFile, err := os.Create(csvFilepath)
-	if err != nil {
-		return err
+	exporters := []exporter.Exporter{}
+	bufferedWriters := []*bufio.Writer{}
+	for _, name := range strings.Split(opts.format, ",") {
+		format, err := exporter.Lookup(name)
+		if err != nil {
+			return err
+		}
+
+		targetFile, err := os.Create(sessionFilepath(sourceFilepath, 1, format.Extension))
+		if err != nil {
+			return err
+		}
+		defer targetFile.Close()
+
+		bufferedWriter := bufio.NewWriter(targetFile)
+		defer bufferedWriter.Flush()
+		bufferedWriters = append(bufferedWriters, bufferedWriter)
+		exporters = append(exporters, format.New(bufferedWriter, exporter.Options{Debug: opts.debug}))
+	}
+	flush := func() error {
+		for _, bufferedWriter := range bufferedWriters {
+			if err := bufferedWriter.Flush(); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
-	defer csvFile.Close()
-	bufferedWriter := bufio.NewWriter(csvFile)
 
-	// iterate over frames and write them to CSV
+	// iterate over frames and write them to the target files
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	frameChan, err := flightLog.LoadFile(ctx, logFile)
 	if err != nil {
 		return err
 	}
+	frameDef := flightLog.FrameDef
+	if opts.attitude != "" {
+		estimator, err := imu.NewAttitudeEstimator(frameDef, attitudeFilters[opts.attitude]())
+		if err != nil {
+			return err
+		}
+		frameChan = imu.EstimateAttitude(ctx, frameChan, estimator)
+		frameDef = estimator.Definition()
+	}
+	filters, err := frameFilters(frameDef, opts)
+	if err != nil {
+		return err
+	}
+	if len(filters) > 0 {
+		frameChan = blackbox.FilterFrames(ctx, frameChan, filters)
+	}
+	if opts.rate > 0 {
+		frameChan, err = blackbox.Resample(ctx, frameChan, frameDef, blackbox.ResampleOpts{Rate: opts.rate, Method: resampleMethods[opts.resample]})
+		if err != nil {
+			return err
+		}
+	}
 
-	// prepare exporter and write CSV headers
-	csvExporter := exporter.NewCsvFrameExporter(bufferedWriter, opts.debug, flightLog.FrameDef)
-	err = csvExporter.WriteHeaders()
+	if opts.fields != "" || len(opts.derived) > 0 {
+		selectionOpts := blackbox.SelectionOpts{Derived: opts.derived}
+		if opts.fields != "" {
+			selectionOpts.Fields = strings.Split(opts.fields, ",")
+		}
+		selector, err := blackbox.NewFieldSelector(frameDef, selectionOpts)
+		if err != nil {
+			return err
+		}
+		frameChan = blackbox.SelectFields(ctx, frameChan, selector)
+		frameDef = selector.Definition()
+	}
+
+	// every format is written from the same decoding
+	frameExporter := exporter.NewMultiExporter(exporters...)
+	err = frameExporter.Begin(frameDef)
 	if err != nil {
 		return err
 	}
 
-	for frame := range frameChan {
-		// handle frame error
-		if err := frame.Error(); err != nil {
-			//TODO: Log offset and last id
-			if !isErrorRecoverable(err) {
-				cancel()
+	// when following, frames written so far are regularly made available to readers of the output
+	var flushChan <-chan time.Time
+	if opts.follow {
+		flushTicker := time.NewTicker(followFlushInterval)
+		defer flushTicker.Stop()
+		flushChan = flushTicker.C
+	}
+
+	for {
+		select {
+		case <-flushChan:
+			if err := flush(); err != nil {
+				return err
+			}
+
+		case frame, ok := <-frameChan:
+			if !ok {
+				if err := frameExporter.End(flightLog.Stats); err != nil {
+					return err
+				}
+				return flush()
+			}
+
+			// handle frame error
+			if err := frame.Error(); err != nil {
+				//TODO: Log offset and last id
+				if !isErrorRecoverable(err) {
+					return err
+				}
+			}
+
+			// write frame
+			err = frameExporter.WriteFrame(frame)
+			if err != nil {
 				return err
 			}
 		}
+	}
+}
 
-		// write CSV row
-		err = csvExporter.WriteFrame(frame)
+// frameFilters returns the filters selecting the main frames to export
+func frameFilters(frameDef blackbox.LogDefinition, opts cmdOptions) (blackbox.FilterChain, error) {
+	filters := blackbox.FilterChain{}
+	if opts.from != 0 || opts.to != 0 {
+		filter, err := blackbox.NewTimeFilter(frameDef, opts.from, opts.to)
 		if err != nil {
-			cancel()
-			return err
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if opts.condition != nil {
+		filter, err := blackbox.NewPredicateFilter(frameDef, opts.condition)
+		if err != nil {
+			return nil, err
 		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// forEachFrame decodes a flight log, hands its definition to init and then
+// every frame to handler
+func forEachFrame(sourceFilepath string, readerOpts blackbox.FlightLogReaderOpts, init func(blackbox.LogDefinition) error, handler func(blackbox.Frame) error) error {
+	logFile, err := os.Open(sourceFilepath)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	return forEachFrameOf(logFile, readerOpts, init, handler)
+}
+
+// forEachFrameOf is forEachFrame for a log already opened, like a single session
+func forEachFrameOf(r io.Reader, readerOpts blackbox.FlightLogReaderOpts, init func(blackbox.LogDefinition) error, handler func(blackbox.Frame) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	flightLog := blackbox.NewFlightLogReader(readerOpts)
+	frameChan, err := flightLog.LoadFile(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	err = init(flightLog.FrameDef)
+	if err != nil {
+		return err
 	}
 
+	for frame := range frameChan {
+		if err := frame.Error(); err != nil && !isErrorRecoverable(err) {
+			return err
+		}
+
+		err = handler(frame)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
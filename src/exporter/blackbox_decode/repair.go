@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type repairOptions struct {
+	output string
+	report string
+}
+
+// sessionRepairReport is the part of the repair report about one session, with offsets
+// relative to the start of the file
+type sessionRepairReport struct {
+	Index int   `json:"session"`
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	blackbox.RepairReport
+}
+
+func newRepairCommand() *cobra.Command {
+	var opts repairOptions
+
+	cmd := &cobra.Command{
+		Use:   "repair [options] <input log>",
+		Short: "Write a copy of a log without its corrupted regions",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("You need to provide the path to the log")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return repair(args[0], opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "", "Path of the repaired log (defaults to <input>.repaired.bfl)")
+	cmd.Flags().StringVarP(&opts.report, "report", "", "", "Path of the JSON report (defaults to <input>.repair.json)")
+	return cmd
+}
+
+func repair(sourceFilepath string, opts repairOptions) error {
+	logFile, err := os.Open(sourceFilepath)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	sessions, err := findSessions(logFile)
+	if err != nil {
+		return err
+	}
+
+	targetFilepath := opts.output
+	if targetFilepath == "" {
+		targetFilepath = siblingFilepath(sourceFilepath, "repaired.bfl")
+	}
+	targetFile, err := os.Create(targetFilepath)
+	if err != nil {
+		return err
+	}
+	defer targetFile.Close()
+	bufferedWriter := bufio.NewWriter(targetFile)
+
+	reports := []sessionRepairReport{}
+	for _, session := range sessions {
+		report, err := blackbox.Repair(io.NewSectionReader(logFile, session.Start, session.Size()), bufferedWriter)
+		if _, ok := errors.Cause(err).(*blackbox.NoValidFrameError); ok {
+			// Nothing was written for sessions without a single valid frame
+			report.Removed = []blackbox.RemovedRange{{Start: 0, End: session.Size(), Reason: err.Error()}}
+		} else if err != nil {
+			return errors.Wrapf(err, "could not repair log %d of %d", session.Index, len(sessions))
+		}
+
+		for i := range report.Removed {
+			report.Removed[i].Start += session.Start
+			report.Removed[i].End += session.Start
+		}
+		reports = append(reports, sessionRepairReport{Index: session.Index, Start: session.Start, End: session.End, RepairReport: report})
+		fmt.Printf("Log %d of %d: kept %d main frames, removed %d regions (%d bytes)\n", session.Index, len(sessions), report.MainFrames, len(report.Removed), removedBytes(report))
+	}
+
+	err = bufferedWriter.Flush()
+	if err != nil {
+		return err
+	}
+
+	reportFilepath := opts.report
+	if reportFilepath == "" {
+		reportFilepath = siblingFilepath(sourceFilepath, "repair.json")
+	}
+	content, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(reportFilepath, append(content, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s and %s\n", targetFilepath, reportFilepath)
+	return nil
+}
+
+func removedBytes(report blackbox.RepairReport) int64 {
+	total := int64(0)
+	for _, r := range report.Removed {
+		total += r.End - r.Start
+	}
+	return total
+}
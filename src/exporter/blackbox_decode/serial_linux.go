@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// termiosBaudMask is the part of the control flags holding the baud rate (CBAUD)
+const termiosBaudMask = 0x100f
+
+var baudRates = map[int]uint32{
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	57600:  syscall.B57600,
+	115200: syscall.B115200,
+	230400: syscall.B230400,
+	460800: syscall.B460800,
+	921600: syscall.B921600,
+}
+
+// openSerialPort opens a serial port in raw mode, so that no byte gets altered
+func openSerialPort(port string, baud int) (*os.File, error) {
+	speed, ok := baudRates[baud]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported baud rate %d", baud)
+	}
+
+	file, err := os.OpenFile(port, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var termios syscall.Termios
+	if err := ioctl(file, syscall.TCGETS, &termios); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a serial port: %v", port, err)
+	}
+
+	termios.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON | syscall.IXOFF
+	termios.Oflag &^= syscall.OPOST
+	termios.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	termios.Cflag &^= syscall.CSIZE | syscall.PARENB | syscall.CSTOPB | termiosBaudMask
+	termios.Cflag |= syscall.CS8 | syscall.CLOCAL | syscall.CREAD | speed
+	termios.Ispeed = speed
+	termios.Ospeed = speed
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+
+	if err := ioctl(file, syscall.TCSETS, &termios); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Could not configure %s: %v", port, err)
+	}
+	return file, nil
+}
+
+func ioctl(file *os.File, request uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), request, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+// +build !linux
+
+package main
+
+import (
+	"os"
+)
+
+// openSerialPort opens a serial port. Its settings are left untouched: it has to be
+// configured in raw mode beforehand, which USB ports of flight controllers usually don't need.
+func openSerialPort(port string, baud int) (*os.File, error) {
+	return os.OpenFile(port, os.O_RDWR, 0)
+}
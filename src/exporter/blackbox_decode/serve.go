@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/maxlaverse/blackbox-library/src/server"
+	"github.com/spf13/cobra"
+)
+
+type serveOptions struct {
+	listen  string
+	dataDir string
+}
+
+func newServeCommand() *cobra.Command {
+	var opts serveOptions
+
+	cmd := &cobra.Command{
+		Use:   "serve [options]",
+		Short: "Serve an HTTP API to upload, query and export logs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serve(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.listen, "listen", "", ":8080", "Address to listen on")
+	cmd.Flags().StringVarP(&opts.dataDir, "data-dir", "", "logs", "Directory where the uploaded logs are stored")
+	return cmd
+}
+
+func serve(opts serveOptions) error {
+	handler, err := server.NewServer(opts.dataDir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Serving the logs of %s on %s\n", opts.dataDir, opts.listen)
+	return http.ListenAndServe(opts.listen, handler)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+)
+
+// findSessions returns the sessions of a log file
+func findSessions(logFile *os.File) ([]blackbox.LogSession, error) {
+	info, err := logFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return blackbox.FindSessions(logFile, info.Size())
+}
+
+// openSession returns a reader on a single session of a log file, numbered from 1
+func openSession(logFile *os.File, index int) (io.Reader, error) {
+	sessions, err := findSessions(logFile)
+	if err != nil {
+		return nil, err
+	}
+	if index < 1 || index > len(sessions) {
+		return nil, fmt.Errorf("Session %d doesn't exist, the log has %d sessions", index, len(sessions))
+	}
+
+	session := sessions[index-1]
+	return io.NewSectionReader(logFile, session.Start, session.Size()), nil
+}
+
+// sessionFilepath returns the path of a file written next to the source log for a given session,
+// e.g. 'LOG00007.02.csv'
+func sessionFilepath(sourceFilepath string, index int, extension string) string {
+	return siblingFilepath(sourceFilepath, fmt.Sprintf("%02d.%s", index, extension))
+}
+
+// siblingFilepath returns the path of a file written next to the source log, with the extension
+// of the source log replaced by the given suffix
+func siblingFilepath(sourceFilepath string, suffix string) string {
+	filename := path.Base(sourceFilepath)
+	dirpath := path.Dir(sourceFilepath)
+	parts := strings.Split(filename, ".")
+	return path.Join(dirpath, strings.TrimSuffix(filename, parts[len(parts)-1])+suffix)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+	"github.com/spf13/cobra"
+)
+
+type settingsOptions struct {
+	session int
+	output  string
+}
+
+func newSettingsCommand() *cobra.Command {
+	var opts settingsOptions
+
+	cmd := &cobra.Command{
+		Use:   "settings [options] <input log>",
+		Short: "Print the Betaflight CLI commands restoring the configuration recorded in a log",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("You need to provide the path to the log")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return settings(args[0], opts)
+		},
+	}
+
+	cmd.Flags().IntVarP(&opts.session, "session", "", 1, "Session of the log to read the configuration from")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "", "Path of the file to write the commands to (defaults to the standard output)")
+	return cmd
+}
+
+func settings(sourceFilepath string, opts settingsOptions) error {
+	logFile, err := os.Open(sourceFilepath)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	session, err := openSession(logFile, opts.session)
+	if err != nil {
+		return err
+	}
+
+	headerReader := blackbox.NewHeaderReader(stream.NewDecoder(bufio.NewReader(session)))
+	frameDef, err := headerReader.ProcessHeaders()
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	cliSettings, unmapped, err := blackbox.BetaflightSettings(frameDef)
+	if err != nil {
+		return err
+	}
+
+	var target io.Writer = os.Stdout
+	if opts.output != "" {
+		targetFile, err := os.Create(opts.output)
+		if err != nil {
+			return err
+		}
+		defer targetFile.Close()
+		target = targetFile
+	}
+
+	w := bufio.NewWriter(target)
+	fmt.Fprintf(w, "# %s, craft '%s', from session %d of %s\n", frameDef.Firmware, frameDef.CraftName, opts.session, path.Base(sourceFilepath))
+	if len(unmapped) > 0 {
+		fmt.Fprintf(w, "# Headers without a CLI equivalent:\n")
+		for _, header := range unmapped {
+			fmt.Fprintf(w, "#   %s: %s\n", header.Name, header.Value)
+		}
+	}
+	fmt.Fprintln(w)
+	for _, setting := range cliSettings {
+		fmt.Fprintln(w, setting)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "save")
+	return w.Flush()
+}
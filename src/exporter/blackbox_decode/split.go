@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func newSplitCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "split <input log>",
+		Short: "Write every session of a log to its own file",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("You need to provide the path to the log")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return split(args[0])
+		},
+	}
+}
+
+func split(sourceFilepath string) error {
+	logFile, err := os.Open(sourceFilepath)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	sessions, err := findSessions(logFile)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		targetFilepath := sessionFilepath(sourceFilepath, session.Index, "bfl")
+		err := copySession(io.NewSectionReader(logFile, session.Start, session.Size()), targetFilepath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Log %d of %d: wrote %s (%d bytes)\n", session.Index, len(sessions), targetFilepath, session.Size())
+	}
+	return nil
+}
+
+func copySession(session io.Reader, targetFilepath string) error {
+	targetFile, err := os.Create(targetFilepath)
+	if err != nil {
+		return err
+	}
+	defer targetFile.Close()
+
+	_, err = io.Copy(targetFile, session)
+	return err
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/spf13/cobra"
+)
+
+type trimOptions struct {
+	from    time.Duration
+	to      time.Duration
+	session int
+	output  string
+}
+
+func newTrimCommand() *cobra.Command {
+	var opts trimOptions
+
+	cmd := &cobra.Command{
+		Use:   "trim [options] <input log>",
+		Short: "Write the frames of a time range to a new log",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("You need to provide the path to the log")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return trim(args[0], opts)
+		},
+	}
+
+	cmd.Flags().VarP((*flightTimeValue)(&opts.from), "from", "", "Keep the main frames from this time after the first one, as mm:ss[.fff], hh:mm:ss or a duration")
+	cmd.Flags().VarP((*flightTimeValue)(&opts.to), "to", "", "Keep the main frames until this time after the first one (0 until the end)")
+	cmd.Flags().IntVarP(&opts.session, "session", "", 1, "Session of the log to trim")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "", "Path of the new log (defaults to <input>.<session>.trim.bfl)")
+	return cmd
+}
+
+func trim(sourceFilepath string, opts trimOptions) error {
+	logFile, err := os.Open(sourceFilepath)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	session, err := openSession(logFile, opts.session)
+	if err != nil {
+		return err
+	}
+
+	targetFilepath := opts.output
+	if targetFilepath == "" {
+		targetFilepath = sessionFilepath(sourceFilepath, opts.session, "trim.bfl")
+	}
+	targetFile, err := os.Create(targetFilepath)
+	if err != nil {
+		return err
+	}
+	defer targetFile.Close()
+
+	result, err := blackbox.Trim(session, targetFile, blackbox.TrimOpts{From: opts.from, To: opts.to})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s: start %s, end %s, %d main frames, %d bytes\n", targetFilepath, formatFlightTime(result.Start), formatFlightTime(result.End), result.MainFrames, result.Bytes)
+	return nil
+}
+
+func formatFlightTime(d time.Duration) string {
+	return time.Unix(0, d.Nanoseconds()).Format("04:05.000")
+}
+
+// flightTimeValue is a flag holding a time in a flight, given as mm:ss[.fff], hh:mm:ss
+// or a duration
+type flightTimeValue time.Duration
+
+func (v *flightTimeValue) Set(value string) error {
+	d, err := blackbox.ParseFlightTime(value)
+	if err != nil {
+		return err
+	}
+	*v = flightTimeValue(d)
+	return nil
+}
+
+func (v *flightTimeValue) String() string {
+	if *v == 0 {
+		return "0"
+	}
+	return formatFlightTime(time.Duration(*v))
+}
+
+func (v *flightTimeValue) Type() string {
+	return "time"
+}
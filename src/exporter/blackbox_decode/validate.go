@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// exitCodeInvalidLog is returned when a log is decoded but doesn't pass the validation.
+	// Any other failure exits with 1.
+	exitCodeInvalidLog = 2
+)
+
+type validateOptions struct {
+	json   bool
+	strict bool
+}
+
+// sessionValidationReport is the part of the validation report about one session, with offsets
+// relative to the start of the file
+type sessionValidationReport struct {
+	Index int   `json:"session"`
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Valid bool  `json:"valid"`
+	blackbox.ValidationReport
+}
+
+func newValidateCommand() *cobra.Command {
+	var opts validateOptions
+
+	cmd := &cobra.Command{
+		Use:   "validate [options] <input log>",
+		Short: "Check a log for inconsistencies, exits with 2 if any is found",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("You need to provide the path to the log")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			valid, err := validate(args[0], opts)
+			if err != nil {
+				return err
+			}
+			if !valid {
+				os.Exit(exitCodeInvalidLog)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.json, "json", "", false, "Print the report as JSON")
+	cmd.Flags().BoolVarP(&opts.strict, "strict", "", false, "Consider warnings as errors")
+	return cmd
+}
+
+func validate(sourceFilepath string, opts validateOptions) (bool, error) {
+	logFile, err := os.Open(sourceFilepath)
+	if err != nil {
+		return false, err
+	}
+	defer logFile.Close()
+
+	sessions, err := findSessions(logFile)
+	if err != nil {
+		return false, err
+	}
+
+	valid := true
+	reports := []sessionValidationReport{}
+	for _, session := range sessions {
+		report := blackbox.Validate(io.NewSectionReader(logFile, session.Start, session.Size()))
+		for i := range report.Issues {
+			report.Issues[i].Offset += session.Start
+		}
+
+		sessionValid := report.Valid(opts.strict)
+		valid = valid && sessionValid
+		reports = append(reports, sessionValidationReport{Index: session.Index, Start: session.Start, End: session.End, Valid: sessionValid, ValidationReport: report})
+	}
+
+	if opts.json {
+		content, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return false, err
+		}
+		fmt.Println(string(content))
+		return valid, nil
+	}
+
+	for _, report := range reports {
+		status := "valid"
+		if !report.Valid {
+			status = "invalid"
+		}
+		fmt.Printf("Log %d of %d: %s, %d errors, %d warnings\n", report.Index, len(reports), status, report.Count(blackbox.SeverityError), report.Count(blackbox.SeverityWarning))
+		for _, issue := range report.Issues {
+			fmt.Printf("  %s\n", issue)
+		}
+	}
+	return valid, nil
+}
@@ -2,10 +2,8 @@ package exporter
 
 import (
 	"time"
-)
 
-const (
-	adcVref = 33.0
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
 )
 
 type batteryState struct {
@@ -13,13 +11,11 @@ type batteryState struct {
 	energyMilliampHours float64
 	voltageVolt         float64
 	lastTime            int64
-	currentScale        int32
-	currentOffset       int64
-	vbatScale           int64
+	frameDef            blackbox.LogDefinition
 }
 
 func (b *batteryState) setLatestAmperage(value int64, newTime int64) {
-	b.currentAmps = float64(value*adcVref*100/4095-b.currentOffset) * 10 / float64(b.currentScale)
+	b.currentAmps = b.frameDef.AmperageToAmps(value)
 	if b.lastTime != 0.0 {
 		b.energyMilliampHours += b.currentAmps * float64(newTime-b.lastTime) / time.Hour.Seconds() / 1000
 	}
@@ -27,6 +23,15 @@ func (b *batteryState) setLatestAmperage(value int64, newTime int64) {
 }
 
 func (b *batteryState) setLatestVbat(value int64) {
-	// ADC is 12 bit (i.e. max 0xFFF), voltage reference is 3.3V, vbatscale is premultiplied by 100
-	b.voltageVolt = float64(value*adcVref*b.vbatScale) / 4095 / 100.0
+	b.voltageVolt = b.frameDef.VbatToVolts(value)
+}
+
+// mainFrameTime returns the time of a main frame, or 0 if the time isn't one of its
+// fields, in which case no energy is accumulated
+func mainFrameTime(frameDef blackbox.LogDefinition, values []int64) int64 {
+	timeFieldIndex, err := frameDef.GetFieldIndex(blackbox.FieldTime)
+	if err != nil {
+		return 0
+	}
+	return values[timeFieldIndex]
 }
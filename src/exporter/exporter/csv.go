@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"strconv"
 	"strings"
 
 	"github.com/maxlaverse/blackbox-library/src/blackbox"
@@ -13,7 +14,9 @@ import (
 var fieldUnits = map[blackbox.FieldName]string{
 	blackbox.FieldTime:             "us",
 	blackbox.FieldVbatLatest:       "V",
+	blackbox.FieldVbat:             "V",
 	blackbox.FieldAmperageLatest:   "A",
+	blackbox.FieldAmperage:         "A",
 	blackbox.FieldEnergyCumulative: "mAh",
 	blackbox.FieldFlightModeFlags:  "flags",
 	blackbox.FieldStateFlags:       "flags",
@@ -30,22 +33,46 @@ type CsvFrameExporter struct {
 	batteryState   batteryState
 }
 
+func init() {
+	Register(Format{
+		Name:        "csv",
+		Extension:   "csv",
+		ContentType: "text/csv",
+		New: func(w io.Writer, opts Options) Exporter {
+			return NewCsvFrameExporter(w, opts.Debug, blackbox.LogDefinition{})
+		},
+	})
+}
+
 // NewCsvFrameExporter returns a new CsvFrameExporter
 func NewCsvFrameExporter(file io.Writer, debugMode bool, frameDef blackbox.LogDefinition) *CsvFrameExporter {
+	e := &CsvFrameExporter{
+		target:    file,
+		debugMode: debugMode,
+	}
+	e.setDefinition(frameDef)
+	return e
+}
+
+// Begin sets the definition of the log and writes the headers
+func (e *CsvFrameExporter) Begin(frameDef blackbox.LogDefinition) error {
+	e.setDefinition(frameDef)
+	return e.WriteHeaders()
+}
+
+// End does nothing, CSV files have no footer
+func (e *CsvFrameExporter) End(stats *blackbox.LogStatistics) error {
+	return nil
+}
+
+func (e *CsvFrameExporter) setDefinition(frameDef blackbox.LogDefinition) {
 	_, err := frameDef.GetFieldIndex(blackbox.FieldAmperageLatest)
-	hasAmperageAdc := err == nil
-
-	return &CsvFrameExporter{
-		target:         file,
-		lastSlowFrame:  blackbox.NewSlowFrame([]int64{0, 0, 0, 0, 0}, 0, 0, nil),
-		debugMode:      debugMode,
-		frameDef:       frameDef,
-		hasAmperageAdc: hasAmperageAdc,
-		batteryState: batteryState{
-			currentOffset: int64(frameDef.Sysconfig.CurrentMeterOffset),
-			currentScale:  int32(frameDef.Sysconfig.CurrentMeterScale),
-			vbatScale:     int64(frameDef.Sysconfig.Vbatscale),
-		},
+
+	e.frameDef = frameDef
+	e.hasAmperageAdc = err == nil
+	e.lastSlowFrame = blackbox.NewSlowFrame([]int64{0, 0, 0, 0, 0}, 0, 0, nil)
+	e.batteryState = batteryState{
+		frameDef: frameDef,
 	}
 }
 
@@ -53,7 +80,7 @@ func NewCsvFrameExporter(file io.Writer, debugMode bool, frameDef blackbox.LogDe
 func (e *CsvFrameExporter) WriteHeaders() error {
 	var headers []string
 	for _, f := range e.frameDef.FieldsI {
-		headers = append(headers, fieldWithUnit(f.Name))
+		headers = append(headers, e.columnName(f.Name))
 	}
 	if e.hasAmperageAdc {
 		headers = append(headers, fieldWithUnit(blackbox.FieldEnergyCumulative))
@@ -115,17 +142,20 @@ func (e *CsvFrameExporter) WriteFrame(frame blackbox.Frame) error {
 func (e *CsvFrameExporter) friendlyMainFrameValues(valuesS []int64) []string {
 	var values []string
 	for k, v := range valuesS {
-		if i, _ := e.frameDef.GetFieldIndex(blackbox.FieldVbatLatest); k == i {
+		if i, err := e.frameDef.GetFieldIndex(blackbox.FieldVbatLatest); err == nil && k == i {
 			e.batteryState.setLatestVbat(v)
 			values = append(values, prependSpaceForField(k, fmt.Sprintf("%.3f", math.Floor(e.batteryState.voltageVolt*1000)/1000)))
 			continue
 		}
-		if i, _ := e.frameDef.GetFieldIndex(blackbox.FieldAmperageLatest); k == i {
-			timeFieldIndex, _ := e.frameDef.GetFieldIndex(blackbox.FieldTime)
-			e.batteryState.setLatestAmperage(v, valuesS[timeFieldIndex])
+		if i, err := e.frameDef.GetFieldIndex(blackbox.FieldAmperageLatest); err == nil && k == i {
+			e.batteryState.setLatestAmperage(v, mainFrameTime(e.frameDef, valuesS))
 			values = append(values, prependSpaceForField(k, fmt.Sprintf("%.3f", e.batteryState.currentAmps)))
 			continue
 		}
+		if k < len(e.frameDef.FieldsI) && e.frameDef.FieldsI[k].Decimals > 0 {
+			values = append(values, prependSpaceForField(k, strconv.FormatFloat(e.frameDef.FieldsI[k].Value(v), 'f', -1, 64)))
+			continue
+		}
 		values = append(values, prependSpaceForField(k, fmt.Sprintf("%d", v)))
 	}
 
@@ -148,6 +178,18 @@ func (e *CsvFrameExporter) writeLn(data string) error {
 	return e.writeBytes(append([]byte(data), 10))
 }
 
+// columnName returns the header of a field's column. Debug fields are followed by what they hold, when known.
+func (e *CsvFrameExporter) columnName(name blackbox.FieldName) string {
+	slot, ok := e.frameDef.DebugSlot(name)
+	if !ok {
+		return fieldWithUnit(name)
+	}
+	if slot.Unit == "" {
+		return fmt.Sprintf("%s %s", name, slot.Alias)
+	}
+	return fmt.Sprintf("%s %s (%s)", name, slot.Alias, slot.Unit)
+}
+
 func fieldWithUnit(name blackbox.FieldName) string {
 	v, ok := fieldUnits[name]
 	if !ok {
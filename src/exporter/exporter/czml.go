@@ -0,0 +1,174 @@
+package exporter
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	Register(Format{
+		Name:        "czml",
+		Extension:   "czml",
+		ContentType: "application/json",
+		New: func(w io.Writer, opts Options) Exporter {
+			return NewCZMLExporter(w)
+		},
+	})
+}
+
+// CZMLExporter writes the path of the craft as a CZML document, for Cesium to replay the
+// flight on a globe. The craft has position keyframes at every GPS frame, and orientation
+// keyframes when the log has an attitude. Models are expected to face their X axis with
+// their Z axis up, like Cesium does for glTF models.
+type CZMLExporter struct {
+	target     io.Writer
+	trajectory trajectory
+}
+
+// NewCZMLExporter returns a new CZMLExporter
+func NewCZMLExporter(file io.Writer) *CZMLExporter {
+	return &CZMLExporter{target: file}
+}
+
+// Begin sets the definition of the log
+func (e *CZMLExporter) Begin(frameDef blackbox.LogDefinition) error {
+	e.trajectory.setDefinition(frameDef)
+	return nil
+}
+
+// WriteFrame adds the position of GPS frames to the path
+func (e *CZMLExporter) WriteFrame(frame blackbox.Frame) error {
+	e.trajectory.add(frame)
+	return nil
+}
+
+// End writes the document, made of a packet describing the clock and one for the craft
+func (e *CZMLExporter) End(stats *blackbox.LogStatistics) error {
+	name := e.trajectory.frameDef.CraftName
+	if name == "" {
+		name = "Craft"
+	}
+
+	// Times are relative to the start of the log. Cesium needs a date, a fixed one is used
+	// when the flight controller didn't know it.
+	epoch, ok := e.trajectory.startTime()
+	if !ok {
+		epoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	points := e.trajectory.points
+	document := orderedObject{}
+	document.set("id", "document")
+	document.set("name", name)
+	document.set("version", "1.0")
+	if len(points) == 0 {
+		return e.write([]interface{}{document})
+	}
+
+	interval := czmlTime(epoch, points[0].Time) + "/" + czmlTime(epoch, points[len(points)-1].Time)
+	clock := orderedObject{}
+	clock.set("interval", interval)
+	clock.set("currentTime", czmlTime(epoch, points[0].Time))
+	clock.set("multiplier", 1)
+	document.set("clock", clock)
+
+	positions, orientations := []float64{}, []float64{}
+	for _, point := range points {
+		positions = append(positions, point.Time, point.Longitude, point.Latitude, point.Altitude)
+		if point.Roll != nil {
+			q := orientationOf(point)
+			orientations = append(orientations, point.Time, q[0], q[1], q[2], q[3])
+		}
+	}
+
+	position := orderedObject{}
+	position.set("epoch", czmlTime(epoch, 0))
+	position.set("cartographicDegrees", positions)
+
+	path := orderedObject{}
+	path.set("width", 2)
+	path.set("leadTime", 0)
+
+	craft := orderedObject{}
+	craft.set("id", "craft")
+	craft.set("name", name)
+	craft.set("availability", interval)
+	craft.set("position", position)
+	if len(orientations) > 0 {
+		orientation := orderedObject{}
+		orientation.set("epoch", czmlTime(epoch, 0))
+		orientation.set("unitQuaternion", orientations)
+		craft.set("orientation", orientation)
+	}
+	craft.set("path", path)
+	return e.write([]interface{}{document, craft})
+}
+
+func (e *CZMLExporter) write(packets []interface{}) error {
+	data, err := json.Marshal(packets)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = e.target.Write(append(data, '\n'))
+	return errors.Wrap(err, "could not write the CZML document to target file")
+}
+
+// czmlTime returns the ISO 8601 date of a number of seconds after the epoch
+func czmlTime(epoch time.Time, seconds float64) string {
+	return epoch.Add(time.Duration(seconds * float64(time.Second))).Format(time.RFC3339Nano)
+}
+
+// orientationOf returns the rotation from the axes of the craft to the Earth-fixed frame
+// CZML orientations are given in, as a quaternion X, Y, Z, W
+func orientationOf(point trajectoryPoint) [4]float64 {
+	roll, pitch, yaw := *point.Roll*math.Pi/180, *point.Pitch*math.Pi/180, *point.Yaw*math.Pi/180
+	cr, sr := math.Cos(roll), math.Sin(roll)
+	cp, sp := math.Cos(pitch), math.Sin(pitch)
+	cy, sy := math.Cos(yaw), math.Sin(yaw)
+
+	// Axes of the craft in the north-east-down frame, flipped from forward-right-down to
+	// forward-left-up for the model
+	forward := [3]float64{cp * cy, cp * sy, -sp}
+	left := [3]float64{-(sr*sp*cy - cr*sy), -(sr*sp*sy + cr*cy), -sr * cp}
+	up := [3]float64{-(cr*sp*cy + sr*sy), -(cr*sp*sy - sr*cy), -cr * cp}
+
+	// North, east and down directions in the Earth-fixed frame
+	lat, lon := point.Latitude*math.Pi/180, point.Longitude*math.Pi/180
+	north := [3]float64{-math.Sin(lat) * math.Cos(lon), -math.Sin(lat) * math.Sin(lon), math.Cos(lat)}
+	east := [3]float64{-math.Sin(lon), math.Cos(lon), 0}
+	down := [3]float64{-math.Cos(lat) * math.Cos(lon), -math.Cos(lat) * math.Sin(lon), -math.Sin(lat)}
+
+	// Columns of the rotation matrix are the axes of the craft in the Earth-fixed frame
+	m := [3][3]float64{}
+	for i, axis := range [][3]float64{forward, left, up} {
+		for row := 0; row < 3; row++ {
+			m[row][i] = axis[0]*north[row] + axis[1]*east[row] + axis[2]*down[row]
+		}
+	}
+	return quaternionOf(m)
+}
+
+// quaternionOf returns the unit quaternion X, Y, Z, W of a rotation matrix
+func quaternionOf(m [3][3]float64) [4]float64 {
+	var x, y, z, w float64
+	switch trace := m[0][0] + m[1][1] + m[2][2]; {
+	case trace > 0:
+		s := 2 * math.Sqrt(trace+1)
+		w, x, y, z = s/4, (m[2][1]-m[1][2])/s, (m[0][2]-m[2][0])/s, (m[1][0]-m[0][1])/s
+	case m[0][0] > m[1][1] && m[0][0] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[0][0]-m[1][1]-m[2][2])
+		w, x, y, z = (m[2][1]-m[1][2])/s, s/4, (m[0][1]+m[1][0])/s, (m[0][2]+m[2][0])/s
+	case m[1][1] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[1][1]-m[0][0]-m[2][2])
+		w, x, y, z = (m[0][2]-m[2][0])/s, (m[0][1]+m[1][0])/s, s/4, (m[1][2]+m[2][1])/s
+	default:
+		s := 2 * math.Sqrt(1+m[2][2]-m[0][0]-m[1][1])
+		w, x, y, z = (m[1][0]-m[0][1])/s, (m[0][2]+m[2][0])/s, (m[1][2]+m[2][1])/s, s/4
+	}
+	return [4]float64{x, y, z, w}
+}
@@ -0,0 +1,76 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCZML(t *testing.T) {
+	frameDef, frameChan, logFile := readFixture(t, "inav_synthetic.bfl", blackbox.FlightLogReaderOpts{})
+	defer logFile.Close()
+
+	var buffer bytes.Buffer
+	czmlExporter := NewCZMLExporter(&buffer)
+	assert.NoError(t, czmlExporter.Begin(frameDef))
+	for frame := range frameChan {
+		assert.NoError(t, czmlExporter.WriteFrame(frame))
+	}
+	assert.NoError(t, czmlExporter.End(nil))
+
+	packets := []struct {
+		ID       string
+		Clock    map[string]interface{}
+		Position struct {
+			Epoch               string
+			CartographicDegrees []float64
+		}
+		Orientation struct {
+			UnitQuaternion []float64
+		}
+	}{}
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &packets))
+	assert.Len(t, packets, 2)
+	assert.Equal(t, "document", packets[0].ID)
+	assert.Equal(t, "2021-05-02T14:31:07.00031Z", packets[0].Clock["currentTime"])
+	assert.Equal(t, "craft", packets[1].ID)
+	assert.Equal(t, "2021-05-02T14:31:07Z", packets[1].Position.Epoch)
+	assert.Len(t, packets[1].Position.CartographicDegrees, 6*4)
+	assert.InDelta(t, 8.5455939, packets[1].Position.CartographicDegrees[1], 1e-9)
+	assert.InDelta(t, 47.398191, packets[1].Position.CartographicDegrees[2], 1e-9)
+	assert.Len(t, packets[1].Orientation.UnitQuaternion, 6*5)
+}
+
+func TestOrientation(t *testing.T) {
+	angle := func(degrees float64) *float64 { return &degrees }
+
+	// Level and facing north on the equator, the nose points to the north pole
+	q := orientationOf(trajectoryPoint{Roll: angle(0), Pitch: angle(0), Yaw: angle(0)})
+	assertRotation(t, q, [3]float64{1, 0, 0}, [3]float64{0, 0, 1})
+	assertRotation(t, q, [3]float64{0, 0, 1}, [3]float64{1, 0, 0})
+
+	// Facing east, rolled right by 90 degrees, the left wing points up
+	q = orientationOf(trajectoryPoint{Roll: angle(90), Pitch: angle(0), Yaw: angle(90)})
+	assertRotation(t, q, [3]float64{1, 0, 0}, [3]float64{0, 1, 0})
+	assertRotation(t, q, [3]float64{0, 1, 0}, [3]float64{1, 0, 0})
+
+	// Pitched up by 90 degrees at the north pole, the nose points to space
+	q = orientationOf(trajectoryPoint{Latitude: 90, Roll: angle(0), Pitch: angle(90), Yaw: angle(0)})
+	assertRotation(t, q, [3]float64{1, 0, 0}, [3]float64{0, 0, 1})
+}
+
+// assertRotation checks that the quaternion X, Y, Z, W rotates a vector into the expected one
+func assertRotation(t *testing.T, q [4]float64, v, expected [3]float64) {
+	x, y, z, w := q[0], q[1], q[2], q[3]
+	rotated := [3]float64{
+		(1-2*(y*y+z*z))*v[0] + 2*(x*y-w*z)*v[1] + 2*(x*z+w*y)*v[2],
+		2*(x*y+w*z)*v[0] + (1-2*(x*x+z*z))*v[1] + 2*(y*z-w*x)*v[2],
+		2*(x*z-w*y)*v[0] + 2*(y*z+w*x)*v[1] + (1-2*(x*x+y*y))*v[2],
+	}
+	for i := range rotated {
+		assert.InDelta(t, expected[i], rotated[i], 1e-9)
+	}
+}
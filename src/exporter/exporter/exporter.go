@@ -0,0 +1,127 @@
+// Package exporter writes decoded flight logs to files. Every format is an Exporter
+// registered under a name, which the tools look up instead of knowing every format.
+package exporter
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/pkg/errors"
+)
+
+// Exporter writes the frames of a log in a given format
+type Exporter interface {
+	// Begin is called once the headers of the log have been read, before any frame
+	Begin(frameDef blackbox.LogDefinition) error
+
+	// WriteFrame is called for every frame, in the order they were decoded
+	WriteFrame(frame blackbox.Frame) error
+
+	// End is called once every frame was written. stats can be nil if they are not known.
+	End(stats *blackbox.LogStatistics) error
+}
+
+// Options are the options every format accepts
+type Options struct {
+	// Debug writes extra information, like corrupted frames and the position of frames
+	Debug bool
+}
+
+// Format is an output format
+type Format struct {
+	// Name is how the format is selected, like csv
+	Name string
+
+	// Extension is the extension of the files in this format, without the dot
+	Extension string
+
+	// ContentType is the media type of the format, when served over HTTP
+	ContentType string
+
+	// New returns an Exporter writing to w
+	New func(w io.Writer, opts Options) Exporter
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   = map[string]Format{}
+)
+
+// Register makes a format available. It panics if a format with the same name
+// is already registered.
+func Register(format Format) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	if _, found := formats[format.Name]; found {
+		panic("exporter: format " + format.Name + " registered twice")
+	}
+	formats[format.Name] = format
+}
+
+// Lookup returns a registered format
+func Lookup(name string) (Format, error) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	format, found := formats[name]
+	if !found {
+		return Format{}, errors.Errorf("Unknown format '%s', expected one of %s", name, strings.Join(formatNames(), ", "))
+	}
+	return format, nil
+}
+
+// Formats returns the names of the registered formats, sorted
+func Formats() []string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	return formatNames()
+}
+
+func formatNames() []string {
+	names := []string{}
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// multiExporter writes every frame to several exporters
+type multiExporter []Exporter
+
+// NewMultiExporter returns an Exporter writing to all the given exporters, so that a log
+// is decoded once for all of them. It stops at the first error.
+func NewMultiExporter(exporters ...Exporter) Exporter {
+	return multiExporter(exporters)
+}
+
+func (m multiExporter) Begin(frameDef blackbox.LogDefinition) error {
+	for _, exporter := range m {
+		if err := exporter.Begin(frameDef); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiExporter) WriteFrame(frame blackbox.Frame) error {
+	for _, exporter := range m {
+		if err := exporter.WriteFrame(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiExporter) End(stats *blackbox.LogStatistics) error {
+	for _, exporter := range m {
+		if err := exporter.End(stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
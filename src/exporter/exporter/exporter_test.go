@@ -0,0 +1,88 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisteredFormats(t *testing.T) {
+	assert.Equal(t, []string{"csv", "czml", "json", "ndjson", "parquet", "trajectory"}, Formats())
+
+	format, err := Lookup("ndjson")
+	assert.NoError(t, err)
+	assert.Equal(t, "ndjson", format.Extension)
+
+	_, err = Lookup("xlsx")
+	assert.EqualError(t, err, "Unknown format 'xlsx', expected one of csv, czml, json, ndjson, parquet, trajectory")
+
+	assert.Panics(t, func() {
+		Register(Format{Name: "csv"})
+	})
+}
+
+func TestMultiExporter(t *testing.T) {
+	frameDef, frameChan, logFile := readFixture(t, "normal.bfl", blackbox.FlightLogReaderOpts{})
+	defer logFile.Close()
+
+	outputs := map[string]*bytes.Buffer{}
+	exporters := []Exporter{}
+	for _, name := range Formats() {
+		format, err := Lookup(name)
+		assert.NoError(t, err)
+
+		outputs[name] = &bytes.Buffer{}
+		exporters = append(exporters, format.New(outputs[name], Options{}))
+	}
+
+	multiExporter := NewMultiExporter(exporters...)
+	assert.NoError(t, multiExporter.Begin(frameDef))
+	for frame := range frameChan {
+		assert.NoError(t, multiExporter.WriteFrame(frame))
+	}
+	assert.NoError(t, multiExporter.End(nil))
+
+	assert.Equal(t, 6, bytes.Count(outputs["csv"].Bytes(), []byte("\n")))
+	assert.Equal(t, 5, bytes.Count(outputs["ndjson"].Bytes(), []byte("\n")))
+
+	document := struct {
+		Log    map[string]interface{}
+		Frames []map[string]interface{}
+		Stats  map[string]interface{}
+	}{}
+	assert.NoError(t, json.Unmarshal(outputs["json"].Bytes(), &document))
+	assert.Equal(t, "Blackbox flight data recorder by Nicholas Sherlock", document.Log["product"])
+	assert.Len(t, document.Frames, 5)
+	assert.Equal(t, float64(52996), document.Frames[4]["loopIteration"])
+	assert.Nil(t, document.Stats)
+}
+
+func TestJSONStats(t *testing.T) {
+	flightLog := blackbox.NewFlightLogReader(blackbox.FlightLogReaderOpts{})
+	logFile, err := os.Open("../../../fixtures/normal.bfl")
+	assert.NoError(t, err)
+	defer logFile.Close()
+
+	frameChan, err := flightLog.LoadFile(context.Background(), logFile)
+	assert.NoError(t, err)
+
+	var buffer bytes.Buffer
+	jsonExporter := NewJSONFrameExporter(&buffer, false)
+	assert.NoError(t, jsonExporter.Begin(flightLog.FrameDef))
+	for frame := range frameChan {
+		assert.NoError(t, jsonExporter.WriteFrame(frame))
+	}
+	assert.NoError(t, jsonExporter.End(flightLog.Stats))
+
+	document := struct {
+		Stats map[string]interface{}
+	}{}
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &document))
+	assert.Equal(t, float64(10), document.Stats["totalFrames"])
+	assert.Equal(t, float64(55160009), document.Stats["end"])
+}
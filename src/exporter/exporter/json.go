@@ -0,0 +1,115 @@
+package exporter
+
+import (
+	"io"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	Register(Format{
+		Name:        "json",
+		Extension:   "json",
+		ContentType: "application/json",
+		New: func(w io.Writer, opts Options) Exporter {
+			return NewJSONFrameExporter(w, opts.Debug)
+		},
+	})
+}
+
+// JSONFrameExporter transforms a FlightLog into a single JSON document holding the
+// description of the log, its frames as NDJSON would write them, and its statistics
+type JSONFrameExporter struct {
+	target  io.Writer
+	objects frameObjects
+	frames  int
+}
+
+// NewJSONFrameExporter returns a new JSONFrameExporter
+func NewJSONFrameExporter(file io.Writer, debugMode bool) *JSONFrameExporter {
+	return &JSONFrameExporter{
+		target:  file,
+		objects: frameObjects{debugMode: debugMode},
+	}
+}
+
+// Begin writes the description of the log
+func (e *JSONFrameExporter) Begin(frameDef blackbox.LogDefinition) error {
+	e.objects.setDefinition(frameDef)
+
+	headers := orderedObject{}
+	for _, header := range frameDef.Headers {
+		headers.set(string(header.Name), header.Value)
+	}
+
+	fields := []string{}
+	for _, field := range frameDef.FieldsI {
+		fields = append(fields, string(field.Name))
+	}
+
+	log := orderedObject{}
+	log.set("product", frameDef.Product)
+	log.set("firmware", frameDef.Firmware.String())
+	log.set("craftName", frameDef.CraftName)
+	log.set("logStartDatetime", frameDef.LogStartDatetime)
+	log.set("fields", fields)
+	log.set("headers", headers)
+
+	data, err := log.MarshalJSON()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return e.write(`{"log":`, string(data), `,"frames":[`)
+}
+
+// WriteFrame writes a main frame as a JSON object, like NdjsonFrameExporter
+func (e *JSONFrameExporter) WriteFrame(frame blackbox.Frame) error {
+	object, ok := e.objects.of(frame)
+	if !ok {
+		return nil
+	}
+
+	data, err := object.MarshalJSON()
+	if err != nil {
+		return errors.Wrapf(err, "could not write frame '%s' to target file", string(frame.Type()))
+	}
+
+	separator := ","
+	if e.frames == 0 {
+		separator = ""
+	}
+	e.frames++
+	return errors.Wrapf(e.write(separator, "\n", string(data)), "could not write frame '%s' to target file", string(frame.Type()))
+}
+
+// End writes the statistics of the log and closes the document
+func (e *JSONFrameExporter) End(stats *blackbox.LogStatistics) error {
+	if stats == nil {
+		return e.write("\n],\"stats\":null}\n")
+	}
+
+	object := orderedObject{}
+	object.set("totalFrames", stats.TotalFrames)
+	object.set("corruptedFrames", stats.TotalCorruptedFrames)
+	object.set("headerBytes", stats.HeaderBytes)
+	object.set("bytes", stats.Bytes)
+	object.set("corruptedBytes", stats.CorruptedBytes)
+	object.set("start", stats.Start.UnixNano()/1000)
+	object.set("end", stats.End.UnixNano()/1000)
+
+	data, err := object.MarshalJSON()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return e.write("\n],\"stats\":", string(data), "}\n")
+}
+
+func (e *JSONFrameExporter) write(parts ...string) error {
+	for _, part := range parts {
+		if _, err := io.WriteString(e.target, part); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	Register(Format{
+		Name:        "ndjson",
+		Extension:   "ndjson",
+		ContentType: "application/x-ndjson",
+		New: func(w io.Writer, opts Options) Exporter {
+			return NewNdjsonFrameExporter(w, opts.Debug, blackbox.LogDefinition{})
+		},
+	})
+}
+
+// NdjsonFrameExporter transforms a FlightLog into newline-delimited JSON, with one
+// object per main frame. The fields keep the order of the log.
+type NdjsonFrameExporter struct {
+	target  io.Writer
+	objects frameObjects
+}
+
+// NewNdjsonFrameExporter returns a new NdjsonFrameExporter
+func NewNdjsonFrameExporter(file io.Writer, debugMode bool, frameDef blackbox.LogDefinition) *NdjsonFrameExporter {
+	e := &NdjsonFrameExporter{
+		target:  file,
+		objects: frameObjects{debugMode: debugMode},
+	}
+	e.objects.setDefinition(frameDef)
+	return e
+}
+
+// Begin sets the definition of the log
+func (e *NdjsonFrameExporter) Begin(frameDef blackbox.LogDefinition) error {
+	e.objects.setDefinition(frameDef)
+	return nil
+}
+
+// WriteFrame writes a main frame as a JSON object. In debug mode, events and frames
+// with errors are written too, and objects tell where the frame was in the log.
+func (e *NdjsonFrameExporter) WriteFrame(frame blackbox.Frame) error {
+	object, ok := e.objects.of(frame)
+	if !ok {
+		return nil
+	}
+
+	data, err := object.MarshalJSON()
+	if err == nil {
+		_, err = e.target.Write(append(data, '\n'))
+	}
+	return errors.Wrapf(err, "could not write frame '%s' to target file", string(frame.Type()))
+}
+
+// End does nothing, NDJSON files have no footer
+func (e *NdjsonFrameExporter) End(stats *blackbox.LogStatistics) error {
+	return nil
+}
+
+// frameObjects turns frames into JSON objects, with the values of main frames converted
+// like in CSV files
+type frameObjects struct {
+	lastSlowFrame  *blackbox.SlowFrame
+	debugMode      bool
+	hasAmperageAdc bool
+	frameDef       blackbox.LogDefinition
+	batteryState   batteryState
+}
+
+func (o *frameObjects) setDefinition(frameDef blackbox.LogDefinition) {
+	_, err := frameDef.GetFieldIndex(blackbox.FieldAmperageLatest)
+
+	o.frameDef = frameDef
+	o.hasAmperageAdc = err == nil
+	o.lastSlowFrame = blackbox.NewSlowFrame([]int64{0, 0, 0, 0, 0}, 0, 0, nil)
+	o.batteryState = batteryState{
+		frameDef: frameDef,
+	}
+}
+
+// of returns the object of a frame, or false if the frame isn't exported
+func (o *frameObjects) of(frame blackbox.Frame) (orderedObject, bool) {
+	object := orderedObject{}
+
+	if frame.Error() != nil {
+		if !o.debugMode {
+			return object, false
+		}
+		object.set("error", frame.Error().Error())
+		o.setPosition(&object, frame)
+		return object, true
+	}
+
+	switch frame.(type) {
+	case *blackbox.EventFrame:
+		if !o.debugMode {
+			return object, false
+		}
+		object.set("event", frame.Values())
+		o.setPosition(&object, frame)
+
+	case *blackbox.SlowFrame:
+		o.lastSlowFrame = frame.(*blackbox.SlowFrame)
+		return object, false
+
+	case *blackbox.MainFrame:
+		o.setMainFrameValues(&object, frame.Values().([]int64))
+		if o.debugMode {
+			o.setPosition(&object, frame)
+		}
+
+	default:
+		return object, false
+	}
+	return object, true
+}
+
+func (o *frameObjects) setMainFrameValues(object *orderedObject, valuesS []int64) {
+	vbatIndex, vbatErr := o.frameDef.GetFieldIndex(blackbox.FieldVbatLatest)
+	amperageIndex, amperageErr := o.frameDef.GetFieldIndex(blackbox.FieldAmperageLatest)
+
+	for k, v := range valuesS {
+		name := string(o.frameDef.FieldsI[k].Name)
+		switch {
+		case vbatErr == nil && k == vbatIndex:
+			o.batteryState.setLatestVbat(v)
+			object.set(name, o.batteryState.voltageVolt)
+		case amperageErr == nil && k == amperageIndex:
+			o.batteryState.setLatestAmperage(v, mainFrameTime(o.frameDef, valuesS))
+			object.set(name, o.batteryState.currentAmps)
+		case o.frameDef.FieldsI[k].Decimals > 0:
+			object.set(name, o.frameDef.FieldsI[k].Value(v))
+		default:
+			object.set(name, v)
+		}
+	}
+
+	if o.hasAmperageAdc {
+		object.set(string(blackbox.FieldEnergyCumulative), o.batteryState.energyMilliampHours)
+	}
+
+	slowValues := o.lastSlowFrame.StringValues()
+	for k, f := range o.frameDef.FieldsS {
+		if k < len(slowValues) {
+			object.set(string(f.Name), slowValues[k])
+		}
+	}
+}
+
+func (o *frameObjects) setPosition(object *orderedObject, frame blackbox.Frame) {
+	object.set("frame", string(frame.Type()))
+	object.set("offset", frame.Start())
+	object.set("size", frame.Size())
+}
+
+// orderedObject is a JSON object whose keys keep the order they were set in
+type orderedObject struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o *orderedObject) set(key string, value interface{}) {
+	o.keys = append(o.keys, key)
+	o.values = append(o.values, value)
+}
+
+// MarshalJSON returns the JSON encoding of the object
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		keyData, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		valueData, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(keyData)
+		buf.WriteByte(':')
+		buf.Write(valueData)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
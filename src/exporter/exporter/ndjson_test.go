@@ -0,0 +1,71 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNdjsonValues(t *testing.T) {
+	frameDef, frameChan, logFile := readFixture(t, "normal.bfl", blackbox.FlightLogReaderOpts{Raw: false})
+	defer logFile.Close()
+
+	var buffer bytes.Buffer
+	ndjsonExporter := NewNdjsonFrameExporter(&buffer, false, frameDef)
+	for frame := range frameChan {
+		assert.NoError(t, ndjsonExporter.WriteFrame(frame))
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	assert.Len(t, lines, 5)
+	assert.True(t, strings.HasPrefix(lines[1], `{"loopIteration":52993,"time":55158507,"axisP[0]":0,`))
+	assert.Contains(t, lines[1], `"vbatLatest":16.07,"amperageLatest":7.55,"rssi":785,`)
+	assert.True(t, strings.HasSuffix(lines[1], `"flightModeFlags":"ARM|BLACKBOX","stateFlags":"SMALL_ANGLE","failsafePhase":"IDLE","rxSignalReceived":"1","rxFlightChannelsValid":"1"}`))
+
+	for _, line := range lines {
+		values := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &values))
+		assert.Len(t, values, len(frameDef.FieldsI)+1+len(frameDef.FieldsS))
+	}
+}
+
+func TestNdjsonDebugMode(t *testing.T) {
+	frameDef, frameChan, logFile := readFixture(t, "normal.bfl", blackbox.FlightLogReaderOpts{Raw: false})
+	defer logFile.Close()
+
+	var buffer bytes.Buffer
+	ndjsonExporter := NewNdjsonFrameExporter(&buffer, true, frameDef)
+	for frame := range frameChan {
+		assert.NoError(t, ndjsonExporter.WriteFrame(frame))
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	assert.Len(t, lines, 9)
+	assert.Equal(t, `{"event":{"currentTime":55158008,"iteration":52992,"name":"Logging resume"},"frame":"E","offset":1564,"size":9}`, lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], `,"frame":"I","offset":1573,"size":53}`))
+}
+
+func TestDerivedFieldsObjects(t *testing.T) {
+	frameDef, frameChan, logFile := readFixture(t, "normal.bfl", blackbox.FlightLogReaderOpts{Raw: false})
+	defer logFile.Close()
+
+	derived, err := blackbox.ParseDerivedField("motorAvg = mean(motor[*])")
+	assert.NoError(t, err)
+	selector, err := blackbox.NewFieldSelector(frameDef, blackbox.SelectionOpts{
+		Fields:  []string{"motor[0]"},
+		Derived: []blackbox.DerivedField{derived},
+	})
+	assert.NoError(t, err)
+
+	var buffer bytes.Buffer
+	ndjsonExporter := NewNdjsonFrameExporter(&buffer, false, selector.Definition())
+	for frame := range blackbox.SelectFields(context.Background(), frameChan, selector) {
+		assert.NoError(t, ndjsonExporter.WriteFrame(frame))
+	}
+	assert.True(t, strings.HasPrefix(buffer.String(), `{"motor[0]":521,"motorAvg":588.75,`))
+}
@@ -0,0 +1,297 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/pkg/errors"
+)
+
+const (
+	// parquetMagic starts and ends Parquet files
+	parquetMagic = "PAR1"
+
+	// parquetRowGroupSize is the number of rows kept in memory before being written as a row group
+	parquetRowGroupSize = 65536
+
+	// parquetCreatedBy is the name of the writer, stored in the metadata of the file
+	parquetCreatedBy = "blackbox-library"
+)
+
+// Values of the enums of the Parquet metadata
+const (
+	parquetTypeInt64          = 2
+	parquetTypeDouble         = 5
+	parquetTypeByteArray      = 6
+	parquetConvertedTypeUTF8  = 0
+	parquetRepetitionRequired = 0
+	parquetEncodingPlain      = 0
+	parquetEncodingRLE        = 3
+	parquetCodecUncompressed  = 0
+	parquetPageTypeData       = 0
+)
+
+func init() {
+	Register(Format{
+		Name:        "parquet",
+		Extension:   "parquet",
+		ContentType: "application/vnd.apache.parquet",
+		New: func(w io.Writer, opts Options) Exporter {
+			return NewParquetFrameExporter(w)
+		},
+	})
+}
+
+// ParquetFrameExporter transforms a FlightLog into a Parquet file, with one row per main
+// frame and the same columns as NDJSON objects. Integers are written as INT64, converted
+// values as DOUBLE and the flags of the last S frame as UTF8 strings. Every column is
+// required, plain encoded and not compressed. The types of the columns are the ones of the
+// first main frame: a later frame with a value of another type is rejected with an error,
+// never converted.
+type ParquetFrameExporter struct {
+	target  io.Writer
+	objects frameObjects
+	offset  int64
+
+	columns   []*parquetColumn
+	rows      int64
+	rowGroups []parquetRowGroup
+}
+
+// parquetColumn holds the values of a column for the row group being built
+type parquetColumn struct {
+	name   string
+	kind   int32
+	values bytes.Buffer
+}
+
+// parquetRowGroup describes a row group already written
+type parquetRowGroup struct {
+	rows   int64
+	chunks []parquetColumnChunk
+}
+
+// parquetColumnChunk describes the values of a column in a row group already written
+type parquetColumnChunk struct {
+	offset int64
+	size   int64
+}
+
+// NewParquetFrameExporter returns a new ParquetFrameExporter
+func NewParquetFrameExporter(file io.Writer) *ParquetFrameExporter {
+	return &ParquetFrameExporter{target: file}
+}
+
+// Begin sets the definition of the log and writes the magic number of the file
+func (e *ParquetFrameExporter) Begin(frameDef blackbox.LogDefinition) error {
+	e.objects.setDefinition(frameDef)
+	return e.write([]byte(parquetMagic))
+}
+
+// WriteFrame adds a main frame to the rows of the current row group. Other frames are skipped.
+func (e *ParquetFrameExporter) WriteFrame(frame blackbox.Frame) error {
+	object, ok := e.objects.of(frame)
+	if !ok {
+		return nil
+	}
+
+	if e.columns == nil {
+		for i, key := range object.keys {
+			kind, err := parquetKind(object.values[i])
+			if err != nil {
+				return errors.Wrapf(err, "could not write column '%s'", key)
+			}
+			e.columns = append(e.columns, &parquetColumn{name: key, kind: kind})
+		}
+	}
+	if len(object.keys) != len(e.columns) {
+		return errors.Errorf("Frame has %d values instead of %d", len(object.keys), len(e.columns))
+	}
+
+	for i, column := range e.columns {
+		err := column.append(object.values[i])
+		if err != nil {
+			return errors.Wrapf(err, "could not write column '%s'", column.name)
+		}
+	}
+	e.rows++
+
+	if e.rows == parquetRowGroupSize {
+		return e.writeRowGroup()
+	}
+	return nil
+}
+
+// End writes the remaining rows and the metadata of the file
+func (e *ParquetFrameExporter) End(stats *blackbox.LogStatistics) error {
+	if e.rows > 0 {
+		err := e.writeRowGroup()
+		if err != nil {
+			return err
+		}
+	}
+
+	footer := e.fileMetadata()
+	length := make([]byte, 4)
+	binary.LittleEndian.PutUint32(length, uint32(len(footer)))
+	return e.write(footer, length, []byte(parquetMagic))
+}
+
+// writeRowGroup writes the values of every column as a single data page
+func (e *ParquetFrameExporter) writeRowGroup() error {
+	rowGroup := parquetRowGroup{rows: e.rows}
+	for _, column := range e.columns {
+		header := thriftWriter{}
+		header.structBegin()
+		header.i32Field(1, parquetPageTypeData)
+		header.i32Field(2, int32(column.values.Len()))
+		header.i32Field(3, int32(column.values.Len()))
+		header.structField(5)
+		header.i32Field(1, int32(e.rows))
+		header.i32Field(2, parquetEncodingPlain)
+		header.i32Field(3, parquetEncodingRLE)
+		header.i32Field(4, parquetEncodingRLE)
+		header.structEnd()
+		header.structEnd()
+
+		chunk := parquetColumnChunk{offset: e.offset, size: int64(header.buf.Len() + column.values.Len())}
+		err := e.write(header.buf.Bytes(), column.values.Bytes())
+		if err != nil {
+			return err
+		}
+		rowGroup.chunks = append(rowGroup.chunks, chunk)
+		column.values.Reset()
+	}
+
+	e.rowGroups = append(e.rowGroups, rowGroup)
+	e.rows = 0
+	return nil
+}
+
+// fileMetadata returns the FileMetaData structure ending the file
+func (e *ParquetFrameExporter) fileMetadata() []byte {
+	totalRows := int64(0)
+	for _, rowGroup := range e.rowGroups {
+		totalRows += rowGroup.rows
+	}
+
+	w := thriftWriter{}
+	w.structBegin()
+	w.i32Field(1, 1)
+
+	// The schema is a root element followed by its columns
+	w.listField(2, thriftTypeStruct, len(e.columns)+1)
+	w.structBegin()
+	w.stringField(4, "schema")
+	w.i32Field(5, int32(len(e.columns)))
+	w.structEnd()
+	for _, column := range e.columns {
+		w.structBegin()
+		w.i32Field(1, column.kind)
+		w.i32Field(3, parquetRepetitionRequired)
+		w.stringField(4, column.name)
+		if column.kind == parquetTypeByteArray {
+			w.i32Field(6, parquetConvertedTypeUTF8)
+		}
+		w.structEnd()
+	}
+
+	w.i64Field(3, totalRows)
+	w.listField(4, thriftTypeStruct, len(e.rowGroups))
+	for _, rowGroup := range e.rowGroups {
+		totalSize := int64(0)
+		w.structBegin()
+		w.listField(1, thriftTypeStruct, len(rowGroup.chunks))
+		for i, chunk := range rowGroup.chunks {
+			w.structBegin()
+			w.i64Field(2, chunk.offset)
+			w.structField(3)
+			w.i32Field(1, e.columns[i].kind)
+			w.listField(2, thriftTypeI32, 2)
+			w.i32Value(parquetEncodingPlain)
+			w.i32Value(parquetEncodingRLE)
+			w.listField(3, thriftTypeBinary, 1)
+			w.stringValue(e.columns[i].name)
+			w.i32Field(4, parquetCodecUncompressed)
+			w.i64Field(5, rowGroup.rows)
+			w.i64Field(6, chunk.size)
+			w.i64Field(7, chunk.size)
+			w.i64Field(9, chunk.offset)
+			w.structEnd()
+			w.structEnd()
+			totalSize += chunk.size
+		}
+		w.i64Field(2, totalSize)
+		w.i64Field(3, rowGroup.rows)
+		w.structEnd()
+	}
+	w.stringField(6, parquetCreatedBy)
+	w.structEnd()
+	return w.buf.Bytes()
+}
+
+func (e *ParquetFrameExporter) write(parts ...[]byte) error {
+	for _, part := range parts {
+		n, err := e.target.Write(part)
+		e.offset += int64(n)
+		if err != nil {
+			return errors.Wrap(err, "could not write to target file")
+		}
+	}
+	return nil
+}
+
+// append adds a value to the column, plain encoded
+func (c *parquetColumn) append(value interface{}) error {
+	kind, err := parquetKind(value)
+	if err != nil {
+		return err
+	}
+	if kind != c.kind {
+		return errors.Errorf("Value %v is of type %s, while the column is of type %s since the first frame", value, parquetTypeName(kind), parquetTypeName(c.kind))
+	}
+
+	data := make([]byte, 8)
+	switch v := value.(type) {
+	case int64:
+		binary.LittleEndian.PutUint64(data, uint64(v))
+		c.values.Write(data)
+	case float64:
+		binary.LittleEndian.PutUint64(data, math.Float64bits(v))
+		c.values.Write(data)
+	case string:
+		binary.LittleEndian.PutUint32(data, uint32(len(v)))
+		c.values.Write(data[:4])
+		c.values.WriteString(v)
+	}
+	return nil
+}
+
+// parquetTypeName returns the name of a physical type
+func parquetTypeName(kind int32) string {
+	switch kind {
+	case parquetTypeInt64:
+		return "INT64"
+	case parquetTypeDouble:
+		return "DOUBLE"
+	default:
+		return "BYTE_ARRAY"
+	}
+}
+
+// parquetKind returns the physical type of a column holding the value
+func parquetKind(value interface{}) (int32, error) {
+	switch value.(type) {
+	case int64:
+		return parquetTypeInt64, nil
+	case float64:
+		return parquetTypeDouble, nil
+	case string:
+		return parquetTypeByteArray, nil
+	default:
+		return 0, errors.Errorf("Values of type %T can't be written", value)
+	}
+}
@@ -0,0 +1,161 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParquetValues(t *testing.T) {
+	frameDef, frameChan, logFile := readFixture(t, "normal.bfl", blackbox.FlightLogReaderOpts{})
+	defer logFile.Close()
+
+	var buffer bytes.Buffer
+	parquetExporter := NewParquetFrameExporter(&buffer)
+	assert.NoError(t, parquetExporter.Begin(frameDef))
+	for frame := range frameChan {
+		assert.NoError(t, parquetExporter.WriteFrame(frame))
+	}
+	assert.NoError(t, parquetExporter.End(nil))
+
+	data := buffer.Bytes()
+	assert.Equal(t, []byte(parquetMagic), data[:4])
+	assert.Equal(t, []byte(parquetMagic), data[len(data)-4:])
+
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	metadata := readThriftStruct(bytes.NewReader(data[len(data)-8-footerLength:]))
+	assert.Equal(t, int64(1), metadata[1])
+	assert.Equal(t, int64(5), metadata[3])
+	assert.Equal(t, []byte(parquetCreatedBy), metadata[6])
+
+	schema := metadata[2].([]interface{})
+	columns := len(frameDef.FieldsI) + 1 + len(frameDef.FieldsS)
+	assert.Len(t, schema, columns+1)
+	assert.Equal(t, int64(columns), schema[0].(thriftStruct)[5])
+
+	rowGroups := metadata[4].([]interface{})
+	assert.Len(t, rowGroups, 1)
+	chunks := rowGroups[0].(thriftStruct)[1].([]interface{})
+	assert.Len(t, chunks, columns)
+
+	column := func(name string) (thriftStruct, []byte) {
+		for i, element := range schema[1:] {
+			if string(element.(thriftStruct)[4].([]byte)) != name {
+				continue
+			}
+
+			chunk := chunks[i].(thriftStruct)
+			reader := bytes.NewReader(data[chunk[2].(int64):])
+			pageHeader := readThriftStruct(reader)
+			assert.Equal(t, int64(5), pageHeader[5].(thriftStruct)[1])
+			values := make([]byte, pageHeader[2].(int64))
+			_, err := reader.Read(values)
+			assert.NoError(t, err)
+			return element.(thriftStruct), values
+		}
+		t.Fatalf("column %s not found", name)
+		return nil, nil
+	}
+
+	element, values := column("loopIteration")
+	assert.Equal(t, int64(parquetTypeInt64), element[1])
+	assert.Equal(t, int64(parquetRepetitionRequired), element[3])
+	assert.Equal(t, int64(52992), int64(binary.LittleEndian.Uint64(values[0:8])))
+	assert.Equal(t, int64(52993), int64(binary.LittleEndian.Uint64(values[8:16])))
+
+	element, values = column("vbatLatest")
+	assert.Equal(t, int64(parquetTypeDouble), element[1])
+	assert.Equal(t, 16.07, math.Float64frombits(binary.LittleEndian.Uint64(values[0:8])))
+
+	element, values = column("flightModeFlags")
+	assert.Equal(t, int64(parquetTypeByteArray), element[1])
+	assert.Equal(t, int64(parquetConvertedTypeUTF8), element[6])
+	// The first main frame comes before the first S frame
+	assert.Equal(t, uint32(1), binary.LittleEndian.Uint32(values[0:4]))
+	assert.Equal(t, "0", string(values[4:5]))
+	assert.Equal(t, uint32(len("ARM|BLACKBOX")), binary.LittleEndian.Uint32(values[5:9]))
+	assert.Equal(t, "ARM|BLACKBOX", string(values[9:21]))
+}
+
+func TestParquetWithoutFrames(t *testing.T) {
+	var buffer bytes.Buffer
+	parquetExporter := NewParquetFrameExporter(&buffer)
+	assert.NoError(t, parquetExporter.Begin(blackbox.LogDefinition{}))
+	assert.NoError(t, parquetExporter.End(nil))
+
+	data := buffer.Bytes()
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	assert.Equal(t, len(data), 4+footerLength+8)
+
+	metadata := readThriftStruct(bytes.NewReader(data[4 : 4+footerLength]))
+	assert.Equal(t, int64(0), metadata[3])
+	assert.Len(t, metadata[4], 0)
+}
+
+func TestParquetColumnType(t *testing.T) {
+	column := parquetColumn{name: "vbatLatest", kind: parquetTypeDouble}
+	assert.NoError(t, column.append(16.07))
+	assert.EqualError(t, column.append(int64(16)), "Value 16 is of type INT64, while the column is of type DOUBLE since the first frame")
+	assert.Equal(t, 8, column.values.Len())
+}
+
+// thriftStruct holds the fields of a structure decoded by readThriftStruct, by id
+type thriftStruct map[int16]interface{}
+
+// readThriftStruct decodes a structure encoded with the Thrift compact protocol. Integers
+// are returned as int64, binaries as []byte and lists as []interface{}.
+func readThriftStruct(r *bytes.Reader) thriftStruct {
+	result := thriftStruct{}
+	lastFieldID := int16(0)
+	for {
+		header, _ := r.ReadByte()
+		if header == 0 {
+			return result
+		}
+
+		fieldID := lastFieldID + int16(header>>4)
+		if header>>4 == 0 {
+			fieldID = int16(readThriftZigzag(r))
+		}
+		result[fieldID] = readThriftValue(r, header&0x0f)
+		lastFieldID = fieldID
+	}
+}
+
+func readThriftValue(r *bytes.Reader, valueType byte) interface{} {
+	switch valueType {
+	case thriftTypeI32, thriftTypeI64:
+		return readThriftZigzag(r)
+
+	case thriftTypeBinary:
+		length, _ := binary.ReadUvarint(r)
+		value := make([]byte, length)
+		r.Read(value)
+		return value
+
+	case thriftTypeList:
+		header, _ := r.ReadByte()
+		size := uint64(header >> 4)
+		if size == 15 {
+			size, _ = binary.ReadUvarint(r)
+		}
+		values := []interface{}{}
+		for i := uint64(0); i < size; i++ {
+			values = append(values, readThriftValue(r, header&0x0f))
+		}
+		return values
+
+	case thriftTypeStruct:
+		return readThriftStruct(r)
+	}
+	panic("unsupported Thrift type")
+}
+
+func readThriftZigzag(r *bytes.Reader) int64 {
+	value, _ := binary.ReadUvarint(r)
+	return int64(value>>1) ^ -int64(value&1)
+}
@@ -0,0 +1,97 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Types of the fields of the Thrift compact protocol
+const (
+	thriftTypeI32    = 5
+	thriftTypeI64    = 6
+	thriftTypeBinary = 8
+	thriftTypeList   = 9
+	thriftTypeStruct = 12
+)
+
+// thriftWriter encodes structures with the Thrift compact protocol, which Parquet files use for
+// their metadata. Fields must be written in increasing order of their id within a structure.
+type thriftWriter struct {
+	buf bytes.Buffer
+
+	// lastFieldIds holds the id of the last field written in each of the open structures
+	lastFieldIds []int16
+}
+
+// structBegin opens a structure, either as a field, a list element or the top-level value
+func (w *thriftWriter) structBegin() {
+	w.lastFieldIds = append(w.lastFieldIds, 0)
+}
+
+// structEnd closes the last structure opened
+func (w *thriftWriter) structEnd() {
+	w.buf.WriteByte(0)
+	w.lastFieldIds = w.lastFieldIds[:len(w.lastFieldIds)-1]
+}
+
+// structField opens a structure as the field of the current one
+func (w *thriftWriter) structField(id int16) {
+	w.fieldHeader(id, thriftTypeStruct)
+	w.structBegin()
+}
+
+func (w *thriftWriter) i32Field(id int16, value int32) {
+	w.fieldHeader(id, thriftTypeI32)
+	w.varint(zigzag(int64(value)))
+}
+
+func (w *thriftWriter) i64Field(id int16, value int64) {
+	w.fieldHeader(id, thriftTypeI64)
+	w.varint(zigzag(value))
+}
+
+func (w *thriftWriter) stringField(id int16, value string) {
+	w.fieldHeader(id, thriftTypeBinary)
+	w.stringValue(value)
+}
+
+// listField starts a list, whose size elements have to be written right after
+func (w *thriftWriter) listField(id int16, elementType byte, size int) {
+	w.fieldHeader(id, thriftTypeList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elementType)
+		return
+	}
+	w.buf.WriteByte(0xf0 | elementType)
+	w.varint(uint64(size))
+}
+
+func (w *thriftWriter) i32Value(value int32) {
+	w.varint(zigzag(int64(value)))
+}
+
+func (w *thriftWriter) stringValue(value string) {
+	w.varint(uint64(len(value)))
+	w.buf.WriteString(value)
+}
+
+// fieldHeader writes the type of a field and its id, as a delta from the previous field when it fits
+func (w *thriftWriter) fieldHeader(id int16, fieldType byte) {
+	last := &w.lastFieldIds[len(w.lastFieldIds)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		w.buf.WriteByte(fieldType)
+		w.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) varint(value uint64) {
+	data := make([]byte, binary.MaxVarintLen64)
+	w.buf.Write(data[:binary.PutUvarint(data, value)])
+}
+
+func zigzag(value int64) uint64 {
+	return uint64(value<<1) ^ uint64(value>>63)
+}
@@ -0,0 +1,210 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/imu"
+	"github.com/pkg/errors"
+)
+
+const (
+	// logStartDatetimeLayout is how the 'Log start datetime' header is written
+	logStartDatetimeLayout = "2006-01-02T15:04:05.000-07:00"
+)
+
+func init() {
+	Register(Format{
+		Name:        "trajectory",
+		Extension:   "trajectory.json",
+		ContentType: "application/json",
+		New: func(w io.Writer, opts Options) Exporter {
+			return NewTrajectoryExporter(w)
+		},
+	})
+}
+
+// trajectoryPoint is where the craft was at a given time, and its attitude when the log has one
+type trajectoryPoint struct {
+	// Time is the number of seconds since the first frame of the log
+	Time float64 `json:"time"`
+
+	// Latitude and Longitude are in degrees, and Altitude in meters above the sea level
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+
+	// Roll, Pitch and Yaw are in degrees. The yaw is the heading, clockwise from the north.
+	Roll  *float64 `json:"roll,omitempty"`
+	Pitch *float64 `json:"pitch,omitempty"`
+	Yaw   *float64 `json:"yaw,omitempty"`
+}
+
+// trajectory collects the positions of GPS frames, with the attitude of the main frame
+// preceding each of them
+type trajectory struct {
+	frameDef    blackbox.LogDefinition
+	coordIdx    []int
+	altitudeIdx int
+	timeIdx     int
+	mainTimeIdx int
+	attitudeIdx []int
+	attitude    []float64
+	firstTime   int64
+	started     bool
+	points      []trajectoryPoint
+}
+
+func (t *trajectory) setDefinition(frameDef blackbox.LogDefinition) {
+	*t = trajectory{frameDef: frameDef, coordIdx: []int{-1, -1}, altitudeIdx: -1, timeIdx: -1, mainTimeIdx: -1}
+	if index, err := frameDef.GetFieldIndex(blackbox.FieldTime); err == nil {
+		t.mainTimeIdx = index
+	}
+	for i, field := range frameDef.FieldsG {
+		switch field.Name {
+		case blackbox.FieldGPSCoord0:
+			t.coordIdx[0] = i
+		case blackbox.FieldGPSCoord1:
+			t.coordIdx[1] = i
+		case "GPS_altitude":
+			t.altitudeIdx = i
+		case blackbox.FieldTime:
+			t.timeIdx = i
+		}
+	}
+
+	// INAV logs its attitude, otherwise it can be estimated with the imu package
+	for _, group := range []string{"attitude", imu.AttitudeField} {
+		indexes := []int{}
+		for axis := 0; axis < 3; axis++ {
+			if index, err := frameDef.GetFieldIndex(blackbox.FieldName(fmt.Sprintf("%s[%d]", group, axis))); err == nil {
+				indexes = append(indexes, index)
+			}
+		}
+		if len(indexes) == 3 {
+			t.attitudeIdx = indexes
+			break
+		}
+	}
+}
+
+func (t *trajectory) add(frame blackbox.Frame) {
+	if frame.Error() != nil {
+		return
+	}
+
+	switch frame := frame.(type) {
+	case *blackbox.MainFrame:
+		values := frame.Values().([]int64)
+		if t.mainTimeIdx >= 0 && t.mainTimeIdx < len(values) {
+			t.start(values[t.mainTimeIdx])
+		}
+		if t.attitudeIdx == nil {
+			return
+		}
+		t.attitude = []float64{}
+		for _, index := range t.attitudeIdx {
+			if index >= len(values) {
+				t.attitude = nil
+				return
+			}
+			t.attitude = append(t.attitude, float64(values[index])/10)
+		}
+
+	case *blackbox.GPSFrame:
+		values := frame.Values().([]int64)
+		if t.coordIdx[0] < 0 || t.coordIdx[1] < 0 || t.altitudeIdx < 0 || t.timeIdx < 0 || len(values) != len(t.frameDef.FieldsG) {
+			return
+		}
+		t.start(values[t.timeIdx])
+
+		point := trajectoryPoint{
+			Time:      float64(values[t.timeIdx]-t.firstTime) / 1e6,
+			Latitude:  float64(values[t.coordIdx[0]]) / 1e7,
+			Longitude: float64(values[t.coordIdx[1]]) / 1e7,
+			Altitude:  float64(values[t.altitudeIdx]) * gpsAltitudeScale(t.frameDef),
+		}
+		if t.attitude != nil {
+			point.Roll, point.Pitch, point.Yaw = &t.attitude[0], &t.attitude[1], &t.attitude[2]
+		}
+		t.points = append(t.points, point)
+	}
+}
+
+func (t *trajectory) start(frameTime int64) {
+	if !t.started {
+		t.firstTime = frameTime
+		t.started = true
+	}
+}
+
+// startTime returns when the log started, if the flight controller knew the date
+func (t *trajectory) startTime() (time.Time, bool) {
+	start, err := time.Parse(logStartDatetimeLayout, t.frameDef.LogStartDatetime)
+	if err != nil || start.Year() < 2000 {
+		return time.Time{}, false
+	}
+	return start.UTC(), true
+}
+
+// gpsAltitudeScale returns the meters per unit of the GPS_altitude field. INAV logs it in
+// centimeters, and Betaflight in meters, then in decimeters since 4.3.
+func gpsAltitudeScale(frameDef blackbox.LogDefinition) float64 {
+	switch {
+	case frameDef.Dialect == blackbox.DialectINAV:
+		return 0.01
+	case frameDef.Firmware.AtLeast(4, 3, 0):
+		return 0.1
+	default:
+		return 1
+	}
+}
+
+// TrajectoryExporter writes the path of the craft as a JSON document, with the position
+// of every GPS frame and the attitude of the craft at that time when the log has one
+type TrajectoryExporter struct {
+	target     io.Writer
+	trajectory trajectory
+}
+
+// NewTrajectoryExporter returns a new TrajectoryExporter
+func NewTrajectoryExporter(file io.Writer) *TrajectoryExporter {
+	return &TrajectoryExporter{target: file}
+}
+
+// Begin sets the definition of the log
+func (e *TrajectoryExporter) Begin(frameDef blackbox.LogDefinition) error {
+	e.trajectory.setDefinition(frameDef)
+	return nil
+}
+
+// WriteFrame adds the position of GPS frames to the trajectory
+func (e *TrajectoryExporter) WriteFrame(frame blackbox.Frame) error {
+	e.trajectory.add(frame)
+	return nil
+}
+
+// End writes the trajectory
+func (e *TrajectoryExporter) End(stats *blackbox.LogStatistics) error {
+	document := struct {
+		CraftName string            `json:"craftName"`
+		Start     string            `json:"start,omitempty"`
+		Points    []trajectoryPoint `json:"points"`
+	}{
+		CraftName: e.trajectory.frameDef.CraftName,
+		Points:    append([]trajectoryPoint{}, e.trajectory.points...),
+	}
+	if start, ok := e.trajectory.startTime(); ok {
+		document.Start = start.Format(time.RFC3339Nano)
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = e.target.Write(append(data, '\n'))
+	return errors.Wrap(err, "could not write the trajectory to target file")
+}
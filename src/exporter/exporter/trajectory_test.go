@@ -0,0 +1,58 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrajectory(t *testing.T) {
+	frameDef, frameChan, logFile := readFixture(t, "inav_synthetic.bfl", blackbox.FlightLogReaderOpts{})
+	defer logFile.Close()
+
+	var buffer bytes.Buffer
+	trajectoryExporter := NewTrajectoryExporter(&buffer)
+	assert.NoError(t, trajectoryExporter.Begin(frameDef))
+	for frame := range frameChan {
+		assert.NoError(t, trajectoryExporter.WriteFrame(frame))
+	}
+	assert.NoError(t, trajectoryExporter.End(nil))
+
+	document := struct {
+		Start  string
+		Points []map[string]float64
+	}{}
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &document))
+	assert.Equal(t, "2021-05-02T14:31:07Z", document.Start)
+	assert.Len(t, document.Points, 6)
+	assert.InDelta(t, 0.00031, document.Points[0]["time"], 1e-9)
+	assert.InDelta(t, 47.398191, document.Points[0]["latitude"], 1e-9)
+	assert.InDelta(t, 8.5455939, document.Points[0]["longitude"], 1e-9)
+	assert.InDelta(t, 30, document.Points[0]["altitude"], 1e-9)
+	assert.InDelta(t, 30.39, document.Points[5]["altitude"], 1e-9)
+	assert.Contains(t, document.Points[0], "roll")
+	assert.Contains(t, document.Points[0], "yaw")
+}
+
+func TestTrajectoryWithoutGPS(t *testing.T) {
+	frameDef, frameChan, logFile := readFixture(t, "normal.bfl", blackbox.FlightLogReaderOpts{})
+	defer logFile.Close()
+
+	var buffer bytes.Buffer
+	trajectoryExporter := NewTrajectoryExporter(&buffer)
+	assert.NoError(t, trajectoryExporter.Begin(frameDef))
+	for frame := range frameChan {
+		assert.NoError(t, trajectoryExporter.WriteFrame(frame))
+	}
+	assert.NoError(t, trajectoryExporter.End(nil))
+	assert.Equal(t, "{\"craftName\":\"Ergo\",\"points\":[]}\n", buffer.String())
+}
+
+func TestGPSAltitudeScale(t *testing.T) {
+	assert.Equal(t, 0.01, gpsAltitudeScale(blackbox.LogDefinition{Dialect: blackbox.DialectINAV}))
+	assert.Equal(t, 1.0, gpsAltitudeScale(blackbox.LogDefinition{Firmware: blackbox.FirmwareVersion{Product: "Betaflight", Major: 4}}))
+	assert.Equal(t, 0.1, gpsAltitudeScale(blackbox.LogDefinition{Firmware: blackbox.FirmwareVersion{Product: "Betaflight", Major: 4, Minor: 4}}))
+}
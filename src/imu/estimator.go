@@ -0,0 +1,151 @@
+package imu
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/pkg/errors"
+)
+
+const (
+	// AttitudeField is the name of the fields holding the estimated roll, pitch and
+	// yaw, in tenths of degrees like the attitude fields of INAV
+	AttitudeField = "imuAttitude"
+
+	// maxUpdateInterval is the longest time between two main frames the gyro is
+	// integrated over. After longer gaps, the attitude is reset from the accelerometer.
+	maxUpdateInterval = 100 * time.Millisecond
+)
+
+// AttitudeEstimator adds the attitude estimated by an AttitudeFilter to the main frames
+// of a log, as the imuAttitude[0], imuAttitude[1] and imuAttitude[2] fields. It changes
+// the definition of the log accordingly, for exporters to be given the one of Definition.
+type AttitudeEstimator struct {
+	filter      AttitudeFilter
+	definition  blackbox.LogDefinition
+	fieldsCount int
+	timeIdx     int
+	gyroIdx     []int
+	accIdx      []int
+	gyroScale   float64
+	started     bool
+	lastTime    int64
+}
+
+// NewAttitudeEstimator returns a new AttitudeEstimator for logs with the given definition
+func NewAttitudeEstimator(frameDef blackbox.LogDefinition, filter AttitudeFilter) (*AttitudeEstimator, error) {
+	timeIdx, err := frameDef.GetFieldIndex(blackbox.FieldTime)
+	if err != nil {
+		return nil, err
+	}
+	gyroIdx, err := axisFieldIndexes(frameDef, "gyroADC")
+	if err != nil {
+		return nil, err
+	}
+	accIdx, err := axisFieldIndexes(frameDef, "accSmooth")
+	if err != nil {
+		return nil, err
+	}
+
+	e := &AttitudeEstimator{
+		filter:      filter,
+		fieldsCount: len(frameDef.FieldsI),
+		timeIdx:     timeIdx,
+		gyroIdx:     gyroIdx,
+		accIdx:      accIdx,
+
+		// Like in the blackbox log viewer, gyro_scale turns the logged gyro into deg/s
+		// for Betaflight, Cleanflight and INAV
+		gyroScale: frameDef.Sysconfig.GyroScale * math.Pi / 180,
+	}
+
+	e.definition = frameDef
+	e.definition.FieldsI = append([]blackbox.FieldDefinition{}, frameDef.FieldsI...)
+	e.definition.FieldsP = append([]blackbox.FieldDefinition{}, frameDef.FieldsP...)
+	e.definition.FieldIRL = map[blackbox.FieldName]int{}
+	for i, field := range frameDef.FieldsI {
+		e.definition.FieldIRL[field.Name] = i
+	}
+	for axis := 0; axis < 3; axis++ {
+		field := blackbox.FieldDefinition{Name: blackbox.FieldName(fmt.Sprintf("%s[%d]", AttitudeField, axis)), Signed: true}
+		if _, err := frameDef.GetFieldIndex(field.Name); err == nil {
+			return nil, errors.Errorf("The log already has a field named '%s'", field.Name)
+		}
+		e.definition.FieldIRL[field.Name] = len(e.definition.FieldsI)
+		e.definition.FieldsI = append(e.definition.FieldsI, field)
+		e.definition.FieldsP = append(e.definition.FieldsP, field)
+	}
+	return e, nil
+}
+
+// axisFieldIndexes returns the position of the fields of the three axes of a group
+func axisFieldIndexes(frameDef blackbox.LogDefinition, name string) ([]int, error) {
+	indexes := []int{}
+	for axis := 0; axis < 3; axis++ {
+		index, err := frameDef.GetFieldIndex(blackbox.FieldName(fmt.Sprintf("%s[%d]", name, axis)))
+		if err != nil {
+			return nil, errors.Errorf("The log doesn't contain the %s fields the attitude is estimated from", name)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// Definition returns the definition of the log with the estimated attitude fields
+func (e *AttitudeEstimator) Definition() blackbox.LogDefinition {
+	return e.definition
+}
+
+// Frame returns a main frame with the estimated attitude added. Other frames, and main
+// frames whose values couldn't all be decoded, are returned as they are.
+func (e *AttitudeEstimator) Frame(frame blackbox.Frame) blackbox.Frame {
+	mainFrame, ok := frame.(*blackbox.MainFrame)
+	if !ok || frame.Error() != nil {
+		return frame
+	}
+	values := mainFrame.Values().([]int64)
+	if len(values) != e.fieldsCount {
+		return frame
+	}
+
+	gyro, acc := Vector{}, Vector{}
+	for axis := range gyro {
+		gyro[axis] = float64(values[e.gyroIdx[axis]]) * e.gyroScale
+		acc[axis] = float64(values[e.accIdx[axis]])
+	}
+
+	t := values[e.timeIdx]
+	dt := time.Duration(t-e.lastTime) * time.Microsecond
+	if !e.started || dt <= 0 || dt > maxUpdateInterval {
+		e.filter.Reset(QuaternionFromAcceleration(acc))
+		e.started = true
+	} else {
+		e.filter.Update(gyro, acc, dt.Seconds())
+	}
+	e.lastTime = t
+
+	roll, pitch, yaw := e.filter.Attitude().Euler()
+	estimated := append(append(make([]int64, 0, len(values)+3), values...), decidegrees(roll), decidegrees(pitch), decidegrees(yaw))
+
+	withAttitude := blackbox.NewMainFrame(frame.Type(), estimated, int64(frame.Start()), int64(frame.Start()+frame.Size()), nil)
+	if frame.Validity() {
+		blackbox.MarkValid(withAttitude)
+	}
+	return withAttitude
+}
+
+func decidegrees(radians float64) int64 {
+	return int64(math.Round(radians * 1800 / math.Pi))
+}
+
+// EstimateAttitude returns the frames of a channel with the estimated attitude added to
+// their main frames. The returned channel is closed once the input channel is, or the
+// context canceled.
+func EstimateAttitude(ctx context.Context, frames <-chan blackbox.Frame, estimator *AttitudeEstimator) <-chan blackbox.Frame {
+	return blackbox.TransformFrames(ctx, frames, blackbox.FrameFunc(func(frame blackbox.Frame) []blackbox.Frame {
+		return []blackbox.Frame{estimator.Frame(frame)}
+	}))
+}
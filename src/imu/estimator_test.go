@@ -0,0 +1,54 @@
+package imu
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/blackbox/blackboxtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttitudeEstimator(t *testing.T) {
+	frameDef := blackboxtest.LogDefinition("loopIteration", "time", "gyroADC[0]", "gyroADC[1]", "gyroADC[2]", "accSmooth[0]", "accSmooth[1]", "accSmooth[2]")
+	frameDef.Sysconfig.GyroScale = 1
+	frameDef.Sysconfig.Acc1G = 2048
+	estimator, err := NewAttitudeEstimator(frameDef, NewMahonyFilter())
+	assert.NoError(t, err)
+
+	definition := estimator.Definition()
+	assert.Len(t, definition.FieldsI, 11)
+	index, err := definition.GetFieldIndex("imuAttitude[1]")
+	assert.NoError(t, err)
+	assert.Equal(t, 9, index)
+
+	// Rolling right at 90deg/s for half a second, starting level
+	frames := make(chan blackbox.Frame)
+	go func() {
+		defer close(frames)
+		for ms := int64(0); ms <= 500; ms++ {
+			gravity := QuaternionFromEuler(math.Pi/2*float64(ms)/1000, 0, 0).gravity()
+			acc := []int64{int64(gravity[0] * 2048), int64(gravity[1] * 2048), int64(gravity[2] * 2048)}
+			values := append([]int64{ms, ms * 1000, 90, 0, 0}, acc...)
+			frames <- blackbox.MarkValid(blackbox.NewMainFrame(blackbox.LogFrameInter, values, 0, 0, nil))
+		}
+		frames <- blackbox.NewEventFrame(blackbox.LogEventLogEnd, map[string]interface{}{}, 0, 0, nil)
+	}()
+
+	var last []int64
+	for frame := range EstimateAttitude(context.Background(), frames, estimator) {
+		if frame.Type() == blackbox.LogFrameInter {
+			assert.True(t, frame.Validity())
+			last = frame.Values().([]int64)
+		}
+	}
+	assert.InDelta(t, 450, last[8], 5)
+	assert.InDelta(t, 0, last[9], 5)
+	assert.InDelta(t, 0, last[10], 5)
+}
+
+func TestAttitudeEstimatorWithoutAccelerometer(t *testing.T) {
+	_, err := NewAttitudeEstimator(blackboxtest.LogDefinition("loopIteration", "time", "gyroADC[0]", "gyroADC[1]", "gyroADC[2]"), NewMadgwickFilter())
+	assert.EqualError(t, err, "The log doesn't contain the accSmooth fields the attitude is estimated from")
+}
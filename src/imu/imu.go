@@ -0,0 +1,99 @@
+// Package imu estimates the attitude of a craft from its gyro and accelerometer, like
+// the flight controller does, for logs without attitude fields.
+package imu
+
+import (
+	"math"
+)
+
+// Vector is a value on each axis of the craft: roll, pitch and yaw
+type Vector [3]float64
+
+// norm returns the length of a vector
+func (v Vector) norm() float64 {
+	return math.Sqrt(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])
+}
+
+// Quaternion is a rotation from the earth frame to the frame of the craft
+type Quaternion struct {
+	W, X, Y, Z float64
+}
+
+// identity is the attitude of a level craft facing north
+var identity = Quaternion{W: 1}
+
+// QuaternionFromAcceleration returns the attitude of a craft at rest measuring a given
+// acceleration. The yaw can't be known from the gravity, and is 0.
+func QuaternionFromAcceleration(acc Vector) Quaternion {
+	if acc.norm() == 0 {
+		return identity
+	}
+	roll := math.Atan2(acc[1], acc[2])
+	pitch := math.Atan2(-acc[0], math.Sqrt(acc[1]*acc[1]+acc[2]*acc[2]))
+	return QuaternionFromEuler(roll, pitch, 0)
+}
+
+// QuaternionFromEuler returns the rotation of the given angles, in radians, applied yaw first
+func QuaternionFromEuler(roll, pitch, yaw float64) Quaternion {
+	cr, sr := math.Cos(roll/2), math.Sin(roll/2)
+	cp, sp := math.Cos(pitch/2), math.Sin(pitch/2)
+	cy, sy := math.Cos(yaw/2), math.Sin(yaw/2)
+	return Quaternion{
+		W: cr*cp*cy + sr*sp*sy,
+		X: sr*cp*cy - cr*sp*sy,
+		Y: cr*sp*cy + sr*cp*sy,
+		Z: cr*cp*sy - sr*sp*cy,
+	}
+}
+
+// Euler returns the roll, pitch and yaw of the rotation, in radians
+func (q Quaternion) Euler() (roll, pitch, yaw float64) {
+	roll = math.Atan2(2*(q.W*q.X+q.Y*q.Z), 1-2*(q.X*q.X+q.Y*q.Y))
+	pitch = math.Asin(math.Max(-1, math.Min(1, 2*(q.W*q.Y-q.Z*q.X))))
+	yaw = math.Atan2(2*(q.W*q.Z+q.X*q.Y), 1-2*(q.Y*q.Y+q.Z*q.Z))
+	return roll, pitch, yaw
+}
+
+// gravity returns the direction the accelerometer of a craft at rest measures, in the
+// frame of the craft
+func (q Quaternion) gravity() Vector {
+	return Vector{
+		2 * (q.X*q.Z - q.W*q.Y),
+		2 * (q.W*q.X + q.Y*q.Z),
+		q.W*q.W - q.X*q.X - q.Y*q.Y + q.Z*q.Z,
+	}
+}
+
+// integrate rotates the quaternion by the angular rate of the craft, in rad/s, during dt
+// seconds, and normalizes it
+func (q Quaternion) integrate(rate Vector, dt float64) Quaternion {
+	gx, gy, gz := rate[0]*dt/2, rate[1]*dt/2, rate[2]*dt/2
+	return Quaternion{
+		W: q.W - q.X*gx - q.Y*gy - q.Z*gz,
+		X: q.X + q.W*gx + q.Y*gz - q.Z*gy,
+		Y: q.Y + q.W*gy - q.X*gz + q.Z*gx,
+		Z: q.Z + q.W*gz + q.X*gy - q.Y*gx,
+	}.normalize()
+}
+
+func (q Quaternion) normalize() Quaternion {
+	norm := math.Sqrt(q.W*q.W + q.X*q.X + q.Y*q.Y + q.Z*q.Z)
+	if norm == 0 {
+		return identity
+	}
+	return Quaternion{q.W / norm, q.X / norm, q.Y / norm, q.Z / norm}
+}
+
+// AttitudeFilter fuses the gyro and the accelerometer into an attitude. The gyro is
+// integrated, and the accelerometer slowly corrects its drift on roll and pitch.
+type AttitudeFilter interface {
+	// Update moves the attitude forward by dt seconds, given the angular rate in rad/s
+	// and the acceleration in any unit
+	Update(gyro, acc Vector, dt float64)
+
+	// Attitude returns the current attitude
+	Attitude() Quaternion
+
+	// Reset sets the attitude, forgetting any correction in progress
+	Reset(attitude Quaternion)
+}
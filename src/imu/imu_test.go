@@ -0,0 +1,55 @@
+package imu
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuaternionEuler(t *testing.T) {
+	roll, pitch, yaw := QuaternionFromEuler(0.3, -0.2, 1.5).Euler()
+	assert.InDelta(t, 0.3, roll, 1e-9)
+	assert.InDelta(t, -0.2, pitch, 1e-9)
+	assert.InDelta(t, 1.5, yaw, 1e-9)
+}
+
+func TestQuaternionFromAcceleration(t *testing.T) {
+	roll, pitch, yaw := QuaternionFromAcceleration(Vector{0, 1024, 1774}).Euler()
+	assert.InDelta(t, 30, degrees(roll), 0.1)
+	assert.InDelta(t, 0, degrees(pitch), 0.1)
+	assert.Equal(t, 0.0, yaw)
+
+	attitude := QuaternionFromEuler(0.4, 0.3, 0)
+	roll, pitch, _ = QuaternionFromAcceleration(attitude.gravity()).Euler()
+	assert.InDelta(t, 0.4, roll, 1e-9)
+	assert.InDelta(t, 0.3, pitch, 1e-9)
+
+	assert.Equal(t, identity, QuaternionFromAcceleration(Vector{}))
+}
+
+func TestAttitudeFilters(t *testing.T) {
+	for name, filter := range map[string]AttitudeFilter{"mahony": NewMahonyFilter(), "madgwick": NewMadgwickFilter()} {
+		// The accelerometer corrects an attitude that's wrong from the start
+		tilted := QuaternionFromEuler(0.5, -0.3, 0).gravity()
+		for i := 0; i < 30000; i++ {
+			filter.Update(Vector{}, tilted, 0.001)
+		}
+		roll, pitch, _ := filter.Attitude().Euler()
+		assert.InDelta(t, 0.5, roll, 0.01, name)
+		assert.InDelta(t, -0.3, pitch, 0.01, name)
+
+		// The gyro is integrated
+		filter.Reset(identity)
+		for i := 0; i < 1000; i++ {
+			filter.Update(Vector{0, 0, math.Pi / 2}, Vector{0, 0, 1}, 0.001)
+		}
+		roll, _, yaw := filter.Attitude().Euler()
+		assert.InDelta(t, 90, degrees(yaw), 0.5, name)
+		assert.InDelta(t, 0, roll, 0.001, name)
+	}
+}
+
+func degrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
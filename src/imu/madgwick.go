@@ -0,0 +1,62 @@
+package imu
+
+import (
+	"math"
+)
+
+// MadgwickFilter corrects the attitude integrated from the gyro with a gradient descent
+// step moving the estimated gravity towards the measured one
+type MadgwickFilter struct {
+	// Beta is how fast the accelerometer corrects the attitude, in rad/s
+	Beta float64
+
+	attitude Quaternion
+}
+
+// NewMadgwickFilter returns a new MadgwickFilter with a gain suited to flight logs
+func NewMadgwickFilter() *MadgwickFilter {
+	return &MadgwickFilter{Beta: 0.1, attitude: identity}
+}
+
+// Update moves the attitude forward by dt seconds
+func (f *MadgwickFilter) Update(gyro, acc Vector, dt float64) {
+	q := f.attitude
+
+	// Rate of change of the quaternion given by the gyro
+	dw := 0.5 * (-q.X*gyro[0] - q.Y*gyro[1] - q.Z*gyro[2])
+	dx := 0.5 * (q.W*gyro[0] + q.Y*gyro[2] - q.Z*gyro[1])
+	dy := 0.5 * (q.W*gyro[1] - q.X*gyro[2] + q.Z*gyro[0])
+	dz := 0.5 * (q.W*gyro[2] + q.X*gyro[1] - q.Y*gyro[0])
+
+	if norm := acc.norm(); norm > 0 {
+		ax, ay, az := acc[0]/norm, acc[1]/norm, acc[2]/norm
+
+		// Objective function: the estimated gravity minus the measured one
+		estimated := q.gravity()
+		fx, fy, fz := estimated[0]-ax, estimated[1]-ay, estimated[2]-az
+
+		// Gradient of the objective function, given by its Jacobian
+		sw := -2*q.Y*fx + 2*q.X*fy + 2*q.W*fz
+		sx := 2*q.Z*fx + 2*q.W*fy - 2*q.X*fz
+		sy := -2*q.W*fx + 2*q.Z*fy - 2*q.Y*fz
+		sz := 2*q.X*fx + 2*q.Y*fy + 2*q.Z*fz
+		if gradient := math.Sqrt(sw*sw + sx*sx + sy*sy + sz*sz); gradient > 0 {
+			dw -= f.Beta * sw / gradient
+			dx -= f.Beta * sx / gradient
+			dy -= f.Beta * sy / gradient
+			dz -= f.Beta * sz / gradient
+		}
+	}
+
+	f.attitude = Quaternion{q.W + dw*dt, q.X + dx*dt, q.Y + dy*dt, q.Z + dz*dt}.normalize()
+}
+
+// Attitude returns the current attitude
+func (f *MadgwickFilter) Attitude() Quaternion {
+	return f.attitude
+}
+
+// Reset sets the attitude
+func (f *MadgwickFilter) Reset(attitude Quaternion) {
+	f.attitude = attitude
+}
@@ -0,0 +1,52 @@
+package imu
+
+// MahonyFilter is the complementary filter Betaflight and INAV use: the error between
+// the measured and the estimated gravity is fed back to the gyro through a PI controller
+type MahonyFilter struct {
+	// Kp is how fast the accelerometer corrects the attitude
+	Kp float64
+
+	// Ki is how fast the gyro bias is learnt from the accelerometer
+	Ki float64
+
+	attitude Quaternion
+	integral Vector
+}
+
+// NewMahonyFilter returns a new MahonyFilter with the gains Betaflight uses by default
+func NewMahonyFilter() *MahonyFilter {
+	return &MahonyFilter{Kp: 0.25, attitude: identity}
+}
+
+// Update moves the attitude forward by dt seconds
+func (f *MahonyFilter) Update(gyro, acc Vector, dt float64) {
+	if norm := acc.norm(); norm > 0 {
+		estimated := f.attitude.gravity()
+		measured := Vector{acc[0] / norm, acc[1] / norm, acc[2] / norm}
+
+		// The cross product of the gravity vectors is the rotation correcting the error
+		e := Vector{
+			measured[1]*estimated[2] - measured[2]*estimated[1],
+			measured[2]*estimated[0] - measured[0]*estimated[2],
+			measured[0]*estimated[1] - measured[1]*estimated[0],
+		}
+		for axis := range gyro {
+			if f.Ki > 0 {
+				f.integral[axis] += f.Ki * e[axis] * dt
+			}
+			gyro[axis] += f.Kp*e[axis] + f.integral[axis]
+		}
+	}
+	f.attitude = f.attitude.integrate(gyro, dt)
+}
+
+// Attitude returns the current attitude
+func (f *MahonyFilter) Attitude() Quaternion {
+	return f.attitude
+}
+
+// Reset sets the attitude and forgets the learnt gyro bias
+func (f *MahonyFilter) Reset(attitude Quaternion) {
+	f.attitude = attitude
+	f.integral = Vector{}
+}
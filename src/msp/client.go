@@ -0,0 +1,239 @@
+package msp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// List of the supported commands
+const (
+	MSPDataflashSummary uint16 = 70
+	MSPDataflashRead    uint16 = 71
+	MSPDataflashErase   uint16 = 72
+)
+
+// List of the compressions of a dataflash read
+const (
+	compressionNone    = 0
+	compressionHuffman = 1
+)
+
+const (
+	// DefaultChunkSize is the number of bytes requested by each dataflash read
+	DefaultChunkSize = 4096
+
+	// DefaultTimeout is how long a response is waited for
+	DefaultTimeout = 5 * time.Second
+)
+
+// deadliner is implemented by connections which can time out, like serial ports and sockets
+type deadliner interface {
+	SetDeadline(t time.Time) error
+}
+
+// DataflashSummary describes the onboard flash of a flight controller
+type DataflashSummary struct {
+	// Ready is false while the flash is being erased
+	Ready bool
+
+	// Supported is false if the flight controller has no onboard flash
+	Supported bool
+
+	Sectors   uint32
+	TotalSize uint32
+
+	// UsedSize is the number of bytes written to the flash
+	UsedSize uint32
+}
+
+// Client sends requests to a flight controller, one at a time
+type Client struct {
+	// Version is the protocol version of the requests
+	Version Version
+
+	// Timeout bounds each request, if the connection supports deadlines. Zero disables it.
+	Timeout time.Duration
+
+	// Huffman is the table used to decompress dataflash reads, as read by
+	// ParseHuffmanTable. Reads are not compressed if it's nil.
+	Huffman *HuffmanTable
+
+	rw     io.ReadWriter
+	reader *bufio.Reader
+}
+
+// NewClient returns a new Client
+func NewClient(rw io.ReadWriter, version Version) *Client {
+	return &Client{
+		Version: version,
+		Timeout: DefaultTimeout,
+		rw:      rw,
+		reader:  bufio.NewReader(rw),
+	}
+}
+
+// ReadDataflashSummary returns the state of the onboard flash
+func (c *Client) ReadDataflashSummary() (DataflashSummary, error) {
+	payload, err := c.request(MSPDataflashSummary, nil)
+	if err != nil {
+		return DataflashSummary{}, err
+	}
+	if len(payload) < 13 {
+		return DataflashSummary{}, errors.Errorf("Dataflash summary has %d bytes instead of 13", len(payload))
+	}
+
+	return DataflashSummary{
+		Ready:     payload[0]&1 != 0,
+		Supported: payload[0]&2 != 0,
+		Sectors:   binary.LittleEndian.Uint32(payload[1:5]),
+		TotalSize: binary.LittleEndian.Uint32(payload[5:9]),
+		UsedSize:  binary.LittleEndian.Uint32(payload[9:13]),
+	}, nil
+}
+
+// ReadDataflash reads up to size bytes of the onboard flash, starting at address.
+// The flight controller can return fewer bytes, and none past the end of the flash.
+func (c *Client) ReadDataflash(address uint32, size uint16) ([]byte, error) {
+	request := make([]byte, 7)
+	binary.LittleEndian.PutUint32(request[0:4], address)
+	binary.LittleEndian.PutUint16(request[4:6], size)
+	if c.Huffman != nil {
+		request[6] = 1
+	}
+
+	payload, err := c.request(MSPDataflashRead, request)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) < 7 {
+		return nil, errors.Errorf("Dataflash read response has %d bytes", len(payload))
+	}
+
+	responseAddress := binary.LittleEndian.Uint32(payload[0:4])
+	if responseAddress != address {
+		return nil, errors.Errorf("Dataflash read returned address %d instead of %d", responseAddress, address)
+	}
+
+	dataSize := int(binary.LittleEndian.Uint16(payload[4:6]))
+	data := payload[7:]
+	if dataSize > len(data) {
+		return nil, errors.Errorf("Dataflash read announced %d bytes but returned %d", dataSize, len(data))
+	}
+	data = data[:dataSize]
+
+	switch payload[6] {
+	case compressionNone:
+		return data, nil
+
+	case compressionHuffman:
+		if c.Huffman == nil {
+			return nil, errors.New("Dataflash read is compressed but no Huffman table is set")
+		}
+		if len(data) < 2 {
+			return nil, errors.Errorf("Compressed dataflash read has %d bytes", len(data))
+		}
+		decompressed, err := c.Huffman.Decode(data[2:], int(binary.LittleEndian.Uint16(data[0:2])))
+		return decompressed, errors.Wrapf(err, "could not decompress the dataflash read at address %d", address)
+
+	default:
+		return nil, errors.Errorf("Dataflash read has unknown compression %d", payload[6])
+	}
+}
+
+// EraseDataflash starts erasing the onboard flash. The flash is not ready until the
+// erase completes, see WaitDataflashReady.
+func (c *Client) EraseDataflash() error {
+	_, err := c.request(MSPDataflashErase, nil)
+	return err
+}
+
+// WaitDataflashReady polls the flight controller until its onboard flash is ready
+func (c *Client) WaitDataflashReady(interval, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		summary, err := c.ReadDataflashSummary()
+		if err != nil {
+			return err
+		}
+		if summary.Ready {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("Dataflash still not ready after %s", timeout)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// DownloadDataflash copies the used part of the onboard flash to w, chunk by chunk.
+// progress is called after each chunk if not nil. Returns the number of bytes copied.
+func (c *Client) DownloadDataflash(w io.Writer, chunkSize uint16, progress func(read, total uint32)) (int64, error) {
+	summary, err := c.ReadDataflashSummary()
+	if err != nil {
+		return 0, err
+	}
+	if !summary.Supported {
+		return 0, errors.New("Flight controller has no onboard flash")
+	}
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	address := uint32(0)
+	for address < summary.UsedSize {
+		data, err := c.ReadDataflash(address, chunkSize)
+		if err != nil {
+			return int64(address), err
+		}
+		if len(data) == 0 {
+			return int64(address), errors.Errorf("Dataflash read returned no data at address %d", address)
+		}
+		if remaining := summary.UsedSize - address; uint32(len(data)) > remaining {
+			data = data[:remaining]
+		}
+
+		if _, err := w.Write(data); err != nil {
+			return int64(address), errors.WithStack(err)
+		}
+		address += uint32(len(data))
+
+		if progress != nil {
+			progress(address, summary.UsedSize)
+		}
+	}
+	return int64(address), nil
+}
+
+// request sends a command and returns the payload of its response
+func (c *Client) request(command uint16, payload []byte) ([]byte, error) {
+	if conn, ok := c.rw.(deadliner); ok && c.Timeout > 0 {
+		// Not every file supports deadlines, in which case requests just don't time out
+		_ = conn.SetDeadline(time.Now().Add(c.Timeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	err := WritePacket(c.rw, Packet{Version: c.Version, Direction: ToFlightController, Command: command, Payload: payload})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not send command %d", command)
+	}
+
+	for {
+		response, err := ReadPacket(c.reader)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read the response to command %d", command)
+		}
+
+		// Responses to other requests, like the ones of a previous client, are ignored
+		if response.Command != command || response.Direction == ToFlightController {
+			continue
+		}
+		if response.Direction == Unsupported {
+			return nil, errors.Errorf("Flight controller doesn't support command %d", command)
+		}
+		return response.Payload, nil
+	}
+}
@@ -0,0 +1,193 @@
+package msp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFlightController answers dataflash requests like Betaflight does, with a log in its flash
+type fakeFlightController struct {
+	flash []byte
+
+	// maxReadSize is the size of the largest read, bounded by the buffers of the firmware
+	maxReadSize int
+
+	// huffman compresses the reads which allow it, if not nil
+	huffman *HuffmanTable
+
+	// erasePolls is how many summaries report the flash as not ready after an erase
+	erasePolls int
+
+	requests []Packet
+}
+
+func (f *fakeFlightController) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		request, err := ReadPacket(r)
+		if err != nil {
+			return
+		}
+		f.requests = append(f.requests, request)
+
+		response := Packet{Version: request.Version, Direction: FromFlightController, Command: request.Command}
+		switch request.Command {
+		case MSPDataflashSummary:
+			response.Payload = f.summary()
+		case MSPDataflashRead:
+			response.Payload = f.read(request.Payload)
+		case MSPDataflashErase:
+			f.flash = nil
+			f.erasePolls = 2
+		default:
+			response.Direction = Unsupported
+		}
+
+		if err := WritePacket(conn, response); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeFlightController) summary() []byte {
+	payload := make([]byte, 13)
+	payload[0] = 2
+	if f.erasePolls > 0 {
+		f.erasePolls--
+	} else {
+		payload[0] |= 1
+	}
+	binary.LittleEndian.PutUint32(payload[1:5], 64)
+	binary.LittleEndian.PutUint32(payload[5:9], 1<<20)
+	binary.LittleEndian.PutUint32(payload[9:13], uint32(len(f.flash)))
+	return payload
+}
+
+func (f *fakeFlightController) read(request []byte) []byte {
+	address := int(binary.LittleEndian.Uint32(request[0:4]))
+	size := int(binary.LittleEndian.Uint16(request[4:6]))
+	if size > f.maxReadSize {
+		size = f.maxReadSize
+	}
+	if address+size > len(f.flash) {
+		size = len(f.flash) - address
+	}
+	data := f.flash[address : address+size]
+
+	payload := make([]byte, 7)
+	binary.LittleEndian.PutUint32(payload[0:4], uint32(address))
+	if request[6] == 1 && f.huffman != nil {
+		payload[6] = 1
+		count := make([]byte, 2)
+		binary.LittleEndian.PutUint16(count, uint16(len(data)))
+		data = append(count, f.huffman.Encode(data)...)
+	}
+	binary.LittleEndian.PutUint16(payload[4:6], uint16(len(data)))
+	return append(payload, data...)
+}
+
+func TestReadDataflashSummary(t *testing.T) {
+	fc := &fakeFlightController{flash: make([]byte, 1234)}
+	client := newTestClient(fc, V1)
+
+	summary, err := client.ReadDataflashSummary()
+	assert.NoError(t, err)
+	assert.Equal(t, DataflashSummary{Ready: true, Supported: true, Sectors: 64, TotalSize: 1 << 20, UsedSize: 1234}, summary)
+}
+
+func TestDownloadDataflash(t *testing.T) {
+	fixture := readFixture(t)
+
+	for _, version := range []Version{V1, V2} {
+		fc := &fakeFlightController{flash: fixture, maxReadSize: 1000}
+		client := newTestClient(fc, version)
+
+		progress := []uint32{}
+		buf := bytes.Buffer{}
+		n, err := client.DownloadDataflash(&buf, 4096, func(read, total uint32) {
+			assert.Equal(t, uint32(len(fixture)), total)
+			progress = append(progress, read)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(fixture)), n)
+		assert.Equal(t, fixture, buf.Bytes())
+		assert.Equal(t, (len(fixture)+999)/1000, len(progress))
+		assert.Equal(t, version, fc.requests[1].Version)
+	}
+}
+
+func TestDownloadDataflashCompressed(t *testing.T) {
+	// The table isn't the one of the firmware: this only covers how compressed reads
+	// are requested and unpacked
+	fixture := readFixture(t)
+	table := BuildHuffmanTable(fixture)
+
+	fc := &fakeFlightController{flash: fixture, maxReadSize: 4096, huffman: table}
+	client := newTestClient(fc, V2)
+	client.Huffman = table
+
+	buf := bytes.Buffer{}
+	_, err := client.DownloadDataflash(&buf, 4096, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, fixture, buf.Bytes())
+	assert.Equal(t, byte(1), fc.requests[1].Payload[6])
+}
+
+func TestReadDataflashCompressedWithoutTable(t *testing.T) {
+	fixture := readFixture(t)
+	fc := &fakeFlightController{flash: fixture, maxReadSize: 4096, huffman: BuildHuffmanTable(fixture)}
+	client := newTestClient(fc, V2)
+
+	// Reads are only compressed when the client asks for it
+	data, err := client.ReadDataflash(0, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, fixture[:100], data)
+	assert.Equal(t, byte(0), fc.requests[0].Payload[6])
+}
+
+func TestEraseDataflash(t *testing.T) {
+	fc := &fakeFlightController{flash: readFixture(t)}
+	client := newTestClient(fc, V1)
+
+	assert.NoError(t, client.EraseDataflash())
+	assert.NoError(t, client.WaitDataflashReady(time.Millisecond, time.Second))
+
+	summary, err := client.ReadDataflashSummary()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), summary.UsedSize)
+	assert.Equal(t, 5, len(fc.requests))
+}
+
+func TestUnsupportedCommand(t *testing.T) {
+	client := newTestClient(&fakeFlightController{}, V2)
+
+	_, err := client.request(1000, nil)
+	assert.Error(t, err)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	go bufio.NewReader(remote).WriteTo(&bytes.Buffer{})
+
+	client := NewClient(local, V1)
+	client.Timeout = 10 * time.Millisecond
+
+	_, err := client.ReadDataflashSummary()
+	assert.Error(t, err)
+}
+
+// newTestClient returns a client connected to a fake flight controller
+func newTestClient(fc *fakeFlightController, version Version) *Client {
+	local, remote := net.Pipe()
+	go fc.serve(remote)
+	return NewClient(local, version)
+}
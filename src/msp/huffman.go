@@ -0,0 +1,258 @@
+package msp
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxHuffmanCodeLength is the length of the longest code a table can hold
+	maxHuffmanCodeLength = 32
+
+	// firmwareHuffmanCodeLength is the size in bits of the codes in the table of the firmware,
+	// which are aligned on their most significant bit
+	firmwareHuffmanCodeLength = 16
+
+	// HuffmanEOF is the symbol of the table marking the end of compressed data
+	HuffmanEOF = 256
+)
+
+// firmwareHuffmanEntry matches the entries of the table in the sources of the firmware,
+// e.g. '{  2, 0xC000 }, // 0x00: 11'
+var firmwareHuffmanEntry = regexp.MustCompile(`\{\s*(\d+)\s*,\s*0[xX]([0-9a-fA-F]+)\s*\}`)
+
+// HuffmanCode is the code of a byte, stored in the lowest Length bits of Bits
+type HuffmanCode struct {
+	Length uint8
+	Bits   uint32
+}
+
+// HuffmanTable holds the code of each byte value, followed by the code of HuffmanEOF.
+// The flight controller compresses dataflash reads with a fixed table: the same one has
+// to be used to decompress them, see ParseHuffmanTable.
+type HuffmanTable [HuffmanEOF + 1]HuffmanCode
+
+// huffmanNode is a node of the tree used to decode bits. Leaves have no children.
+type huffmanNode struct {
+	children [2]*huffmanNode
+	symbol   int
+	leaf     bool
+}
+
+// BuildHuffmanTable returns a canonical Huffman table suited to data looking like the
+// sample. Every byte value gets a code, even the ones the sample doesn't contain.
+// It can't decompress what the firmware compresses.
+func BuildHuffmanTable(sample []byte) *HuffmanTable {
+	frequencies := make([]uint64, HuffmanEOF+1)
+	for _, b := range sample {
+		frequencies[b]++
+	}
+
+	for {
+		lengths := huffmanCodeLengths(frequencies)
+
+		longest := 0
+		for _, length := range lengths {
+			if length > longest {
+				longest = length
+			}
+		}
+		if longest <= maxHuffmanCodeLength {
+			return canonicalHuffmanTable(lengths)
+		}
+
+		// Flatten the distribution until the codes fit
+		for i := range frequencies {
+			frequencies[i] /= 2
+		}
+	}
+}
+
+// huffmanCodeLengths returns the length of the code of each byte value
+func huffmanCodeLengths(frequencies []uint64) []int {
+	type weightedNode struct {
+		weight  uint64
+		symbols []int
+	}
+
+	nodes := make([]weightedNode, len(frequencies))
+	for i, frequency := range frequencies {
+		nodes[i] = weightedNode{weight: frequency + 1, symbols: []int{i}}
+	}
+
+	lengths := make([]int, len(frequencies))
+	for len(nodes) > 1 {
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].weight < nodes[j].weight
+		})
+
+		merged := weightedNode{
+			weight:  nodes[0].weight + nodes[1].weight,
+			symbols: append(append([]int{}, nodes[0].symbols...), nodes[1].symbols...),
+		}
+		for _, symbol := range merged.symbols {
+			lengths[symbol]++
+		}
+		nodes = append([]weightedNode{merged}, nodes[2:]...)
+	}
+	return lengths
+}
+
+// canonicalHuffmanTable assigns canonical codes to symbols given the length of their codes
+func canonicalHuffmanTable(lengths []int) *HuffmanTable {
+	symbols := make([]int, len(lengths))
+	for i := range symbols {
+		symbols[i] = i
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return lengths[symbols[i]] < lengths[symbols[j]]
+	})
+
+	table := HuffmanTable{}
+	code := uint64(0)
+	previousLength := lengths[symbols[0]]
+	for _, symbol := range symbols {
+		code <<= uint(lengths[symbol] - previousLength)
+		previousLength = lengths[symbol]
+
+		table[symbol] = HuffmanCode{Length: uint8(lengths[symbol]), Bits: uint32(code)}
+		code++
+	}
+	return &table
+}
+
+// ParseHuffmanTable reads the table the firmware compresses with from its sources, e.g.
+// src/main/common/huffman_table.c in Betaflight. Entries are read in order, one per
+// byte value followed by the one of HuffmanEOF.
+func ParseHuffmanTable(r io.Reader) (*HuffmanTable, error) {
+	table := HuffmanTable{}
+	count := 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		match := firmwareHuffmanEntry.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		if count == len(table) {
+			return nil, errors.Errorf("Huffman table has more than %d entries", len(table))
+		}
+
+		length, err := strconv.ParseUint(match[1], 10, 8)
+		if err != nil || length == 0 || length > firmwareHuffmanCodeLength {
+			return nil, errors.Errorf("Huffman table entry %d has an invalid length '%s'", count, match[1])
+		}
+		code, err := strconv.ParseUint(match[2], 16, firmwareHuffmanCodeLength)
+		if err != nil {
+			return nil, errors.Errorf("Huffman table entry %d has an invalid code '%s'", count, match[2])
+		}
+
+		table[count] = HuffmanCode{Length: uint8(length), Bits: uint32(code >> (firmwareHuffmanCodeLength - length))}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if count != len(table) {
+		return nil, errors.Errorf("Huffman table has %d entries instead of %d", count, len(table))
+	}
+
+	_, err := table.tree()
+	return &table, err
+}
+
+// Encode compresses data. Bits are written starting with the most significant one of
+// each byte, and the last byte is padded with zeros.
+func (t *HuffmanTable) Encode(data []byte) []byte {
+	result := []byte{}
+	current := byte(0)
+	used := uint(0)
+
+	for _, b := range data {
+		code := t[b]
+		for i := int(code.Length) - 1; i >= 0; i-- {
+			current = current<<1 | byte(code.Bits>>uint(i)&1)
+			used++
+			if used == 8 {
+				result = append(result, current)
+				current = 0
+				used = 0
+			}
+		}
+	}
+
+	if used > 0 {
+		result = append(result, current<<(8-used))
+	}
+	return result
+}
+
+// Decode decompresses count bytes out of data, stopping early at the HuffmanEOF code
+func (t *HuffmanTable) Decode(data []byte, count int) ([]byte, error) {
+	root, err := t.tree()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, 0, count)
+	node := root
+DecodingLoop:
+	for _, b := range data {
+		for i := 7; i >= 0 && len(result) < count; i-- {
+			node = node.children[b>>uint(i)&1]
+			if node == nil {
+				return nil, errors.Errorf("Invalid code after %d decoded bytes", len(result))
+			}
+			if node.leaf && node.symbol == HuffmanEOF {
+				break DecodingLoop
+			}
+			if node.leaf {
+				result = append(result, byte(node.symbol))
+				node = root
+			}
+		}
+	}
+
+	if len(result) < count {
+		return nil, errors.Errorf("Compressed data ends after %d of the %d bytes", len(result), count)
+	}
+	return result, nil
+}
+
+// tree returns the decoding tree of the table
+func (t *HuffmanTable) tree() (*huffmanNode, error) {
+	root := &huffmanNode{}
+	for symbol, code := range t {
+		if code.Length == 0 {
+			continue
+		}
+		if code.Length > maxHuffmanCodeLength {
+			return nil, errors.Errorf("Code of symbol %d is %d bits long", symbol, code.Length)
+		}
+
+		node := root
+		for i := int(code.Length) - 1; i >= 0; i-- {
+			if node.leaf {
+				return nil, errors.Errorf("Code of symbol %d starts with the code of symbol %d", symbol, node.symbol)
+			}
+
+			bit := code.Bits >> uint(i) & 1
+			if node.children[bit] == nil {
+				node.children[bit] = &huffmanNode{}
+			}
+			node = node.children[bit]
+		}
+
+		if node.leaf || node.children[0] != nil || node.children[1] != nil {
+			return nil, errors.Errorf("Code of symbol %d is the prefix of another code", symbol)
+		}
+		node.leaf = true
+		node.symbol = symbol
+	}
+	return root, nil
+}
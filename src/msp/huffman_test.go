@@ -0,0 +1,108 @@
+package msp
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHuffmanRoundTrip(t *testing.T) {
+	fixture := readFixture(t)
+	table := BuildHuffmanTable(fixture)
+
+	compressed := table.Encode(fixture)
+	assert.True(t, len(compressed) < len(fixture))
+
+	decompressed, err := table.Decode(compressed, len(fixture))
+	assert.NoError(t, err)
+	assert.Equal(t, fixture, decompressed)
+}
+
+func TestHuffmanEncodesUnseenBytes(t *testing.T) {
+	table := BuildHuffmanTable([]byte("aaaaaaab"))
+	assert.True(t, table['a'].Length < table['b'].Length)
+
+	data := []byte{0, 255, 'a', 'z'}
+	decompressed, err := table.Decode(table.Encode(data), len(data))
+	assert.NoError(t, err)
+	assert.Equal(t, data, decompressed)
+}
+
+func TestHuffmanDecodeTruncated(t *testing.T) {
+	table := BuildHuffmanTable(nil)
+
+	_, err := table.Decode(table.Encode([]byte("abc")), 4)
+	assert.Error(t, err)
+}
+
+func TestHuffmanDecodeAmbiguousTable(t *testing.T) {
+	table := HuffmanTable{}
+	table['a'] = HuffmanCode{Length: 1, Bits: 0}
+	table['b'] = HuffmanCode{Length: 2, Bits: 1}
+
+	_, err := table.Decode([]byte{0}, 1)
+	assert.Error(t, err)
+}
+
+func TestHuffmanDecodeEOF(t *testing.T) {
+	table := HuffmanTable{}
+	table['a'] = HuffmanCode{Length: 1, Bits: 0}
+	table['b'] = HuffmanCode{Length: 2, Bits: 2}
+	table[HuffmanEOF] = HuffmanCode{Length: 2, Bits: 3}
+
+	// 'a', 'b' and the end of the data, before all the announced bytes are decoded
+	_, err := table.Decode([]byte{0x58}, 3)
+	assert.EqualError(t, err, "Compressed data ends after 2 of the 3 bytes")
+}
+
+func TestParseHuffmanTable(t *testing.T) {
+	// 255 codes of 8 bits, and 2 codes of 9 bits
+	lengths := make([]int, HuffmanEOF+1)
+	for i := range lengths {
+		lengths[i] = 8
+	}
+	lengths[HuffmanEOF-1] = 9
+	lengths[HuffmanEOF] = 9
+	expected := canonicalHuffmanTable(lengths)
+
+	source := bytes.Buffer{}
+	source.WriteString("const huffmanTable_t huffmanTable[HUFFMAN_TABLE_SIZE] = {\n    // Len    Code       Char Bitcode\n")
+	for symbol, code := range expected {
+		fmt.Fprintf(&source, "    { %2d, 0x%04X }, // 0x%02X\n", code.Length, code.Bits<<(16-code.Length), symbol)
+	}
+	source.WriteString("};\n")
+
+	table, err := ParseHuffmanTable(&source)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, table)
+
+	data := []byte("some data")
+	decompressed, err := table.Decode(table.Encode(data), len(data))
+	assert.NoError(t, err)
+	assert.Equal(t, data, decompressed)
+}
+
+func TestParseHuffmanTableAmbiguous(t *testing.T) {
+	source := strings.Repeat("{  2, 0xC000 }, // 0x00: 11\n", HuffmanEOF+1)
+
+	_, err := ParseHuffmanTable(strings.NewReader(source))
+	assert.EqualError(t, err, "Code of symbol 1 is the prefix of another code")
+}
+
+func TestParseHuffmanTableIncomplete(t *testing.T) {
+	_, err := ParseHuffmanTable(strings.NewReader("{  2, 0xC000 }, // 0x00: 11\n{  3, 0xA000 }, // 0x01: 101\n"))
+	assert.EqualError(t, err, "Huffman table has 2 entries instead of 257")
+
+	_, err = ParseHuffmanTable(strings.NewReader("{ 17, 0xC000 }, // 0x00\n"))
+	assert.EqualError(t, err, "Huffman table entry 0 has an invalid length '17'")
+}
+
+func readFixture(t *testing.T) []byte {
+	content, err := ioutil.ReadFile("../../fixtures/normal.bfl")
+	assert.NoError(t, err)
+	return content
+}
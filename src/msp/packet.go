@@ -0,0 +1,202 @@
+package msp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Version is a version of the MultiWii Serial Protocol
+type Version int
+
+// List of the supported versions
+const (
+	// V1 has one byte commands and sizes. Larger payloads are sent in jumbo frames.
+	V1 Version = 1
+
+	// V2 has two bytes commands and sizes, and a stronger checksum
+	V2 Version = 2
+)
+
+// Direction tells who sent a packet
+type Direction byte
+
+// List of the directions of a packet
+const (
+	// ToFlightController is a request
+	ToFlightController Direction = '<'
+
+	// FromFlightController is a response
+	FromFlightController Direction = '>'
+
+	// Unsupported is the response to a request the flight controller refused
+	Unsupported Direction = '!'
+)
+
+const (
+	// jumboFrameSize is the size announced by MSP v1 packets whose actual size follows on two bytes
+	jumboFrameSize = 255
+
+	// maxPayloadSize is the size of the largest payload a packet can carry
+	maxPayloadSize = 65535
+)
+
+// Packet is a request or a response
+type Packet struct {
+	Version   Version
+	Direction Direction
+	Command   uint16
+	Payload   []byte
+}
+
+// WritePacket writes a packet
+func WritePacket(w io.Writer, packet Packet) error {
+	if len(packet.Payload) > maxPayloadSize {
+		return errors.Errorf("Payload of %d bytes is too large", len(packet.Payload))
+	}
+
+	var data []byte
+	switch packet.Version {
+	case V1:
+		if packet.Command > 255 {
+			return errors.Errorf("Command %d can't be sent with MSP v1", packet.Command)
+		}
+
+		var header []byte
+		if len(packet.Payload) >= jumboFrameSize {
+			header = []byte{jumboFrameSize, byte(packet.Command), byte(len(packet.Payload)), byte(len(packet.Payload) >> 8)}
+		} else {
+			header = []byte{byte(len(packet.Payload)), byte(packet.Command)}
+		}
+		data = append([]byte{'$', 'M', byte(packet.Direction)}, header...)
+		data = append(data, packet.Payload...)
+		data = append(data, checksumV1(append(header, packet.Payload...)))
+
+	case V2:
+		header := []byte{0, byte(packet.Command), byte(packet.Command >> 8), byte(len(packet.Payload)), byte(len(packet.Payload) >> 8)}
+		data = append([]byte{'$', 'X', byte(packet.Direction)}, header...)
+		data = append(data, packet.Payload...)
+		data = append(data, checksumV2(append(header, packet.Payload...)))
+
+	default:
+		return errors.Errorf("MSP version %d is not supported", packet.Version)
+	}
+
+	_, err := w.Write(data)
+	return errors.WithStack(err)
+}
+
+// ReadPacket reads the next packet. Bytes before its beginning are skipped.
+func ReadPacket(r *bufio.Reader) (Packet, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return Packet{}, err
+		}
+		if b != '$' {
+			continue
+		}
+
+		preamble, err := r.Peek(2)
+		if err != nil {
+			return Packet{}, err
+		}
+
+		packet := Packet{Direction: Direction(preamble[1])}
+		switch preamble[0] {
+		case 'M':
+			packet.Version = V1
+		case 'X':
+			packet.Version = V2
+		default:
+			// Not a packet, look for the next one
+			continue
+		}
+
+		if _, err := r.Discard(2); err != nil {
+			return Packet{}, err
+		}
+		if packet.Version == V1 {
+			err = readPayloadV1(r, &packet)
+		} else {
+			err = readPayloadV2(r, &packet)
+		}
+		return packet, err
+	}
+}
+
+func readPayloadV1(r *bufio.Reader, packet *Packet) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+	packet.Command = uint16(header[1])
+
+	size := int(header[0])
+	if size == jumboFrameSize {
+		jumboSize := make([]byte, 2)
+		if _, err := io.ReadFull(r, jumboSize); err != nil {
+			return err
+		}
+		header = append(header, jumboSize...)
+		size = int(binary.LittleEndian.Uint16(jumboSize))
+	}
+
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	packet.Payload = data[:size]
+
+	if checksum := checksumV1(append(header, packet.Payload...)); checksum != data[size] {
+		return errors.Errorf("Invalid checksum for command %d: expected %d, got %d", packet.Command, checksum, data[size])
+	}
+	return nil
+}
+
+func readPayloadV2(r *bufio.Reader, packet *Packet) error {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+	packet.Command = binary.LittleEndian.Uint16(header[1:3])
+	size := int(binary.LittleEndian.Uint16(header[3:5]))
+
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	packet.Payload = data[:size]
+
+	if checksum := checksumV2(append(header, packet.Payload...)); checksum != data[size] {
+		return errors.Errorf("Invalid checksum for command %d: expected %d, got %d", packet.Command, checksum, data[size])
+	}
+	return nil
+}
+
+// checksumV1 is the XOR of the size, command and payload bytes
+func checksumV1(data []byte) byte {
+	checksum := byte(0)
+	for _, b := range data {
+		checksum ^= b
+	}
+	return checksum
+}
+
+// checksumV2 is the CRC-8/DVB-S2 of the flag, command, size and payload bytes
+func checksumV2(data []byte) byte {
+	crc := byte(0)
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0xD5
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
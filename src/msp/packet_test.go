@@ -0,0 +1,60 @@
+package msp
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePacketV1(t *testing.T) {
+	buf := bytes.Buffer{}
+	err := WritePacket(&buf, Packet{Version: V1, Direction: ToFlightController, Command: MSPDataflashSummary})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{'$', 'M', '<', 0, 70, 70}, buf.Bytes())
+
+	buf.Reset()
+	err = WritePacket(&buf, Packet{Version: V1, Direction: ToFlightController, Command: MSPDataflashRead, Payload: []byte{1, 2, 3}})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{'$', 'M', '<', 3, 71, 1, 2, 3, 3 ^ 71 ^ 1 ^ 2 ^ 3}, buf.Bytes())
+}
+
+func TestWritePacketV1LargeCommand(t *testing.T) {
+	err := WritePacket(&bytes.Buffer{}, Packet{Version: V1, Command: 300})
+	assert.Error(t, err)
+}
+
+func TestReadPacketRoundTrip(t *testing.T) {
+	packets := []Packet{
+		{Version: V1, Direction: FromFlightController, Command: MSPDataflashSummary, Payload: []byte{3, 1, 2}},
+		{Version: V1, Direction: FromFlightController, Command: MSPDataflashRead, Payload: bytes.Repeat([]byte{7}, 1000)},
+		{Version: V2, Direction: FromFlightController, Command: 0x1F01, Payload: []byte{}},
+		{Version: V2, Direction: Unsupported, Command: MSPDataflashErase, Payload: bytes.Repeat([]byte{9}, 5000)},
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteString("noise$")
+	for _, packet := range packets {
+		assert.NoError(t, WritePacket(&buf, packet))
+	}
+
+	r := bufio.NewReader(&buf)
+	for _, expected := range packets {
+		packet, err := ReadPacket(r)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, packet)
+	}
+}
+
+func TestReadPacketInvalidChecksum(t *testing.T) {
+	for _, version := range []Version{V1, V2} {
+		buf := bytes.Buffer{}
+		assert.NoError(t, WritePacket(&buf, Packet{Version: version, Direction: FromFlightController, Command: 70, Payload: []byte{1, 2}}))
+		data := buf.Bytes()
+		data[len(data)-2]++
+
+		_, err := ReadPacket(bufio.NewReader(bytes.NewReader(data)))
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/pkg/errors"
+)
+
+// frameQuery selects the values of main frames, with the following parameters:
+//   fields=time,gyroADC[0]   fields to return, all of them by default
+//   from=10.5&to=1m20s       time range since the first main frame, in seconds or as durations
+//   decimate=10              keeps one main frame out of 10
+//   format=csv               returns CSV instead of JSON
+type frameQuery struct {
+	fields   []blackbox.FieldName
+	from     time.Duration
+	to       time.Duration
+	hasTo    bool
+	decimate int
+	format   string
+}
+
+// frameQueryResult holds the values selected by a query, one row per main frame
+type frameQueryResult struct {
+	Fields []blackbox.FieldName `json:"fields"`
+	Rows   [][]int64            `json:"rows"`
+
+	query     frameQuery
+	indexes   []int
+	timeIndex int
+	firstTime int64
+	started   bool
+	inRange   int
+}
+
+func parseFrameQuery(values url.Values) (frameQuery, error) {
+	query := frameQuery{decimate: 1, format: values.Get("format")}
+
+	if fields := values.Get("fields"); fields != "" {
+		for _, name := range strings.Split(fields, ",") {
+			query.fields = append(query.fields, blackbox.FieldName(strings.TrimSpace(name)))
+		}
+	}
+
+	var err error
+	if value := values.Get("from"); value != "" {
+		if query.from, err = parseOffset(value); err != nil {
+			return query, err
+		}
+	}
+	if value := values.Get("to"); value != "" {
+		if query.to, err = parseOffset(value); err != nil {
+			return query, err
+		}
+		query.hasTo = true
+	}
+	if query.hasTo && query.to < query.from {
+		return query, errors.New("'to' is before 'from'")
+	}
+
+	if value := values.Get("decimate"); value != "" {
+		query.decimate, err = strconv.Atoi(value)
+		if err != nil || query.decimate < 1 {
+			return query, errors.Errorf("Invalid decimation '%s', expected a positive integer", value)
+		}
+	}
+
+	if query.format == "" {
+		query.format = "json"
+	}
+	if query.format != "json" && query.format != "csv" {
+		return query, errors.Errorf("Unknown format '%s', expected json or csv", query.format)
+	}
+	return query, nil
+}
+
+// parseOffset parses an offset from the first main frame, given in seconds or like the --from and
+// --to flags of the tool: mm:ss[.fff], hh:mm:ss or a duration like 1m30s
+func parseOffset(value string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return blackbox.ParseFlightTime(value)
+	}
+	if seconds < 0 {
+		return 0, errors.Errorf("Invalid time '%s', expected a positive number of seconds", value)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// start prepares the result of the query for a session
+func (q frameQuery) start(frameDef blackbox.LogDefinition) (*frameQueryResult, error) {
+	timeIndex, err := frameDef.GetFieldIndex(blackbox.FieldTime)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &frameQueryResult{
+		Fields:    q.fields,
+		Rows:      [][]int64{},
+		query:     q,
+		timeIndex: timeIndex,
+	}
+
+	if len(q.fields) == 0 {
+		for i, field := range frameDef.FieldsI {
+			result.Fields = append(result.Fields, field.Name)
+			result.indexes = append(result.indexes, i)
+		}
+		return result, nil
+	}
+
+	for _, name := range q.fields {
+		index, err := frameDef.GetFieldIndex(name)
+		if err != nil {
+			return nil, errors.Errorf("The log has no field '%s'", name)
+		}
+		result.indexes = append(result.indexes, index)
+	}
+	return result, nil
+}
+
+// add selects the values of a frame if it's a main frame in the time range. Returns
+// false once the end of the range is reached.
+func (r *frameQueryResult) add(frame blackbox.Frame) bool {
+	mainFrame, ok := frame.(*blackbox.MainFrame)
+	if !ok || frame.Error() != nil || !frame.Validity() {
+		return true
+	}
+	values := mainFrame.Values().([]int64)
+
+	if !r.started {
+		r.firstTime = values[r.timeIndex]
+		r.started = true
+	}
+
+	elapsed := time.Duration(values[r.timeIndex]-r.firstTime) * time.Microsecond
+	if elapsed < r.query.from {
+		return true
+	}
+	if r.query.hasTo && elapsed > r.query.to {
+		return false
+	}
+
+	if r.inRange%r.query.decimate == 0 {
+		row := make([]int64, len(r.indexes))
+		for i, index := range r.indexes {
+			row[i] = values[index]
+		}
+		r.Rows = append(r.Rows, row)
+	}
+	r.inRange++
+	return true
+}
+
+func (r *frameQueryResult) writeCSV(w io.Writer) {
+	names := make([]string, len(r.Fields))
+	for i, name := range r.Fields {
+		names[i] = string(name)
+	}
+	fmt.Fprintln(w, strings.Join(names, ","))
+
+	values := make([]string, len(r.indexes))
+	for _, row := range r.Rows {
+		for i, v := range row {
+			values[i] = strconv.FormatInt(v, 10)
+		}
+		fmt.Fprintln(w, strings.Join(values, ","))
+	}
+}
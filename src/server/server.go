@@ -0,0 +1,375 @@
+// Package server serves blackbox logs over HTTP. Logs are uploaded once, and their
+// sessions can then be inspected, queried and exported without downloading them.
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/maxlaverse/blackbox-library/src/blackbox/stream"
+	"github.com/maxlaverse/blackbox-library/src/exporter/exporter"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxUploadSize is the size of the largest log that can be uploaded
+	MaxUploadSize = 256 << 20
+
+	// uploadFormField is the form field holding the log in multipart uploads
+	uploadFormField = "file"
+)
+
+// errSessionNotFound is returned for sessions a log doesn't have
+var errSessionNotFound = errors.New("Session not found")
+
+// Server answers the following requests:
+//   POST /logs                               uploads a log, in the body or in the 'file' field of a form
+//   GET  /logs                               lists the uploaded logs
+//   GET  /logs/{id}                          describes a log and its sessions
+//   GET  /logs/{id}/sessions                 lists the sessions of a log
+//   GET  /logs/{id}/sessions/{n}/headers     returns the definition of a session
+//   GET  /logs/{id}/sessions/{n}/stats       returns the statistics of a session
+//   GET  /logs/{id}/sessions/{n}/frames      returns selected fields of the main frames, see frameQuery
+//   GET  /logs/{id}/sessions/{n}/export      exports a session in one of the exporter formats, with ?format=
+type Server struct {
+	store         *store
+	maxUploadSize int64
+}
+
+// NewServer returns a new Server storing the logs in a directory
+func NewServer(dir string) (*Server, error) {
+	store, err := newStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{store: store, maxUploadSize: MaxUploadSize}, nil
+}
+
+// ServeHTTP answers a request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "logs" {
+		writeError(w, http.StatusNotFound, errors.New("Not found"))
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		s.upload(w, r)
+	case len(parts) == 1:
+		s.list(w, r)
+	case len(parts) == 2:
+		s.describe(w, r, parts[1])
+	case len(parts) == 3 && parts[2] == "sessions":
+		s.sessions(w, r, parts[1])
+	case len(parts) == 5 && parts[2] == "sessions":
+		s.session(w, r, parts[1], parts[3], parts[4])
+	default:
+		writeError(w, http.StatusNotFound, errors.New("Not found"))
+	}
+}
+
+func (s *Server) upload(w http.ResponseWriter, r *http.Request) {
+	// Multipart readers read the body of the request, which is limited whatever its type
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUploadSize)
+	body := io.Reader(r.Body)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		body, err = formFile(reader)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	log, err := s.store.add(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, log)
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	logs, err := s.store.list()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, logs)
+}
+
+func (s *Server) describe(w http.ResponseWriter, r *http.Request, id string) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	log, err := s.store.get(id)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, log)
+}
+
+func (s *Server) sessions(w http.ResponseWriter, r *http.Request, id string) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	log, err := s.store.get(id)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, log.Sessions)
+}
+
+func (s *Server) session(w http.ResponseWriter, r *http.Request, id, index, resource string) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	sessionIndex, err := strconv.Atoi(index)
+	if err != nil {
+		writeError(w, http.StatusNotFound, errSessionNotFound)
+		return
+	}
+
+	logFile, err := s.store.open(id)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	defer logFile.Close()
+
+	sessionReader, err := s.store.openSession(logFile, sessionIndex)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	switch resource {
+	case "headers":
+		s.headers(w, sessionReader)
+	case "stats":
+		s.stats(w, sessionReader)
+	case "frames":
+		s.frames(w, r, sessionReader)
+	case "export":
+		s.export(w, r, sessionReader, fmt.Sprintf("%s.%02d", id, sessionIndex))
+	default:
+		writeError(w, http.StatusNotFound, errors.New("Not found"))
+	}
+}
+
+func (s *Server) headers(w http.ResponseWriter, sessionReader io.Reader) {
+	flightLog := blackbox.NewFlightLogReader(blackbox.FlightLogReaderOpts{})
+	err := readSession(flightLog, sessionReader, nil, nil)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, flightLog.FrameDef)
+}
+
+// statsResponse has the statistics of a session, with frame types as letters
+type statsResponse struct {
+	*blackbox.LogStatistics
+	Frame map[string]*blackbox.FrameStatistics
+}
+
+func (s *Server) stats(w http.ResponseWriter, sessionReader io.Reader) {
+	flightLog := blackbox.NewFlightLogReader(blackbox.FlightLogReaderOpts{})
+	err := readSession(flightLog, sessionReader, nil, func(blackbox.Frame) (bool, error) {
+		return true, nil
+	})
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	response := statsResponse{LogStatistics: flightLog.Stats, Frame: map[string]*blackbox.FrameStatistics{}}
+	for frameType, frameStats := range flightLog.Stats.Frame {
+		response.Frame[string(frameType)] = frameStats
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) frames(w http.ResponseWriter, r *http.Request, sessionReader io.Reader) {
+	query, err := parseFrameQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var result *frameQueryResult
+	flightLog := blackbox.NewFlightLogReader(blackbox.FlightLogReaderOpts{})
+	err = readSession(flightLog, sessionReader, func(frameDef blackbox.LogDefinition) error {
+		var startErr error
+		result, startErr = query.start(frameDef)
+		return startErr
+	}, func(frame blackbox.Frame) (bool, error) {
+		return result.add(frame), nil
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if query.format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		result.writeCSV(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) export(w http.ResponseWriter, r *http.Request, sessionReader io.Reader, name string) {
+	formatName := r.URL.Query().Get("format")
+	if formatName == "" {
+		formatName = "csv"
+	}
+	format, err := exporter.Lookup(formatName)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	bufferedWriter := bufio.NewWriter(w)
+	defer bufferedWriter.Flush()
+
+	started := false
+	frameExporter := format.New(bufferedWriter, exporter.Options{})
+	flightLog := blackbox.NewFlightLogReader(blackbox.FlightLogReaderOpts{})
+	err = readSession(flightLog, sessionReader, func(frameDef blackbox.LogDefinition) error {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", name, format.Extension))
+		w.Header().Set("Content-Type", format.ContentType)
+		if err := frameExporter.Begin(frameDef); err != nil {
+			return err
+		}
+		started = true
+		return nil
+	}, func(frame blackbox.Frame) (bool, error) {
+		return true, frameExporter.WriteFrame(frame)
+	})
+	if err == nil {
+		err = frameExporter.End(flightLog.Stats)
+	}
+
+	// Once the export started, errors can't be reported with the status anymore
+	if err != nil && !started {
+		writeError(w, http.StatusUnprocessableEntity, err)
+	}
+}
+
+// readSession decodes a session, hands its definition to init and then every frame to
+// handler until it returns false. Both can be nil. Reading stops at the first error
+// that can't be recovered from.
+func readSession(flightLog *blackbox.FlightLogReader, r io.Reader, init func(blackbox.LogDefinition) error, handler func(blackbox.Frame) (bool, error)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	frameChan, err := flightLog.LoadFile(ctx, r)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	// The reader is stopped and its last frames discarded when returning early
+	defer func() {
+		cancel()
+		for range frameChan {
+		}
+	}()
+
+	if init != nil {
+		if err := init(flightLog.FrameDef); err != nil {
+			return err
+		}
+	}
+	if handler == nil {
+		return nil
+	}
+
+	for frame := range frameChan {
+		if err := frame.Error(); err != nil && !isErrorRecoverable(err) {
+			return err
+		}
+
+		more, err := handler(frame)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+func isErrorRecoverable(err error) bool {
+	switch err.(type) {
+	case *stream.ReadError, stream.ReadError:
+		return false
+	default:
+		return true
+	}
+}
+
+// formFile returns the content of the file field of a multipart form
+func formFile(reader *multipart.Reader) (io.Reader, error) {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errors.Errorf("The form has no '%s' field", uploadFormField)
+		} else if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if part.FormName() == uploadFormField {
+			return part, nil
+		}
+	}
+}
+
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("Method %s not allowed", r.Method))
+		return false
+	}
+	return true
+}
+
+func statusOf(err error) int {
+	switch err {
+	case errLogNotFound, errSessionNotFound:
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadAndList(t *testing.T) {
+	ts, dir := newTestServer(t)
+	defer os.RemoveAll(dir)
+	defer ts.Close()
+
+	log := upload(t, ts, append(readFixture(t, "normal.bfl"), readFixture(t, "inav_synthetic.bfl")...))
+	assert.Len(t, log.ID, 16)
+	assert.Len(t, log.Sessions, 2)
+	assert.Equal(t, 1, log.Sessions[0].Index)
+	assert.Equal(t, log.Sessions[0].End, log.Sessions[1].Start)
+
+	// Uploading the same log again doesn't store it twice
+	assert.Equal(t, log.ID, upload(t, ts, append(readFixture(t, "normal.bfl"), readFixture(t, "inav_synthetic.bfl")...)).ID)
+	other := upload(t, ts, readFixture(t, "normal.bfl"))
+
+	logs := []storedLog{}
+	getJSON(t, ts.URL+"/logs", http.StatusOK, &logs)
+	assert.Len(t, logs, 2)
+
+	ids := []string{logs[0].ID, logs[1].ID}
+	assert.ElementsMatch(t, []string{log.ID, other.ID}, ids)
+
+	sessions := []session{}
+	getJSON(t, ts.URL+"/logs/"+log.ID+"/sessions", http.StatusOK, &sessions)
+	assert.Equal(t, log.Sessions, sessions)
+}
+
+func TestUploadMultipart(t *testing.T) {
+	ts, dir := newTestServer(t)
+	defer os.RemoveAll(dir)
+	defer ts.Close()
+
+	body := bytes.Buffer{}
+	form := multipart.NewWriter(&body)
+	assert.NoError(t, form.WriteField("comment", "maiden flight"))
+	part, err := form.CreateFormFile("file", "LOG00001.BFL")
+	assert.NoError(t, err)
+	_, err = part.Write(readFixture(t, "normal.bfl"))
+	assert.NoError(t, err)
+	assert.NoError(t, form.Close())
+
+	resp, err := http.Post(ts.URL+"/logs", form.FormDataContentType(), &body)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	log := storedLog{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&log))
+	assert.Len(t, log.Sessions, 1)
+}
+
+func TestUploadMultipartTooLarge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blackbox-server")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	server, err := NewServer(dir)
+	assert.NoError(t, err)
+	content := readFixture(t, "normal.bfl")
+	server.maxUploadSize = int64(len(content))
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	body := bytes.Buffer{}
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "LOG00001.BFL")
+	assert.NoError(t, err)
+	_, err = part.Write(content)
+	assert.NoError(t, err)
+	assert.NoError(t, form.Close())
+
+	resp, err := http.Post(ts.URL+"/logs", form.FormDataContentType(), &body)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	logs := []storedLog{}
+	getJSON(t, ts.URL+"/logs", http.StatusOK, &logs)
+	assert.Empty(t, logs)
+}
+
+func TestUploadInvalidLog(t *testing.T) {
+	ts, dir := newTestServer(t)
+	defer os.RemoveAll(dir)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/logs", "application/octet-stream", strings.NewReader("not a log"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 0)
+}
+
+func TestNotFound(t *testing.T) {
+	ts, dir := newTestServer(t)
+	defer os.RemoveAll(dir)
+	defer ts.Close()
+
+	log := upload(t, ts, readFixture(t, "normal.bfl"))
+	for _, path := range []string{"/other", "/logs/0123456789abcdef", "/logs/../../etc/passwd", "/logs/" + log.ID + "/sessions/2/headers", "/logs/" + log.ID + "/sessions/1/unknown"} {
+		resp, err := http.Get(ts.URL + path)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+}
+
+func TestSessionHeadersAndStats(t *testing.T) {
+	ts, dir := newTestServer(t)
+	defer os.RemoveAll(dir)
+	defer ts.Close()
+
+	log := upload(t, ts, append(readFixture(t, "normal.bfl"), readFixture(t, "inav_synthetic.bfl")...))
+
+	headers := map[string]interface{}{}
+	getJSON(t, ts.URL+"/logs/"+log.ID+"/sessions/2/headers", http.StatusOK, &headers)
+	assert.Equal(t, "INAV", headers["Firmware"].(map[string]interface{})["Product"])
+
+	stats := map[string]interface{}{}
+	getJSON(t, ts.URL+"/logs/"+log.ID+"/sessions/1/stats", http.StatusOK, &stats)
+	assert.Equal(t, float64(10), stats["TotalFrames"])
+	assert.Equal(t, float64(4), stats["Frame"].(map[string]interface{})["P"].(map[string]interface{})["ValidCount"])
+}
+
+func TestSessionFrames(t *testing.T) {
+	ts, dir := newTestServer(t)
+	defer os.RemoveAll(dir)
+	defer ts.Close()
+
+	log := upload(t, ts, readFixture(t, "normal.bfl"))
+	framesURL := ts.URL + "/logs/" + log.ID + "/sessions/1/frames"
+
+	result := frameQueryResult{}
+	getJSON(t, framesURL+"?fields=loopIteration,time,motor[0]", http.StatusOK, &result)
+	assert.Equal(t, [][]int64{
+		{52992, 55158008, 521},
+		{52993, 55158507, 516},
+		{52994, 55159007, 525},
+		{52995, 55159511, 544},
+		{52996, 55160009, 576},
+	}, result.Rows)
+
+	result = frameQueryResult{}
+	getJSON(t, framesURL+"?fields=loopIteration&from=0.0009&to=0.0016", http.StatusOK, &result)
+	assert.Equal(t, [][]int64{{52994}, {52995}}, result.Rows)
+
+	result = frameQueryResult{}
+	getJSON(t, framesURL+"?fields=loopIteration&from=900us&to=1.6ms", http.StatusOK, &result)
+	assert.Equal(t, [][]int64{{52994}, {52995}}, result.Rows)
+
+	result = frameQueryResult{}
+	getJSON(t, framesURL+"?fields=loopIteration&from=00:00.0009&to=00:00.0016", http.StatusOK, &result)
+	assert.Equal(t, [][]int64{{52994}, {52995}}, result.Rows)
+
+	result = frameQueryResult{}
+	getJSON(t, framesURL+"?fields=loopIteration&decimate=2", http.StatusOK, &result)
+	assert.Equal(t, [][]int64{{52992}, {52994}, {52996}}, result.Rows)
+
+	result = frameQueryResult{}
+	getJSON(t, framesURL, http.StatusOK, &result)
+	assert.Len(t, result.Fields, 38)
+	assert.Len(t, result.Rows, 5)
+
+	assert.Equal(t, "loopIteration,motor[3]\n52992,665\n52996,612\n", get(t, framesURL+"?fields=loopIteration,motor[3]&decimate=4&format=csv", http.StatusOK))
+
+	getJSON(t, framesURL+"?fields=unknown", http.StatusBadRequest, &map[string]string{})
+	getJSON(t, framesURL+"?from=2&to=1", http.StatusBadRequest, &map[string]string{})
+	getJSON(t, framesURL+"?from=-1s", http.StatusBadRequest, &map[string]string{})
+	getJSON(t, framesURL+"?from=-1", http.StatusBadRequest, &map[string]string{})
+	getJSON(t, framesURL+"?from=01:60", http.StatusBadRequest, &map[string]string{})
+	getJSON(t, framesURL+"?decimate=0", http.StatusBadRequest, &map[string]string{})
+}
+
+func TestSessionExport(t *testing.T) {
+	ts, dir := newTestServer(t)
+	defer os.RemoveAll(dir)
+	defer ts.Close()
+
+	log := upload(t, ts, readFixture(t, "normal.bfl"))
+
+	resp, err := http.Get(ts.URL + "/logs/" + log.ID + "/sessions/1/export")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, fmt.Sprintf("attachment; filename=\"%s.01.csv\"", log.ID), resp.Header.Get("Content-Disposition"))
+
+	content, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	assert.Len(t, lines, 6)
+	assert.True(t, strings.HasPrefix(lines[0], "loopIteration, time (us), "))
+	assert.True(t, strings.HasPrefix(lines[5], "52996, 55160009, "))
+
+	ndjson := get(t, ts.URL+"/logs/"+log.ID+"/sessions/1/export?format=ndjson", http.StatusOK)
+	assert.Equal(t, 5, strings.Count(ndjson, "\n"))
+
+	exported := struct {
+		Frames []map[string]interface{}
+		Stats  map[string]interface{}
+	}{}
+	assert.NoError(t, json.Unmarshal([]byte(get(t, ts.URL+"/logs/"+log.ID+"/sessions/1/export?format=json", http.StatusOK)), &exported))
+	assert.Len(t, exported.Frames, 5)
+	assert.Equal(t, float64(10), exported.Stats["totalFrames"])
+
+	parquet := get(t, ts.URL+"/logs/"+log.ID+"/sessions/1/export?format=parquet", http.StatusOK)
+	assert.True(t, strings.HasPrefix(parquet, "PAR1"))
+	assert.True(t, strings.HasSuffix(parquet, "PAR1"))
+
+	get(t, ts.URL+"/logs/"+log.ID+"/sessions/1/export?format=xlsx", http.StatusBadRequest)
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, string) {
+	dir, err := ioutil.TempDir("", "blackbox-server")
+	assert.NoError(t, err)
+
+	server, err := NewServer(dir)
+	assert.NoError(t, err)
+	return httptest.NewServer(server), dir
+}
+
+func upload(t *testing.T, ts *httptest.Server, content []byte) storedLog {
+	resp, err := http.Post(ts.URL+"/logs", "application/octet-stream", bytes.NewReader(content))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	log := storedLog{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&log))
+	return log
+}
+
+func get(t *testing.T, url string, expectedStatus int) string {
+	resp, err := http.Get(url)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, expectedStatus, resp.StatusCode, url)
+
+	content, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func getJSON(t *testing.T, url string, expectedStatus int, value interface{}) {
+	assert.NoError(t, json.Unmarshal([]byte(get(t, url, expectedStatus)), value))
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	content, err := ioutil.ReadFile("../../fixtures/" + name)
+	assert.NoError(t, err)
+	return content
+}
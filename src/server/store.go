@@ -0,0 +1,186 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maxlaverse/blackbox-library/src/blackbox"
+	"github.com/pkg/errors"
+)
+
+const (
+	// logIDLength is the number of hexadecimal characters of the identifier of a log
+	logIDLength = 16
+
+	// logExtension is the extension of the stored logs
+	logExtension = ".bfl"
+)
+
+var logIDPattern = regexp.MustCompile("^[0-9a-f]{16}$")
+
+// errLogNotFound is returned for identifiers of logs which aren't stored
+var errLogNotFound = errors.New("Log not found")
+
+// storedLog describes a log uploaded to the server
+type storedLog struct {
+	ID       string    `json:"id"`
+	Size     int64     `json:"size"`
+	Uploaded time.Time `json:"uploaded"`
+	Sessions []session `json:"sessions"`
+}
+
+// session is the location of a session within a stored log
+type session struct {
+	Index int   `json:"index"`
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Size  int64 `json:"size"`
+}
+
+// store keeps the uploaded logs in a directory. Logs are named after a hash of their
+// content, so that uploading the same log twice doesn't store it twice.
+type store struct {
+	dir string
+}
+
+func newStore(dir string) (*store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &store{dir: dir}, nil
+}
+
+// add stores a log and returns its description. Files without any session are refused.
+func (s *store) add(r io.Reader) (storedLog, error) {
+	tempFile, err := ioutil.TempFile(s.dir, "upload-*")
+	if err != nil {
+		return storedLog{}, errors.WithStack(err)
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tempFile, hash), r); err != nil {
+		return storedLog{}, errors.Wrap(err, "could not receive the log")
+	}
+
+	sessions, err := findSessions(tempFile)
+	if err != nil {
+		return storedLog{}, err
+	}
+	if len(sessions) == 0 {
+		return storedLog{}, errors.New("The file doesn't contain any blackbox log")
+	}
+
+	id := hex.EncodeToString(hash.Sum(nil))[:logIDLength]
+	if err := tempFile.Close(); err != nil {
+		return storedLog{}, errors.WithStack(err)
+	}
+	if err := os.Rename(tempFile.Name(), s.path(id)); err != nil {
+		return storedLog{}, errors.WithStack(err)
+	}
+	return s.get(id)
+}
+
+// list returns the stored logs, the most recent first
+func (s *store) list() ([]storedLog, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*"+logExtension))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	logs := []storedLog{}
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), logExtension)
+		if !logIDPattern.MatchString(id) {
+			continue
+		}
+
+		log, err := s.get(id)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+
+	sort.SliceStable(logs, func(i, j int) bool {
+		return logs[i].Uploaded.After(logs[j].Uploaded)
+	})
+	return logs, nil
+}
+
+// get returns the description of a stored log
+func (s *store) get(id string) (storedLog, error) {
+	logFile, err := s.open(id)
+	if err != nil {
+		return storedLog{}, err
+	}
+	defer logFile.Close()
+
+	info, err := logFile.Stat()
+	if err != nil {
+		return storedLog{}, errors.WithStack(err)
+	}
+	sessions, err := findSessions(logFile)
+	if err != nil {
+		return storedLog{}, err
+	}
+	return storedLog{ID: id, Size: info.Size(), Uploaded: info.ModTime().UTC(), Sessions: sessions}, nil
+}
+
+// open opens a stored log
+func (s *store) open(id string) (*os.File, error) {
+	if !logIDPattern.MatchString(id) {
+		return nil, errLogNotFound
+	}
+
+	logFile, err := os.Open(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, errLogNotFound
+	}
+	return logFile, errors.WithStack(err)
+}
+
+// openSession returns a reader on a session of a stored log, numbered from 1
+func (s *store) openSession(logFile *os.File, index int) (*io.SectionReader, error) {
+	sessions, err := findSessions(logFile)
+	if err != nil {
+		return nil, err
+	}
+	if index < 1 || index > len(sessions) {
+		return nil, errSessionNotFound
+	}
+
+	session := sessions[index-1]
+	return io.NewSectionReader(logFile, session.Start, session.Size), nil
+}
+
+func (s *store) path(id string) string {
+	return filepath.Join(s.dir, id+logExtension)
+}
+
+func findSessions(logFile *os.File) ([]session, error) {
+	info, err := logFile.Stat()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	logSessions, err := blackbox.FindSessions(logFile, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]session, len(logSessions))
+	for i, s := range logSessions {
+		sessions[i] = session{Index: s.Index, Start: s.Start, End: s.End, Size: s.Size()}
+	}
+	return sessions, nil
+}